@@ -0,0 +1,32 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+// AuditLogFilter 审计日志筛选条件，均可为空
+type AuditLogFilter struct {
+	KBId       string                 `query:"kb_id" json:"kb_id"`
+	ActorType  domain.AuditActorType  `query:"actor_type" json:"actor_type" validate:"omitempty,oneof=user api_token system"`
+	ActorId    string                 `query:"actor_id" json:"actor_id"`
+	Action     domain.AuditAction     `query:"action" json:"action"`
+	TargetType domain.AuditTargetType `query:"target_type" json:"target_type"`
+	TargetId   string                 `query:"target_id" json:"target_id"`
+	RemoteIP   string                 `query:"remote_ip" json:"remote_ip"`
+	StartTime  *time.Time             `query:"start_time" json:"start_time"`
+	EndTime    *time.Time             `query:"end_time" json:"end_time"`
+}
+
+type AuditLogListReq struct {
+	AuditLogFilter
+	domain.Pager
+}
+
+type AuditLogListResp = domain.PaginatedResult[[]*domain.AuditLogListItem]
+
+type AuditLogExportReq struct {
+	AuditLogFilter
+	Format string `query:"format" json:"format" validate:"required,oneof=csv jsonl"`
+}
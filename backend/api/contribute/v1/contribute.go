@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+type ContributeListReq struct {
+	KbId   string                  `query:"kb_id" json:"kb_id" validate:"required"`
+	Status consts.ContributeStatus `query:"status" json:"status" validate:"omitempty,oneof=pending approved rejected"`
+	domain.Pager
+}
+
+type ContributeListItem struct {
+	*domain.Contribute
+	AuthName string `json:"auth_name"`
+	NodeName string `json:"node_name"` // 修改的文档或新页面所在目录的当前名称
+}
+
+type ContributeListResp = domain.PaginatedResult[[]*ContributeListItem]
+
+type ContributeDetailReq struct {
+	KbId string `query:"kb_id" json:"kb_id" validate:"required"`
+	ID   string `query:"id" json:"id" validate:"required"`
+}
+
+// ContributeDetailResp 与当前文档的差异，新页面与空文档对比
+type ContributeDetailResp struct {
+	ContributeListItem
+	NodeChanged bool             `json:"node_changed"` // 提交后文档又被修改过
+	NameChanged bool             `json:"name_changed"`
+	Stats       utils.DiffStats  `json:"stats"`
+	Lines       []utils.DiffLine `json:"lines"`
+}
+
+type ContributeApproveReq struct {
+	KbId    string `json:"kb_id" validate:"required"`
+	ID      string `json:"id" validate:"required"`
+	Publish bool   `json:"publish"` // 合并后立即发布
+}
+
+type ContributeApproveResp struct {
+	NodeID    string `json:"node_id"`
+	ReleaseID string `json:"release_id,omitempty"` // 发布时生成的知识库版本
+}
+
+type ContributeRejectReq struct {
+	KbId   string `json:"kb_id" validate:"required"`
+	ID     string `json:"id" validate:"required"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
@@ -0,0 +1,53 @@
+package v1
+
+import "time"
+
+type KnowledgeGapReason string
+
+const (
+	KnowledgeGapReasonNoRetrieval KnowledgeGapReason = "no_retrieval" // 未检索到任何文档
+	KnowledgeGapReasonDisliked    KnowledgeGapReason = "disliked"     // 用户点踩
+	KnowledgeGapReasonUnanswered  KnowledgeGapReason = "unanswered"   // 模型表示无法回答
+)
+
+type KnowledgeGapReportReq struct {
+	KbId      string  `query:"kb_id" json:"kb_id" validate:"required"`
+	Days      int     `query:"days" json:"days" validate:"omitempty,min=1,max=365"`        // 统计最近多少天的对话，默认 30
+	Threshold float64 `query:"threshold" json:"threshold" validate:"omitempty,gt=0,lte=1"` // 问题聚类的相似度阈值，默认 0.85
+}
+
+type KnowledgeGapQuestion struct {
+	MessageId      string               `json:"message_id"`
+	ConversationId string               `json:"conversation_id"`
+	Question       string               `json:"question"`
+	Answer         string               `json:"answer"`
+	Reasons        []KnowledgeGapReason `json:"reasons"`
+	CreatedAt      time.Time            `json:"created_at"`
+}
+
+type KnowledgeGapCluster struct {
+	Question  string                     `json:"question"` // 最能代表该类的问题
+	Count     int                        `json:"count"`
+	Reasons   map[KnowledgeGapReason]int `json:"reasons"` // 各原因出现的次数
+	LatestAt  time.Time                  `json:"latest_at"`
+	Questions []*KnowledgeGapQuestion    `json:"questions"`
+}
+
+type KnowledgeGapReportResp struct {
+	Total          int                    `json:"total"`
+	Clusters       []*KnowledgeGapCluster `json:"clusters"`
+	EmbeddingError string                 `json:"embedding_error,omitempty"` // 向量化失败时按问题原文分组
+}
+
+type KnowledgeGapDraftReq struct {
+	KbId      string   `json:"kb_id" validate:"required"`
+	ParentId  string   `json:"parent_id"`
+	Name      string   `json:"name"` // 为空时使用模型生成的标题
+	Questions []string `json:"questions" validate:"required,min=1,max=50"`
+}
+
+type KnowledgeGapDraftResp struct {
+	NodeId  string `json:"node_id"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+type RetrievalDebugChunkStatus string
+
+const (
+	RetrievalDebugChunkStatusUsed              RetrievalDebugChunkStatus = "used"               // 进入最终提示词
+	RetrievalDebugChunkStatusDroppedTopK       RetrievalDebugChunkStatus = "dropped_top_k"      // 融合排序后超出 top_k
+	RetrievalDebugChunkStatusDroppedPermission RetrievalDebugChunkStatus = "dropped_permission" // 用户无权访问所在文档
+	RetrievalDebugChunkStatusDroppedBudget     RetrievalDebugChunkStatus = "dropped_budget"     // 超出上下文中文档的 token 预算
+)
+
+type RetrievalDebugReq struct {
+	KbId       string         `json:"kb_id" validate:"required"`
+	Question   string         `json:"question"`     // 与 message_id 二选一
+	MessageId  string         `json:"message_id"`   // 复现已有对话消息的检索过程
+	AuthUserId uint           `json:"auth_user_id"` // 以该用户的权限检索，为空时使用对话用户或 app_type 的匿名用户
+	AppType    domain.AppType `json:"app_type"`     // 为空时使用网页挂件
+}
+
+type RetrievalDebugChunk struct {
+	NodeID        string                    `json:"node_id"`
+	NodeName      string                    `json:"node_name"`
+	NodePathNames []string                  `json:"node_path_names"`
+	ChunkID       string                    `json:"chunk_id"`
+	DocID         string                    `json:"doc_id"`
+	Seq           uint                      `json:"seq"`
+	Content       string                    `json:"content"`
+	Source        string                    `json:"source"` // vector 或 keyword
+	Rank          int                       `json:"rank"`   // 文档在该路检索结果中的排名，从 1 开始
+	Score         float64                   `json:"score"`
+	FusedScore    float64                   `json:"fused_score"` // 文档的 RRF 融合得分，仅混合检索时有值
+	Status        RetrievalDebugChunkStatus `json:"status"`
+}
+
+type RetrievalDebugPromptMessage struct {
+	Role    schema.RoleType `json:"role"`
+	Content string          `json:"content"`
+}
+
+type RetrievalDebugResp struct {
+	Question       string                         `json:"question"`
+	RewrittenQuery string                         `json:"rewritten_query"`
+	ConversationId string                         `json:"conversation_id,omitempty"`
+	MessageId      string                         `json:"message_id,omitempty"`
+	AuthUserId     uint                           `json:"auth_user_id"`
+	GroupIds       []int                          `json:"group_ids"`
+	Settings       domain.RetrievalSettings       `json:"settings"`
+	KeywordError   string                         `json:"keyword_error,omitempty"` // 关键词检索失败时的错误，此时仅使用向量检索结果
+	Chunks         []*RetrievalDebugChunk         `json:"chunks"`
+	Prompt         []*RetrievalDebugPromptMessage `json:"prompt"` // 发送给模型的完整消息
+	Budget         *domain.TokenBudget            `json:"budget"` // 提示词的 token 预算分配
+}
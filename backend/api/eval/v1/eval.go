@@ -0,0 +1,131 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+type EvalSetListReq struct {
+	KbId string `query:"kb_id" json:"kb_id" validate:"required"`
+}
+
+type EvalSetListItem struct {
+	ID            string    `json:"id"`
+	KBID          string    `json:"kb_id"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	QuestionCount int64     `json:"question_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type EvalSetCreateReq struct {
+	KbId        string `json:"kb_id" validate:"required"`
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+}
+
+type EvalSetUpdateReq struct {
+	KbId        string  `json:"kb_id" validate:"required"`
+	ID          string  `json:"id" validate:"required"`
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+type EvalSetDeleteReq struct {
+	KbId string `query:"kb_id" json:"kb_id" validate:"required"`
+	ID   string `query:"id" json:"id" validate:"required"`
+}
+
+type EvalQuestionListReq struct {
+	KbId  string `query:"kb_id" json:"kb_id" validate:"required"`
+	SetId string `query:"set_id" json:"set_id" validate:"required"`
+}
+
+type EvalQuestionItem struct {
+	Question        string   `json:"question" validate:"required"`
+	ExpectedNodeIds []string `json:"expected_node_ids"` // 期望召回的文档，用于计算 recall@k 和 MRR
+	ReferenceAnswer string   `json:"reference_answer"`  // 参考答案，用于评估回答
+}
+
+type EvalQuestionCreateReq struct {
+	KbId      string              `json:"kb_id" validate:"required"`
+	SetId     string              `json:"set_id" validate:"required"`
+	Questions []*EvalQuestionItem `json:"questions" validate:"required,min=1,dive"`
+}
+
+type EvalQuestionUpdateReq struct {
+	KbId string `json:"kb_id" validate:"required"`
+	ID   string `json:"id" validate:"required"`
+	EvalQuestionItem
+}
+
+type EvalQuestionDeleteReq struct {
+	KbId string   `query:"kb_id" json:"kb_id" validate:"required"`
+	IDs  []string `query:"ids" json:"ids" validate:"required,min=1"`
+}
+
+type EvalRunCreateReq struct {
+	KbId  string `json:"kb_id" validate:"required"`
+	SetId string `json:"set_id" validate:"required"`
+	Name  string `json:"name"`
+	Judge bool   `json:"judge"` // 是否使用大模型评估回答的忠实度
+}
+
+type EvalRunCreateResp struct {
+	ID string `json:"id"`
+}
+
+type EvalRunListReq struct {
+	KbId  string `query:"kb_id" json:"kb_id" validate:"required"`
+	SetId string `query:"set_id" json:"set_id"`
+	domain.Pager
+}
+
+type EvalRunDetailReq struct {
+	KbId string `query:"kb_id" json:"kb_id" validate:"required"`
+	ID   string `query:"id" json:"id" validate:"required"`
+}
+
+type EvalRunDetailResp struct {
+	*domain.EvalRun
+	Results []*domain.EvalRunResult `json:"results"`
+}
+
+type EvalRunCancelReq struct {
+	KbId string `json:"kb_id" validate:"required"`
+	ID   string `json:"id" validate:"required"`
+}
+
+type EvalRunDeleteReq struct {
+	KbId string `query:"kb_id" json:"kb_id" validate:"required"`
+	ID   string `query:"id" json:"id" validate:"required"`
+}
+
+type EvalRunCompareReq struct {
+	KbId     string `query:"kb_id" json:"kb_id" validate:"required"`
+	BaseId   string `query:"base_id" json:"base_id" validate:"required"`
+	TargetId string `query:"target_id" json:"target_id" validate:"required"`
+}
+
+type EvalMetricsDelta struct {
+	RecallAtK    float64 `json:"recall_at_k"`
+	MRR          float64 `json:"mrr"`
+	Faithfulness float64 `json:"faithfulness"`
+	AvgLatencyMs int64   `json:"avg_latency_ms"`
+}
+
+type EvalRunCompareItem struct {
+	QuestionID string                `json:"question_id"`
+	Question   string                `json:"question"`
+	Base       *domain.EvalRunResult `json:"base"`   // 基准运行中不存在该问题时为空
+	Target     *domain.EvalRunResult `json:"target"` // 对比运行中不存在该问题时为空
+}
+
+type EvalRunCompareResp struct {
+	Base   *domain.EvalRun       `json:"base"`
+	Target *domain.EvalRun       `json:"target"`
+	Delta  EvalMetricsDelta      `json:"delta"` // target - base
+	Items  []*EvalRunCompareItem `json:"items"`
+}
@@ -0,0 +1,47 @@
+package v1
+
+import (
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+type FAQListReq struct {
+	KbId     string `query:"kb_id" json:"kb_id" validate:"required"`
+	Question string `query:"question" json:"question"` // 按问题或回答模糊搜索
+}
+
+type FAQCreateReq struct {
+	KbId      string   `json:"kb_id" validate:"required"`
+	Questions []string `json:"questions" validate:"required,min=1,max=50,dive,required"`
+	Answer    string   `json:"answer" validate:"required"`
+	NodeIds   []string `json:"node_ids"`
+	Threshold float64  `json:"threshold" validate:"gte=0,lte=1"`
+	Enabled   *bool    `json:"enabled"` // 默认启用
+}
+
+type FAQUpdateReq struct {
+	KbId      string   `json:"kb_id" validate:"required"`
+	ID        string   `json:"id" validate:"required"`
+	Questions []string `json:"questions" validate:"omitempty,min=1,max=50,dive,required"` // 修改问题会重新向量化
+	Answer    *string  `json:"answer"`
+	NodeIds   []string `json:"node_ids"`
+	Threshold *float64 `json:"threshold" validate:"omitempty,gte=0,lte=1"`
+	Enabled   *bool    `json:"enabled"`
+}
+
+type FAQDeleteReq struct {
+	KbId string `query:"kb_id" json:"kb_id" validate:"required"`
+	ID   string `query:"id" json:"id" validate:"required"`
+}
+
+type FAQMatchReq struct {
+	KbId     string `json:"kb_id" validate:"required"`
+	Question string `json:"question" validate:"required"`
+}
+
+type FAQMatchResp struct {
+	Matched         bool        `json:"matched"`
+	FAQ             *domain.FAQ `json:"faq,omitempty"`
+	MatchedQuestion string      `json:"matched_question,omitempty"`
+	Score           float64     `json:"score"`
+	EmbeddingError  string      `json:"embedding_error,omitempty"` // 向量化失败时仅按问题原文匹配
+}
@@ -0,0 +1,39 @@
+package v1
+
+import (
+	"encoding/json"
+)
+
+type AgentToolListReq struct {
+	KBId string `query:"kb_id" json:"kb_id" validate:"required"`
+}
+
+type AgentToolCreateReq struct {
+	KBId           string            `json:"kb_id" validate:"required"`
+	Name           string            `json:"name" validate:"required"` // 字母、数字、下划线或短横线，不能与内置工具重名
+	Description    string            `json:"description" validate:"required"`
+	Parameters     json.RawMessage   `json:"parameters"` // 参数的 JSON Schema，为空时无参数
+	Method         string            `json:"method" validate:"omitempty,oneof=GET POST"`
+	URL            string            `json:"url" validate:"required,url"`
+	Headers        map[string]string `json:"headers"`
+	TimeoutSeconds int               `json:"timeout_seconds" validate:"gte=0,lte=60"`
+	Enabled        *bool             `json:"enabled"` // 默认启用
+}
+
+type AgentToolUpdateReq struct {
+	KBId           string            `json:"kb_id" validate:"required"`
+	ID             string            `json:"id" validate:"required"`
+	Name           *string           `json:"name"`
+	Description    *string           `json:"description"`
+	Parameters     json.RawMessage   `json:"parameters"`
+	Method         *string           `json:"method" validate:"omitempty,oneof=GET POST"`
+	URL            *string           `json:"url" validate:"omitempty,url"`
+	Headers        map[string]string `json:"headers"` // 值为 ****** 的请求头保持不变
+	TimeoutSeconds *int              `json:"timeout_seconds" validate:"omitempty,gte=0,lte=60"`
+	Enabled        *bool             `json:"enabled"`
+}
+
+type AgentToolDeleteReq struct {
+	KBId string `query:"kb_id" json:"kb_id" validate:"required"`
+	ID   string `query:"id" json:"id" validate:"required"`
+}
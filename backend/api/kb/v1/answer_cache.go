@@ -0,0 +1,22 @@
+package v1
+
+import (
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+type AnswerCacheListReq struct {
+	KBId     string `query:"kb_id" json:"kb_id" validate:"required"`
+	Question string `query:"question" json:"question"` // 按问题模糊搜索
+	domain.Pager
+}
+
+type AnswerCacheListResp = domain.PaginatedResult[[]*domain.AnswerCache]
+
+type AnswerCacheDeleteReq struct {
+	KBId string   `query:"kb_id" json:"kb_id" validate:"required"`
+	IDs  []string `query:"ids" json:"ids"` // 为空时清空该知识库的全部缓存
+}
+
+type AnswerCacheDeleteResp struct {
+	Deleted int64 `json:"deleted"`
+}
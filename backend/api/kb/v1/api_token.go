@@ -0,0 +1,46 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+type APITokenListReq struct {
+	KBId string `query:"kb_id" json:"kb_id" validate:"required"`
+}
+
+type APITokenListResp []*domain.APIToken
+
+type APITokenCreateReq struct {
+	KBId       string                  `json:"kb_id" validate:"required"`
+	Name       string                  `json:"name" validate:"required,max=100"`
+	Permission consts.UserKBPermission `json:"permission" validate:"required,oneof=full_control doc_manage data_operate"`
+	Scopes     []consts.APITokenScope  `json:"scopes" validate:"required,min=1,dive,oneof=node:read node:write release:read release:create stats:read"`
+	ExpiresAt  *time.Time              `json:"expires_at"` // 为空表示不过期
+}
+
+// APITokenCreateResp token 仅在创建和轮换时返回一次
+type APITokenCreateResp struct {
+	*domain.APIToken
+	Token string `json:"token"`
+}
+
+type APITokenUpdateReq struct {
+	KBId      string                 `json:"kb_id" validate:"required"`
+	TokenId   string                 `json:"token_id" validate:"required"`
+	Name      string                 `json:"name" validate:"required,max=100"`
+	Scopes    []consts.APITokenScope `json:"scopes" validate:"required,min=1,dive,oneof=node:read node:write release:read release:create stats:read legacy"` // legacy 仅可保留
+	ExpiresAt *time.Time             `json:"expires_at"`
+}
+
+type APITokenRotateReq struct {
+	KBId    string `json:"kb_id" validate:"required"`
+	TokenId string `json:"token_id" validate:"required"`
+}
+
+type APITokenDeleteReq struct {
+	KBId    string `query:"kb_id" json:"kb_id" validate:"required"`
+	TokenId string `query:"token_id" json:"token_id" validate:"required"`
+}
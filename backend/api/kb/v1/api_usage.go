@@ -0,0 +1,12 @@
+package v1
+
+import (
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+type APIUsageListReq struct {
+	KBId string `query:"kb_id" json:"kb_id" validate:"required"`
+	Days int    `query:"days" json:"days" validate:"omitempty,gte=1,lte=90"` // 默认 30 天
+}
+
+type APIUsageListResp []*domain.APIUsage
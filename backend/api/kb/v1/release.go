@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+type KBReleaseDiffReq struct {
+	KBId         string `query:"kb_id" json:"kb_id" validate:"required"`
+	OldReleaseId string `query:"old_release_id" json:"old_release_id" validate:"required"`
+	NewReleaseId string `query:"new_release_id" json:"new_release_id"` // 为空时与最新版本对比
+	WithLines    bool   `query:"with_lines" json:"with_lines"`         // 是否返回修改文档的逐行差异
+}
+
+type KBReleaseDiffSide struct {
+	ID        string    `json:"id"`
+	Tag       string    `json:"tag"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type KBReleaseDiffNode struct {
+	NodeID           string           `json:"node_id"`
+	Name             string           `json:"name"`
+	Type             domain.NodeType  `json:"type"`
+	OldNodeReleaseID string           `json:"old_node_release_id,omitempty"`
+	NewNodeReleaseID string           `json:"new_node_release_id,omitempty"`
+	OldName          string           `json:"old_name,omitempty"` // 仅修改的文档
+	NameChanged      bool             `json:"name_changed"`
+	Stats            utils.DiffStats  `json:"stats"`
+	Lines            []utils.DiffLine `json:"lines,omitempty"`
+}
+
+type KBReleaseDiffResp struct {
+	Old      KBReleaseDiffSide    `json:"old"`
+	New      KBReleaseDiffSide    `json:"new"`
+	Added    []*KBReleaseDiffNode `json:"added"`
+	Removed  []*KBReleaseDiffNode `json:"removed"`
+	Modified []*KBReleaseDiffNode `json:"modified"`
+}
+
+type KBReleaseRollbackReq struct {
+	KBId    string `json:"kb_id" validate:"required"`
+	ID      string `json:"id" validate:"required"` // 回滚到的知识库版本
+	Message string `json:"message"`
+}
+
+type KBReleaseRollbackResp struct {
+	ID string `json:"id"` // 回滚生成的新版本
+}
@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+type WebhookListReq struct {
+	KBId string `query:"kb_id" json:"kb_id" validate:"required"`
+}
+
+type WebhookListResp []*domain.Webhook
+
+type WebhookCreateReq struct {
+	KBId    string                    `json:"kb_id" validate:"required"`
+	Name    string                    `json:"name" validate:"required,max=100"`
+	URL     string                    `json:"url" validate:"required,url,startswith=http"`
+	Events  []domain.WebhookEventType `json:"events" validate:"required,min=1,dive,required"`
+	Enabled *bool                     `json:"enabled"` // 默认启用
+}
+
+type WebhookUpdateReq struct {
+	KBId         string                    `json:"kb_id" validate:"required"`
+	WebhookId    string                    `json:"webhook_id" validate:"required"`
+	Name         *string                   `json:"name" validate:"omitempty,max=100"`
+	URL          *string                   `json:"url" validate:"omitempty,url,startswith=http"`
+	Events       []domain.WebhookEventType `json:"events" validate:"omitempty,dive,required"`
+	Enabled      *bool                     `json:"enabled"`
+	RotateSecret bool                      `json:"rotate_secret"` // 重新生成签名密钥
+}
+
+type WebhookDeleteReq struct {
+	KBId      string `query:"kb_id" json:"kb_id" validate:"required"`
+	WebhookId string `query:"webhook_id" json:"webhook_id" validate:"required"`
+}
+
+type WebhookTestReq struct {
+	KBId      string `json:"kb_id" validate:"required"`
+	WebhookId string `json:"webhook_id" validate:"required"`
+}
+
+type WebhookDeliveryListReq struct {
+	KBId      string                       `query:"kb_id" json:"kb_id" validate:"required"`
+	WebhookId string                       `query:"webhook_id" json:"webhook_id"`
+	Status    domain.WebhookDeliveryStatus `query:"status" json:"status" validate:"omitempty,oneof=pending success failed"`
+	domain.Pager
+}
+
+type WebhookDeliveryListResp = domain.PaginatedResult[[]*domain.WebhookDelivery]
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/utils"
 )
 
 type GetNodeDetailReq struct {
@@ -65,3 +66,103 @@ type NodeRestudyReq struct {
 
 type NodeRestudyResp struct {
 }
+
+type NodeReleaseListReq struct {
+	KbId   string `query:"kb_id" json:"kb_id" validate:"required"`
+	NodeId string `query:"node_id" json:"node_id" validate:"required"`
+	domain.Pager
+}
+
+type NodeReleaseListItem struct {
+	ID               string          `json:"id"`
+	NodeID           string          `json:"node_id"`
+	Name             string          `json:"name"`
+	Meta             domain.NodeMeta `json:"meta" gorm:"type:jsonb"`
+	PublisherId      string          `json:"publisher_id"`
+	PublisherAccount string          `json:"publisher_account"`
+	EditorId         string          `json:"editor_id"`
+	EditorAccount    string          `json:"editor_account"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+type NodeReleaseListResp = domain.PaginatedResult[[]*NodeReleaseListItem]
+
+type NodeReleaseDetailReq struct {
+	KbId string `query:"kb_id" json:"kb_id" validate:"required"`
+	ID   string `query:"id" json:"id" validate:"required"`
+}
+
+type NodeReleaseDetailResp struct {
+	ID          string          `json:"id"`
+	NodeID      string          `json:"node_id"`
+	Type        domain.NodeType `json:"type"`
+	Name        string          `json:"name"`
+	Content     string          `json:"content"`
+	Meta        domain.NodeMeta `json:"meta"`
+	PublisherId string          `json:"publisher_id"`
+	EditorId    string          `json:"editor_id"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+type NodeReleaseDiffReq struct {
+	KbId  string `query:"kb_id" json:"kb_id" validate:"required"`
+	OldId string `query:"old_id" json:"old_id" validate:"required"`
+	NewId string `query:"new_id" json:"new_id"` // 为空时与当前草稿对比
+}
+
+type NodeReleaseDiffSide struct {
+	ID        string    `json:"id"` // 当前草稿时为空
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type NodeReleaseDiffResp struct {
+	NodeID      string              `json:"node_id"`
+	Old         NodeReleaseDiffSide `json:"old"`
+	New         NodeReleaseDiffSide `json:"new"`
+	NameChanged bool                `json:"name_changed"`
+	Stats       utils.DiffStats     `json:"stats"`
+	Lines       []utils.DiffLine    `json:"lines"`
+}
+
+type NodeReleaseRestoreReq struct {
+	KbId    string `json:"kb_id" validate:"required"`
+	ID      string `json:"id" validate:"required"` // node release id
+	Publish *bool  `json:"publish"`                // 恢复后立即发布，默认发布；仅恢复为草稿时不会更新向量
+}
+
+type NodeReleaseRestoreResp struct {
+	NodeID    string `json:"node_id"`
+	ReleaseID string `json:"release_id,omitempty"` // 发布时生成的知识库版本
+}
+
+type NodeTrashListReq struct {
+	KbId string `query:"kb_id" json:"kb_id" validate:"required"`
+	domain.Pager
+}
+
+type NodeTrashListItem struct {
+	ID               string          `json:"id"`
+	Name             string          `json:"name"`
+	Type             domain.NodeType `json:"type"`
+	Emoji            string          `json:"emoji"`
+	ParentID         string          `json:"parent_id"`
+	ChildCount       int64           `json:"child_count"` // 随之删除的子节点数量
+	DeletedAt        time.Time       `json:"deleted_at"`
+	DeletedBy        string          `json:"deleted_by"`
+	DeletedByAccount string          `json:"deleted_by_account"`
+}
+
+type NodeTrashListResp = domain.PaginatedResult[[]*NodeTrashListItem]
+
+type NodeTrashRestoreReq struct {
+	KbId string   `json:"kb_id" validate:"required"`
+	IDs  []string `json:"ids" validate:"required,min=1"`
+}
+
+type NodeTrashPurgeReq struct {
+	KbId string   `json:"kb_id" validate:"required"`
+	IDs  []string `json:"ids"`
+	All  bool     `json:"all"` // 清空回收站
+}
@@ -0,0 +1,21 @@
+package v1
+
+import (
+	"github.com/chaitin/panda-wiki/consts"
+)
+
+// ShareContributeReq 读者提交的修改，edit 需要 node_id，add 可以指定所在目录 parent_id
+type ShareContributeReq struct {
+	Type         consts.ContributeType `json:"type" validate:"required,oneof=add edit"`
+	NodeId       string                `json:"node_id" validate:"required_if=Type edit"`
+	ParentId     string                `json:"parent_id"`
+	Name         string                `json:"name" validate:"required,max=200"`
+	Content      string                `json:"content" validate:"required"`
+	ContentType  string                `json:"content_type" validate:"omitempty,oneof=html md"`
+	Reason       string                `json:"reason" validate:"max=500"`
+	CaptchaToken string                `json:"captcha_token" validate:"required"`
+}
+
+type ShareContributeResp struct {
+	ID string `json:"id"`
+}
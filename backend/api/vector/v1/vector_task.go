@@ -0,0 +1,28 @@
+package v1
+
+import (
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+type VectorTaskDeadLetterListReq struct {
+	KBId   string                            `query:"kb_id" json:"kb_id"`
+	Action string                            `query:"action" json:"action" validate:"omitempty,oneof=upsert delete summary update_group_ids"`
+	Status domain.VectorTaskDeadLetterStatus `query:"status" json:"status" validate:"omitempty,oneof=pending replayed"`
+	domain.Pager
+}
+
+type VectorTaskDeadLetterListResp = domain.PaginatedResult[[]*domain.VectorTaskDeadLetter]
+
+type VectorTaskDeadLetterReplayReq struct {
+	IDs []string `json:"ids" validate:"required,min=1,max=100"`
+}
+
+type VectorTaskDeadLetterReplayResp struct {
+	Replayed []string `json:"replayed"` // 已重新投递的死信，已重放过的会被跳过
+}
+
+type VectorTaskMetricsReq struct {
+	Hours int `query:"hours" json:"hours" validate:"omitempty,min=1,max=720"` // 默认 24 小时
+}
+
+type VectorTaskMetricsResp = domain.VectorTaskMetrics
@@ -60,7 +60,7 @@ func createApp() (*App, error) {
 	if err != nil {
 		return nil, err
 	}
-	ragService, err := rag.NewRAGService(configConfig, logger)
+	ragService, err := rag.NewRAGService(configConfig, db, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +73,9 @@ func createApp() (*App, error) {
 	ragRepository := mq2.NewRAGRepository(mqProducer)
 	userRepository := pg2.NewUserRepository(db, logger)
 	kbRepo := cache2.NewKBRepo(cacheCache)
-	knowledgeBaseUsecase, err := usecase.NewKnowledgeBaseUsecase(knowledgeBaseRepository, nodeRepository, ragRepository, userRepository, ragService, kbRepo, logger, configConfig)
+	webhookRepository := mq2.NewWebhookRepository(mqProducer)
+	auditLogRepository := pg2.NewAuditLogRepository(db, logger)
+	knowledgeBaseUsecase, err := usecase.NewKnowledgeBaseUsecase(knowledgeBaseRepository, nodeRepository, ragRepository, userRepository, ragService, kbRepo, logger, configConfig, webhookRepository, auditLogRepository)
 	if err != nil {
 		return nil, err
 	}
@@ -97,8 +99,8 @@ func createApp() (*App, error) {
 	}
 	authRepo := pg2.NewAuthRepo(db, logger, cacheCache)
 	systemSettingRepo := pg2.NewSystemSettingRepo(db, logger)
-	modelUsecase := usecase.NewModelUsecase(modelRepository, nodeRepository, ragRepository, ragService, logger, configConfig, knowledgeBaseRepository, systemSettingRepo)
-	nodeUsecase := usecase.NewNodeUsecase(nodeRepository, appRepository, ragRepository, userRepository, knowledgeBaseRepository, llmUsecase, ragService, logger, minioClient, modelRepository, authRepo, modelUsecase)
+	modelUsecase := usecase.NewModelUsecase(modelRepository, nodeRepository, ragRepository, ragService, logger, configConfig, knowledgeBaseRepository, systemSettingRepo, auditLogRepository)
+	nodeUsecase := usecase.NewNodeUsecase(nodeRepository, appRepository, ragRepository, userRepository, knowledgeBaseRepository, llmUsecase, ragService, logger, minioClient, modelRepository, authRepo, modelUsecase, webhookRepository, auditLogRepository)
 	nodeHandler := v1.NewNodeHandler(baseHandler, echo, nodeUsecase, authMiddleware, logger)
 	geoRepo := cache2.NewGeoCache(cacheCache, db, logger)
 	ipdbIPDB, err := ipdb.NewIPDB(configConfig, logger)
@@ -106,18 +108,24 @@ func createApp() (*App, error) {
 		return nil, err
 	}
 	ipAddressRepo := ipdb2.NewIPAddressRepo(ipdbIPDB, logger)
-	conversationUsecase := usecase.NewConversationUsecase(conversationRepository, nodeRepository, geoRepo, logger, ipAddressRepo, authRepo)
+	conversationUsecase := usecase.NewConversationUsecase(conversationRepository, nodeRepository, geoRepo, logger, ipAddressRepo, authRepo, webhookRepository)
 	blockWordRepo := pg2.NewBlockWordRepo(db, logger)
-	chatUsecase, err := usecase.NewChatUsecase(llmUsecase, knowledgeBaseRepository, conversationUsecase, modelUsecase, appRepository, blockWordRepo, nodeRepository, authRepo, logger)
+	answerCacheRepository := pg2.NewAnswerCacheRepository(db, logger)
+	answerCacheUsecase := usecase.NewAnswerCacheUsecase(answerCacheRepository, knowledgeBaseRepository, modelUsecase, logger)
+	faqRepository := pg2.NewFAQRepository(db, logger)
+	faqUsecase := usecase.NewFAQUsecase(faqRepository, nodeRepository, modelUsecase, logger)
+	agentToolRepository := pg2.NewAgentToolRepository(db, logger)
+	agentUsecase := usecase.NewAgentUsecase(agentToolRepository, llmUsecase, nodeUsecase, logger)
+	chatUsecase, err := usecase.NewChatUsecase(llmUsecase, knowledgeBaseRepository, conversationUsecase, modelUsecase, appRepository, blockWordRepo, nodeRepository, authRepo, answerCacheUsecase, faqUsecase, agentUsecase, logger)
 	if err != nil {
 		return nil, err
 	}
-	appUsecase := usecase.NewAppUsecase(appRepository, authRepo, nodeRepository, knowledgeBaseRepository, nodeUsecase, logger, configConfig, chatUsecase, cacheCache)
+	appUsecase := usecase.NewAppUsecase(appRepository, authRepo, nodeRepository, knowledgeBaseRepository, nodeUsecase, logger, configConfig, chatUsecase, cacheCache, auditLogRepository)
 	appHandler := v1.NewAppHandler(echo, baseHandler, logger, authMiddleware, appUsecase, modelUsecase, conversationUsecase, configConfig)
 	fileUsecase := usecase.NewFileUsecase(logger, minioClient, configConfig, systemSettingRepo)
 	fileHandler := v1.NewFileHandler(echo, baseHandler, logger, authMiddleware, minioClient, configConfig, fileUsecase)
 	modelHandler := v1.NewModelHandler(echo, baseHandler, logger, authMiddleware, modelUsecase, llmUsecase)
-	conversationHandler := v1.NewConversationHandler(echo, baseHandler, logger, authMiddleware, conversationUsecase)
+	conversationHandler := v1.NewConversationHandler(echo, baseHandler, logger, authMiddleware, conversationUsecase, chatUsecase)
 	mqConsumer, err := mq.NewMQConsumer(configConfig, logger)
 	if err != nil {
 		return nil, err
@@ -133,13 +141,37 @@ func createApp() (*App, error) {
 	statUseCase := usecase.NewStatUseCase(statRepository, nodeRepository, conversationRepository, appRepository, ipAddressRepo, geoRepo, authRepo, knowledgeBaseRepository, logger)
 	statHandler := v1.NewStatHandler(baseHandler, echo, statUseCase, logger, authMiddleware)
 	commentRepository := pg2.NewCommentRepository(db, logger)
-	commentUsecase := usecase.NewCommentUsecase(commentRepository, logger, nodeRepository, ipAddressRepo, authRepo)
+	commentUsecase := usecase.NewCommentUsecase(commentRepository, logger, nodeRepository, ipAddressRepo, authRepo, webhookRepository)
 	commentHandler := v1.NewCommentHandler(echo, baseHandler, logger, authMiddleware, commentUsecase)
-	authUsecase, err := usecase.NewAuthUsecase(authRepo, logger, knowledgeBaseRepository, cacheCache)
+	authUsecase, err := usecase.NewAuthUsecase(authRepo, logger, knowledgeBaseRepository, cacheCache, auditLogRepository)
 	if err != nil {
 		return nil, err
 	}
 	authV1Handler := v1.NewAuthV1Handler(echo, baseHandler, logger, authUsecase)
+	evalRepository := pg2.NewEvalRepository(db, logger)
+	evalUsecase := usecase.NewEvalUsecase(evalRepository, knowledgeBaseRepository, authRepo, promptRepo, llmUsecase, modelUsecase, logger)
+	evalHandler := v1.NewEvalHandler(echo, baseHandler, logger, evalUsecase)
+	knowledgeGapUsecase := usecase.NewKnowledgeGapUsecase(conversationRepository, nodeRepository, llmUsecase, modelUsecase, logger)
+	knowledgeGapHandler := v1.NewKnowledgeGapHandler(echo, baseHandler, logger, knowledgeGapUsecase)
+	answerCacheHandler := v1.NewAnswerCacheHandler(echo, baseHandler, logger, answerCacheUsecase)
+	faqHandler := v1.NewFAQHandler(echo, baseHandler, logger, faqUsecase)
+	agentToolHandler := v1.NewAgentToolHandler(echo, baseHandler, logger, agentUsecase)
+	apiUsageRepository := pg2.NewAPIUsageRepository(db, logger)
+	retrievalAPIUsecase := usecase.NewRetrievalAPIUsecase(llmUsecase, modelUsecase, authRepo, apiUsageRepository, logger)
+	apiUsageHandler := v1.NewAPIUsageHandler(echo, baseHandler, logger, retrievalAPIUsecase)
+	apiTokenUsecase := usecase.NewAPITokenUsecase(apiTokenRepo, logger)
+	apiTokenHandler := v1.NewAPITokenHandler(echo, baseHandler, logger, apiTokenUsecase)
+	contributeRepository := pg2.NewContributeRepository(db, logger)
+	contributeUsecase := usecase.NewContributeUsecase(contributeRepository, nodeRepository, authRepo, nodeUsecase, logger)
+	contributeHandler := v1.NewContributeHandler(echo, baseHandler, logger, contributeUsecase)
+	webhookRepository2 := pg2.NewWebhookRepository(db, logger)
+	webhookUsecase := usecase.NewWebhookUsecase(webhookRepository2, logger)
+	webhookHandler := v1.NewWebhookHandler(echo, baseHandler, logger, webhookUsecase)
+	auditLogUsecase := usecase.NewAuditLogUsecase(auditLogRepository, logger)
+	auditLogHandler := v1.NewAuditLogHandler(echo, baseHandler, logger, auditLogUsecase)
+	vectorTaskRepository := pg2.NewVectorTaskRepository(db, logger)
+	vectorTaskUsecase := usecase.NewVectorTaskUsecase(vectorTaskRepository, ragRepository, logger)
+	vectorTaskHandler := v1.NewVectorTaskHandler(echo, baseHandler, logger, vectorTaskUsecase)
 	apiHandlers := &v1.APIHandlers{
 		UserHandler:          userHandler,
 		KnowledgeBaseHandler: knowledgeBaseHandler,
@@ -153,6 +185,17 @@ func createApp() (*App, error) {
 		StatHandler:          statHandler,
 		CommentHandler:       commentHandler,
 		AuthV1Handler:        authV1Handler,
+		EvalHandler:          evalHandler,
+		KnowledgeGapHandler:  knowledgeGapHandler,
+		AnswerCacheHandler:   answerCacheHandler,
+		FAQHandler:           faqHandler,
+		AgentToolHandler:     agentToolHandler,
+		APIUsageHandler:      apiUsageHandler,
+		APITokenHandler:      apiTokenHandler,
+		ContributeHandler:    contributeHandler,
+		WebhookHandler:       webhookHandler,
+		AuditLogHandler:      auditLogHandler,
+		VectorTaskHandler:    vectorTaskHandler,
 	}
 	shareNodeHandler := share.NewShareNodeHandler(baseHandler, echo, nodeUsecase, logger)
 	shareAppHandler := share.NewShareAppHandler(echo, baseHandler, logger, appUsecase)
@@ -171,6 +214,13 @@ func createApp() (*App, error) {
 	shareCaptchaHandler := share.NewShareCaptchaHandler(baseHandler, echo, logger)
 	openapiV1Handler := share.NewOpenapiV1Handler(echo, baseHandler, logger, authUsecase, appUsecase)
 	shareCommonHandler := share.NewShareCommonHandler(echo, baseHandler, logger, fileUsecase)
+	mcpRepository := pg2.NewMCPRepository(db, logger)
+	mcpUsecase := usecase.NewMCPUsecase(appRepository, authRepo, knowledgeBaseRepository, mcpRepository, chatUsecase, nodeUsecase, logger)
+	shareMCPHandler := share.NewShareMCPHandler(echo, baseHandler, logger, mcpUsecase)
+	shareOpenAIHandler := share.NewShareOpenAIHandler(echo, baseHandler, logger, appUsecase, chatUsecase, conversationUsecase)
+	shareAnthropicHandler := share.NewShareAnthropicHandler(echo, baseHandler, logger, appUsecase, chatUsecase, conversationUsecase, fileUsecase)
+	shareRetrievalHandler := share.NewShareRetrievalHandler(echo, baseHandler, logger, appUsecase, retrievalAPIUsecase, cacheCache)
+	shareContributeHandler := share.NewShareContributeHandler(echo, baseHandler, logger, contributeUsecase, nodeUsecase, appUsecase)
 	shareHandler := &share.ShareHandler{
 		ShareNodeHandler:         shareNodeHandler,
 		ShareAppHandler:          shareAppHandler,
@@ -184,8 +234,12 @@ func createApp() (*App, error) {
 		ShareCaptchaHandler:      shareCaptchaHandler,
 		OpenapiV1Handler:         openapiV1Handler,
 		ShareCommonHandler:       shareCommonHandler,
+		ShareMCPHandler:          shareMCPHandler,
+		ShareOpenAIHandler:       shareOpenAIHandler,
+		ShareAnthropicHandler:    shareAnthropicHandler,
+		ShareRetrievalHandler:    shareRetrievalHandler,
+		ShareContributeHandler:   shareContributeHandler,
 	}
-	mcpRepository := pg2.NewMCPRepository(db, logger)
 	client, err := telemetry.NewClient(logger, knowledgeBaseRepository, modelUsecase, userUsecase, nodeRepository, conversationRepository, mcpRepository, configConfig)
 	if err != nil {
 		return nil, err
@@ -35,11 +35,11 @@ func createApp() (*App, error) {
 	if err != nil {
 		return nil, err
 	}
-	ragService, err := rag.NewRAGService(configConfig, logger)
+	db, err := pg.NewDB(configConfig)
 	if err != nil {
 		return nil, err
 	}
-	db, err := pg.NewDB(configConfig)
+	ragService, err := rag.NewRAGService(configConfig, db, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -55,12 +55,15 @@ func createApp() (*App, error) {
 	}
 	ragRepository := mq2.NewRAGRepository(mqProducer)
 	systemSettingRepo := pg2.NewSystemSettingRepo(db, logger)
-	modelUsecase := usecase.NewModelUsecase(modelRepository, nodeRepository, ragRepository, ragService, logger, configConfig, knowledgeBaseRepository, systemSettingRepo)
-	ragmqHandler, err := mq3.NewRAGMQHandler(mqConsumer, logger, ragService, nodeRepository, knowledgeBaseRepository, llmUsecase, modelUsecase)
+	auditLogRepository := pg2.NewAuditLogRepository(db, logger)
+	modelUsecase := usecase.NewModelUsecase(modelRepository, nodeRepository, ragRepository, ragService, logger, configConfig, knowledgeBaseRepository, systemSettingRepo, auditLogRepository)
+	webhookRepository := mq2.NewWebhookRepository(mqProducer)
+	vectorTaskRepository := pg2.NewVectorTaskRepository(db, logger)
+	ragmqHandler, err := mq3.NewRAGMQHandler(mqConsumer, logger, ragService, nodeRepository, knowledgeBaseRepository, llmUsecase, modelUsecase, webhookRepository, ragRepository, vectorTaskRepository)
 	if err != nil {
 		return nil, err
 	}
-	ragDocUpdateHandler, err := mq3.NewRagDocUpdateHandler(mqConsumer, logger, nodeRepository)
+	ragDocUpdateHandler, err := mq3.NewRagDocUpdateHandler(mqConsumer, logger, nodeRepository, webhookRepository)
 	if err != nil {
 		return nil, err
 	}
@@ -83,15 +86,28 @@ func createApp() (*App, error) {
 	if err != nil {
 		return nil, err
 	}
-	nodeUsecase := usecase.NewNodeUsecase(nodeRepository, appRepository, ragRepository, userRepository, knowledgeBaseRepository, llmUsecase, ragService, logger, minioClient, modelRepository, authRepo, modelUsecase)
-	cronHandler, err := mq3.NewStatCronHandler(logger, statRepository, statUseCase, nodeUsecase)
+	nodeUsecase := usecase.NewNodeUsecase(nodeRepository, appRepository, ragRepository, userRepository, knowledgeBaseRepository, llmUsecase, ragService, logger, minioClient, modelRepository, authRepo, modelUsecase, webhookRepository, auditLogRepository)
+	webhookRepository2 := pg2.NewWebhookRepository(db, logger)
+	webhookUsecase := usecase.NewWebhookUsecase(webhookRepository2, logger)
+	vectorTaskUsecase := usecase.NewVectorTaskUsecase(vectorTaskRepository, ragRepository, logger)
+	cronHandler, err := mq3.NewStatCronHandler(configConfig, logger, statRepository, statUseCase, nodeUsecase, webhookUsecase, vectorTaskUsecase)
+	if err != nil {
+		return nil, err
+	}
+	webhookMQHandler, err := mq3.NewWebhookMQHandler(mqConsumer, logger, webhookUsecase)
+	if err != nil {
+		return nil, err
+	}
+	vectorDeadLetterMQHandler, err := mq3.NewVectorDeadLetterMQHandler(mqConsumer, logger, vectorTaskUsecase)
 	if err != nil {
 		return nil, err
 	}
 	mqHandlers := &mq3.MQHandlers{
-		RAGMQHandler:        ragmqHandler,
-		RagDocUpdateHandler: ragDocUpdateHandler,
-		StatCronHandler:     cronHandler,
+		RAGMQHandler:              ragmqHandler,
+		RagDocUpdateHandler:       ragDocUpdateHandler,
+		StatCronHandler:           cronHandler,
+		WebhookMQHandler:          webhookMQHandler,
+		VectorDeadLetterMQHandler: vectorDeadLetterMQHandler,
 	}
 	app := &App{
 		MQConsumer:      mqConsumer,
@@ -41,8 +41,9 @@ func createApp() (*App, error) {
 		return nil, err
 	}
 	ragRepository := mq2.NewRAGRepository(mqProducer)
+	webhookRepository := mq2.NewWebhookRepository(mqProducer)
 	userRepository := pg2.NewUserRepository(db, logger)
-	ragService, err := rag.NewRAGService(configConfig, logger)
+	ragService, err := rag.NewRAGService(configConfig, db, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -61,10 +62,11 @@ func createApp() (*App, error) {
 	}
 	authRepo := pg2.NewAuthRepo(db, logger, cacheCache)
 	systemSettingRepo := pg2.NewSystemSettingRepo(db, logger)
-	modelUsecase := usecase.NewModelUsecase(modelRepository, nodeRepository, ragRepository, ragService, logger, configConfig, knowledgeBaseRepository, systemSettingRepo)
-	nodeUsecase := usecase.NewNodeUsecase(nodeRepository, appRepository, ragRepository, userRepository, knowledgeBaseRepository, llmUsecase, ragService, logger, minioClient, modelRepository, authRepo, modelUsecase)
+	auditLogRepository := pg2.NewAuditLogRepository(db, logger)
+	modelUsecase := usecase.NewModelUsecase(modelRepository, nodeRepository, ragRepository, ragService, logger, configConfig, knowledgeBaseRepository, systemSettingRepo, auditLogRepository)
+	nodeUsecase := usecase.NewNodeUsecase(nodeRepository, appRepository, ragRepository, userRepository, knowledgeBaseRepository, llmUsecase, ragService, logger, minioClient, modelRepository, authRepo, modelUsecase, webhookRepository, auditLogRepository)
 	kbRepo := cache2.NewKBRepo(cacheCache)
-	knowledgeBaseUsecase, err := usecase.NewKnowledgeBaseUsecase(knowledgeBaseRepository, nodeRepository, ragRepository, userRepository, ragService, kbRepo, logger, configConfig)
+	knowledgeBaseUsecase, err := usecase.NewKnowledgeBaseUsecase(knowledgeBaseRepository, nodeRepository, ragRepository, userRepository, ragService, kbRepo, logger, configConfig, webhookRepository, auditLogRepository)
 	if err != nil {
 		return nil, err
 	}
@@ -72,11 +74,13 @@ func createApp() (*App, error) {
 	migrationCreateBotAuth := fns.NewMigrationCreateBotAuth(logger)
 	migrationFixGroupIds := fns.NewMigrationFixGroupIds(logger, ragRepository)
 	migrationUpdateNodeStatusUnreleased := fns.NewMigrationUpdateNodeStatusUnreleased(logger)
+	migrationBackfillNodeReleaseSearchVector := fns.NewMigrationBackfillNodeReleaseSearchVector(logger)
 	migrationFuncs := &migration.MigrationFuncs{
 		NodeMigration:                       migrationNodeVersion,
 		BotAuthMigration:                    migrationCreateBotAuth,
 		FixGroupIdsMigration:                migrationFixGroupIds,
 		UpdateNodeStatusUnreleasedMigration: migrationUpdateNodeStatusUnreleased,
+		BackfillSearchVectorMigration:       migrationBackfillNodeReleaseSearchVector,
 	}
 	manager, err := migration.NewManager(db, logger, migrationFuncs)
 	if err != nil {
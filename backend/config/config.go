@@ -19,6 +19,7 @@ type Config struct {
 	Auth          AuthConfig   `mapstructure:"auth"`
 	S3            S3Config     `mapstructure:"s3"`
 	Sentry        SentryConfig `mapstructure:"sentry"`
+	Trash         TrashConfig  `mapstructure:"trash"`
 	CaddyAPI      string       `mapstructure:"caddy_api"`
 	SubnetPrefix  string       `mapstructure:"subnet_prefix"`
 }
@@ -47,8 +48,9 @@ type NATSConfig struct {
 }
 
 type RAGConfig struct {
-	Provider string      `mapstructure:"provider"`
-	CTRAG    CTRAGConfig `mapstructure:"ct_rag"`
+	Provider string         `mapstructure:"provider"` // ct, pgvector
+	CTRAG    CTRAGConfig    `mapstructure:"ct_rag"`
+	PGVector PGVectorConfig `mapstructure:"pgvector"`
 }
 
 type CTRAGConfig struct {
@@ -56,6 +58,13 @@ type CTRAGConfig struct {
 	APIKey  string `mapstructure:"api_key"`
 }
 
+type PGVectorConfig struct {
+	Dimension    int `mapstructure:"dimension"`     // embedding dimension searched through the hnsw index (768, 1024 or 1536), 0 disables the index
+	ChunkSize    int `mapstructure:"chunk_size"`    // max runes per chunk
+	ChunkOverlap int `mapstructure:"chunk_overlap"` // runes shared by adjacent chunks
+	TopK         int `mapstructure:"top_k"`
+}
+
 type RedisConfig struct {
 	Addr     string `mapstructure:"addr"`
 	Password string `mapstructure:"password"`
@@ -81,6 +90,12 @@ type SentryConfig struct {
 	DSN     string `mapstructure:"dsn"`
 }
 
+// TrashConfig is set in the trash section of config.yml, TRASH_RETENTION_DAYS overrides retention_days when set
+type TrashConfig struct {
+	RetentionDays int    `mapstructure:"retention_days"` // <= 0 disables auto purge
+	PurgeCron     string `mapstructure:"purge_cron"`
+}
+
 func NewConfig() (*Config, error) {
 	// set default config
 	SUBNET_PREFIX := os.Getenv("SUBNET_PREFIX")
@@ -112,6 +127,12 @@ func NewConfig() (*Config, error) {
 				BaseURL: fmt.Sprintf("http://%s.18:5050", SUBNET_PREFIX),
 				APIKey:  "sk-1234567890",
 			},
+			PGVector: PGVectorConfig{
+				Dimension:    0,
+				ChunkSize:    800,
+				ChunkOverlap: 100,
+				TopK:         10,
+			},
 		},
 		Redis: RedisConfig{
 			Addr:     "panda-wiki-redis:6379",
@@ -130,6 +151,10 @@ func NewConfig() (*Config, error) {
 			Enabled: true,
 			DSN:     "https://2a4cff1ae04b624ffc72663f523024ff@sentry.baizhi.cloud/4",
 		},
+		Trash: TrashConfig{
+			RetentionDays: 30,
+			PurgeCron:     "15 3 * * *",
+		},
 		CaddyAPI:     "/app/run/caddy-admin.sock",
 		SubnetPrefix: "169.254.15",
 	}
@@ -190,9 +215,17 @@ func overrideWithEnv(c *Config) {
 		c.MQ.NATS.Server = env
 	}
 	// rag
+	if env := os.Getenv("RAG_PROVIDER"); env != "" {
+		c.RAG.Provider = env
+	}
 	if env := os.Getenv("RAG_CT_RAG_BASE_URL"); env != "" {
 		c.RAG.CTRAG.BaseURL = env
 	}
+	if env := os.Getenv("RAG_PGVECTOR_DIMENSION"); env != "" {
+		if i, err := strconv.Atoi(env); err == nil {
+			c.RAG.PGVector.Dimension = i
+		}
+	}
 	// redis
 	if env := os.Getenv("REDIS_ADDR"); env != "" {
 		c.Redis.Addr = env
@@ -208,6 +241,12 @@ func overrideWithEnv(c *Config) {
 	if env := os.Getenv("SENTRY_DSN"); env != "" {
 		c.Sentry.DSN = env
 	}
+	// trash
+	if env := os.Getenv("TRASH_RETENTION_DAYS"); env != "" {
+		if i, err := strconv.Atoi(env); err == nil {
+			c.Trash.RetentionDays = i
+		}
+	}
 	// caddy api
 	if env := os.Getenv("CADDY_API"); env != "" {
 		c.CaddyAPI = env
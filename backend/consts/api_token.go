@@ -0,0 +1,57 @@
+package consts
+
+import (
+	"net/http"
+	"strings"
+)
+
+type APITokenScope string
+
+const (
+	APITokenScopeNodeRead      APITokenScope = "node:read"      // 读取文档
+	APITokenScopeNodeWrite     APITokenScope = "node:write"     // 编辑文档
+	APITokenScopeReleaseRead   APITokenScope = "release:read"   // 查看发布记录
+	APITokenScopeReleaseCreate APITokenScope = "release:create" // 发布及回滚
+	APITokenScopeStatsRead     APITokenScope = "stats:read"     // 查看统计
+	// APITokenScopeLegacy 升级前创建的 token 可继续访问未声明权限范围的接口，只能保留或移除，不能授予
+	APITokenScopeLegacy APITokenScope = "legacy"
+)
+
+var APITokenScopes = []APITokenScope{
+	APITokenScopeNodeRead,
+	APITokenScopeNodeWrite,
+	APITokenScopeReleaseRead,
+	APITokenScopeReleaseCreate,
+	APITokenScopeStatsRead,
+}
+
+// apiTokenRoute 可使用 api token 的接口，读接口(GET)与写接口分别对应一个权限范围，为空表示不允许 token 访问
+type apiTokenRoute struct {
+	prefix string
+	read   APITokenScope
+	write  APITokenScope
+}
+
+// apiTokenRoutes 按前缀从长到短排列，未列出的接口只允许带 legacy 权限范围的 token 访问
+var apiTokenRoutes = []apiTokenRoute{
+	{prefix: "/api/v1/knowledge_base/release", read: APITokenScopeReleaseRead, write: APITokenScopeReleaseCreate},
+	{prefix: "/api/v1/file/upload", write: APITokenScopeNodeWrite},
+	{prefix: "/api/v1/contribute", read: APITokenScopeNodeRead, write: APITokenScopeNodeWrite},
+	{prefix: "/api/v1/node", read: APITokenScopeNodeRead, write: APITokenScopeNodeWrite},
+	{prefix: "/api/v1/stat", read: APITokenScopeStatsRead},
+}
+
+// APITokenRouteScope returns the scope an api token needs for the route, false if the route declares no scope
+func APITokenRouteScope(method, path string) (APITokenScope, bool) {
+	for _, route := range apiTokenRoutes {
+		if path != route.prefix && !strings.HasPrefix(path, route.prefix+"/") {
+			continue
+		}
+		scope := route.write
+		if method == http.MethodGet || method == http.MethodHead {
+			scope = route.read
+		}
+		return scope, scope != ""
+	}
+	return "", false
+}
@@ -0,0 +1,151 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	DefaultAgentMaxSteps = 5
+	MaxAgentMaxSteps     = 20
+
+	DefaultAgentToolTimeout = 10 * time.Second
+	MaxAgentToolTimeout     = 60 * time.Second
+)
+
+// AgentToolNameRegexp is the tool name allowed by the function calling apis of the model providers
+var AgentToolNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// AgentBuiltinTools are the tools on the published documents, named the same as the tools of the mcp server
+var AgentBuiltinTools = []string{MCPToolSearchDefaultName, MCPToolGetNodeName, MCPToolListNodesName}
+
+// AgentSettings zero values fall back to defaults, see WithDefaults
+type AgentSettings struct {
+	Enabled       bool     `json:"enabled"`                                                                      // 允许模型在回答时调用工具
+	MaxSteps      int      `json:"max_steps" validate:"gte=0,lte=20"`                                            // 最多调用工具的轮数，0 使用默认值 5
+	DisabledTools []string `json:"disabled_tools" validate:"omitempty,dive,oneof=search_docs get_doc list_docs"` // 禁用的内置工具
+}
+
+func (s AgentSettings) WithDefaults() AgentSettings {
+	if s.MaxSteps <= 0 {
+		s.MaxSteps = DefaultAgentMaxSteps
+	}
+	s.MaxSteps = min(s.MaxSteps, MaxAgentMaxSteps)
+	return s
+}
+
+func (s *AgentSettings) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid agent settings value type:", value))
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+func (s AgentSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// table: agent_tools
+type AgentTool struct {
+	ID             string           `json:"id" gorm:"primaryKey"`
+	KBID           string           `json:"kb_id" gorm:"index"`
+	Name           string           `json:"name"`
+	Description    string           `json:"description"`
+	Parameters     json.RawMessage  `json:"parameters" gorm:"type:jsonb"` // 参数的 JSON Schema
+	Method         string           `json:"method"`                       // GET 时参数作为查询参数，POST 时作为 JSON 请求体
+	URL            string           `json:"url"`
+	Headers        AgentToolHeaders `json:"headers" gorm:"type:jsonb"`
+	TimeoutSeconds int              `json:"timeout_seconds"` // 0 使用默认值 10 秒
+	Enabled        bool             `json:"enabled"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+func (t *AgentTool) Timeout() time.Duration {
+	if t.TimeoutSeconds <= 0 {
+		return DefaultAgentToolTimeout
+	}
+	return min(time.Duration(t.TimeoutSeconds)*time.Second, MaxAgentToolTimeout)
+}
+
+type AgentToolHeaders map[string]string
+
+func (h *AgentToolHeaders) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid agent tool headers value type:", value))
+	}
+	return json.Unmarshal(bytes, h)
+}
+
+func (h AgentToolHeaders) Value() (driver.Value, error) {
+	if h == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(h)
+}
+
+// Masked returns a copy with every value masked, the headers usually carry credentials
+func (h AgentToolHeaders) Masked() AgentToolHeaders {
+	masked := make(AgentToolHeaders, len(h))
+	for key, value := range h {
+		masked[key] = value
+		if value != "" {
+			masked[key] = ChatModelAPIKeyMask
+		}
+	}
+	return masked
+}
+
+// KeepMasked restores the stored values of the headers sent back masked, the stored values are only
+// kept for the same url so that they can not be sent to another server
+func (h AgentToolHeaders) KeepMasked(stored *AgentTool, url string) error {
+	for key, value := range h {
+		if value != ChatModelAPIKeyMask {
+			continue
+		}
+		storedValue, ok := stored.Headers[key]
+		if !ok || url != stored.URL {
+			return ErrAgentToolHeaderRequired
+		}
+		h[key] = storedValue
+	}
+	return nil
+}
+
+// AgentToolTrace is one tool call of the model while answering
+type AgentToolTrace struct {
+	Step       int    `json:"step"`
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name"`
+	Arguments  string `json:"arguments"`
+	Result     string `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type AgentToolTraces []*AgentToolTrace
+
+func (t *AgentToolTraces) Scan(value any) error {
+	if value == nil {
+		*t = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid agent tool traces value type:", value))
+	}
+	return json.Unmarshal(bytes, t)
+}
+
+func (t AgentToolTraces) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(t)
+}
@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentToolHeaders(t *testing.T) {
+	stored := &AgentTool{URL: "https://example.com/api", Headers: AgentToolHeaders{"Authorization": "Bearer sk", "X-Empty": ""}}
+	masked := stored.Headers.Masked()
+	assert.Equal(t, AgentToolHeaders{"Authorization": ChatModelAPIKeyMask, "X-Empty": ""}, masked)
+	assert.Equal(t, "Bearer sk", stored.Headers["Authorization"])
+
+	assert.NoError(t, masked.KeepMasked(stored, stored.URL))
+	assert.Equal(t, "Bearer sk", masked["Authorization"])
+
+	assert.ErrorIs(t, stored.Headers.Masked().KeepMasked(stored, "https://attacker.example.com"), ErrAgentToolHeaderRequired)
+}
@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DefaultAnswerCacheSimilarityThreshold = 0.95
+	DefaultAnswerCacheMaxEntries          = 1000
+)
+
+// AnswerCacheSettings zero values fall back to defaults, see WithDefaults
+type AnswerCacheSettings struct {
+	Enabled             bool    `json:"enabled"`
+	SimilarityThreshold float64 `json:"similarity_threshold" validate:"gte=0,lte=1"` // 问题向量相似度阈值，0 使用默认值 0.95
+	TTLHours            int     `json:"ttl_hours" validate:"gte=0"`                  // 缓存有效期，0 表示直到下次发布
+	MaxEntries          int     `json:"max_entries" validate:"gte=0,lte=100000"`     // 每个版本最多缓存的回答数，0 使用默认值 1000
+}
+
+func (s AnswerCacheSettings) WithDefaults() AnswerCacheSettings {
+	if s.SimilarityThreshold <= 0 {
+		s.SimilarityThreshold = DefaultAnswerCacheSimilarityThreshold
+	}
+	if s.MaxEntries <= 0 {
+		s.MaxEntries = DefaultAnswerCacheMaxEntries
+	}
+	return s
+}
+
+func (s *AnswerCacheSettings) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid answer cache settings value type:", value))
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+func (s AnswerCacheSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// table: answer_caches
+type AnswerCache struct {
+	ID           string               `json:"id" gorm:"primaryKey"`
+	KBID         string               `json:"kb_id"`
+	ReleaseID    string               `json:"release_id"` // 缓存时知识库的最新版本
+	AppID        string               `json:"app_id"`     // 回答的应用，不同应用的提示词和模型不同
+	GroupKey     string               `json:"group_key"`  // 提问用户所属的用户组，见 AnswerCacheGroupKey
+	Question     string               `json:"question"`
+	QuestionHash string               `json:"-"`
+	Embedding    pq.Float32Array      `json:"-" gorm:"type:real[]"`
+	Answer       string               `json:"answer"`
+	ChunkResults AnswerCacheChunks    `json:"chunk_results" gorm:"type:jsonb"`
+	Citations    AnswerCacheCitations `json:"citations" gorm:"type:jsonb"`
+	Provider     ModelProvider        `json:"provider"`
+	Model        string               `json:"model"`
+	HitCount     int                  `json:"hit_count"`
+	LastHitAt    *time.Time           `json:"last_hit_at"`
+	CreatedAt    time.Time            `json:"created_at"`
+}
+
+type AnswerCacheChunks []*NodeContentChunkSSE
+
+func (c *AnswerCacheChunks) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid answer cache chunks value type:", value))
+	}
+	return json.Unmarshal(bytes, c)
+}
+
+func (c AnswerCacheChunks) Value() (driver.Value, error) {
+	if c == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(c)
+}
+
+type AnswerCacheCitations []*ConversationReference
+
+func (c *AnswerCacheCitations) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid answer cache citations value type:", value))
+	}
+	return json.Unmarshal(bytes, c)
+}
+
+func (c AnswerCacheCitations) Value() (driver.Value, error) {
+	if c == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(c)
+}
+
+// NormalizeQuestion lowercases the question and drops whitespace and punctuation,
+// so that questions differing only in them share the cache entry
+func NormalizeQuestion(question string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, question)
+}
+
+func AnswerCacheQuestionHash(question string) string {
+	sum := sha256.Sum256([]byte(NormalizeQuestion(question)))
+	return hex.EncodeToString(sum[:])
+}
+
+// AnswerCacheGroupKey identifies the auth group set of the asker, answers are only shared
+// between users who can read the same documents
+func AnswerCacheGroupKey(groupIDs []int) string {
+	ids := slices.Clone(groupIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,172 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// AnthropicImageMediaTypes 支持的图片类型及上传时使用的扩展名
+var AnthropicImageMediaTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Anthropic Messages API 请求结构体
+type AnthropicMessagesRequest struct {
+	Model         string             `json:"model" validate:"required"`
+	Messages      []AnthropicMessage `json:"messages" validate:"required"`
+	System        AnthropicContent   `json:"system,omitempty"`
+	MaxTokens     int                `json:"max_tokens,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	TopK          *int               `json:"top_k,omitempty"`
+	Metadata      map[string]any     `json:"metadata,omitempty"`
+}
+
+type AnthropicMessage struct {
+	Role    string           `json:"role" validate:"required"`
+	Content AnthropicContent `json:"content"`
+}
+
+// AnthropicContent 支持字符串或内容块数组，字符串解析为一个 text 块
+type AnthropicContent []AnthropicContentBlock
+
+func (c *AnthropicContent) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*c = AnthropicContent{{Type: "text", Text: str}}
+		return nil
+	}
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return fmt.Errorf("content must be string or array")
+	}
+	*c = blocks
+	return nil
+}
+
+// Text 返回所有 text 块的内容
+func (c AnthropicContent) Text() string {
+	texts := make([]string, 0, len(c))
+	for _, block := range c {
+		if block.Type == "text" && block.Text != "" {
+			texts = append(texts, block.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Images 返回所有 image 块的图片
+func (c AnthropicContent) Images() []*AnthropicImageSource {
+	images := make([]*AnthropicImageSource, 0)
+	for _, block := range c {
+		if block.Type == "image" && block.Source != nil {
+			images = append(images, block.Source)
+		}
+	}
+	return images
+}
+
+type AnthropicContentBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *AnthropicImageSource `json:"source,omitempty"`
+}
+
+type AnthropicImageSource struct {
+	Type      string `json:"type"` // base64, url
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"` // 仅支持 /static-file/ 路径
+}
+
+// ToChatRequest maps the request onto a chat request, the last user message is the question and the
+// user and assistant messages before it are the history. The system prompt is appended to the system
+// prompt of the kb, the images of the question are returned to be uploaded by the caller.
+func (r *AnthropicMessagesRequest) ToChatRequest() (*ChatRequest, []*AnthropicImageSource, error) {
+	last := -1
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == "user" {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		return nil, nil, errors.New("no user message found")
+	}
+	question := r.Messages[last].Content
+	if question.Text() == "" && len(question.Images()) == 0 {
+		return nil, nil, errors.New("user message is empty")
+	}
+	req := &ChatRequest{
+		Message:         question.Text(),
+		AppType:         AppTypeOpenAIAPI,
+		SkipSuggestions: true,
+	}
+	if system := r.System.Text(); system != "" {
+		req.ExtraPrompt = "\n\n" + system
+	}
+	for _, msg := range r.Messages[:last] {
+		if content := msg.Content.Text(); content != "" && (msg.Role == "user" || msg.Role == "assistant") {
+			req.History = append(req.History, &ConversationMessage{Role: schema.RoleType(msg.Role), Content: content})
+		}
+	}
+	return req, question.Images(), nil
+}
+
+// Anthropic Messages API 响应结构体
+type AnthropicMessagesResponse struct {
+	ID           string                  `json:"id"`
+	Type         string                  `json:"type"`
+	Role         string                  `json:"role"`
+	Model        string                  `json:"model"`
+	Content      []AnthropicContentBlock `json:"content"`
+	StopReason   *string                 `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        AnthropicUsage          `json:"usage"`
+}
+
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicStreamEvent 流式响应事件，Type 同时作为 SSE 的 event 名称
+type AnthropicStreamEvent struct {
+	Type         string                     `json:"type"`
+	Message      *AnthropicMessagesResponse `json:"message,omitempty"`
+	Index        *int                       `json:"index,omitempty"`
+	ContentBlock *AnthropicContentBlock     `json:"content_block,omitempty"`
+	Delta        any                        `json:"delta,omitempty"`
+	Usage        *AnthropicUsage            `json:"usage,omitempty"`
+	Error        *AnthropicError            `json:"error,omitempty"`
+}
+
+type AnthropicTextDelta struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type AnthropicMessageDelta struct {
+	StopReason   *string `json:"stop_reason"`
+	StopSequence *string `json:"stop_sequence"`
+}
+
+// Anthropic 错误响应结构体
+type AnthropicErrorResponse struct {
+	Type  string         `json:"type"`
+	Error AnthropicError `json:"error"`
+}
+
+type AnthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
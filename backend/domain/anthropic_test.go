@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnthropicMessagesRequest_ToChatRequest(t *testing.T) {
+	var req AnthropicMessagesRequest
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"model": "kb",
+		"max_tokens": 1024,
+		"system": [{"type": "text", "text": "answer briefly"}],
+		"messages": [
+			{"role": "user", "content": "what is sla"},
+			{"role": "assistant", "content": [{"type": "text", "text": "sla is ..."}]},
+			{"role": "user", "content": [
+				{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="}},
+				{"type": "text", "text": "what is in the image"}
+			]}
+		]
+	}`), &req))
+
+	chatReq, images, err := req.ToChatRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "what is in the image", chatReq.Message)
+	assert.Equal(t, "\n\nanswer briefly", chatReq.ExtraPrompt)
+	require.Len(t, chatReq.History, 2)
+	assert.Equal(t, schema.User, chatReq.History[0].Role)
+	assert.Equal(t, "sla is ...", chatReq.History[1].Content)
+	require.Len(t, images, 1)
+	assert.Equal(t, "image/png", images[0].MediaType)
+
+	req.Messages = []AnthropicMessage{{Role: "user", Content: AnthropicContent{}}}
+	_, _, err = req.ToChatRequest()
+	assert.Error(t, err)
+}
@@ -2,31 +2,70 @@ package domain
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
 	"time"
 
+	"github.com/lib/pq"
+
 	"github.com/chaitin/panda-wiki/consts"
 )
 
+// APIToken is a kb scoped token for the v1 api, only the sha256 of the token is stored and
+// the token itself is returned once on creation and rotation
 type APIToken struct {
-	ID         string                  `json:"id" gorm:"primaryKey"`
-	Name       string                  `json:"name" gorm:"not null"`
-	UserID     string                  `json:"user_id" gorm:"not null"`
-	Token      string                  `json:"token" gorm:"uniqueIndex;not null"`
-	KbId       string                  `json:"kb_id" gorm:"not null"`
-	Permission consts.UserKBPermission `json:"permission" gorm:"not null"`
-	CreatedAt  time.Time               `json:"created_at"`
-	UpdatedAt  time.Time               `json:"updated_at"`
+	ID          string                  `json:"id" gorm:"primaryKey"`
+	Name        string                  `json:"name" gorm:"not null"`
+	UserID      string                  `json:"user_id" gorm:"not null"`
+	Token       string                  `json:"-" gorm:"uniqueIndex;not null"` // sha256 hex
+	TokenPrefix string                  `json:"token_prefix"`                  // 用于区分 token 的前几位
+	KbId        string                  `json:"kb_id" gorm:"not null"`
+	Permission  consts.UserKBPermission `json:"permission" gorm:"not null"`
+	Scopes      pq.StringArray          `json:"scopes" gorm:"type:text[]"`
+	ExpiresAt   *time.Time              `json:"expires_at"`
+	LastUsedAt  *time.Time              `json:"last_used_at"`
+	CreatedAt   time.Time               `json:"created_at"`
+	UpdatedAt   time.Time               `json:"updated_at"`
 }
 
 func (APIToken) TableName() string {
 	return "api_tokens"
 }
 
+func (t *APIToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// RouteScope returns the scope the token needs for the route and whether the token has it,
+// a route declaring no scope returns an empty scope and is only allowed to legacy tokens
+func (t *APIToken) RouteScope(method, path string) (consts.APITokenScope, bool) {
+	scope, ok := consts.APITokenRouteScope(method, path)
+	if !ok {
+		return "", slices.Contains(t.Scopes, string(consts.APITokenScopeLegacy))
+	}
+	return scope, slices.Contains(t.Scopes, string(scope))
+}
+
+// HashAPIToken returns the stored form of a token
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 type CtxAuthInfo struct {
 	IsToken    bool
 	Permission consts.UserKBPermission
+	Scopes     []string
 	UserId     string
 	KBId       string
+	TokenId    string // IsToken 时为 API Token ID
+	RemoteIP   string
+}
+
+// HasScope reports whether the caller may use an endpoint of scope, users are not limited by scopes
+func (a *CtxAuthInfo) HasScope(scope consts.APITokenScope) bool {
+	return !a.IsToken || slices.Contains(a.Scopes, string(scope))
 }
 
 type contextKey string
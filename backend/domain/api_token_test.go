@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chaitin/panda-wiki/consts"
+)
+
+func TestAPIToken(t *testing.T) {
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", HashAPIToken(""))
+
+	now := time.Now()
+	token := &APIToken{Scopes: pq.StringArray{string(consts.APITokenScopeNodeRead)}}
+	assert.False(t, token.IsExpired(now))
+	token.ExpiresAt = &now
+	assert.True(t, token.IsExpired(now))
+
+	authInfo := &CtxAuthInfo{IsToken: true, Scopes: token.Scopes}
+	assert.True(t, authInfo.HasScope(consts.APITokenScopeNodeRead))
+	assert.False(t, authInfo.HasScope(consts.APITokenScopeNodeWrite))
+	assert.True(t, (&CtxAuthInfo{}).HasScope(consts.APITokenScopeNodeWrite))
+
+	scope, ok := consts.APITokenRouteScope(http.MethodGet, "/api/v1/node/list")
+	assert.True(t, ok)
+	assert.Equal(t, consts.APITokenScopeNodeRead, scope)
+	scope, ok = consts.APITokenRouteScope(http.MethodPost, "/api/v1/knowledge_base/release/rollback")
+	assert.True(t, ok)
+	assert.Equal(t, consts.APITokenScopeReleaseCreate, scope)
+	_, ok = consts.APITokenRouteScope(http.MethodPost, "/api/v1/stat/count")
+	assert.False(t, ok)
+	_, ok = consts.APITokenRouteScope(http.MethodGet, "/api/v1/knowledge_base/detail")
+	assert.False(t, ok)
+	_, ok = consts.APITokenRouteScope(http.MethodGet, "/api/v1/nodes")
+	assert.False(t, ok)
+
+	scope, ok = token.RouteScope(http.MethodGet, "/api/v1/node/detail")
+	assert.True(t, ok)
+	assert.Equal(t, consts.APITokenScopeNodeRead, scope)
+	scope, ok = token.RouteScope(http.MethodPost, "/api/v1/node/detail")
+	assert.False(t, ok)
+	assert.Equal(t, consts.APITokenScopeNodeWrite, scope)
+	scope, ok = token.RouteScope(http.MethodGet, "/api/v1/knowledge_base/detail")
+	assert.False(t, ok)
+	assert.Empty(t, scope)
+	// 升级前创建的 token 仍可访问未声明权限范围的接口
+	legacy := &APIToken{Scopes: pq.StringArray{string(consts.APITokenScopeNodeRead), string(consts.APITokenScopeLegacy)}}
+	scope, ok = legacy.RouteScope(http.MethodGet, "/api/v1/knowledge_base/detail")
+	assert.True(t, ok)
+	assert.Empty(t, scope)
+	_, ok = legacy.RouteScope(http.MethodPost, "/api/v1/node/detail")
+	assert.False(t, ok)
+}
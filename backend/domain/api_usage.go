@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// 按接口统计的 API 用量
+const (
+	APIUsageEndpointRetrieval  = "retrieval"
+	APIUsageEndpointEmbeddings = "embeddings"
+)
+
+// table: api_usages, one row per kb, day and endpoint
+type APIUsage struct {
+	KBID     string    `json:"kb_id" gorm:"primaryKey"`
+	Date     time.Time `json:"date" gorm:"primaryKey;type:date"`
+	Endpoint string    `json:"endpoint" gorm:"primaryKey"`
+	Requests int64     `json:"requests"`
+	Tokens   int64     `json:"tokens"`
+}
+
+// RetrievalAPIReq 检索接口请求，密钥只能访问一个知识库时 kb_id 可以为空
+type RetrievalAPIReq struct {
+	KBID  string `json:"kb_id"`
+	Query string `json:"query" validate:"required"`
+	TopK  int    `json:"top_k" validate:"gte=0,lte=50"` // 0 使用知识库的检索设置
+}
+
+type RetrievalAPIResp struct {
+	KBID           string               `json:"kb_id"`
+	Query          string               `json:"query"`
+	RewrittenQuery string               `json:"rewritten_query"`
+	Chunks         []*RetrievalAPIChunk `json:"chunks"`
+	Usage          RetrievalAPIUsage    `json:"usage"`
+}
+
+// RetrievalAPIChunk 按文档排名及文档内顺序排列的片段，Score 为片段的检索得分
+type RetrievalAPIChunk struct {
+	NodeID        string   `json:"node_id"`
+	NodeName      string   `json:"node_name"`
+	NodePathNames []string `json:"node_path_names"`
+	Text          string   `json:"text"`
+	Score         float64  `json:"score"`
+	Rank          int      `json:"rank"` // 文档的排名，从 1 开始
+}
+
+type RetrievalAPIUsage struct {
+	Tokens int `json:"tokens"`
+}
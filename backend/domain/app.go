@@ -66,6 +66,8 @@ func (t AppType) ToSourceType() consts.SourceType {
 		return consts.SourceTypeOpenAIAPI
 	case AppTypeLarkBot:
 		return consts.SourceTypeLarkBot
+	case AppTypeMcpServer:
+		return consts.SourceTypeMcpServer
 	default:
 		return ""
 	}
@@ -172,6 +174,8 @@ type AppSettings struct {
 	// MCP Server Settings
 	MCPServerSettings MCPServerSettings `json:"mcp_server_settings,omitempty"`
 	StatsSetting      StatsSetting      `json:"stats_setting"`
+	// 应用单独使用的对话模型，为空时使用知识库的对话模型
+	ChatModel *ChatModelSetting `json:"chat_model,omitempty"`
 }
 
 type WeChatAppAdvancedSetting struct {
@@ -383,6 +387,9 @@ type ContributeSettings struct {
 type OpenAIAPIBotSettings struct {
 	IsEnabled bool   `json:"is_enabled"`
 	SecretKey string `json:"secret_key"`
+	// 检索和向量接口的频率限制，0 表示不限制
+	RequestsPerMinute int `json:"requests_per_minute"`
+	TokensPerMinute   int `json:"tokens_per_minute"`
 }
 
 type WebAppCustomSettings struct {
@@ -575,6 +582,8 @@ type AppSettingsResp struct {
 	// MCP Server Settings
 	MCPServerSettings MCPServerSettings `json:"mcp_server_settings,omitempty"`
 	StatsSetting      StatsSetting      `json:"stats_setting"`
+	// 应用单独使用的对话模型，为空时使用知识库的对话模型
+	ChatModel *ChatModelSetting `json:"chat_model,omitempty"`
 }
 
 type WebAppLandingConfigResp struct {
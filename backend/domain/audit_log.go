@@ -0,0 +1,196 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditActorType string
+
+const (
+	AuditActorTypeUser     AuditActorType = "user"
+	AuditActorTypeAPIToken AuditActorType = "api_token"
+	AuditActorTypeSystem   AuditActorType = "system" // 没有登录信息的调用，如后台任务
+)
+
+type AuditAction string
+
+const (
+	AuditActionNodeCreate           AuditAction = "node.create"
+	AuditActionNodeUpdate           AuditAction = "node.update"
+	AuditActionNodeDelete           AuditAction = "node.delete"
+	AuditActionNodeMove             AuditAction = "node.move"
+	AuditActionNodePermissionUpdate AuditAction = "node.permission_update"
+	AuditActionKBUpdate             AuditAction = "kb.update"
+	AuditActionKBUserInvite         AuditAction = "kb_user.invite"
+	AuditActionKBUserUpdate         AuditAction = "kb_user.update"
+	AuditActionKBUserRemove         AuditAction = "kb_user.remove"
+	AuditActionAppUpdate            AuditAction = "app.update"
+	AuditActionModelCreate          AuditAction = "model.create"
+	AuditActionModelUpdate          AuditAction = "model.update"
+	AuditActionModelSwitchMode      AuditAction = "model.switch_mode"
+	AuditActionAuthConfigUpdate     AuditAction = "auth_config.update"
+	AuditActionAuthDelete           AuditAction = "auth.delete"
+)
+
+type AuditTargetType string
+
+const (
+	AuditTargetNode         AuditTargetType = "node"
+	AuditTargetKB           AuditTargetType = "knowledge_base"
+	AuditTargetKBUser       AuditTargetType = "kb_user"
+	AuditTargetApp          AuditTargetType = "app"
+	AuditTargetModel        AuditTargetType = "model"
+	AuditTargetModelSetting AuditTargetType = "model_setting"
+	AuditTargetAuthConfig   AuditTargetType = "auth_config"
+	AuditTargetAuth         AuditTargetType = "auth"
+)
+
+// table: audit_logs, append only, updates and deletes are rejected by a trigger
+type AuditLog struct {
+	ID         string          `json:"id" gorm:"primaryKey"`
+	KBID       string          `json:"kb_id"` // 全局设置（如模型）为空
+	ActorType  AuditActorType  `json:"actor_type"`
+	ActorID    string          `json:"actor_id"` // 用户 ID 或 API Token ID
+	UserID     string          `json:"user_id"`  // API Token 的创建者
+	Action     AuditAction     `json:"action"`
+	TargetType AuditTargetType `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before" gorm:"type:jsonb"`
+	After      json.RawMessage `json:"after" gorm:"type:jsonb"`
+	RemoteIP   string          `json:"remote_ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// NewAuditLog builds the audit log of the caller in ctx, secrets in the snapshots are masked
+func NewAuditLog(ctx context.Context, kbID string, action AuditAction, targetType AuditTargetType, targetID string, before, after any) (*AuditLog, error) {
+	beforeBytes, err := RedactAuditSnapshot(before)
+	if err != nil {
+		return nil, err
+	}
+	afterBytes, err := RedactAuditSnapshot(after)
+	if err != nil {
+		return nil, err
+	}
+	auditLog := &AuditLog{
+		ID:         uuid.New().String(),
+		KBID:       kbID,
+		ActorType:  AuditActorTypeSystem,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     beforeBytes,
+		After:      afterBytes,
+		CreatedAt:  time.Now(),
+	}
+	if authInfo := GetAuthInfoFromCtx(ctx); authInfo != nil {
+		auditLog.UserID = authInfo.UserId
+		auditLog.RemoteIP = authInfo.RemoteIP
+		if authInfo.IsToken {
+			auditLog.ActorType = AuditActorTypeAPIToken
+			auditLog.ActorID = authInfo.TokenId
+		} else {
+			auditLog.ActorType = AuditActorTypeUser
+			auditLog.ActorID = authInfo.UserId
+		}
+	}
+	return auditLog, nil
+}
+
+const auditRedactedValue = "******"
+
+// RedactAuditSnapshot marshals the snapshot and masks the values of secret fields, nil stays nil
+func RedactAuditSnapshot(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot any
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, nil
+	}
+	return json.Marshal(redactAuditValue(snapshot))
+}
+
+func redactAuditValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for key, item := range value {
+			if isAuditSecretKey(key) {
+				if item != nil && item != "" {
+					value[key] = auditRedactedValue
+				}
+				continue
+			}
+			value[key] = redactAuditValue(item)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = redactAuditValue(item)
+		}
+	}
+	return v
+}
+
+func isAuditSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"secret", "password", "private_key", "api_key", "aeskey", "encrypt_key"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	// 请求头中常带鉴权信息（如 api_header、headers），header_search_placeholder 等界面配置不受影响
+	if strings.HasSuffix(key, "header") || strings.HasSuffix(key, "headers") {
+		return true
+	}
+	return key == "token" || key == "key" || strings.HasSuffix(key, "_token")
+}
+
+// AuditNodeSnapshot 文档快照，正文只记录长度和摘要，历史正文见文档发布记录
+type AuditNodeSnapshot struct {
+	Name          string     `json:"name"`
+	Type          NodeType   `json:"type"`
+	Status        NodeStatus `json:"status"`
+	ParentID      string     `json:"parent_id"`
+	Position      float64    `json:"position"`
+	Emoji         string     `json:"emoji"`
+	Summary       string     `json:"summary"`
+	ContentLength int        `json:"content_length"`
+	ContentSHA256 string     `json:"content_sha256"`
+}
+
+func NewAuditNodeSnapshot(node *Node) *AuditNodeSnapshot {
+	if node == nil {
+		return nil
+	}
+	hash := sha256.Sum256([]byte(node.Content))
+	return &AuditNodeSnapshot{
+		Name:          node.Name,
+		Type:          node.Type,
+		Status:        node.Status,
+		ParentID:      node.ParentID,
+		Position:      node.Position,
+		Emoji:         node.Meta.Emoji,
+		Summary:       node.Meta.Summary,
+		ContentLength: len(node.Content),
+		ContentSHA256: hex.EncodeToString(hash[:]),
+	}
+}
+
+// AuditLogListItem 审计日志，actor_name 为用户账号或 API Token 名称
+type AuditLogListItem struct {
+	AuditLog
+	ActorName string `json:"actor_name"`
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLog(t *testing.T) {
+	snapshot, err := RedactAuditSnapshot(map[string]any{
+		"name":          "app",
+		"api_key":       "sk-123",
+		"client_secret": "",
+		"api_header":    "Authorization: Bearer sk-123",
+		"headers":       map[string]any{"X-API-Key": "sk-456"},
+		"settings": map[string]any{
+			"header_search_placeholder": "搜索",
+			"discord_bot_token":         "token",
+			"wechat_app_encodingaeskey": "aes",
+			"tokens_per_minute":         60,
+		},
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"name": "app",
+		"api_key": "******",
+		"client_secret": "",
+		"api_header": "******",
+		"headers": "******",
+		"settings": {
+			"header_search_placeholder": "搜索",
+			"discord_bot_token": "******",
+			"wechat_app_encodingaeskey": "******",
+			"tokens_per_minute": 60
+		}
+	}`, string(snapshot))
+
+	var node *Node
+	snapshot, err = RedactAuditSnapshot(NewAuditNodeSnapshot(node))
+	assert.NoError(t, err)
+	assert.Nil(t, snapshot)
+
+	auditLog, err := NewAuditLog(context.Background(), "kb", AuditActionNodeUpdate, AuditTargetNode, "node", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, AuditActorTypeSystem, auditLog.ActorType)
+
+	ctx := context.WithValue(context.Background(), CtxAuthInfoKey, &CtxAuthInfo{
+		UserId:   "user",
+		TokenId:  "token",
+		IsToken:  true,
+		RemoteIP: "10.0.0.1",
+	})
+	auditLog, err = NewAuditLog(ctx, "kb", AuditActionNodeUpdate, AuditTargetNode, "node", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, AuditActorTypeAPIToken, auditLog.ActorType)
+	assert.Equal(t, "token", auditLog.ActorID)
+	assert.Equal(t, "user", auditLog.UserID)
+	assert.Equal(t, "10.0.0.1", auditLog.RemoteIP)
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+
+	"github.com/cloudwego/eino/schema"
 )
 
 type ChatRequest struct {
@@ -23,6 +25,14 @@ type ChatRequest struct {
 	RemoteIP string           `json:"-"`
 	Info     ConversationInfo `json:"-"`
 	Prompt   string           `json:"-"`
+
+	// 以下字段用于 OpenAI 兼容接口等由调用方维护上下文的请求
+	History         []*ConversationMessage `json:"-"` // 调用方提供的历史对话，放在问题之前
+	ToolMessages    []*schema.Message      `json:"-"` // 调用方执行工具后回传的工具调用及结果，放在问题之后
+	Tools           []*schema.ToolInfo     `json:"-"` // 调用方的工具，模型的调用通过 tool_calls 事件返回给调用方执行
+	ToolChoice      schema.ToolChoice      `json:"-"`
+	ExtraPrompt     string                 `json:"-"` // 追加到系统提示词之后，如调用方的系统消息和输出格式要求
+	SkipSuggestions bool                   `json:"-"`
 }
 
 type ChatRagOnlyRequest struct {
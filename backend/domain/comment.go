@@ -89,6 +89,12 @@ type DeleteCommentListReq struct {
 	IDS []string `json:"ids" query:"ids"`
 }
 
+type ModerateCommentReq struct {
+	KbID   string        `json:"kb_id" validate:"required"`
+	IDs    []string      `json:"ids" validate:"required,min=1"`
+	Status CommentStatus `json:"status" validate:"oneof=-1 1"` // -1 reject 1 accept
+}
+
 type ShareCommentListItem struct {
 	ID        string         `json:"id" gorm:"primaryKey"`
 	KbID      string         `json:"kb_id"`
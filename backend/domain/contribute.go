@@ -6,6 +6,8 @@ import (
 	"github.com/chaitin/panda-wiki/consts"
 )
 
+// Contribute is a change proposed by a reader, an edit of a released node or a new page under ParentId.
+// NodeId of a new page is reserved before the merge and is the id of the created node once approved.
 type Contribute struct {
 	Id          string                  `json:"id" gorm:"primaryKey;type:text"`
 	AuthId      *int64                  `json:"auth_id"`
@@ -13,17 +15,42 @@ type Contribute struct {
 	Status      consts.ContributeStatus `json:"status" gorm:"type:text;not null"`
 	Type        consts.ContributeType   `json:"type" gorm:"type:text;not null"`
 	NodeId      string                  `json:"node_id" gorm:"type:text"`
+	ParentId    string                  `json:"parent_id" gorm:"type:text;not null"`
 	Name        string                  `json:"name" gorm:"type:text"`
 	Content     string                  `json:"content" gorm:"type:text;not null"`
 	Meta        NodeMeta                `json:"meta"`
-	Reason      string                  `json:"reason" gorm:"type:text;not null"`
+	Reason      string                  `json:"reason" gorm:"type:text;not null"` // 提交说明
 	AuditUserID string                  `json:"audit_user_id" gorm:"type:text;not null"`
 	AuditTime   *time.Time              `json:"audit_time"`
+	AuditReason string                  `json:"audit_reason" gorm:"type:text;not null"` // 驳回原因
 	RemoteIP    string                  `json:"remote_ip" gorm:"type:text;not null"`
-	CreatedAt   time.Time               `gorm:"column:created_at;not null;default:now()"`
-	UpdatedAt   time.Time               `gorm:"column:updated_at;not null;default:now()"`
+	CreatedAt   time.Time               `json:"created_at" gorm:"column:created_at;not null;default:now()"`
+	UpdatedAt   time.Time               `json:"updated_at" gorm:"column:updated_at;not null;default:now()"`
 }
 
 func (Contribute) TableName() string {
 	return "contributes"
 }
+
+// Approve moves the pending contribute to approved after it is merged into NodeId
+func (c *Contribute) Approve(userID string, now time.Time) error {
+	if c.Status != consts.ContributeStatusPending {
+		return ErrContributeAudited
+	}
+	c.Status = consts.ContributeStatusApproved
+	c.AuditUserID = userID
+	c.AuditTime = &now
+	return nil
+}
+
+// Reject moves the pending contribute to rejected with the reason shown to the reader
+func (c *Contribute) Reject(userID, reason string, now time.Time) error {
+	if c.Status != consts.ContributeStatusPending {
+		return ErrContributeAudited
+	}
+	c.Status = consts.ContributeStatusRejected
+	c.AuditUserID = userID
+	c.AuditTime = &now
+	c.AuditReason = reason
+	return nil
+}
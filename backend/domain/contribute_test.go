@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chaitin/panda-wiki/consts"
+)
+
+func TestContributeAudit(t *testing.T) {
+	now := time.Now()
+
+	approved := &Contribute{Status: consts.ContributeStatusPending, Type: consts.ContributeTypeAdd, NodeId: "node-1"}
+	assert.NoError(t, approved.Approve("admin", now))
+	assert.Equal(t, consts.ContributeStatusApproved, approved.Status)
+	assert.Equal(t, "node-1", approved.NodeId)
+	assert.Equal(t, "admin", approved.AuditUserID)
+	assert.Equal(t, &now, approved.AuditTime)
+	// 已审核的贡献不能再次审核
+	assert.ErrorIs(t, approved.Approve("other", now), ErrContributeAudited)
+	assert.ErrorIs(t, approved.Reject("other", "重复", now), ErrContributeAudited)
+	assert.Equal(t, "node-1", approved.NodeId)
+	assert.Equal(t, "admin", approved.AuditUserID)
+
+	rejected := &Contribute{Status: consts.ContributeStatusPending, Type: consts.ContributeTypeEdit, NodeId: "node-1"}
+	assert.NoError(t, rejected.Reject("admin", "内容有误", now))
+	assert.Equal(t, consts.ContributeStatusRejected, rejected.Status)
+	assert.Equal(t, "内容有误", rejected.AuditReason)
+	assert.ErrorIs(t, rejected.Approve("admin", now), ErrContributeAudited)
+	assert.ErrorIs(t, rejected.Reject("admin", "再次驳回", now), ErrContributeAudited)
+	assert.Equal(t, "内容有误", rejected.AuditReason)
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cloudwego/eino/schema"
@@ -22,6 +24,30 @@ type Conversation struct {
 	RemoteIP  string           `json:"remote_ip"`
 	Info      ConversationInfo `json:"info" gorm:"type:jsonb"`
 	CreatedAt time.Time        `json:"created_at"`
+
+	HistorySummary *ConversationHistorySummary `json:"history_summary,omitempty" gorm:"type:jsonb"` // 超出上下文的早期对话摘要
+}
+
+// ConversationHistorySummary is the rolling summary of the first MessageCount messages of a conversation
+type ConversationHistorySummary struct {
+	Content      string    `json:"content"`
+	MessageCount int       `json:"message_count"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (s *ConversationHistorySummary) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("invalid conversation history summary type")
+	}
+	return json.Unmarshal(b, s)
+}
+
+func (s ConversationHistorySummary) Value() (driver.Value, error) {
+	return json.Marshal(s)
 }
 
 type ConversationMessage struct {
@@ -40,6 +66,14 @@ type ConversationMessage struct {
 	PromptTokens     int           `json:"prompt_tokens" gorm:"default:0"`
 	CompletionTokens int           `json:"completion_tokens" gorm:"default:0"`
 	TotalTokens      int           `json:"total_tokens" gorm:"default:0"`
+	FallbackFrom     string        `json:"fallback_from"` // 首选模型失败后由备用模型回答时，记录首选模型
+
+	RetrievedCount *int   `json:"retrieved_count,omitempty"`             // 回答时放入提示词的文档数，为空表示未记录
+	AnswerCacheID  string `json:"answer_cache_id,omitempty"`             // 命中回答缓存时的缓存条目
+	FAQID          string `json:"faq_id,omitempty" gorm:"column:faq_id"` // 命中常见问题时直接返回的条目
+
+	ToolTraces  AgentToolTraces `json:"tool_traces,omitempty" gorm:"type:jsonb"`            // 回答过程中的工具调用
+	Suggestions pq.StringArray  `json:"suggestions" gorm:"type:text[];not null;default:{}"` // 回答后推荐的追问
 
 	// stats
 	RemoteIP  string    `json:"remote_ip"`
@@ -73,10 +107,26 @@ func (f *FeedBackInfo) Scan(value any) error {
 type ConversationReference struct {
 	ConversationID string `json:"conversation_id" gorm:"index"`
 	AppID          string `json:"app_id"`
+	MessageID      string `json:"message_id" gorm:"index"`
 
-	NodeID string `json:"node_id"`
-	Name   string `json:"name"`
-	URL    string `json:"url"`
+	Index    int            `json:"index" gorm:"column:citation_index"` // 回答中的引用标记 [n]，从回答末尾的引用列表解析时为列表序号
+	NodeID   string         `json:"node_id"`
+	Name     string         `json:"name"`
+	URL      string         `json:"url"`
+	ChunkIDs pq.StringArray `json:"chunk_ids" gorm:"type:text[];not null;default:{}"` // 引用文档中放入提示词的分段
+}
+
+// FormatCitationFootnotes renders the citations as markdown footnotes for the bots
+func FormatCitationFootnotes(citations []*ConversationReference) string {
+	if len(citations) == 0 {
+		return ""
+	}
+	footnotes := strings.Builder{}
+	footnotes.WriteString("\n\n参考资料：\n")
+	for _, citation := range citations {
+		footnotes.WriteString(fmt.Sprintf("[%d] [%s](%s)\n", citation.Index, citation.Name, citation.URL))
+	}
+	return footnotes.String()
 }
 
 type ConversationListReq struct {
@@ -153,8 +203,9 @@ type ShareConversationDetailResp struct {
 }
 
 type ShareConversationMessage struct {
-	Role       schema.RoleType `json:"role"`
-	Content    string          `json:"content"`
-	ImagePaths pq.StringArray  `json:"image_paths"`
-	CreatedAt  time.Time       `json:"created_at"`
+	Role        schema.RoleType `json:"role"`
+	Content     string          `json:"content"`
+	ImagePaths  pq.StringArray  `json:"image_paths"`
+	Suggestions pq.StringArray  `json:"suggestions"`
+	CreatedAt   time.Time       `json:"created_at"`
 }
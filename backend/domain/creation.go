@@ -1,6 +1,7 @@
 package domain
 
 type TextReq struct {
+	KBID   string `json:"kb_id"` // 使用知识库的对话模型，为空时使用全局对话模型
 	Text   string `json:"text" validate:"required"`
 	Action string `json:"action"` // action: improve, summary, extend, shorten, etc.
 }
@@ -13,6 +14,7 @@ const (
 )
 
 type CompleteReq struct {
+	KBID string `json:"kb_id"` // 使用知识库的对话模型，为空时使用全局对话模型
 	// For FIM (Fill in Middle) style completion
 	Prefix string `json:"prefix,omitempty"`
 	Suffix string `json:"suffix,omitempty"`
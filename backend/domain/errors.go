@@ -4,6 +4,8 @@ import "errors"
 
 var ErrModelNotConfigured = errors.New("model not configured")
 
+var ErrChatModelAPIKeyRequired = errors.New("api key is required when the chat model provider or base url changes")
+
 var ErrPortHostAlreadyExists = errors.New("port and host already exists")
 
 var ErrSyncCaddyConfigFailed = errors.New("failed to sync caddy config")
@@ -15,3 +17,31 @@ var ErrPermissionDenied = errors.New("permission denied")
 var ErrInternalServerError = errors.New("internal server error")
 
 var ErrMaxNodeLimitReached = errors.New("max node limit reached")
+
+var ErrAgentToolNameInvalid = errors.New("agent tool name invalid")
+
+var ErrAgentToolNameExists = errors.New("agent tool name already exists")
+
+var ErrAgentToolParametersInvalid = errors.New("agent tool parameters is not a valid json schema")
+
+var ErrAgentToolHeaderRequired = errors.New("agent tool header value is required when the url changes")
+
+var ErrOpenAIAPIKeyInvalid = errors.New("invalid api key")
+
+var ErrAPIRateLimited = errors.New("api rate limit exceeded")
+
+var ErrAPIModelNotFound = errors.New("api model not found")
+
+var ErrAPITokenExpiresAtInvalid = errors.New("api token expires_at must be in the future")
+
+var ErrAPITokenScopeLegacy = errors.New("api token legacy scope can not be granted")
+
+var ErrContributeDisabled = errors.New("contribute is disabled")
+
+var ErrContributeAudited = errors.New("contribute has been audited")
+
+var ErrEvalRunBusy = errors.New("too many eval runs in progress")
+
+var ErrEvalRunNotRunning = errors.New("eval run is not running")
+
+var ErrWebhookEventInvalid = errors.New("webhook event type invalid")
@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// table: eval_sets
+type EvalSet struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	KBID        string    `json:"kb_id" gorm:"index"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// table: eval_questions
+type EvalQuestion struct {
+	ID              string         `json:"id" gorm:"primaryKey"`
+	SetID           string         `json:"set_id" gorm:"index"`
+	KBID            string         `json:"kb_id"`
+	Question        string         `json:"question"`
+	ExpectedNodeIDs pq.StringArray `json:"expected_node_ids" gorm:"type:text[]"` // 期望召回的文档
+	ReferenceAnswer string         `json:"reference_answer"`                     // 参考答案
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+type EvalRunStatus string
+
+const (
+	EvalRunStatusRunning   EvalRunStatus = "running"
+	EvalRunStatusSucceeded EvalRunStatus = "succeeded"
+	EvalRunStatusFailed    EvalRunStatus = "failed"
+	EvalRunStatusCanceled  EvalRunStatus = "canceled"
+)
+
+const (
+	EvalRunHeartbeatInterval = 30 * time.Second
+	EvalRunLease             = 3 * time.Minute // 超过租约未更新心跳的运行视为中断
+	EvalMaxConcurrentRuns    = 2               // 每个进程同时执行的评测运行数
+)
+
+// table: eval_runs
+type EvalRun struct {
+	ID          string        `json:"id" gorm:"primaryKey"`
+	SetID       string        `json:"set_id" gorm:"index"`
+	KBID        string        `json:"kb_id"`
+	Name        string        `json:"name"`
+	Status      EvalRunStatus `json:"status"`
+	Config      EvalRunConfig `json:"config" gorm:"type:jsonb"`
+	Metrics     EvalMetrics   `json:"metrics" gorm:"type:jsonb"`
+	Error       string        `json:"error"`
+	CreatedAt   time.Time     `json:"created_at"`
+	HeartbeatAt *time.Time    `json:"heartbeat_at"` // 执行中的运行定期更新
+	FinishedAt  *time.Time    `json:"finished_at"`
+}
+
+// EvalRunConfig is the snapshot of the settings a run was evaluated with
+type EvalRunConfig struct {
+	ModelMode      string            `json:"model_mode"`
+	ChatModel      string            `json:"chat_model"`
+	EmbeddingModel string            `json:"embedding_model"`
+	RerankModel    string            `json:"rerank_model"`
+	Retrieval      RetrievalSettings `json:"retrieval"`
+	Prompt         string            `json:"prompt"`
+	Judge          bool              `json:"judge"` // 是否使用大模型评估回答的忠实度
+}
+
+func (c *EvalRunConfig) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid eval run config value type:", value))
+	}
+	return json.Unmarshal(bytes, c)
+}
+
+func (c EvalRunConfig) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+type EvalMetrics struct {
+	QuestionCount  int     `json:"question_count"`
+	RetrievalCount int     `json:"retrieval_count"` // 有期望文档的问题数
+	K              int     `json:"k"`
+	RecallAtK      float64 `json:"recall_at_k"`
+	MRR            float64 `json:"mrr"`
+	JudgedCount    int     `json:"judged_count"`
+	Faithfulness   float64 `json:"faithfulness"`
+	ErrorCount     int     `json:"error_count"`
+	AvgLatencyMs   int64   `json:"avg_latency_ms"`
+}
+
+func (m *EvalMetrics) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid eval metrics value type:", value))
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+func (m EvalMetrics) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+// table: eval_run_results
+type EvalRunResult struct {
+	ID               string         `json:"id" gorm:"primaryKey"`
+	RunID            string         `json:"run_id" gorm:"index"`
+	QuestionID       string         `json:"question_id"`
+	Question         string         `json:"question"`
+	ExpectedNodeIDs  pq.StringArray `json:"expected_node_ids" gorm:"type:text[]"`
+	RetrievedNodeIDs pq.StringArray `json:"retrieved_node_ids" gorm:"type:text[]"`
+	Recall           *float64       `json:"recall"`          // 无期望文档时为空
+	ReciprocalRank   *float64       `json:"reciprocal_rank"` // 无期望文档时为空
+	Answer           string         `json:"answer"`
+	Faithfulness     *float64       `json:"faithfulness"` // 未评估时为空
+	JudgeReason      string         `json:"judge_reason"`
+	LatencyMs        int64          `json:"latency_ms"`
+	Error            string         `json:"error"`
+	CreatedAt        time.Time      `json:"created_at"`
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const DefaultFAQSimilarityThreshold = 0.9
+
+// table: faqs
+type FAQ struct {
+	ID         string         `json:"id" gorm:"primaryKey"`
+	KBID       string         `json:"kb_id" gorm:"index"`
+	Questions  pq.StringArray `json:"questions" gorm:"type:text[]"` // 问题及其不同问法
+	Embeddings FAQEmbeddings  `json:"-" gorm:"type:jsonb"`          // 与 Questions 一一对应，向量化失败时为空，仅按原文匹配
+	Answer     string         `json:"answer"`                       // 审核通过的回答，原样返回
+	NodeIDs    pq.StringArray `json:"node_ids" gorm:"type:text[]"`  // 关联文档
+	Threshold  float64        `json:"threshold"`                    // 问题相似度阈值，0 使用默认值 0.9
+	Enabled    bool           `json:"enabled"`
+	HitCount   int            `json:"hit_count"`
+	LastHitAt  *time.Time     `json:"last_hit_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+func (f *FAQ) SimilarityThreshold() float64 {
+	if f.Threshold <= 0 {
+		return DefaultFAQSimilarityThreshold
+	}
+	return f.Threshold
+}
+
+type FAQEmbeddings [][]float32
+
+func (e *FAQEmbeddings) Scan(value any) error {
+	if value == nil {
+		*e = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid faq embeddings value type:", value))
+	}
+	return json.Unmarshal(bytes, e)
+}
+
+func (e FAQEmbeddings) Value() (driver.Value, error) {
+	if e == nil {
+		return nil, nil
+	}
+	return json.Marshal(e)
+}
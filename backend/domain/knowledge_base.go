@@ -20,11 +20,59 @@ type KnowledgeBase struct {
 
 	// public info for public access
 	AccessSettings AccessSettings `json:"access_settings" gorm:"type:jsonb"`
+	// rag retrieval tuning of the knowledge base
+	RetrievalSettings RetrievalSettings `json:"retrieval_settings" gorm:"type:jsonb"`
+	ChatModel         *ChatModelSetting `json:"chat_model" gorm:"type:jsonb"` // 为空时使用全局对话模型
+	// cache answers of repeated questions, disabled by default
+	AnswerCacheSettings AnswerCacheSettings `json:"answer_cache_settings" gorm:"type:jsonb"`
+	// tools the model may call while answering, disabled by default
+	AgentSettings AgentSettings `json:"agent_settings" gorm:"type:jsonb"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+const (
+	DefaultRetrievalSimilarityThreshold = 0.2
+	DefaultRetrievalTopK                = 10
+)
+
+// RetrievalSettings unset or zero values fall back to defaults, see WithDefaults
+type RetrievalSettings struct {
+	SimilarityThreshold *float64 `json:"similarity_threshold,omitempty" validate:"omitempty,gte=0,lte=1"` // 向量相似度阈值，未设置时使用默认值 0.2，0 不过滤
+	TopK                int      `json:"top_k" validate:"gte=0,lte=50"`                                   // 召回文档数，0 使用默认值 10
+	MaxChunksPerDoc     int      `json:"max_chunks_per_doc" validate:"gte=0,lte=20"`                      // 每篇文档最多片段数，0 不限制
+	VectorWeight        float64  `json:"vector_weight" validate:"gte=0,lte=10"`                           // 向量检索融合权重
+	KeywordWeight       float64  `json:"keyword_weight" validate:"gte=0,lte=10"`                          // 关键词检索融合权重，0 关闭关键词检索，两个权重都为 0 时只使用向量检索
+}
+
+// WithDefaults fills the unset settings, SimilarityThreshold is always set afterwards
+func (s RetrievalSettings) WithDefaults() RetrievalSettings {
+	if s.SimilarityThreshold == nil {
+		threshold := DefaultRetrievalSimilarityThreshold
+		s.SimilarityThreshold = &threshold
+	}
+	if s.TopK <= 0 {
+		s.TopK = DefaultRetrievalTopK
+	}
+	if s.VectorWeight <= 0 && s.KeywordWeight <= 0 {
+		s.VectorWeight = 1
+	}
+	return s
+}
+
+func (s *RetrievalSettings) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid retrieval settings value type:", value))
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+func (s RetrievalSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
 type AccessSettings struct {
 	Ports          []int             `json:"ports"`
 	SSLPorts       []int             `json:"ssl_ports"`
@@ -105,9 +153,14 @@ type CreateKnowledgeBaseReq struct {
 }
 
 type UpdateKnowledgeBaseReq struct {
-	ID             string          `json:"id" validate:"required"`
-	Name           *string         `json:"name"`
-	AccessSettings *AccessSettings `json:"access_settings"`
+	ID                string             `json:"id" validate:"required"`
+	Name              *string            `json:"name"`
+	AccessSettings    *AccessSettings    `json:"access_settings"`
+	RetrievalSettings *RetrievalSettings `json:"retrieval_settings"`
+	ChatModel         *ChatModelSetting  `json:"chat_model"` // 模型和参数均为空时恢复使用全局对话模型
+
+	AnswerCacheSettings *AnswerCacheSettings `json:"answer_cache_settings"`
+	AgentSettings       *AgentSettings       `json:"agent_settings"`
 }
 
 type KnowledgeBaseListItem struct {
@@ -130,6 +183,12 @@ type KnowledgeBaseDetail struct {
 	Perm           consts.UserKBPermission `json:"perm"` // 用户对知识库的权限
 	AccessSettings AccessSettings          `json:"access_settings" gorm:"type:jsonb"`
 
+	RetrievalSettings RetrievalSettings `json:"retrieval_settings" gorm:"type:jsonb"`
+	ChatModel         *ChatModelSetting `json:"chat_model" gorm:"type:jsonb"` // 为空时使用全局对话模型
+
+	AnswerCacheSettings AnswerCacheSettings `json:"answer_cache_settings" gorm:"type:jsonb"`
+	AgentSettings       AgentSettings       `json:"agent_settings" gorm:"type:jsonb"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
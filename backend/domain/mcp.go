@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	MCPServerName    = "PandaWiki"
+	MCPServerVersion = "1.0.0"
+
+	MCPToolSearchDefaultName = "search_docs"
+	MCPToolSearchDefaultDesc = "搜索知识库中与问题相关的文档，返回文档 ID、标题、摘要和路径"
+	MCPToolGetNodeName       = "get_doc"
+	MCPToolGetNodeDesc       = "根据文档 ID 获取已发布文档的完整内容"
+	MCPToolListNodesName     = "list_docs"
+	MCPToolListNodesDesc     = "获取知识库的文档目录树，可指定父节点 ID 只返回其子树"
+)
+
+// table: mcp_calls
+type MCPCall struct {
+	ID             int             `json:"id" gorm:"primaryKey"`
+	MCPSessionID   string          `json:"mcp_session_id" gorm:"column:mcp_session_id"`
+	KBID           string          `json:"kb_id"`
+	RemoteIP       string          `json:"remote_ip"`
+	InitializeReq  json.RawMessage `json:"initialize_req" gorm:"type:jsonb"`
+	InitializeResp json.RawMessage `json:"initialize_resp" gorm:"type:jsonb"`
+	ToolCallReq    json.RawMessage `json:"tool_call_req" gorm:"type:jsonb"`
+	ToolCallResp   string          `json:"tool_call_resp"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+func (MCPCall) TableName() string {
+	return "mcp_calls"
+}
+
+// MCPRequestInfo carries the share request info into mcp tool handlers and hooks
+type MCPRequestInfo struct {
+	KBID     string
+	RemoteIP string
+	AuthID   uint
+}
+
+const CtxMCPRequestInfoKey contextKey = "ctx_mcp_request_info"
+
+func GetMCPRequestInfoFromCtx(c context.Context) *MCPRequestInfo {
+	info, ok := c.Value(CtxMCPRequestInfoKey).(*MCPRequestInfo)
+	if !ok {
+		return nil
+	}
+	return info
+}
+
+type MCPNodeDetail struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Type      NodeType  `json:"type"`
+	ParentID  string    `json:"parent_id"`
+	Summary   string    `json:"summary"`
+	Content   string    `json:"content"`
+	URL       string    `json:"url,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type MCPSearchResult struct {
+	NodeID        string   `json:"node_id"`
+	Name          string   `json:"name"`
+	Summary       string   `json:"summary"`
+	NodePathNames []string `json:"node_path_names"`
+	URL           string   `json:"url,omitempty"`
+}
@@ -3,6 +3,7 @@ package domain
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -143,6 +144,147 @@ func (p *ModelParam) Scan(value interface{}) error {
 	}
 }
 
+// ChatModelSetting pins the chat model of a knowledge base or an app, unset fields fall back to the upper level:
+// app -> knowledge base -> global chat model
+type ChatModelSetting struct {
+	Provider   ModelProvider `json:"provider"`
+	Model      string        `json:"model"` // 为空时沿用上一级的模型，仅覆盖参数
+	BaseURL    string        `json:"base_url"`
+	APIKey     string        `json:"api_key"`
+	APIHeader  string        `json:"api_header"`
+	APIVersion string        `json:"api_version"`
+	Parameters *ModelParam   `json:"parameters"` // 为空时沿用模型自身的参数
+}
+
+// ChatModelAPIKeyMask 接口返回的 API Key，更新时原样传回表示不修改
+const ChatModelAPIKeyMask = "******"
+
+// Masked returns a copy for responses, the api key is never returned
+func (s *ChatModelSetting) Masked() *ChatModelSetting {
+	if s == nil {
+		return nil
+	}
+	masked := *s
+	if masked.APIKey != "" {
+		masked.APIKey = ChatModelAPIKeyMask
+	}
+	return &masked
+}
+
+// KeepAPIKey restores the stored api key when the masked value is sent back,
+// the key is only kept for the same provider and base url so that it can not be sent to another endpoint
+func (s *ChatModelSetting) KeepAPIKey(stored *ChatModelSetting) error {
+	if s == nil || s.APIKey != ChatModelAPIKeyMask {
+		return nil
+	}
+	if stored == nil || stored.APIKey == "" || stored.Provider != s.Provider || stored.BaseURL != s.BaseURL {
+		return ErrChatModelAPIKeyRequired
+	}
+	s.APIKey = stored.APIKey
+	return nil
+}
+
+func (s *ChatModelSetting) IsEmpty() bool {
+	return s == nil || (s.Model == "" && s.Parameters == nil)
+}
+
+// Apply returns the model with the setting applied on base, base may be nil when no upper level model is configured
+func (s *ChatModelSetting) Apply(base *Model) *Model {
+	if s.IsEmpty() {
+		return base
+	}
+	var model Model
+	if s.Model != "" {
+		model = Model{
+			Provider:   s.Provider,
+			Model:      s.Model,
+			BaseURL:    s.BaseURL,
+			APIKey:     s.APIKey,
+			APIHeader:  s.APIHeader,
+			APIVersion: s.APIVersion,
+			Type:       ModelTypeChat,
+			IsActive:   true,
+		}
+	} else if base != nil {
+		model = *base
+	} else {
+		return nil
+	}
+	if s.Parameters != nil {
+		model.Parameters = *s.Parameters
+	}
+	return &model
+}
+
+func (s *ChatModelSetting) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid chat model setting value type:", value))
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+func (s ChatModelSetting) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// ModelFallback is a backup model of the same type, tried in ascending priority when the primary model fails
+type ModelFallback struct {
+	ID         string        `json:"id"`
+	Type       ModelType     `json:"type"`
+	Priority   int           `json:"priority"`
+	Provider   ModelProvider `json:"provider"`
+	Model      string        `json:"model"`
+	APIKey     string        `json:"api_key"`
+	APIHeader  string        `json:"api_header"`
+	BaseURL    string        `json:"base_url"`
+	APIVersion string        `json:"api_version"`
+	Parameters ModelParam    `json:"parameters" gorm:"column:parameters;type:jsonb"`
+
+	PromptTokens     uint64 `json:"prompt_tokens" gorm:"default:0"`
+	CompletionTokens uint64 `json:"completion_tokens" gorm:"default:0"`
+	TotalTokens      uint64 `json:"total_tokens" gorm:"default:0"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (f *ModelFallback) ToModel() *Model {
+	return &Model{
+		ID:         f.ID,
+		Provider:   f.Provider,
+		Model:      f.Model,
+		APIKey:     f.APIKey,
+		APIHeader:  f.APIHeader,
+		BaseURL:    f.BaseURL,
+		APIVersion: f.APIVersion,
+		Type:       f.Type,
+		IsActive:   true,
+		Parameters: f.Parameters,
+	}
+}
+
+type GetModelFallbackListReq struct {
+	Type ModelType `json:"type" query:"type" validate:"required,oneof=chat embedding rerank analysis analysis-vl"`
+}
+
+type CreateModelFallbackReq struct {
+	BaseModelInfo
+	Priority   int         `json:"priority"`
+	Parameters *ModelParam `json:"parameters"`
+}
+
+type UpdateModelFallbackReq struct {
+	ID string `json:"id" validate:"required"`
+	BaseModelInfo
+	Priority   int         `json:"priority"`
+	Parameters *ModelParam `json:"parameters"`
+}
+
+type DeleteModelFallbackReq struct {
+	ID string `json:"id" query:"id" validate:"required"`
+}
+
 type BaseModelInfo struct {
 	Provider   ModelProvider `json:"provider" validate:"required"`
 	Model      string        `json:"model" validate:"required"`
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatModelSettingAPIKey(t *testing.T) {
+	stored := &ChatModelSetting{Provider: ModelProvider("OpenAI"), Model: "gpt-4o", BaseURL: "https://api.openai.com/v1", APIKey: "sk-secret"}
+	masked := stored.Masked()
+	assert.Equal(t, ChatModelAPIKeyMask, masked.APIKey)
+	assert.Equal(t, "sk-secret", stored.APIKey)
+
+	assert.NoError(t, masked.KeepAPIKey(stored))
+	assert.Equal(t, "sk-secret", masked.APIKey)
+
+	moved := stored.Masked()
+	moved.BaseURL = "https://attacker.example.com"
+	assert.ErrorIs(t, moved.KeepAPIKey(stored), ErrChatModelAPIKeyRequired)
+
+	var empty *ChatModelSetting
+	assert.Nil(t, empty.Masked())
+	assert.NoError(t, empty.KeepAPIKey(stored))
+}
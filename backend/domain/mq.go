@@ -4,12 +4,17 @@ const (
 	VectorTaskTopic       = "apps.panda-wiki.vector.task"
 	AnydocTaskExportTopic = "anydoc.persistence.doc.task.export"
 	RagDocUpdateTopic     = "raglite.events.doc.update"
+	WebhookEventTopic     = "apps.panda-wiki.webhook.event"
+	// 多次重试仍失败的向量任务
+	VectorTaskDeadLetterTopic = "apps.panda-wiki.vector.dead_letter"
 )
 
 var TopicConsumerName = map[string]string{
-	VectorTaskTopic:       "panda-wiki-vector-consumer",
-	AnydocTaskExportTopic: "anydoc-task-export-consumer",
-	RagDocUpdateTopic:     "raglite-doc-update-consumer",
+	VectorTaskTopic:           "panda-wiki-vector-consumer",
+	AnydocTaskExportTopic:     "anydoc-task-export-consumer",
+	RagDocUpdateTopic:         "raglite-doc-update-consumer",
+	WebhookEventTopic:         "panda-wiki-webhook-consumer",
+	VectorTaskDeadLetterTopic: "panda-wiki-vector-dead-letter-consumer",
 }
 
 type NodeReleaseVectorRequest struct {
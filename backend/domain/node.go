@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/lib/pq"
+	"gorm.io/gorm"
 
 	"github.com/chaitin/panda-wiki/consts"
 )
@@ -56,6 +57,11 @@ type Node struct {
 	Permissions NodePermissions `json:"permissions" gorm:"type:jsonb"`
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
+
+	// recycle bin, deleted_root_id is the node the user deleted, shared by its whole subtree
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+	DeletedBy     string         `json:"-"`
+	DeletedRootID string         `json:"-"`
 }
 
 func (Node) TableName() string {
@@ -138,6 +144,7 @@ func (d *NodeMeta) Scan(value any) error {
 }
 
 type CreateNodeReq struct {
+	ID       string   `json:"-"` // 指定节点 id，为空时生成
 	KBID     string   `json:"kb_id" validate:"required"`
 	ParentID string   `json:"parent_id"`
 	Type     NodeType `json:"type" validate:"required,oneof=1 2"`
@@ -185,9 +192,10 @@ type NodeContentChunk struct {
 	KBID  string `json:"kb_id"`
 	DocID string `json:"doc_id"`
 
-	Seq     uint   `json:"seq"`
-	Name    string `json:"name"`
-	Content string `json:"content"`
+	Seq     uint    `json:"seq"`
+	Name    string  `json:"name"`
+	Content string  `json:"content"`
+	Score   float64 `json:"score,omitempty"` // 检索得分，向量检索为相似度，关键词检索为 ts_rank
 }
 
 type RankedNodeChunks struct {
@@ -2,8 +2,13 @@ package domain
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+	"github.com/samber/lo"
 )
 
 // OpenAI API 请求结构体
@@ -94,6 +99,9 @@ func NewArrayContent(parts []OpenAIContentPart) *MessageContent {
 
 // String 获取文本内容
 func (mc *MessageContent) String() string {
+	if mc == nil {
+		return ""
+	}
 	if mc.isString {
 		return mc.strValue
 	}
@@ -130,6 +138,7 @@ type OpenAIFunction struct {
 }
 
 type OpenAIToolCall struct {
+	Index    *int               `json:"index,omitempty"` // 仅用于流式响应
 	ID       string             `json:"id" validate:"required"`
 	Type     string             `json:"type" validate:"required"`
 	Function OpenAIFunctionCall `json:"function" validate:"required"`
@@ -140,17 +149,53 @@ type OpenAIFunctionCall struct {
 	Arguments string `json:"arguments" validate:"required"`
 }
 
+// OpenAIToolChoice 支持 "none"/"auto"/"required" 字符串或指定函数的对象，字符串解析到 Type
 type OpenAIToolChoice struct {
 	Type     string                `json:"type,omitempty"`
 	Function *OpenAIFunctionChoice `json:"function,omitempty"`
 }
 
+func (tc *OpenAIToolChoice) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		tc.Type = str
+		return nil
+	}
+	type toolChoice OpenAIToolChoice
+	var obj toolChoice
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("tool_choice must be string or object")
+	}
+	*tc = OpenAIToolChoice(obj)
+	return nil
+}
+
 type OpenAIFunctionChoice struct {
 	Name string `json:"name" validate:"required"`
 }
 
 type OpenAIResponseFormat struct {
-	Type string `json:"type" validate:"required"`
+	Type       string            `json:"type" validate:"required"` // text, json_object, json_schema
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type OpenAIJSONSchema struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      bool            `json:"strict,omitempty"`
+}
+
+// Prompt 返回要求模型按格式输出的提示词，模型接口的 response_format 参数各厂商支持不一，统一通过提示词约束
+func (f *OpenAIResponseFormat) Prompt() string {
+	if f == nil || (f.Type != "json_object" && f.Type != "json_schema") {
+		return ""
+	}
+	prompt := "\n\n请只输出一个合法的 JSON 对象，不要输出任何其他内容，也不要使用代码块包裹。"
+	if f.Type == "json_schema" && f.JSONSchema != nil && len(f.JSONSchema.Schema) > 0 {
+		prompt += fmt.Sprintf("JSON 对象需要符合以下 JSON Schema：\n%s", f.JSONSchema.Schema)
+	}
+	return prompt
 }
 
 // OpenAI API 响应结构体
@@ -192,6 +237,63 @@ type OpenAIStreamChoice struct {
 	FinishReason *string       `json:"finish_reason,omitempty"`
 }
 
+// OpenAIModel 知识库作为虚拟模型，ID 为知识库 ID
+type OpenAIModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+	Name    string `json:"name,omitempty"`
+}
+
+type OpenAIModelList struct {
+	Object string         `json:"object"`
+	Data   []*OpenAIModel `json:"data"`
+}
+
+// OpenAIEmbeddingsRequest 模型为知识库 ID，向量由系统配置的向量模型生成
+type OpenAIEmbeddingsRequest struct {
+	Model          string               `json:"model" validate:"required"`
+	Input          OpenAIEmbeddingInput `json:"input" validate:"required,max=2048"`
+	EncodingFormat string               `json:"encoding_format,omitempty" validate:"omitempty,oneof=float base64"`
+	User           string               `json:"user,omitempty"`
+}
+
+// OpenAIEmbeddingInput 支持字符串或字符串数组
+type OpenAIEmbeddingInput []string
+
+func (in *OpenAIEmbeddingInput) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*in = OpenAIEmbeddingInput{str}
+		return nil
+	}
+	var arr []string
+	if err := json.Unmarshal(data, &arr); err != nil {
+		return fmt.Errorf("input must be string or array of strings")
+	}
+	*in = arr
+	return nil
+}
+
+type OpenAIEmbeddingsResponse struct {
+	Object string                `json:"object"`
+	Data   []*OpenAIEmbedding    `json:"data"`
+	Model  string                `json:"model"`
+	Usage  *OpenAIEmbeddingUsage `json:"usage"`
+}
+
+type OpenAIEmbedding struct {
+	Object    string `json:"object"`
+	Index     int    `json:"index"`
+	Embedding any    `json:"embedding"` // float 为 []float32，base64 为小端 float32 的 base64 字符串
+}
+
+type OpenAIEmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
 // OpenAI 错误响应结构体
 type OpenAIErrorResponse struct {
 	Error OpenAIError `json:"error"`
@@ -203,3 +305,130 @@ type OpenAIError struct {
 	Code    string `json:"code,omitempty"`
 	Param   string `json:"param,omitempty"`
 }
+
+// ToChatRequest maps the messages and tools of the request onto a chat request.
+// The last user message is the question, the user and assistant messages before it are the history,
+// the assistant tool calls and tool results after it are passed to the model as is.
+// System messages and the response format are appended to the system prompt of the kb.
+func (r *OpenAICompletionsRequest) ToChatRequest() (*ChatRequest, error) {
+	last := -1
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == "user" {
+			last = i
+			break
+		}
+	}
+	if last < 0 || r.Messages[last].Content.String() == "" {
+		return nil, errors.New("no user message found")
+	}
+	req := &ChatRequest{
+		Message:         r.Messages[last].Content.String(),
+		AppType:         AppTypeOpenAIAPI,
+		SkipSuggestions: true,
+	}
+
+	extraPrompt := strings.Builder{}
+	for i, msg := range r.Messages {
+		content := msg.Content.String()
+		switch {
+		case msg.Role == "system" || msg.Role == "developer":
+			if content != "" {
+				extraPrompt.WriteString("\n\n" + content)
+			}
+		case i < last && (msg.Role == "user" || msg.Role == "assistant"):
+			// 历史中的工具调用过程不再提供给模型，只保留文本
+			if content != "" {
+				req.History = append(req.History, &ConversationMessage{Role: schema.RoleType(msg.Role), Content: content})
+			}
+		case i > last && msg.Role == "assistant":
+			toolCalls := make([]schema.ToolCall, 0, len(msg.ToolCalls))
+			for _, toolCall := range msg.ToolCalls {
+				toolCalls = append(toolCalls, schema.ToolCall{
+					ID:       toolCall.ID,
+					Type:     toolCall.Type,
+					Function: schema.FunctionCall{Name: toolCall.Function.Name, Arguments: toolCall.Function.Arguments},
+				})
+			}
+			req.ToolMessages = append(req.ToolMessages, schema.AssistantMessage(content, toolCalls))
+		case i > last && msg.Role == "tool":
+			req.ToolMessages = append(req.ToolMessages, schema.ToolMessage(content, msg.ToolCallID))
+		}
+	}
+	extraPrompt.WriteString(r.ResponseFormat.Prompt())
+	req.ExtraPrompt = extraPrompt.String()
+
+	tools := make([]*schema.ToolInfo, 0, len(r.Tools))
+	for _, tool := range r.Tools {
+		info, err := tool.ToToolInfo()
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, info)
+	}
+	if r.ToolChoice != nil {
+		switch r.ToolChoice.Type {
+		case "", "auto":
+		case "none":
+			req.ToolChoice = schema.ToolChoiceForbidden
+		case "required":
+			req.ToolChoice = schema.ToolChoiceForced
+		case "function":
+			// 指定函数时只提供该函数并要求模型调用
+			if r.ToolChoice.Function == nil {
+				return nil, errors.New("tool_choice.function is required")
+			}
+			tools = lo.Filter(tools, func(tool *schema.ToolInfo, _ int) bool { return tool.Name == r.ToolChoice.Function.Name })
+			if len(tools) == 0 {
+				return nil, fmt.Errorf("tool %s not found", r.ToolChoice.Function.Name)
+			}
+			req.ToolChoice = schema.ToolChoiceForced
+		default:
+			return nil, fmt.Errorf("unsupported tool_choice %s", r.ToolChoice.Type)
+		}
+	}
+	if len(tools) > 0 {
+		req.Tools = tools
+	}
+	return req, nil
+}
+
+// ToToolInfo converts the function tool for the chat model, empty parameters mean no arguments
+func (t OpenAITool) ToToolInfo() (*schema.ToolInfo, error) {
+	if t.Type != "function" || t.Function == nil || t.Function.Name == "" {
+		return nil, fmt.Errorf("unsupported tool type %s", t.Type)
+	}
+	parameters := map[string]any{"type": "object", "properties": map[string]any{}}
+	if len(t.Function.Parameters) > 0 {
+		parameters = t.Function.Parameters
+	}
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters of tool %s: %w", t.Function.Name, err)
+	}
+	var params jsonschema.Schema
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters of tool %s: %w", t.Function.Name, err)
+	}
+	return &schema.ToolInfo{
+		Name:        t.Function.Name,
+		Desc:        t.Function.Description,
+		ParamsOneOf: schema.NewParamsOneOfByJSONSchema(&params),
+	}, nil
+}
+
+// NewOpenAIToolCalls converts the tool calls of the model, streamed tool calls carry their index
+func NewOpenAIToolCalls(toolCalls []schema.ToolCall, stream bool) []OpenAIToolCall {
+	result := make([]OpenAIToolCall, 0, len(toolCalls))
+	for i, toolCall := range toolCalls {
+		call := OpenAIToolCall{
+			ID:       toolCall.ID,
+			Type:     "function",
+			Function: OpenAIFunctionCall{Name: toolCall.Function.Name, Arguments: toolCall.Function.Arguments},
+		}
+		if stream {
+			call.Index = lo.ToPtr(i)
+		}
+		result = append(result, call)
+	}
+	return result
+}
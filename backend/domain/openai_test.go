@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/cloudwego/eino/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -184,3 +185,59 @@ func TestMessageContent_String_NoTextParts(t *testing.T) {
 	})
 	assert.Equal(t, "", mc.String())
 }
+
+func TestOpenAICompletionsRequest_ToChatRequest(t *testing.T) {
+	var req OpenAICompletionsRequest
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"model": "kb",
+		"messages": [
+			{"role": "system", "content": "answer briefly"},
+			{"role": "user", "content": "what is sla"},
+			{"role": "assistant", "content": "sla is ..."},
+			{"role": "user", "content": [{"type": "text", "text": "weather in"}, {"type": "text", "text": "beijing"}]},
+			{"role": "assistant", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "weather", "arguments": "{\"city\":\"beijing\"}"}}]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "sunny"}
+		],
+		"tools": [
+			{"type": "function", "function": {"name": "weather", "parameters": {"type": "object", "properties": {"city": {"type": "string"}}}}},
+			{"type": "function", "function": {"name": "time"}}
+		],
+		"tool_choice": {"type": "function", "function": {"name": "weather"}},
+		"response_format": {"type": "json_object"}
+	}`), &req))
+
+	chatReq, err := req.ToChatRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "weather in beijing", chatReq.Message)
+	assert.True(t, chatReq.SkipSuggestions)
+	require.Len(t, chatReq.History, 2)
+	assert.Equal(t, schema.Assistant, chatReq.History[1].Role)
+	require.Len(t, chatReq.ToolMessages, 2)
+	assert.Equal(t, "weather", chatReq.ToolMessages[0].ToolCalls[0].Function.Name)
+	assert.Equal(t, "call_1", chatReq.ToolMessages[1].ToolCallID)
+	require.Len(t, chatReq.Tools, 1)
+	assert.Equal(t, schema.ToolChoiceForced, chatReq.ToolChoice)
+	assert.Contains(t, chatReq.ExtraPrompt, "answer briefly")
+	assert.Contains(t, chatReq.ExtraPrompt, "JSON")
+
+	require.NoError(t, json.Unmarshal([]byte(`"none"`), &req.ToolChoice))
+	chatReq, err = req.ToChatRequest()
+	require.NoError(t, err)
+	assert.Len(t, chatReq.Tools, 2)
+	assert.Equal(t, schema.ToolChoiceForbidden, chatReq.ToolChoice)
+
+	req.Messages = []OpenAIMessage{{Role: "system", Content: NewStringContent("hi")}}
+	_, err = req.ToChatRequest()
+	assert.Error(t, err)
+}
+
+func TestOpenAIEmbeddingInput_UnmarshalJSON(t *testing.T) {
+	var req OpenAIEmbeddingsRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"model":"kb","input":"hello"}`), &req))
+	assert.Equal(t, OpenAIEmbeddingInput{"hello"}, req.Input)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"model":"kb","input":["a","b"]}`), &req))
+	assert.Equal(t, OpenAIEmbeddingInput{"a", "b"}, req.Input)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"model":"kb","input":[1,2]}`), &req))
+}
@@ -1,8 +1,14 @@
 package domain
 
+import "github.com/cloudwego/eino/schema"
+
 type SSEEvent struct {
-	Type        string               `json:"type"`
-	Content     string               `json:"content"`
-	ChunkResult *NodeContentChunkSSE `json:"chunk_result,omitempty"`
-	Error       string               `json:"error,omitempty"`
+	Type        string                   `json:"type"`
+	Content     string                   `json:"content"`
+	ChunkResult *NodeContentChunkSSE     `json:"chunk_result,omitempty"`
+	Citations   []*ConversationReference `json:"citations,omitempty"`   // type 为 citations 时回答中引用的文档
+	ToolTrace   *AgentToolTrace          `json:"tool_trace,omitempty"`  // type 为 tool_call 或 tool_result 时的工具调用
+	Suggestions []string                 `json:"suggestions,omitempty"` // type 为 suggestions 时推荐的追问
+	ToolCalls   []schema.ToolCall        `json:"tool_calls,omitempty"`  // type 为 tool_calls 时需要调用方执行的工具
+	Error       string                   `json:"error,omitempty"`
 }
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	VectorTaskMaxAttempts   = 5
+	VectorTaskRetryBaseWait = 10 * time.Second
+)
+
+// VectorTaskRetryDelay returns the wait before the next delivery after the given number of failed attempts
+func VectorTaskRetryDelay(attempts int) time.Duration {
+	attempts = min(max(attempts, 1), VectorTaskMaxAttempts)
+	return VectorTaskRetryBaseWait << (attempts - 1)
+}
+
+type VectorTaskDeadLetterStatus string
+
+const (
+	VectorTaskDeadLetterStatusPending  VectorTaskDeadLetterStatus = "pending"
+	VectorTaskDeadLetterStatusReplayed VectorTaskDeadLetterStatus = "replayed"
+)
+
+// table: vector_task_dead_letters, the vector tasks that still failed after the last retry
+type VectorTaskDeadLetter struct {
+	ID            string                     `json:"id" gorm:"primaryKey"`
+	KBID          string                     `json:"kb_id"`
+	Action        string                     `json:"action"`
+	NodeID        string                     `json:"node_id"`
+	NodeReleaseID string                     `json:"node_release_id"`
+	DocID         string                     `json:"doc_id"`
+	Payload       json.RawMessage            `json:"payload" gorm:"type:jsonb"` // 原始任务，重放时原样投递
+	Error         string                     `json:"error"`
+	Attempts      int                        `json:"attempts"`
+	Status        VectorTaskDeadLetterStatus `json:"status"`
+	ReplayedAt    *time.Time                 `json:"replayed_at"`
+	CreatedAt     time.Time                  `json:"created_at"`
+}
+
+// NewVectorTaskDeadLetter builds the dead letter of the raw task, the request may be nil when the payload is malformed
+func NewVectorTaskDeadLetter(payload []byte, request *NodeReleaseVectorRequest, reason string, attempts int) *VectorTaskDeadLetter {
+	deadLetter := &VectorTaskDeadLetter{
+		ID:        uuid.New().String(),
+		Payload:   payload,
+		Error:     reason,
+		Attempts:  attempts,
+		Status:    VectorTaskDeadLetterStatusPending,
+		CreatedAt: time.Now(),
+	}
+	if !json.Valid(payload) {
+		// jsonb 只接受合法的 json，非法的任务按字符串保存
+		deadLetter.Payload, _ = json.Marshal(string(payload))
+	}
+	if request != nil {
+		deadLetter.KBID = request.KBID
+		deadLetter.Action = request.Action
+		deadLetter.NodeID = request.NodeID
+		deadLetter.NodeReleaseID = request.NodeReleaseID
+		deadLetter.DocID = request.DocID
+	}
+	return deadLetter
+}
+
+// table: vector_task_stats, one row per hour and action
+type VectorTaskStat struct {
+	Hour         time.Time `json:"hour" gorm:"primaryKey"`
+	Action       string    `json:"action" gorm:"primaryKey"`
+	Succeeded    int64     `json:"succeeded"`
+	Retried      int64     `json:"retried"`       // 失败后等待重试的次数
+	DeadLettered int64     `json:"dead_lettered"` // 重试耗尽进入死信的任务数
+}
+
+type VectorTaskOutcome string
+
+const (
+	VectorTaskOutcomeSucceeded    VectorTaskOutcome = "succeeded"
+	VectorTaskOutcomeRetried      VectorTaskOutcome = "retried"
+	VectorTaskOutcomeDeadLettered VectorTaskOutcome = "dead_lettered"
+)
+
+// VectorTaskQueueStat 向量任务队列积压
+type VectorTaskQueueStat struct {
+	Pending     uint64 `json:"pending"`     // 尚未投递
+	AckPending  int    `json:"ack_pending"` // 处理中或等待重试
+	Redelivered int    `json:"redelivered"` // 等待重试中的重投递消息
+}
+
+type VectorTaskMetrics struct {
+	Queue        *VectorTaskQueueStat `json:"queue"`        // 队列不可用时为空
+	DeadLetters  int64                `json:"dead_letters"` // 待处理的死信数
+	Succeeded    int64                `json:"succeeded"`
+	Retried      int64                `json:"retried"`
+	DeadLettered int64                `json:"dead_lettered"`
+	FailureRate  float64              `json:"failure_rate"` // 进入死信的任务占已完成任务的比例
+	RetryRate    float64              `json:"retry_rate"`   // 失败重试占全部处理次数的比例
+	Stats        []*VectorTaskStat    `json:"stats"`
+}
+
+// NewVectorTaskMetrics sums up the hourly stats
+func NewVectorTaskMetrics(queue *VectorTaskQueueStat, deadLetters int64, stats []*VectorTaskStat) *VectorTaskMetrics {
+	metrics := &VectorTaskMetrics{
+		Queue:       queue,
+		DeadLetters: deadLetters,
+		Stats:       stats,
+	}
+	for _, stat := range stats {
+		metrics.Succeeded += stat.Succeeded
+		metrics.Retried += stat.Retried
+		metrics.DeadLettered += stat.DeadLettered
+	}
+	if finished := metrics.Succeeded + metrics.DeadLettered; finished > 0 {
+		metrics.FailureRate = float64(metrics.DeadLettered) / float64(finished)
+	}
+	if attempts := metrics.Succeeded + metrics.Retried + metrics.DeadLettered; attempts > 0 {
+		metrics.RetryRate = float64(metrics.Retried) / float64(attempts)
+	}
+	return metrics
+}
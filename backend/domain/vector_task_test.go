@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVectorTask(t *testing.T) {
+	assert.Equal(t, 10*time.Second, VectorTaskRetryDelay(1))
+	assert.Equal(t, 80*time.Second, VectorTaskRetryDelay(4))
+	assert.Equal(t, VectorTaskRetryDelay(VectorTaskMaxAttempts), VectorTaskRetryDelay(100))
+
+	deadLetter := NewVectorTaskDeadLetter([]byte(`{"kb_id":"kb","action":"upsert"}`), &NodeReleaseVectorRequest{KBID: "kb", Action: "upsert", NodeReleaseID: "release"}, "raglite is down", VectorTaskMaxAttempts)
+	assert.JSONEq(t, `{"kb_id":"kb","action":"upsert"}`, string(deadLetter.Payload))
+	assert.Equal(t, "release", deadLetter.NodeReleaseID)
+	assert.Equal(t, VectorTaskDeadLetterStatusPending, deadLetter.Status)
+
+	deadLetter = NewVectorTaskDeadLetter([]byte(`not json`), nil, "invalid character", 1)
+	assert.Equal(t, `"not json"`, string(deadLetter.Payload))
+
+	metrics := NewVectorTaskMetrics(nil, 1, []*VectorTaskStat{
+		{Action: "upsert", Succeeded: 6, Retried: 4, DeadLettered: 1},
+		{Action: "delete", Succeeded: 3, Retried: 1},
+	})
+	assert.Equal(t, int64(9), metrics.Succeeded)
+	assert.Equal(t, 0.1, metrics.FailureRate)
+	assert.InDelta(t, 5.0/15.0, metrics.RetryRate, 1e-9)
+	assert.Zero(t, NewVectorTaskMetrics(nil, 0, nil).FailureRate)
+}
@@ -0,0 +1,185 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type WebhookEventType string
+
+const (
+	WebhookEventNodePublished     WebhookEventType = "node.published"
+	WebhookEventNodeDeleted       WebhookEventType = "node.deleted"
+	WebhookEventKBReleaseCreated  WebhookEventType = "kb_release.created"
+	WebhookEventCommentCreated    WebhookEventType = "comment.created"
+	WebhookEventCommentModerated  WebhookEventType = "comment.moderated"
+	WebhookEventFeedbackSubmitted WebhookEventType = "feedback.submitted"
+	WebhookEventRAGIndexFailed    WebhookEventType = "rag.index_failed"
+	WebhookEventTest              WebhookEventType = "webhook.test" // 仅用于发送测试事件，不能订阅
+)
+
+// WebhookEventTypes 可以订阅的事件
+var WebhookEventTypes = []WebhookEventType{
+	WebhookEventNodePublished,
+	WebhookEventNodeDeleted,
+	WebhookEventKBReleaseCreated,
+	WebhookEventCommentCreated,
+	WebhookEventCommentModerated,
+	WebhookEventFeedbackSubmitted,
+	WebhookEventRAGIndexFailed,
+}
+
+const (
+	WebhookHeaderEvent     = "X-PandaWiki-Event"
+	WebhookHeaderDelivery  = "X-PandaWiki-Delivery"
+	WebhookHeaderTimestamp = "X-PandaWiki-Timestamp"
+	WebhookHeaderSignature = "X-PandaWiki-Signature"
+)
+
+const (
+	WebhookMaxAttempts   = 6                // 首次投递加 5 次重试
+	WebhookRetryBaseWait = 30 * time.Second // 第 n 次失败后等待 30s * 2^(n-1)
+	WebhookTimeout       = 10 * time.Second
+)
+
+// table: webhooks
+type Webhook struct {
+	ID        string         `json:"id" gorm:"primaryKey"`
+	KBID      string         `json:"kb_id" gorm:"index"`
+	Name      string         `json:"name"`
+	URL       string         `json:"url"`
+	Secret    string         `json:"secret"` // 用于签名请求体，接收方用它校验 X-PandaWiki-Signature
+	Events    pq.StringArray `json:"events" gorm:"type:text[]"`
+	Enabled   bool           `json:"enabled"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (w *Webhook) Subscribed(eventType WebhookEventType) bool {
+	return w.Enabled && slices.Contains(w.Events, string(eventType))
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending WebhookDeliveryStatus = "pending" // 等待首次投递或重试
+	WebhookDeliveryStatusSuccess WebhookDeliveryStatus = "success"
+	WebhookDeliveryStatusFailed  WebhookDeliveryStatus = "failed" // 重试次数用尽
+)
+
+// table: webhook_deliveries
+type WebhookDelivery struct {
+	ID             string                `json:"id" gorm:"primaryKey"`
+	WebhookID      string                `json:"webhook_id" gorm:"index"`
+	KBID           string                `json:"kb_id"`
+	EventID        string                `json:"event_id"`
+	EventType      WebhookEventType      `json:"event_type"`
+	Payload        json.RawMessage       `json:"payload" gorm:"type:jsonb"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextRetryAt    *time.Time            `json:"next_retry_at"`
+	ResponseStatus int                   `json:"response_status"`
+	ResponseBody   string                `json:"response_body"` // 截断后的响应体
+	Error          string                `json:"error"`
+	DurationMs     int64                 `json:"duration_ms"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// WebhookEvent 通过 mq 传递给 consumer 的事件，也是投递给订阅方的请求体
+type WebhookEvent struct {
+	ID        string           `json:"id"`
+	Type      WebhookEventType `json:"type"`
+	KBID      string           `json:"kb_id"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      json.RawMessage  `json:"data"`
+}
+
+func NewWebhookEvent(kbID string, eventType WebhookEventType, data any) (*WebhookEvent, error) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		KBID:      kbID,
+		CreatedAt: time.Now(),
+		Data:      dataBytes,
+	}, nil
+}
+
+// SignWebhookPayload returns the value of X-PandaWiki-Signature: sha256=hex(hmac_sha256(secret, "<timestamp>.<body>"))
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(fmt.Appendf(nil, "%d.", timestamp))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookRetryDelay returns the wait before the next attempt after the given number of failed attempts
+func WebhookRetryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return WebhookRetryBaseWait << (attempts - 1)
+}
+
+type WebhookNodePublishedData struct {
+	ReleaseID string   `json:"release_id"`
+	NodeIDs   []string `json:"node_ids"`
+}
+
+type WebhookNodeDeletedData struct {
+	NodeIDs []string `json:"node_ids"`
+}
+
+type WebhookKBReleaseCreatedData struct {
+	ReleaseID   string   `json:"release_id"`
+	Tag         string   `json:"tag"`
+	Message     string   `json:"message"`
+	PublisherID string   `json:"publisher_id"`
+	NodeIDs     []string `json:"node_ids"`
+}
+
+type WebhookCommentCreatedData struct {
+	CommentID string        `json:"comment_id"`
+	NodeID    string        `json:"node_id"`
+	ParentID  string        `json:"parent_id"`
+	UserName  string        `json:"user_name"`
+	Content   string        `json:"content"`
+	Status    CommentStatus `json:"status"`
+}
+
+type WebhookCommentModeratedData struct {
+	CommentIDs []string      `json:"comment_ids"`
+	Status     CommentStatus `json:"status"`
+}
+
+type WebhookFeedbackSubmittedData struct {
+	ConversationID string       `json:"conversation_id"`
+	MessageID      string       `json:"message_id"`
+	Score          ScoreType    `json:"score"`
+	Type           FeedbackType `json:"type"`
+	Content        string       `json:"content"`
+}
+
+type WebhookRAGIndexFailedData struct {
+	NodeID        string `json:"node_id"`
+	NodeReleaseID string `json:"node_release_id"`
+	NodeName      string `json:"node_name"`
+	Error         string `json:"error"`
+}
+
+type WebhookTestData struct {
+	WebhookID string `json:"webhook_id"`
+	Message   string `json:"message"`
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook(t *testing.T) {
+	assert.Equal(t,
+		"sha256=11bf4466ea17c3df3fd743af0b435368e16b7a05eb8eced85e8c4670767bdec5",
+		SignWebhookPayload("whsec_test", 1700000000, []byte(`{"id":"1"}`)))
+
+	assert.Equal(t, 30*time.Second, WebhookRetryDelay(1))
+	assert.Equal(t, 2*time.Minute, WebhookRetryDelay(3))
+	assert.Equal(t, 8*time.Minute, WebhookRetryDelay(WebhookMaxAttempts-1))
+
+	webhook := &Webhook{Enabled: true, Events: pq.StringArray{string(WebhookEventNodePublished)}}
+	assert.True(t, webhook.Subscribed(WebhookEventNodePublished))
+	assert.False(t, webhook.Subscribed(WebhookEventNodeDeleted))
+	webhook.Enabled = false
+	assert.False(t, webhook.Subscribed(WebhookEventNodePublished))
+}
@@ -6,25 +6,33 @@ import (
 
 	"github.com/robfig/cron/v3"
 
+	"github.com/chaitin/panda-wiki/config"
 	"github.com/chaitin/panda-wiki/log"
 	"github.com/chaitin/panda-wiki/repo/pg"
 	"github.com/chaitin/panda-wiki/usecase"
 )
 
 type CronHandler struct {
-	logger      *log.Logger
-	statRepo    *pg.StatRepository
-	statUseCase *usecase.StatUseCase
-	nodeUseCase *usecase.NodeUsecase
+	config            *config.Config
+	logger            *log.Logger
+	statRepo          *pg.StatRepository
+	statUseCase       *usecase.StatUseCase
+	nodeUseCase       *usecase.NodeUsecase
+	webhookUseCase    *usecase.WebhookUsecase
+	vectorTaskUseCase *usecase.VectorTaskUsecase
 }
 
-func NewStatCronHandler(logger *log.Logger, statRepo *pg.StatRepository, statUseCase *usecase.StatUseCase, nodeUseCase *usecase.NodeUsecase) (*CronHandler, error) {
+func NewStatCronHandler(config *config.Config, logger *log.Logger, statRepo *pg.StatRepository, statUseCase *usecase.StatUseCase, nodeUseCase *usecase.NodeUsecase, webhookUseCase *usecase.WebhookUsecase, vectorTaskUseCase *usecase.VectorTaskUsecase) (*CronHandler, error) {
 	h := &CronHandler{
-		statRepo:    statRepo,
-		statUseCase: statUseCase,
-		nodeUseCase: nodeUseCase,
-		logger:      logger.WithModule("handler.mq.cron"),
+		config:            config,
+		statRepo:          statRepo,
+		statUseCase:       statUseCase,
+		nodeUseCase:       nodeUseCase,
+		webhookUseCase:    webhookUseCase,
+		vectorTaskUseCase: vectorTaskUseCase,
+		logger:            logger.WithModule("handler.mq.cron"),
 	}
+	retryWebhookJob := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(cron.FuncJob(h.RetryWebhookDeliveries))
 	cron := cron.New()
 
 	// 每小时 */10 分执行聚合统计数据任务
@@ -60,6 +68,36 @@ func NewStatCronHandler(logger *log.Logger, statRepo *pg.StatRepository, statUse
 	}
 	h.logger.Info("add cron job", log.String("cron_id", "sync_rag_node_status"))
 
+	// 默认每天3点15分清理回收站中过期的文档
+	if config.Trash.RetentionDays > 0 && config.Trash.PurgeCron != "" {
+		if _, err := cron.AddFunc(config.Trash.PurgeCron, h.PurgeExpiredTrash); err != nil {
+			h.logger.Error("failed to add cron job for purging expired trash", log.Error(err))
+			return nil, err
+		}
+		h.logger.Info("add cron job", log.String("cron_id", "purge_expired_trash"), log.Int("retention_days", config.Trash.RetentionDays))
+	}
+
+	// 每分钟重试到期的 webhook 投递，上一轮未结束时跳过本轮
+	if _, err := cron.AddJob("* * * * *", retryWebhookJob); err != nil {
+		h.logger.Error("failed to add cron job for retrying webhook deliveries", log.Error(err))
+		return nil, err
+	}
+	h.logger.Info("add cron job", log.String("cron_id", "retry_webhook_deliveries"))
+
+	// 每天4点清理过期的 webhook 投递日志
+	if _, err := cron.AddFunc("5 4 * * *", h.CleanupWebhookDeliveries); err != nil {
+		h.logger.Error("failed to add cron job for cleaning up webhook deliveries", log.Error(err))
+		return nil, err
+	}
+	h.logger.Info("add cron job", log.String("cron_id", "cleanup_webhook_deliveries"))
+
+	// 每天4点清理已重放的向量任务死信和过期的统计
+	if _, err := cron.AddFunc("15 4 * * *", h.CleanupVectorTasks); err != nil {
+		h.logger.Error("failed to add cron job for cleaning up vector tasks", log.Error(err))
+		return nil, err
+	}
+	h.logger.Info("add cron job", log.String("cron_id", "cleanup_vector_tasks"))
+
 	cron.Start()
 	h.logger.Info("start cron jobs")
 	return h, nil
@@ -113,3 +151,39 @@ func (h *CronHandler) SyncRagNodeStatus() {
 	}
 	h.logger.Info("sync rag node status successful")
 }
+
+func (h *CronHandler) PurgeExpiredTrash() {
+	h.logger.Info("purge expired trash start")
+	retention := time.Duration(h.config.Trash.RetentionDays) * 24 * time.Hour
+	if err := h.nodeUseCase.PurgeExpiredTrash(context.Background(), retention); err != nil {
+		h.logger.Error("purge expired trash failed", log.Error(err))
+		return
+	}
+	h.logger.Info("purge expired trash successful")
+}
+
+func (h *CronHandler) RetryWebhookDeliveries() {
+	if err := h.webhookUseCase.RetryDueDeliveries(context.Background()); err != nil {
+		h.logger.Error("retry webhook deliveries failed", log.Error(err))
+	}
+}
+
+func (h *CronHandler) CleanupWebhookDeliveries() {
+	h.logger.Info("cleanup webhook deliveries start")
+	deleted, err := h.webhookUseCase.CleanupDeliveries(context.Background())
+	if err != nil {
+		h.logger.Error("cleanup webhook deliveries failed", log.Error(err))
+		return
+	}
+	h.logger.Info("cleanup webhook deliveries successful", log.Int64("deleted", deleted))
+}
+
+func (h *CronHandler) CleanupVectorTasks() {
+	h.logger.Info("cleanup vector tasks start")
+	deadLetters, stats, err := h.vectorTaskUseCase.Cleanup(context.Background())
+	if err != nil {
+		h.logger.Error("cleanup vector tasks failed", log.Error(err))
+		return
+	}
+	h.logger.Info("cleanup vector tasks successful", log.Int64("dead_letters", deadLetters), log.Int64("stats", stats))
+}
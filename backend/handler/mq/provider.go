@@ -12,9 +12,11 @@ import (
 )
 
 type MQHandlers struct {
-	RAGMQHandler        *RAGMQHandler
-	RagDocUpdateHandler *RagDocUpdateHandler
-	StatCronHandler     *CronHandler
+	RAGMQHandler              *RAGMQHandler
+	RagDocUpdateHandler       *RagDocUpdateHandler
+	StatCronHandler           *CronHandler
+	WebhookMQHandler          *WebhookMQHandler
+	VectorDeadLetterMQHandler *VectorDeadLetterMQHandler
 }
 
 var ProviderSet = wire.NewSet(
@@ -28,10 +30,14 @@ var ProviderSet = wire.NewSet(
 	usecase.NewStatUseCase,
 	usecase.NewNodeUsecase,
 	usecase.NewModelUsecase,
+	usecase.NewWebhookUsecase,
+	usecase.NewVectorTaskUsecase,
 
 	NewRAGMQHandler,
 	NewRagDocUpdateHandler,
 	NewStatCronHandler,
+	NewWebhookMQHandler,
+	NewVectorDeadLetterMQHandler,
 
 	wire.Struct(new(MQHandlers), "*"),
 )
@@ -3,36 +3,48 @@ package mq
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
 
 	"github.com/chaitin/panda-wiki/consts"
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
 	"github.com/chaitin/panda-wiki/mq"
 	"github.com/chaitin/panda-wiki/mq/types"
+	mqRepo "github.com/chaitin/panda-wiki/repo/mq"
 	"github.com/chaitin/panda-wiki/repo/pg"
 	"github.com/chaitin/panda-wiki/store/rag"
 	"github.com/chaitin/panda-wiki/usecase"
 )
 
 type RAGMQHandler struct {
-	consumer     mq.MQConsumer
-	logger       *log.Logger
-	rag          rag.RAGService
-	nodeRepo     *pg.NodeRepository
-	kbRepo       *pg.KnowledgeBaseRepository
-	llmUsecase   *usecase.LLMUsecase
-	modelUsecase *usecase.ModelUsecase
+	consumer       mq.MQConsumer
+	logger         *log.Logger
+	rag            rag.RAGService
+	nodeRepo       *pg.NodeRepository
+	kbRepo         *pg.KnowledgeBaseRepository
+	llmUsecase     *usecase.LLMUsecase
+	modelUsecase   *usecase.ModelUsecase
+	webhookRepo    *mqRepo.WebhookRepository
+	ragRepo        *mqRepo.RAGRepository
+	vectorTaskRepo *pg.VectorTaskRepository
 }
 
-func NewRAGMQHandler(consumer mq.MQConsumer, logger *log.Logger, rag rag.RAGService, nodeRepo *pg.NodeRepository, kbRepo *pg.KnowledgeBaseRepository, llmUsecase *usecase.LLMUsecase, modelUsecase *usecase.ModelUsecase) (*RAGMQHandler, error) {
+func NewRAGMQHandler(consumer mq.MQConsumer, logger *log.Logger, rag rag.RAGService, nodeRepo *pg.NodeRepository, kbRepo *pg.KnowledgeBaseRepository, llmUsecase *usecase.LLMUsecase, modelUsecase *usecase.ModelUsecase, webhookRepo *mqRepo.WebhookRepository, ragRepo *mqRepo.RAGRepository, vectorTaskRepo *pg.VectorTaskRepository) (*RAGMQHandler, error) {
 	h := &RAGMQHandler{
-		consumer:     consumer,
-		logger:       logger.WithModule("mq.rag"),
-		rag:          rag,
-		nodeRepo:     nodeRepo,
-		kbRepo:       kbRepo,
-		llmUsecase:   llmUsecase,
-		modelUsecase: modelUsecase,
+		consumer:       consumer,
+		logger:         logger.WithModule("mq.rag"),
+		rag:            rag,
+		nodeRepo:       nodeRepo,
+		kbRepo:         kbRepo,
+		llmUsecase:     llmUsecase,
+		modelUsecase:   modelUsecase,
+		webhookRepo:    webhookRepo,
+		ragRepo:        ragRepo,
+		vectorTaskRepo: vectorTaskRepo,
 	}
 	if err := consumer.RegisterHandler(domain.VectorTaskTopic, h.HandleNodeContentVectorRequest); err != nil {
 		return nil, err
@@ -40,24 +52,41 @@ func NewRAGMQHandler(consumer mq.MQConsumer, logger *log.Logger, rag rag.RAGServ
 	return h, nil
 }
 
+// HandleNodeContentVectorRequest retries a failed task with backoff and sends it to the dead letter topic after the last attempt
 func (h *RAGMQHandler) HandleNodeContentVectorRequest(ctx context.Context, msg types.Message) error {
 	var request domain.NodeReleaseVectorRequest
-	err := json.Unmarshal(msg.GetData(), &request)
-	if err != nil {
+	if err := json.Unmarshal(msg.GetData(), &request); err != nil {
 		h.logger.Error("unmarshal node content vector request failed", log.Error(err))
+		return h.deadLetter(ctx, msg, nil, err)
+	}
+	err := h.handleVectorRequest(ctx, &request)
+	if err == nil {
+		h.addStat(ctx, request.Action, domain.VectorTaskOutcomeSucceeded)
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 文档或知识库已被删除，重试没有意义
+		h.logger.Warn("vector task target not found, skip", log.Any("request", request), log.Error(err))
 		return nil
 	}
+	attempts := msg.GetNumDelivered()
+	if attempts < domain.VectorTaskMaxAttempts {
+		h.addStat(ctx, request.Action, domain.VectorTaskOutcomeRetried)
+		return types.NewRetryError(err, domain.VectorTaskRetryDelay(attempts))
+	}
+	return h.deadLetter(ctx, msg, &request, err)
+}
+
+func (h *RAGMQHandler) handleVectorRequest(ctx context.Context, request *domain.NodeReleaseVectorRequest) error {
 	switch request.Action {
 	case "update_group_ids":
 		h.logger.Info("update node group request", log.Any("request", request), log.Any("group_id", request.GroupIds))
 		kb, err := h.kbRepo.GetKnowledgeBaseByID(ctx, request.KBID)
 		if err != nil {
-			h.logger.Error("get kb failed", log.Error(err))
-			return nil
+			return fmt.Errorf("get kb failed: %w", err)
 		}
 		if err := h.rag.UpdateDocumentGroupIDs(ctx, kb.DatasetID, request.DocID, request.GroupIds); err != nil {
-			h.logger.Error("update node group failed", log.Error(err))
-			return nil
+			return fmt.Errorf("update node group failed: %w", err)
 		}
 		h.logger.Info("update node group success", log.Any("doc_id", request.DocID), log.Any("group_ids", request.GroupIds))
 
@@ -65,8 +94,7 @@ func (h *RAGMQHandler) HandleNodeContentVectorRequest(ctx context.Context, msg t
 		h.logger.Debug("upsert node content vector request", "request", request)
 		nodeRelease, err := h.nodeRepo.GetNodeReleaseWithDirPathByID(ctx, request.NodeReleaseID)
 		if err != nil {
-			h.logger.Error("get node content by ids failed", log.Error(err))
-			return nil
+			return fmt.Errorf("get node release failed: %w", err)
 		}
 		if nodeRelease.Type == domain.NodeTypeFolder {
 			h.logger.Info("node is folder, skip upsert", log.Any("node_release_id", request.NodeReleaseID))
@@ -74,14 +102,12 @@ func (h *RAGMQHandler) HandleNodeContentVectorRequest(ctx context.Context, msg t
 		}
 		kb, err := h.kbRepo.GetKnowledgeBaseByID(ctx, request.KBID)
 		if err != nil {
-			h.logger.Error("get kb failed", log.Error(err), log.String("kb_id", request.KBID))
-			return nil
+			return fmt.Errorf("get kb failed: %w", err)
 		}
 
 		groupIds, err := h.nodeRepo.GetNodeAuthGroupIdsByNodeId(ctx, nodeRelease.NodeID, consts.NodePermNameAnswerable)
 		if err != nil {
-			h.logger.Error("get groupIds failed", log.Error(err), log.String("kb_id", request.KBID))
-			return nil
+			return fmt.Errorf("get groupIds failed: %w", err)
 		}
 
 		// upsert node content chunks
@@ -94,26 +120,22 @@ func (h *RAGMQHandler) HandleNodeContentVectorRequest(ctx context.Context, msg t
 			GroupIDs:  groupIds,
 		})
 		if err != nil {
-			h.logger.Error("upsert node content vector failed", log.Error(err))
-			return nil
+			return fmt.Errorf("upsert node content vector failed: %w", err)
 		}
 		// update node doc_id
 		if err := h.nodeRepo.UpdateNodeReleaseDocID(ctx, request.NodeReleaseID, docID); err != nil {
-			h.logger.Error("update node doc_id failed", log.String("node_id", request.NodeReleaseID), log.Error(err))
-			return nil
+			return fmt.Errorf("update node doc_id failed: %w", err)
 		}
 		// delete old RAG records
 		// get old doc_ids by node_id
 		oldDocIDs, err := h.nodeRepo.GetOldNodeDocIDsByNodeID(ctx, nodeRelease.ID, nodeRelease.NodeID)
 		if err != nil {
-			h.logger.Error("get old doc_ids by node_id failed", log.String("node_id", nodeRelease.NodeID), log.Error(err))
-			return nil
+			return fmt.Errorf("get old doc_ids by node_id failed: %w", err)
 		}
 		if len(oldDocIDs) > 0 {
 			// delete old RAG records
 			if err := h.rag.DeleteRecords(ctx, kb.DatasetID, oldDocIDs); err != nil {
-				h.logger.Error("delete old RAG records failed", log.String("kb_id", kb.ID), log.Error(err))
-				return nil
+				return fmt.Errorf("delete old RAG records failed: %w", err)
 			}
 		}
 
@@ -122,45 +144,38 @@ func (h *RAGMQHandler) HandleNodeContentVectorRequest(ctx context.Context, msg t
 		h.logger.Info("delete node content vector request", log.Any("request", request))
 		kb, err := h.kbRepo.GetKnowledgeBaseByID(ctx, request.KBID)
 		if err != nil {
-			h.logger.Error("get kb failed", log.Error(err))
-			return nil
+			return fmt.Errorf("get kb failed: %w", err)
 		}
 		if err := h.rag.DeleteRecords(ctx, kb.DatasetID, []string{request.DocID}); err != nil {
-			h.logger.Error("delete node content vector failed", log.Error(err))
-			return nil
+			return fmt.Errorf("delete node content vector failed: %w", err)
 		}
 		h.logger.Info("delete node content vector success", log.Any("deleted_id", request.NodeReleaseID), log.Any("deleted_doc_id", request.DocID))
 	case "summary":
 		h.logger.Info("summary node content vector request", log.Any("request", request))
 		node, err := h.nodeRepo.GetNodeByID(ctx, request.NodeID)
 		if err != nil {
-			h.logger.Error("get node by id failed", log.Error(err))
-			return nil
+			return fmt.Errorf("get node by id failed: %w", err)
 		}
 		if node.Type == domain.NodeTypeFolder {
 			h.logger.Info("node is folder, skip summary", log.Any("node_id", request.NodeID))
 			return nil
 		}
 
-		model, err := h.modelUsecase.GetChatModel(ctx)
+		model, err := h.modelUsecase.GetKBChatModel(ctx, request.KBID, nil)
 		if err != nil {
-			h.logger.Error("get chat model failed", log.Error(err))
-			return nil
+			return fmt.Errorf("get chat model failed: %w", err)
 		}
 
 		summary, err := h.llmUsecase.SummaryNode(ctx, request.KBID, model, node.Name, node.Content)
 		if err != nil {
-			h.logger.Error("summary node content failed", log.Error(err))
-			return nil
+			return fmt.Errorf("summary node content failed: %w", err)
 		}
 		if err := h.nodeRepo.UpdateNodeSummary(ctx, request.KBID, request.NodeID, summary); err != nil {
-			h.logger.Error("update node summary failed", log.Error(err))
-			return nil
+			return fmt.Errorf("update node summary failed: %w", err)
 		}
 		if node.Status == domain.NodeStatusReleased {
 			if err := h.nodeRepo.UpdateNodeStatus(ctx, request.KBID, request.NodeID, domain.NodeStatusDraft); err != nil {
-				h.logger.Error("update node status failed", log.Error(err))
-				return nil
+				return fmt.Errorf("update node status failed: %w", err)
 			}
 		}
 
@@ -169,3 +184,57 @@ func (h *RAGMQHandler) HandleNodeContentVectorRequest(ctx context.Context, msg t
 
 	return nil
 }
+
+// deadLetter sends the task to the dead letter topic, the request is nil when the payload is malformed
+func (h *RAGMQHandler) deadLetter(ctx context.Context, msg types.Message, request *domain.NodeReleaseVectorRequest, cause error) error {
+	deadLetter := domain.NewVectorTaskDeadLetter(msg.GetData(), request, cause.Error(), msg.GetNumDelivered())
+	if err := h.ragRepo.PublishVectorTaskDeadLetter(ctx, deadLetter); err != nil {
+		// 死信发送失败时保留原任务，稍后再处理
+		return types.NewRetryError(fmt.Errorf("publish dead letter failed: %w", err), domain.VectorTaskRetryDelay(domain.VectorTaskMaxAttempts))
+	}
+	h.logger.Error("vector task failed after retries, dead lettered",
+		log.String("dead_letter_id", deadLetter.ID),
+		log.Int("attempts", deadLetter.Attempts),
+		log.Error(cause))
+	h.addStat(ctx, deadLetter.Action, domain.VectorTaskOutcomeDeadLettered)
+	if request != nil && request.Action == "upsert" {
+		h.markIndexFailed(ctx, request, cause.Error())
+	}
+	return nil
+}
+
+func (h *RAGMQHandler) addStat(ctx context.Context, action string, outcome domain.VectorTaskOutcome) {
+	if err := h.vectorTaskRepo.AddStat(ctx, time.Now().Truncate(time.Hour), action, outcome); err != nil {
+		h.logger.Warn("add vector task stat failed", log.String("action", action), log.Error(err))
+	}
+}
+
+// markIndexFailed shows the failure on the node so that it does not look like still learning
+func (h *RAGMQHandler) markIndexFailed(ctx context.Context, request *domain.NodeReleaseVectorRequest, reason string) {
+	nodeRelease, err := h.nodeRepo.GetNodeReleaseWithDirPathByID(ctx, request.NodeReleaseID)
+	if err != nil {
+		h.logger.Warn("get node release for index failure failed", log.String("node_release_id", request.NodeReleaseID), log.Error(err))
+		return
+	}
+	if err := h.nodeRepo.Update(ctx, nodeRelease.NodeID, map[string]any{
+		"rag_info": domain.RagInfo{
+			Status:   consts.NodeRagStatusFailed,
+			Message:  reason,
+			SyncedAt: time.Now(),
+		},
+	}); err != nil {
+		h.logger.Warn("update node rag info failed", log.String("node_id", nodeRelease.NodeID), log.Error(err))
+	}
+	h.publishIndexFailed(ctx, request.KBID, nodeRelease, reason)
+}
+
+func (h *RAGMQHandler) publishIndexFailed(ctx context.Context, kbID string, nodeRelease *domain.NodeReleaseWithDirPath, reason string) {
+	if err := h.webhookRepo.AsyncPublishEvent(ctx, kbID, domain.WebhookEventRAGIndexFailed, &domain.WebhookRAGIndexFailedData{
+		NodeID:        nodeRelease.NodeID,
+		NodeReleaseID: nodeRelease.ID,
+		NodeName:      nodeRelease.Name,
+		Error:         reason,
+	}); err != nil {
+		h.logger.Warn("publish rag index failed event failed", log.String("node_id", nodeRelease.NodeID), log.Error(err))
+	}
+}
@@ -10,20 +10,23 @@ import (
 	"github.com/chaitin/panda-wiki/log"
 	"github.com/chaitin/panda-wiki/mq"
 	"github.com/chaitin/panda-wiki/mq/types"
+	mqRepo "github.com/chaitin/panda-wiki/repo/mq"
 	"github.com/chaitin/panda-wiki/repo/pg"
 )
 
 type RagDocUpdateHandler struct {
-	consumer mq.MQConsumer
-	logger   *log.Logger
-	nodeRepo *pg.NodeRepository
+	consumer    mq.MQConsumer
+	logger      *log.Logger
+	nodeRepo    *pg.NodeRepository
+	webhookRepo *mqRepo.WebhookRepository
 }
 
-func NewRagDocUpdateHandler(consumer mq.MQConsumer, logger *log.Logger, nodeRepo *pg.NodeRepository) (*RagDocUpdateHandler, error) {
+func NewRagDocUpdateHandler(consumer mq.MQConsumer, logger *log.Logger, nodeRepo *pg.NodeRepository, webhookRepo *mqRepo.WebhookRepository) (*RagDocUpdateHandler, error) {
 	h := &RagDocUpdateHandler{
-		consumer: consumer,
-		logger:   logger.WithModule("mq.rag_doc_update"),
-		nodeRepo: nodeRepo,
+		consumer:    consumer,
+		logger:      logger.WithModule("mq.rag_doc_update"),
+		nodeRepo:    nodeRepo,
+		webhookRepo: webhookRepo,
 	}
 	if err := consumer.RegisterHandler(domain.RagDocUpdateTopic, h.HandleRagDocUpdate); err != nil {
 		return nil, err
@@ -61,7 +64,25 @@ func (h *RagDocUpdateHandler) HandleRagDocUpdate(ctx context.Context, msg types.
 	}); err != nil {
 		return err
 	}
+	if consts.NodeRagInfoStatus(event.Status) == consts.NodeRagStatusFailed {
+		h.publishIndexFailed(ctx, nodeId, event)
+	}
 
 	h.logger.Debug("node rag update success", log.String("doc_id", event.ID))
 	return nil
 }
+
+func (h *RagDocUpdateHandler) publishIndexFailed(ctx context.Context, nodeId string, event domain.RagDocInfoUpdateEvent) {
+	node, err := h.nodeRepo.GetNodeByID(ctx, nodeId)
+	if err != nil {
+		h.logger.Warn("get node for rag index failed event failed", log.String("node_id", nodeId), log.Error(err))
+		return
+	}
+	if err := h.webhookRepo.AsyncPublishEvent(ctx, node.KBID, domain.WebhookEventRAGIndexFailed, &domain.WebhookRAGIndexFailedData{
+		NodeID:   node.ID,
+		NodeName: node.Name,
+		Error:    event.Message,
+	}); err != nil {
+		h.logger.Warn("publish rag index failed event failed", log.String("node_id", nodeId), log.Error(err))
+	}
+}
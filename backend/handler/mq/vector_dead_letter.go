@@ -0,0 +1,50 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/mq"
+	"github.com/chaitin/panda-wiki/mq/types"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+// VectorDeadLetterMQHandler keeps the dead lettered vector tasks for the admin to inspect and replay
+type VectorDeadLetterMQHandler struct {
+	consumer mq.MQConsumer
+	logger   *log.Logger
+	usecase  *usecase.VectorTaskUsecase
+}
+
+func NewVectorDeadLetterMQHandler(consumer mq.MQConsumer, logger *log.Logger, usecase *usecase.VectorTaskUsecase) (*VectorDeadLetterMQHandler, error) {
+	h := &VectorDeadLetterMQHandler{
+		consumer: consumer,
+		logger:   logger.WithModule("mq.vector_dead_letter"),
+		usecase:  usecase,
+	}
+	if err := consumer.RegisterHandler(domain.VectorTaskDeadLetterTopic, h.HandleVectorTaskDeadLetter); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *VectorDeadLetterMQHandler) HandleVectorTaskDeadLetter(ctx context.Context, msg types.Message) error {
+	var deadLetter domain.VectorTaskDeadLetter
+	if err := json.Unmarshal(msg.GetData(), &deadLetter); err != nil {
+		h.logger.Error("unmarshal vector task dead letter failed", log.Error(err))
+		return nil
+	}
+	if err := h.usecase.SaveDeadLetter(ctx, &deadLetter); err != nil {
+		h.logger.Error("save vector task dead letter failed", log.String("id", deadLetter.ID), log.Error(err))
+		return types.NewRetryError(err, domain.VectorTaskRetryDelay(msg.GetNumDelivered()))
+	}
+	h.logger.Warn("vector task dead lettered",
+		log.String("id", deadLetter.ID),
+		log.String("kb_id", deadLetter.KBID),
+		log.String("action", deadLetter.Action),
+		log.Int("attempts", deadLetter.Attempts),
+		log.String("error", deadLetter.Error))
+	return nil
+}
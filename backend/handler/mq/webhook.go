@@ -0,0 +1,43 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/mq"
+	"github.com/chaitin/panda-wiki/mq/types"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type WebhookMQHandler struct {
+	consumer mq.MQConsumer
+	logger   *log.Logger
+	usecase  *usecase.WebhookUsecase
+}
+
+func NewWebhookMQHandler(consumer mq.MQConsumer, logger *log.Logger, usecase *usecase.WebhookUsecase) (*WebhookMQHandler, error) {
+	h := &WebhookMQHandler{
+		consumer: consumer,
+		logger:   logger.WithModule("mq.webhook"),
+		usecase:  usecase,
+	}
+	if err := consumer.RegisterHandler(domain.WebhookEventTopic, h.HandleWebhookEvent); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *WebhookMQHandler) HandleWebhookEvent(ctx context.Context, msg types.Message) error {
+	var event domain.WebhookEvent
+	if err := json.Unmarshal(msg.GetData(), &event); err != nil {
+		h.logger.Error("unmarshal webhook event failed", log.Error(err))
+		return nil
+	}
+	if err := h.usecase.HandleEvent(ctx, &event); err != nil {
+		h.logger.Error("handle webhook event failed", log.String("event_id", event.ID), log.String("event", string(event.Type)), log.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package share
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+const (
+	anthropicMaxImages    = 3
+	anthropicMaxImageSize = 5 << 20
+)
+
+// ShareAnthropicHandler serves the Anthropic Messages compatible api with the keys of the api app,
+// the model is the knowledge base id like the OpenAI compatible api
+type ShareAnthropicHandler struct {
+	*handler.BaseHandler
+	logger              *log.Logger
+	appUsecase          *usecase.AppUsecase
+	chatUsecase         *usecase.ChatUsecase
+	conversationUsecase *usecase.ConversationUsecase
+	fileUsecase         *usecase.FileUsecase
+}
+
+func NewShareAnthropicHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	appUsecase *usecase.AppUsecase,
+	chatUsecase *usecase.ChatUsecase,
+	conversationUsecase *usecase.ConversationUsecase,
+	fileUsecase *usecase.FileUsecase,
+) *ShareAnthropicHandler {
+	h := &ShareAnthropicHandler{
+		BaseHandler:         baseHandler,
+		logger:              logger.WithModule("handler.share.anthropic"),
+		appUsecase:          appUsecase,
+		chatUsecase:         chatUsecase,
+		conversationUsecase: conversationUsecase,
+		fileUsecase:         fileUsecase,
+	}
+
+	e.POST("/v1/messages", h.Messages, apiCORS)
+
+	return h
+}
+
+// Messages Anthropic Messages API compatible endpoint
+//
+//	@Summary		Messages
+//	@Description	Anthropic Messages API compatible endpoint, the model is the knowledge base id
+//	@Tags			share_anthropic
+//	@Accept			json
+//	@Produce		json
+//	@Param			x-api-key	header		string							true	"api key"
+//	@Param			request		body		domain.AnthropicMessagesRequest	true	"Anthropic Messages API request"
+//	@Success		200			{object}	domain.AnthropicMessagesResponse
+//	@Failure		400			{object}	domain.AnthropicErrorResponse
+//	@Router			/v1/messages [post]
+func (h *ShareAnthropicHandler) Messages(c echo.Context) error {
+	var req domain.AnthropicMessagesRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Error("parse Anthropic request failed", log.Error(err))
+		return sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", "parse request failed")
+	}
+	if err := c.Validate(&req); err != nil {
+		h.logger.Error("validate Anthropic request failed", log.Error(err))
+		return sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", "validate request failed")
+	}
+	ctx := c.Request().Context()
+
+	kbs, err := h.appUsecase.GetOpenAIAPIKnowledgeBases(ctx, c.Request().Header.Get("X-KB-ID"), apiKey(c))
+	if err != nil {
+		if errors.Is(err, domain.ErrOpenAIAPIKeyInvalid) {
+			return sendAnthropicError(c, http.StatusUnauthorized, "authentication_error", "invalid x-api-key")
+		}
+		h.logger.Error("get api knowledge bases failed", log.Error(err))
+		return sendAnthropicError(c, http.StatusInternalServerError, "api_error", "get knowledge base failed")
+	}
+	kb := selectKnowledgeBase(kbs, req.Model)
+	if kb == nil {
+		return sendAnthropicError(c, http.StatusNotFound, "not_found_error", fmt.Sprintf("model: %s", req.Model))
+	}
+
+	chatReq, images, err := req.ToChatRequest()
+	if err != nil {
+		return sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+	}
+	if len(images) > anthropicMaxImages {
+		return sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("at most %d images are supported", anthropicMaxImages))
+	}
+	for _, image := range images {
+		path, err := h.uploadImage(c, kb.ID, image)
+		if err != nil {
+			return sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		}
+		chatReq.ImagePaths = append(chatReq.ImagePaths, path)
+	}
+	chatReq.KBID = kb.ID
+	chatReq.RemoteIP = c.RealIP()
+
+	eventCh, err := h.chatUsecase.Chat(ctx, chatReq)
+	if err != nil {
+		return sendAnthropicError(c, http.StatusInternalServerError, "api_error", err.Error())
+	}
+	if req.Stream {
+		return h.streamMessages(c, eventCh, kb.ID, req.Model)
+	}
+	return h.messages(c, eventCh, kb.ID, req.Model)
+}
+
+// uploadImage stores a base64 image as a static file, url images must be static files uploaded before
+func (h *ShareAnthropicHandler) uploadImage(c echo.Context, kbID string, image *domain.AnthropicImageSource) (string, error) {
+	switch image.Type {
+	case "url":
+		// 与分享问答一致只接受本站静态文件，外部地址会随对话记录在管理后台渲染
+		if !strings.HasPrefix(image.URL, "/static-file/") {
+			return "", fmt.Errorf("invalid image url, only /static-file/ paths are supported")
+		}
+		return image.URL, nil
+	case "base64":
+		ext, ok := domain.AnthropicImageMediaTypes[image.MediaType]
+		if !ok {
+			return "", fmt.Errorf("unsupported image media type %s", image.MediaType)
+		}
+		data, err := base64.StdEncoding.DecodeString(image.Data)
+		if err != nil {
+			return "", fmt.Errorf("invalid base64 image data")
+		}
+		if len(data) > anthropicMaxImageSize {
+			return "", fmt.Errorf("image exceeds %d MB", anthropicMaxImageSize>>20)
+		}
+		key, err := h.fileUsecase.UploadFileFromBytes(c.Request().Context(), kbID, "image"+ext, data)
+		if err != nil {
+			h.logger.Error("upload image failed", log.Error(err))
+			return "", fmt.Errorf("upload image failed")
+		}
+		return "/static-file/" + key, nil
+	}
+	return "", fmt.Errorf("unsupported image source type %s", image.Type)
+}
+
+func (h *ShareAnthropicHandler) messages(c echo.Context, eventCh <-chan domain.SSEEvent, kbID, model string) error {
+	var (
+		content   strings.Builder
+		messageID string
+	)
+	for event := range eventCh {
+		switch event.Type {
+		case "error":
+			return sendAnthropicError(c, http.StatusInternalServerError, "api_error", event.Content)
+		case "message_id":
+			messageID = event.Content
+		case "data":
+			content.WriteString(event.Content)
+		case "done":
+			resp := newAnthropicMessage(messageID, model)
+			resp.Content = []domain.AnthropicContentBlock{{Type: "text", Text: content.String()}}
+			resp.StopReason = lo.ToPtr("end_turn")
+			resp.Usage = h.getUsage(c, kbID, messageID)
+			return c.JSON(http.StatusOK, resp)
+		}
+	}
+	return sendAnthropicError(c, http.StatusInternalServerError, "api_error", "chat ended unexpectedly")
+}
+
+// streamMessages writes the answer as the Anthropic event sequence, the text is one content block.
+// Tokens are only known after the answer so message_start reports zero usage and message_delta the total.
+func (h *ShareAnthropicHandler) streamMessages(c echo.Context, eventCh <-chan domain.SSEEvent, kbID, model string) error {
+	var messageID string
+	index := lo.ToPtr(0)
+	write := func(event domain.AnthropicStreamEvent) error {
+		if !c.Response().Committed {
+			c.Response().Header().Set("Content-Type", "text/event-stream")
+			c.Response().Header().Set("Cache-Control", "no-cache")
+			c.Response().Header().Set("Connection", "keep-alive")
+			c.Response().WriteHeader(http.StatusOK)
+		}
+		return writeAnthropicStreamEvent(c, event)
+	}
+
+	for event := range eventCh {
+		var events []domain.AnthropicStreamEvent
+		switch event.Type {
+		case "error":
+			if !c.Response().Committed {
+				return sendAnthropicError(c, http.StatusInternalServerError, "api_error", event.Content)
+			}
+			return write(domain.AnthropicStreamEvent{Type: "error", Error: &domain.AnthropicError{Type: "api_error", Message: event.Content}})
+		case "message_id":
+			messageID = event.Content
+			events = []domain.AnthropicStreamEvent{
+				{Type: "message_start", Message: newAnthropicMessage(messageID, model)},
+				{Type: "content_block_start", Index: index, ContentBlock: &domain.AnthropicContentBlock{Type: "text", Text: ""}},
+				{Type: "ping"},
+			}
+		case "data":
+			events = []domain.AnthropicStreamEvent{
+				{Type: "content_block_delta", Index: index, Delta: domain.AnthropicTextDelta{Type: "text_delta", Text: event.Content}},
+			}
+		case "done":
+			usage := h.getUsage(c, kbID, messageID)
+			events = []domain.AnthropicStreamEvent{
+				{Type: "content_block_stop", Index: index},
+				{Type: "message_delta", Delta: domain.AnthropicMessageDelta{StopReason: lo.ToPtr("end_turn")}, Usage: &usage},
+				{Type: "message_stop"},
+			}
+		}
+		for _, e := range events {
+			if err := write(e); err != nil {
+				return err
+			}
+		}
+		if event.Type == "done" {
+			return nil
+		}
+	}
+	return nil
+}
+
+// getUsage returns the tokens recorded on the answer, zero when the message can not be found
+func (h *ShareAnthropicHandler) getUsage(c echo.Context, kbID, messageID string) domain.AnthropicUsage {
+	message, err := h.conversationUsecase.GetMessageDetail(c.Request().Context(), kbID, messageID)
+	if err != nil {
+		h.logger.Warn("get answer usage failed", log.String("message_id", messageID), log.Error(err))
+		return domain.AnthropicUsage{}
+	}
+	return domain.AnthropicUsage{
+		InputTokens:  message.PromptTokens,
+		OutputTokens: message.CompletionTokens,
+	}
+}
+
+func newAnthropicMessage(messageID, model string) *domain.AnthropicMessagesResponse {
+	return &domain.AnthropicMessagesResponse{
+		ID:      "msg_" + messageID,
+		Type:    "message",
+		Role:    "assistant",
+		Model:   model,
+		Content: []domain.AnthropicContentBlock{},
+	}
+}
+
+func sendAnthropicError(c echo.Context, status int, errorType, message string) error {
+	return c.JSON(status, domain.AnthropicErrorResponse{
+		Type: "error",
+		Error: domain.AnthropicError{
+			Type:    errorType,
+			Message: message,
+		},
+	})
+}
+
+func writeAnthropicStreamEvent(c echo.Context, event domain.AnthropicStreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.Response(), "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	c.Response().Flush()
+	return nil
+}
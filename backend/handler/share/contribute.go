@@ -0,0 +1,112 @@
+package share
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/share/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type ShareContributeHandler struct {
+	*handler.BaseHandler
+	logger      *log.Logger
+	usecase     *usecase.ContributeUsecase
+	nodeUsecase *usecase.NodeUsecase
+	app         *usecase.AppUsecase
+}
+
+func NewShareContributeHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.ContributeUsecase,
+	nodeUsecase *usecase.NodeUsecase,
+	app *usecase.AppUsecase,
+) *ShareContributeHandler {
+	h := &ShareContributeHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.share.contribute"),
+		usecase:     usecase,
+		nodeUsecase: nodeUsecase,
+		app:         app,
+	}
+
+	share := e.Group("share/v1/contribute",
+		func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+				c.Response().Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+				c.Response().Header().Set("Access-Control-Allow-Headers", "Content-Type, Origin, Accept")
+				if c.Request().Method == "OPTIONS" {
+					return c.NoContent(http.StatusOK)
+				}
+				return next(c)
+			}
+		}, h.ShareAuthMiddleware.Authorize)
+
+	share.POST("", h.CreateContribute)
+	return h
+}
+
+// CreateContribute
+//
+//	@Summary		CreateContribute
+//	@Description	Submit an edit of a released document or a new page for review
+//	@Tags			share_contribute
+//	@Accept			json
+//	@Produce		json
+//	@Param			X-KB-ID	header		string											true	"kb id"
+//	@Param			body	body		v1.ShareContributeReq							true	"contribute"
+//	@Success		200		{object}	domain.PWResponse{data=v1.ShareContributeResp}	"ContributeID"
+//	@Router			/share/v1/contribute [post]
+func (h *ShareContributeHandler) CreateContribute(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	kbID := c.Request().Header.Get("X-KB-ID")
+	if kbID == "" {
+		return h.NewResponseWithError(c, "kb_id is required", nil)
+	}
+
+	var req v1.ShareContributeReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "bind contribute request failed", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate req failed", err)
+	}
+	// 校验是否开启了贡献
+	appInfo, err := h.app.GetAppDetailByKBIDAndAppType(ctx, kbID, domain.AppTypeWeb)
+	if err != nil {
+		return h.NewResponseWithError(c, "app info is not found", err)
+	}
+	if !appInfo.Settings.ContributeSettings.IsEnable {
+		return h.NewResponseWithError(c, "please check contribute is open", domain.ErrContributeDisabled)
+	}
+	if !h.Captcha.ValidateToken(ctx, req.CaptchaToken) {
+		return h.NewResponseWithError(c, "failed to validate captcha token", nil)
+	}
+
+	// 只能修改或在其下新建有访问权限的文档
+	authID := domain.GetAuthID(c)
+	nodeID := req.NodeId
+	if req.Type == consts.ContributeTypeAdd {
+		nodeID = req.ParentId
+	}
+	if nodeID != "" {
+		if errCode := h.nodeUsecase.ValidateNodePerm(ctx, kbID, nodeID, authID); errCode != nil {
+			return h.NewResponseWithErrCode(c, *errCode)
+		}
+	}
+
+	id, err := h.usecase.Submit(ctx, kbID, authID, c.RealIP(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "create contribute failed", err)
+	}
+	return h.NewResponseWithData(c, v1.ShareContributeResp{ID: id})
+}
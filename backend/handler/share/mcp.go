@@ -0,0 +1,94 @@
+package share
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type ShareMCPHandler struct {
+	*handler.BaseHandler
+	logger     *log.Logger
+	mcpUsecase *usecase.MCPUsecase
+}
+
+func NewShareMCPHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	mcpUsecase *usecase.MCPUsecase,
+) *ShareMCPHandler {
+	h := &ShareMCPHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.share.mcp"),
+		mcpUsecase:  mcpUsecase,
+	}
+
+	e.Any("/share/v1/mcp", h.MCP, h.ShareAuthMiddleware.CheckForbidden)
+
+	return h
+}
+
+// MCP streamable http mcp server
+//
+//	@Summary		MCP
+//	@Description	Streamable HTTP MCP server of the knowledge base
+//	@Tags			share_mcp
+//	@Accept			json
+//	@Produce		json
+//	@Param			X-KB-ID	header	string	true	"Knowledge Base ID"
+//	@Success		200
+//	@Router			/share/v1/mcp [post]
+func (h *ShareMCPHandler) MCP(c echo.Context) error {
+	ctx := c.Request().Context()
+	kbID := c.Request().Header.Get("X-KB-ID")
+
+	settings, err := h.mcpUsecase.GetMCPServerSettings(ctx, kbID)
+	if err != nil {
+		h.logger.Error("get mcp server settings failed", log.String("kb_id", kbID), log.Error(err))
+		return c.JSON(http.StatusInternalServerError, domain.PWResponse{
+			Success: false,
+			Message: "failed to get mcp server settings",
+		})
+	}
+	if !settings.IsEnabled {
+		return c.JSON(http.StatusForbidden, domain.PWResponse{
+			Success: false,
+			Message: "mcp server is not enabled",
+		})
+	}
+
+	if settings.SampleAuth.Enabled && settings.SampleAuth.Password != "" {
+		token, found := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+		if !found || token != settings.SampleAuth.Password {
+			return c.JSON(http.StatusUnauthorized, domain.PWResponse{
+				Success: false,
+				Message: "invalid authorization",
+			})
+		}
+	}
+
+	authID, err := h.mcpUsecase.GetMCPAuthID(ctx, kbID)
+	if err != nil {
+		h.logger.Error("get mcp server auth failed", log.String("kb_id", kbID), log.Error(err))
+		return c.JSON(http.StatusInternalServerError, domain.PWResponse{
+			Success: false,
+			Message: "failed to get mcp server auth",
+		})
+	}
+
+	ctx = context.WithValue(ctx, domain.CtxMCPRequestInfoKey, &domain.MCPRequestInfo{
+		KBID:     kbID,
+		RemoteIP: c.RealIP(),
+		AuthID:   authID,
+	})
+	h.mcpUsecase.GetMCPHandler(kbID, settings).ServeHTTP(c.Response(), c.Request().WithContext(ctx))
+	return nil
+}
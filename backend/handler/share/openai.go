@@ -0,0 +1,356 @@
+package share
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+// ShareOpenAIHandler serves the OpenAI compatible api, every kb the api key can access is a virtual model
+type ShareOpenAIHandler struct {
+	*handler.BaseHandler
+	logger              *log.Logger
+	appUsecase          *usecase.AppUsecase
+	chatUsecase         *usecase.ChatUsecase
+	conversationUsecase *usecase.ConversationUsecase
+}
+
+func NewShareOpenAIHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	appUsecase *usecase.AppUsecase,
+	chatUsecase *usecase.ChatUsecase,
+	conversationUsecase *usecase.ConversationUsecase,
+) *ShareOpenAIHandler {
+	h := &ShareOpenAIHandler{
+		BaseHandler:         baseHandler,
+		logger:              logger.WithModule("handler.share.openai"),
+		appUsecase:          appUsecase,
+		chatUsecase:         chatUsecase,
+		conversationUsecase: conversationUsecase,
+	}
+
+	// 兼容旧的接口地址
+	e.POST("/share/v1/chat/completions", h.ChatCompletions, apiCORS)
+
+	group := e.Group("/v1", apiCORS)
+	group.GET("/models", h.ListModels)
+	group.GET("/models/:model", h.GetModel)
+	group.POST("/chat/completions", h.ChatCompletions)
+
+	return h
+}
+
+// ListModels OpenAI API compatible model list
+//
+//	@Summary		ListModels
+//	@Description	List the knowledge bases the api key can access as models
+//	@Tags			share_openai
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer api key"
+//	@Success		200				{object}	domain.OpenAIModelList
+//	@Failure		401				{object}	domain.OpenAIErrorResponse
+//	@Router			/v1/models [get]
+func (h *ShareOpenAIHandler) ListModels(c echo.Context) error {
+	kbs, err := h.getKnowledgeBases(c)
+	if err != nil {
+		return h.sendAuthError(c, err)
+	}
+	models := make([]*domain.OpenAIModel, 0, len(kbs))
+	for _, kb := range kbs {
+		models = append(models, newOpenAIModel(kb))
+	}
+	return c.JSON(http.StatusOK, domain.OpenAIModelList{Object: "list", Data: models})
+}
+
+// GetModel OpenAI API compatible model detail
+//
+//	@Summary		GetModel
+//	@Description	Get the knowledge base of the model id
+//	@Tags			share_openai
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer api key"
+//	@Param			model			path		string	true	"knowledge base id"
+//	@Success		200				{object}	domain.OpenAIModel
+//	@Failure		404				{object}	domain.OpenAIErrorResponse
+//	@Router			/v1/models/{model} [get]
+func (h *ShareOpenAIHandler) GetModel(c echo.Context) error {
+	kbs, err := h.getKnowledgeBases(c)
+	if err != nil {
+		return h.sendAuthError(c, err)
+	}
+	for _, kb := range kbs {
+		if kb.ID == c.Param("model") {
+			return c.JSON(http.StatusOK, newOpenAIModel(kb))
+		}
+	}
+	return sendOpenAIError(c, http.StatusNotFound, fmt.Sprintf("The model `%s` does not exist", c.Param("model")), "invalid_request_error", "model_not_found")
+}
+
+// ChatCompletions OpenAI API compatible chat completions
+//
+//	@Summary		ChatCompletions
+//	@Description	OpenAI API compatible chat completions, the model is the knowledge base id.
+//	@Description	Tool calls of the tools in the request are returned to the caller to execute.
+//	@Tags			share_openai
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string							true	"Bearer api key"
+//	@Param			request			body		domain.OpenAICompletionsRequest	true	"OpenAI API request"
+//	@Success		200				{object}	domain.OpenAICompletionsResponse
+//	@Failure		400				{object}	domain.OpenAIErrorResponse
+//	@Router			/v1/chat/completions [post]
+func (h *ShareOpenAIHandler) ChatCompletions(c echo.Context) error {
+	var req domain.OpenAICompletionsRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Error("parse OpenAI request failed", log.Error(err))
+		return sendOpenAIError(c, http.StatusBadRequest, "parse request failed", "invalid_request_error", "")
+	}
+	if err := c.Validate(&req); err != nil {
+		h.logger.Error("validate OpenAI request failed", log.Error(err))
+		return sendOpenAIError(c, http.StatusBadRequest, "validate request failed", "invalid_request_error", "")
+	}
+	kbs, err := h.getKnowledgeBases(c)
+	if err != nil {
+		return h.sendAuthError(c, err)
+	}
+	kb := selectKnowledgeBase(kbs, req.Model)
+	if kb == nil {
+		return sendOpenAIError(c, http.StatusNotFound, fmt.Sprintf("The model `%s` does not exist", req.Model), "invalid_request_error", "model_not_found")
+	}
+
+	chatReq, err := req.ToChatRequest()
+	if err != nil {
+		return sendOpenAIError(c, http.StatusBadRequest, err.Error(), "invalid_request_error", "")
+	}
+	chatReq.KBID = kb.ID
+	chatReq.RemoteIP = c.RealIP()
+
+	eventCh, err := h.chatUsecase.Chat(c.Request().Context(), chatReq)
+	if err != nil {
+		return sendOpenAIError(c, http.StatusInternalServerError, err.Error(), "server_error", "")
+	}
+	if req.Stream {
+		includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+		return h.streamCompletions(c, eventCh, kb.ID, req.Model, includeUsage)
+	}
+	return h.completions(c, eventCh, kb.ID, req.Model)
+}
+
+func (h *ShareOpenAIHandler) completions(c echo.Context, eventCh <-chan domain.SSEEvent, kbID, model string) error {
+	var (
+		content   strings.Builder
+		messageID string
+		toolCalls []domain.OpenAIToolCall
+	)
+	for event := range eventCh {
+		switch event.Type {
+		case "error":
+			return sendOpenAIError(c, http.StatusInternalServerError, event.Content, "server_error", "")
+		case "message_id":
+			messageID = event.Content
+		case "data":
+			content.WriteString(event.Content)
+		case "tool_calls":
+			toolCalls = domain.NewOpenAIToolCalls(event.ToolCalls, false)
+		case "done":
+			message := domain.OpenAIMessage{Role: "assistant", ToolCalls: toolCalls}
+			finishReason := "stop"
+			if len(toolCalls) > 0 {
+				finishReason = "tool_calls"
+			}
+			if content.Len() > 0 || len(toolCalls) == 0 {
+				message.Content = domain.NewStringContent(content.String())
+			}
+			return c.JSON(http.StatusOK, domain.OpenAICompletionsResponse{
+				ID:      "chatcmpl-" + messageID,
+				Object:  "chat.completion",
+				Created: time.Now().Unix(),
+				Model:   model,
+				Choices: []domain.OpenAIChoice{
+					{Index: 0, Message: message, FinishReason: finishReason},
+				},
+				Usage: h.getUsage(c.Request().Context(), kbID, messageID),
+			})
+		}
+	}
+	return sendOpenAIError(c, http.StatusInternalServerError, "chat ended unexpectedly", "server_error", "")
+}
+
+// streamCompletions writes the answer as chat.completion.chunk events, the response is started with the
+// first event so that errors before it are returned with the status code
+func (h *ShareOpenAIHandler) streamCompletions(c echo.Context, eventCh <-chan domain.SSEEvent, kbID, model string, includeUsage bool) error {
+	var (
+		responseID string
+		messageID  string
+		finish     = "stop"
+		created    = time.Now().Unix()
+	)
+	write := func(choices []domain.OpenAIStreamChoice, usage *domain.OpenAIUsage) error {
+		if !c.Response().Committed {
+			c.Response().Header().Set("Content-Type", "text/event-stream")
+			c.Response().Header().Set("Cache-Control", "no-cache")
+			c.Response().Header().Set("Connection", "keep-alive")
+			c.Response().WriteHeader(http.StatusOK)
+		}
+		return writeOpenAIStreamEvent(c, domain.OpenAIStreamResponse{
+			ID:      responseID,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: choices,
+			Usage:   usage,
+		})
+	}
+	writeDelta := func(delta domain.OpenAIMessage, finishReason *string) error {
+		return write([]domain.OpenAIStreamChoice{{Index: 0, Delta: delta, FinishReason: finishReason}}, nil)
+	}
+
+	for event := range eventCh {
+		switch event.Type {
+		case "error":
+			if !c.Response().Committed {
+				return sendOpenAIError(c, http.StatusInternalServerError, event.Content, "server_error", "")
+			}
+			return writeOpenAIStreamEvent(c, domain.OpenAIErrorResponse{Error: domain.OpenAIError{Message: event.Content, Type: "server_error"}})
+		case "message_id":
+			messageID = event.Content
+			responseID = "chatcmpl-" + messageID
+			if err := writeDelta(domain.OpenAIMessage{Role: "assistant", Content: domain.NewStringContent("")}, nil); err != nil {
+				return err
+			}
+		case "data":
+			if err := writeDelta(domain.OpenAIMessage{Content: domain.NewStringContent(event.Content)}, nil); err != nil {
+				return err
+			}
+		case "tool_calls":
+			finish = "tool_calls"
+			if err := writeDelta(domain.OpenAIMessage{ToolCalls: domain.NewOpenAIToolCalls(event.ToolCalls, true)}, nil); err != nil {
+				return err
+			}
+		case "done":
+			if err := writeDelta(domain.OpenAIMessage{}, &finish); err != nil {
+				return err
+			}
+			if includeUsage {
+				if err := write([]domain.OpenAIStreamChoice{}, h.getUsage(c.Request().Context(), kbID, messageID)); err != nil {
+					return err
+				}
+			}
+			if _, err := c.Response().Write([]byte("data: [DONE]\n\n")); err != nil {
+				return err
+			}
+			c.Response().Flush()
+			return nil
+		}
+	}
+	return nil
+}
+
+// getUsage returns the tokens recorded on the answer, nil when the message can not be found
+func (h *ShareOpenAIHandler) getUsage(ctx context.Context, kbID, messageID string) *domain.OpenAIUsage {
+	message, err := h.conversationUsecase.GetMessageDetail(ctx, kbID, messageID)
+	if err != nil {
+		h.logger.Warn("get answer usage failed", log.String("message_id", messageID), log.Error(err))
+		return nil
+	}
+	return &domain.OpenAIUsage{
+		PromptTokens:     message.PromptTokens,
+		CompletionTokens: message.CompletionTokens,
+		TotalTokens:      message.TotalTokens,
+	}
+}
+
+// getKnowledgeBases returns the kbs of the bearer api key, limited to the kb of the host when
+// the request comes through the share domain of a kb
+func (h *ShareOpenAIHandler) getKnowledgeBases(c echo.Context) ([]*domain.KnowledgeBase, error) {
+	return h.appUsecase.GetOpenAIAPIKnowledgeBases(c.Request().Context(), c.Request().Header.Get("X-KB-ID"), apiKey(c))
+}
+
+func (h *ShareOpenAIHandler) sendAuthError(c echo.Context, err error) error {
+	if errors.Is(err, domain.ErrOpenAIAPIKeyInvalid) {
+		return sendOpenAIError(c, http.StatusUnauthorized, "Incorrect API key provided", "invalid_request_error", "invalid_api_key")
+	}
+	h.logger.Error("get openai api knowledge bases failed", log.Error(err))
+	return sendOpenAIError(c, http.StatusInternalServerError, "get knowledge base failed", "server_error", "")
+}
+
+// apiKey returns the api key of the request, both Bearer and x-api-key are accepted
+func apiKey(c echo.Context) string {
+	if key := c.Request().Header.Get("X-Api-Key"); key != "" {
+		return key
+	}
+	key, _ := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	return key
+}
+
+// selectKnowledgeBase returns the kb of the model, a key of only one kb accepts any model name
+// so that clients with a fixed model name keep working
+func selectKnowledgeBase(kbs []*domain.KnowledgeBase, model string) *domain.KnowledgeBase {
+	for _, kb := range kbs {
+		if kb.ID == model {
+			return kb
+		}
+	}
+	if len(kbs) == 1 {
+		return kbs[0]
+	}
+	return nil
+}
+
+func newOpenAIModel(kb *domain.KnowledgeBase) *domain.OpenAIModel {
+	return &domain.OpenAIModel{
+		ID:      kb.ID,
+		Object:  "model",
+		Created: kb.CreatedAt.Unix(),
+		OwnedBy: "pandawiki",
+		Name:    kb.Name,
+	}
+}
+
+// apiCORS allows browser clients of the compatible apis to send their api key headers
+func apiCORS(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+		c.Response().Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		c.Response().Header().Set("Access-Control-Allow-Headers", "Content-Type, Origin, Accept, Authorization, X-Api-Key, Anthropic-Version, Anthropic-Beta")
+		if c.Request().Method == "OPTIONS" {
+			return c.NoContent(http.StatusOK)
+		}
+		return next(c)
+	}
+}
+
+func sendOpenAIError(c echo.Context, status int, message, errorType, code string) error {
+	return c.JSON(status, domain.OpenAIErrorResponse{
+		Error: domain.OpenAIError{
+			Message: message,
+			Type:    errorType,
+			Code:    code,
+		},
+	})
+}
+
+func writeOpenAIStreamEvent(c echo.Context, data any) error {
+	jsonContent, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.Response(), "data: %s\n\n", jsonContent); err != nil {
+		return err
+	}
+	c.Response().Flush()
+	return nil
+}
@@ -19,6 +19,11 @@ type ShareHandler struct {
 	ShareCaptchaHandler      *ShareCaptchaHandler
 	OpenapiV1Handler         *OpenapiV1Handler
 	ShareCommonHandler       *ShareCommonHandler
+	ShareMCPHandler          *ShareMCPHandler
+	ShareOpenAIHandler       *ShareOpenAIHandler
+	ShareAnthropicHandler    *ShareAnthropicHandler
+	ShareRetrievalHandler    *ShareRetrievalHandler
+	ShareContributeHandler   *ShareContributeHandler
 }
 
 var ProviderSet = wire.NewSet(
@@ -36,6 +41,11 @@ var ProviderSet = wire.NewSet(
 	NewShareCaptchaHandler,
 	NewShareCommonHandler,
 	NewOpenapiV1Handler,
+	NewShareMCPHandler,
+	NewShareOpenAIHandler,
+	NewShareAnthropicHandler,
+	NewShareRetrievalHandler,
+	NewShareContributeHandler,
 
 	wire.Struct(new(ShareHandler), "*"),
 )
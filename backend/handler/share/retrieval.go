@@ -0,0 +1,203 @@
+package share
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/pkg/ratelimit"
+	"github.com/chaitin/panda-wiki/store/cache"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+// ShareRetrievalHandler serves the retrieval and embeddings api with the keys of the api app,
+// requests are limited per kb by the limits of the api app and counted in the daily usage
+type ShareRetrievalHandler struct {
+	*handler.BaseHandler
+	logger              *log.Logger
+	appUsecase          *usecase.AppUsecase
+	retrievalAPIUsecase *usecase.RetrievalAPIUsecase
+	rateLimiter         *ratelimit.RateLimiter
+}
+
+func NewShareRetrievalHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	appUsecase *usecase.AppUsecase,
+	retrievalAPIUsecase *usecase.RetrievalAPIUsecase,
+	cache *cache.Cache,
+) *ShareRetrievalHandler {
+	h := &ShareRetrievalHandler{
+		BaseHandler:         baseHandler,
+		logger:              logger.WithModule("handler.share.retrieval"),
+		appUsecase:          appUsecase,
+		retrievalAPIUsecase: retrievalAPIUsecase,
+		rateLimiter:         ratelimit.NewRateLimiter(logger, cache),
+	}
+
+	e.POST("/v1/retrieval", h.Retrieval, apiCORS)
+	e.POST("/v1/embeddings", h.Embeddings, apiCORS)
+
+	return h
+}
+
+// Retrieval returns the ranked chunks of the query
+//
+//	@Summary		Retrieval
+//	@Description	Retrieve the ranked chunks of a query with the permissions of the api app
+//	@Tags			share_openai
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string					true	"Bearer api key"
+//	@Param			request			body		domain.RetrievalAPIReq	true	"retrieval request"
+//	@Success		200				{object}	domain.RetrievalAPIResp
+//	@Failure		400				{object}	domain.OpenAIErrorResponse
+//	@Failure		429				{object}	domain.OpenAIErrorResponse
+//	@Router			/v1/retrieval [post]
+func (h *ShareRetrievalHandler) Retrieval(c echo.Context) error {
+	var req domain.RetrievalAPIReq
+	if err := c.Bind(&req); err != nil {
+		return sendOpenAIError(c, http.StatusBadRequest, "parse request failed", "invalid_request_error", "")
+	}
+	if err := c.Validate(&req); err != nil {
+		return sendOpenAIError(c, http.StatusBadRequest, "validate request failed", "invalid_request_error", "")
+	}
+	kb, err := h.getKnowledgeBase(c, req.KBID)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	if err := h.checkLimits(c, kb.ID); err != nil {
+		return h.sendError(c, err)
+	}
+
+	ctx := c.Request().Context()
+	resp, err := h.retrievalAPIUsecase.Retrieve(ctx, kb, &req)
+	if err != nil {
+		h.logger.Error("retrieve failed", log.String("kb_id", kb.ID), log.Error(err))
+		return sendOpenAIError(c, http.StatusInternalServerError, "retrieve failed", "server_error", "")
+	}
+	h.rateLimiter.AddTokens(ctx, kb.ID, resp.Usage.Tokens)
+	h.retrievalAPIUsecase.RecordUsage(ctx, kb.ID, domain.APIUsageEndpointRetrieval, resp.Usage.Tokens)
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Embeddings OpenAI API compatible embeddings with the embedding model of the kb
+//
+//	@Summary		Embeddings
+//	@Description	OpenAI API compatible embeddings, the model is the knowledge base id
+//	@Tags			share_openai
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string							true	"Bearer api key"
+//	@Param			request			body		domain.OpenAIEmbeddingsRequest	true	"OpenAI embeddings request"
+//	@Success		200				{object}	domain.OpenAIEmbeddingsResponse
+//	@Failure		400				{object}	domain.OpenAIErrorResponse
+//	@Failure		429				{object}	domain.OpenAIErrorResponse
+//	@Router			/v1/embeddings [post]
+func (h *ShareRetrievalHandler) Embeddings(c echo.Context) error {
+	var req domain.OpenAIEmbeddingsRequest
+	if err := c.Bind(&req); err != nil {
+		return sendOpenAIError(c, http.StatusBadRequest, "parse request failed", "invalid_request_error", "")
+	}
+	if err := c.Validate(&req); err != nil {
+		return sendOpenAIError(c, http.StatusBadRequest, "validate request failed", "invalid_request_error", "")
+	}
+	kb, err := h.getKnowledgeBase(c, req.Model)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	if err := h.checkLimits(c, kb.ID); err != nil {
+		return h.sendError(c, err)
+	}
+
+	ctx := c.Request().Context()
+	embeddings, model, tokens, err := h.retrievalAPIUsecase.Embed(ctx, req.Input)
+	if err != nil {
+		h.logger.Error("embed failed", log.String("kb_id", kb.ID), log.Error(err))
+		return sendOpenAIError(c, http.StatusInternalServerError, "embedding failed", "server_error", "")
+	}
+	h.rateLimiter.AddTokens(ctx, kb.ID, tokens)
+	h.retrievalAPIUsecase.RecordUsage(ctx, kb.ID, domain.APIUsageEndpointEmbeddings, tokens)
+
+	resp := &domain.OpenAIEmbeddingsResponse{
+		Object: "list",
+		Data:   make([]*domain.OpenAIEmbedding, 0, len(embeddings)),
+		Model:  model,
+		Usage:  &domain.OpenAIEmbeddingUsage{PromptTokens: tokens, TotalTokens: tokens},
+	}
+	for i, embedding := range embeddings {
+		resp.Data = append(resp.Data, &domain.OpenAIEmbedding{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: encodeEmbedding(embedding, req.EncodingFormat),
+		})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// getKnowledgeBase returns the kb of the api key, model is the kb id like the chat api
+func (h *ShareRetrievalHandler) getKnowledgeBase(c echo.Context, model string) (*domain.KnowledgeBase, error) {
+	kbs, err := h.appUsecase.GetOpenAIAPIKnowledgeBases(c.Request().Context(), c.Request().Header.Get("X-KB-ID"), apiKey(c))
+	if err != nil {
+		return nil, err
+	}
+	kb := selectKnowledgeBase(kbs, model)
+	if kb == nil {
+		return nil, domain.ErrAPIModelNotFound
+	}
+	return kb, nil
+}
+
+// checkLimits checks the requests and tokens of the current minute against the limits of the api app
+func (h *ShareRetrievalHandler) checkLimits(c echo.Context, kbID string) error {
+	ctx := c.Request().Context()
+	appInfo, err := h.appUsecase.GetOpenAIAPIAppInfo(ctx, kbID)
+	if err != nil {
+		return err
+	}
+	settings := appInfo.Settings.OpenAIAPIBotSettings
+	allowed, retryAfter := h.rateLimiter.AllowTokens(ctx, kbID, settings.TokensPerMinute)
+	if allowed {
+		allowed, retryAfter = h.rateLimiter.AllowRequest(ctx, kbID, settings.RequestsPerMinute)
+	}
+	if !allowed {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return domain.ErrAPIRateLimited
+	}
+	return nil
+}
+
+func (h *ShareRetrievalHandler) sendError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrOpenAIAPIKeyInvalid):
+		return sendOpenAIError(c, http.StatusUnauthorized, "Incorrect API key provided", "invalid_request_error", "invalid_api_key")
+	case errors.Is(err, domain.ErrAPIModelNotFound):
+		return sendOpenAIError(c, http.StatusNotFound, "The model does not exist", "invalid_request_error", "model_not_found")
+	case errors.Is(err, domain.ErrAPIRateLimited):
+		return sendOpenAIError(c, http.StatusTooManyRequests, "Rate limit reached", "requests", "rate_limit_exceeded")
+	}
+	h.logger.Error("check api request failed", log.Error(err))
+	return sendOpenAIError(c, http.StatusInternalServerError, "get knowledge base failed", "server_error", "")
+}
+
+// encodeEmbedding returns the embedding as floats, or as base64 of the little endian float32 bytes
+// which is the default format of the openai sdk
+func encodeEmbedding(embedding []float32, format string) any {
+	if format != "base64" {
+		return embedding
+	}
+	data := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
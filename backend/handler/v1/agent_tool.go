@@ -0,0 +1,161 @@
+package v1
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type AgentToolHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.AgentUsecase
+}
+
+func NewAgentToolHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.AgentUsecase,
+) *AgentToolHandler {
+	h := &AgentToolHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.agent_tool"),
+		usecase:     usecase,
+	}
+
+	group := e.Group("/api/v1/knowledge_base/agent_tool", h.V1Auth.Authorize, h.V1Auth.ValidateKBUserPerm(consts.UserKBPermissionFullControl))
+	group.GET("/list", h.ListAgentTools)
+	group.POST("", h.CreateAgentTool)
+	group.PATCH("", h.UpdateAgentTool)
+	group.DELETE("", h.DeleteAgentTool)
+
+	return h
+}
+
+// ListAgentTools 智能体工具列表
+//
+//	@Tags			AgentTool
+//	@Summary		智能体工具列表
+//	@Description	管理员注册的 HTTP 工具，内置工具通过知识库的 agent_settings 启用或禁用
+//	@ID				v1-ListAgentTools
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.AgentToolListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=[]domain.AgentTool}
+//	@Router			/api/v1/knowledge_base/agent_tool/list [get]
+func (h *AgentToolHandler) ListAgentTools(c echo.Context) error {
+	var req v1.AgentToolListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	tools, err := h.usecase.List(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list agent tools", err)
+	}
+	return h.NewResponseWithData(c, tools)
+}
+
+// CreateAgentTool 创建智能体工具
+//
+//	@Tags			AgentTool
+//	@Summary		创建智能体工具
+//	@Description	注册模型在回答时可以调用的 HTTP 接口，参数使用 JSON Schema 描述
+//	@ID				v1-CreateAgentTool
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.AgentToolCreateReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=domain.AgentTool}
+//	@Router			/api/v1/knowledge_base/agent_tool [post]
+func (h *AgentToolHandler) CreateAgentTool(c echo.Context) error {
+	var req v1.AgentToolCreateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	tool, err := h.usecase.Create(c.Request().Context(), &req)
+	if err != nil {
+		return h.agentToolError(c, "failed to create agent tool", err)
+	}
+	return h.NewResponseWithData(c, tool)
+}
+
+// UpdateAgentTool 更新智能体工具
+//
+//	@Tags			AgentTool
+//	@Summary		更新智能体工具
+//	@Description	更新智能体工具
+//	@ID				v1-UpdateAgentTool
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.AgentToolUpdateReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/knowledge_base/agent_tool [patch]
+func (h *AgentToolHandler) UpdateAgentTool(c echo.Context) error {
+	var req v1.AgentToolUpdateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.Update(c.Request().Context(), &req); err != nil {
+		return h.agentToolError(c, "failed to update agent tool", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// DeleteAgentTool 删除智能体工具
+//
+//	@Tags			AgentTool
+//	@Summary		删除智能体工具
+//	@Description	删除智能体工具
+//	@ID				v1-DeleteAgentTool
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.AgentToolDeleteReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/knowledge_base/agent_tool [delete]
+func (h *AgentToolHandler) DeleteAgentTool(c echo.Context) error {
+	var req v1.AgentToolDeleteReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.Delete(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "failed to delete agent tool", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+func (h *AgentToolHandler) agentToolError(c echo.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrAgentToolNameInvalid):
+		return h.NewResponseWithError(c, "工具名称只能包含字母、数字、下划线或短横线，且不能与内置工具重名", nil)
+	case errors.Is(err, domain.ErrAgentToolNameExists):
+		return h.NewResponseWithError(c, "工具名称已存在", nil)
+	case errors.Is(err, domain.ErrAgentToolParametersInvalid):
+		return h.NewResponseWithError(c, "工具参数必须是 type 为 object 的 JSON Schema", err)
+	case errors.Is(err, domain.ErrAgentToolHeaderRequired):
+		return h.NewResponseWithError(c, "修改工具地址时需要重新填写请求头", nil)
+	}
+	return h.NewResponseWithError(c, msg, err)
+}
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type AnswerCacheHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.AnswerCacheUsecase
+}
+
+func NewAnswerCacheHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.AnswerCacheUsecase,
+) *AnswerCacheHandler {
+	h := &AnswerCacheHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.answer_cache"),
+		usecase:     usecase,
+	}
+
+	group := e.Group("/api/v1/knowledge_base/answer_cache", h.V1Auth.Authorize)
+	group.GET("/list", h.ListAnswerCaches, h.V1Auth.ValidateKBUserPerm(consts.UserKBPermissionDataOperate))
+	group.DELETE("", h.DeleteAnswerCaches, h.V1Auth.ValidateKBUserPerm(consts.UserKBPermissionFullControl))
+
+	return h
+}
+
+// ListAnswerCaches 回答缓存列表
+//
+//	@Tags			AnswerCache
+//	@Summary		回答缓存列表
+//	@Description	按命中次数列出知识库当前缓存的回答
+//	@ID				v1-ListAnswerCaches
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.AnswerCacheListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.AnswerCacheListResp}
+//	@Router			/api/v1/knowledge_base/answer_cache/list [get]
+func (h *AnswerCacheHandler) ListAnswerCaches(c echo.Context) error {
+	var req v1.AnswerCacheListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	resp, err := h.usecase.List(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list answer caches", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// DeleteAnswerCaches 清除回答缓存
+//
+//	@Tags			AnswerCache
+//	@Summary		清除回答缓存
+//	@Description	删除指定的缓存条目，未指定时清空知识库的全部缓存
+//	@ID				v1-DeleteAnswerCaches
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.AnswerCacheDeleteReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.AnswerCacheDeleteResp}
+//	@Router			/api/v1/knowledge_base/answer_cache [delete]
+func (h *AnswerCacheHandler) DeleteAnswerCaches(c echo.Context) error {
+	var req v1.AnswerCacheDeleteReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	resp, err := h.usecase.Delete(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to delete answer caches", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
@@ -0,0 +1,191 @@
+package v1
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type APITokenHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.APITokenUsecase
+}
+
+func NewAPITokenHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.APITokenUsecase,
+) *APITokenHandler {
+	h := &APITokenHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.api_token"),
+		usecase:     usecase,
+	}
+
+	// api token 不能管理 api token
+	group := e.Group("/api/v1/knowledge_base/api_token",
+		h.V1Auth.Authorize,
+		h.V1Auth.RejectToken,
+		h.V1Auth.ValidateKBUserPerm(consts.UserKBPermissionFullControl),
+	)
+	group.GET("/list", h.ListAPITokens)
+	group.POST("", h.CreateAPIToken)
+	group.PUT("", h.UpdateAPIToken)
+	group.POST("/rotate", h.RotateAPIToken)
+	group.DELETE("", h.DeleteAPIToken)
+
+	return h
+}
+
+// ListAPITokens API Token 列表
+//
+//	@Tags			APIToken
+//	@Summary		API Token 列表
+//	@Description	列出知识库的 API Token，不包含 token 本身
+//	@ID				v1-ListAPITokens
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.APITokenListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.APITokenListResp}
+//	@Router			/api/v1/knowledge_base/api_token/list [get]
+func (h *APITokenHandler) ListAPITokens(c echo.Context) error {
+	var req v1.APITokenListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	tokens, err := h.usecase.List(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list api tokens", err)
+	}
+	return h.NewResponseWithData(c, tokens)
+}
+
+// CreateAPIToken 创建 API Token
+//
+//	@Tags			APIToken
+//	@Summary		创建 API Token
+//	@Description	创建 API Token，token 仅在本次返回
+//	@ID				v1-CreateAPIToken
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.APITokenCreateReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.APITokenCreateResp}
+//	@Router			/api/v1/knowledge_base/api_token [post]
+func (h *APITokenHandler) CreateAPIToken(c echo.Context) error {
+	var req v1.APITokenCreateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	authInfo := domain.GetAuthInfoFromCtx(c.Request().Context())
+	resp, err := h.usecase.Create(c.Request().Context(), authInfo.UserId, &req)
+	if err != nil {
+		return h.apiTokenError(c, "failed to create api token", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// UpdateAPIToken 更新 API Token
+//
+//	@Tags			APIToken
+//	@Summary		更新 API Token
+//	@Description	更新 API Token 的名称、权限范围和过期时间
+//	@ID				v1-UpdateAPIToken
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.APITokenUpdateReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse
+//	@Router			/api/v1/knowledge_base/api_token [put]
+func (h *APITokenHandler) UpdateAPIToken(c echo.Context) error {
+	var req v1.APITokenUpdateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.Update(c.Request().Context(), &req); err != nil {
+		return h.apiTokenError(c, "failed to update api token", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// RotateAPIToken 轮换 API Token
+//
+//	@Tags			APIToken
+//	@Summary		轮换 API Token
+//	@Description	生成新的 token 并立即使旧 token 失效，新 token 仅在本次返回
+//	@ID				v1-RotateAPIToken
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.APITokenRotateReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.APITokenCreateResp}
+//	@Router			/api/v1/knowledge_base/api_token/rotate [post]
+func (h *APITokenHandler) RotateAPIToken(c echo.Context) error {
+	var req v1.APITokenRotateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	resp, err := h.usecase.Rotate(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to rotate api token", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// DeleteAPIToken 吊销 API Token
+//
+//	@Tags			APIToken
+//	@Summary		吊销 API Token
+//	@Description	删除 API Token，立即失效
+//	@ID				v1-DeleteAPIToken
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.APITokenDeleteReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse
+//	@Router			/api/v1/knowledge_base/api_token [delete]
+func (h *APITokenHandler) DeleteAPIToken(c echo.Context) error {
+	var req v1.APITokenDeleteReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.Delete(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "failed to delete api token", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+func (h *APITokenHandler) apiTokenError(c echo.Context, msg string, err error) error {
+	if errors.Is(err, domain.ErrAPITokenExpiresAtInvalid) {
+		return h.NewResponseWithError(c, "过期时间必须晚于当前时间", nil)
+	}
+	if errors.Is(err, domain.ErrAPITokenScopeLegacy) {
+		return h.NewResponseWithError(c, "legacy 权限范围仅供升级前创建的 token 保留，不能授予", nil)
+	}
+	return h.NewResponseWithError(c, msg, err)
+}
@@ -0,0 +1,67 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+const apiUsageDefaultDays = 30
+
+type APIUsageHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.RetrievalAPIUsecase
+}
+
+func NewAPIUsageHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.RetrievalAPIUsecase,
+) *APIUsageHandler {
+	h := &APIUsageHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.api_usage"),
+		usecase:     usecase,
+	}
+
+	group := e.Group("/api/v1/knowledge_base/api_usage", h.V1Auth.Authorize)
+	group.GET("/list", h.ListAPIUsages, h.V1Auth.ValidateKBUserPerm(consts.UserKBPermissionDataOperate))
+
+	return h
+}
+
+// ListAPIUsages API 用量
+//
+//	@Tags			APIUsage
+//	@Summary		API 用量
+//	@Description	按天列出检索和向量接口的请求数及 token 数
+//	@ID				v1-ListAPIUsages
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.APIUsageListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.APIUsageListResp}
+//	@Router			/api/v1/knowledge_base/api_usage/list [get]
+func (h *APIUsageHandler) ListAPIUsages(c echo.Context) error {
+	var req v1.APIUsageListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if req.Days == 0 {
+		req.Days = apiUsageDefaultDays
+	}
+	usages, err := h.usecase.ListUsage(c.Request().Context(), req.KBId, req.Days)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list api usages", err)
+	}
+	return h.NewResponseWithData(c, v1.APIUsageListResp(usages))
+}
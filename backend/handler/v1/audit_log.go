@@ -0,0 +1,106 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/audit/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type AuditLogHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.AuditLogUsecase
+}
+
+func NewAuditLogHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.AuditLogUsecase,
+) *AuditLogHandler {
+	h := &AuditLogHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.audit_log"),
+		usecase:     usecase,
+	}
+
+	group := e.Group("/api/v1/audit_log", h.V1Auth.Authorize, h.V1Auth.ValidateUserRole(consts.UserRoleAdmin))
+	group.GET("/list", h.ListAuditLogs)
+	group.GET("/export", h.ExportAuditLogs)
+
+	return h
+}
+
+// ListAuditLogs 审计日志列表
+//
+//	@Tags			AuditLog
+//	@Summary		审计日志列表
+//	@Description	文档、权限、成员、应用、模型和认证配置的变更记录，按时间倒序，仅管理员可查看
+//	@ID				v1-ListAuditLogs
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.AuditLogListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.AuditLogListResp}
+//	@Router			/api/v1/audit_log/list [get]
+func (h *AuditLogHandler) ListAuditLogs(c echo.Context) error {
+	var req v1.AuditLogListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	resp, err := h.usecase.List(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list audit logs", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// ExportAuditLogs 导出审计日志
+//
+//	@Tags			AuditLog
+//	@Summary		导出审计日志
+//	@Description	按筛选条件导出全部审计日志，按时间正序，format 为 csv 或 jsonl
+//	@ID				v1-ExportAuditLogs
+//	@Accept			json
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Security		bearerAuth
+//	@Param			param	query	v1.AuditLogExportReq	true	"para"
+//	@Success		200		{file}	file
+//	@Router			/api/v1/audit_log/export [get]
+func (h *AuditLogHandler) ExportAuditLogs(c echo.Context) error {
+	var req v1.AuditLogExportReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if req.Format == "jsonl" {
+		contentType = "application/x-ndjson"
+	}
+	filename := fmt.Sprintf("audit_logs_%s.%s", time.Now().Format("20060102150405"), req.Format)
+	c.Response().Header().Set(echo.HeaderContentType, contentType)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	c.Response().WriteHeader(http.StatusOK)
+
+	// 响应已经开始写出，失败时只能中断并记录日志
+	if err := h.usecase.Export(c.Request().Context(), &req, c.Response()); err != nil {
+		h.logger.Error("export audit logs failed", log.Error(err))
+		return err
+	}
+	return nil
+}
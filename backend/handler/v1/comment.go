@@ -30,6 +30,7 @@ func NewCommentHandler(e *echo.Echo, baseHandler *handler.BaseHandler, logger *l
 	group := e.Group("/api/v1/comment", h.auth.Authorize, h.auth.ValidateKBUserPerm(consts.UserKBPermissionDataOperate))
 	group.GET("", h.GetCommentModeratedList)
 	group.DELETE("/list", h.DeleteCommentList)
+	group.PATCH("/status", h.ModerateComments)
 
 	return h
 }
@@ -90,3 +91,27 @@ func (h *CommentHandler) DeleteCommentList(c echo.Context) error {
 	// success
 	return h.NewResponseWithData(c, nil)
 }
+
+// ModerateComments
+//
+//	@Summary		ModerateComments
+//	@Description	审核评论，status 为 1 通过，-1 拒绝
+//	@Tags			comment
+//	@Accept			json
+//	@Produce		json
+//	@Param			req	body		domain.ModerateCommentReq	true	"ModerateCommentReq"
+//	@Success		200	{object}	domain.Response
+//	@Router			/api/v1/comment/status [patch]
+func (h *CommentHandler) ModerateComments(c echo.Context) error {
+	var req domain.ModerateCommentReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "bind request failed", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request failed", err)
+	}
+	if err := h.usecase.ModerateComments(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "failed to moderate comments", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
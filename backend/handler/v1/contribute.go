@@ -0,0 +1,160 @@
+package v1
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/contribute/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type ContributeHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.ContributeUsecase
+}
+
+func NewContributeHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.ContributeUsecase,
+) *ContributeHandler {
+	h := &ContributeHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.contribute"),
+		usecase:     usecase,
+	}
+
+	group := e.Group("/api/v1/contribute", h.V1Auth.Authorize, h.V1Auth.ValidateKBUserPerm(consts.UserKBPermissionDocManage))
+	group.GET("/list", h.ListContributes)
+	group.GET("/detail", h.GetContributeDetail)
+	group.POST("/approve", h.ApproveContribute)
+	group.POST("/reject", h.RejectContribute)
+
+	return h
+}
+
+// ListContributes 读者贡献列表
+//
+//	@Tags			Contribute
+//	@Summary		读者贡献列表
+//	@Description	按提交时间倒序列出读者贡献，可按状态筛选
+//	@ID				v1-ListContributes
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.ContributeListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.ContributeListResp}
+//	@Router			/api/v1/contribute/list [get]
+func (h *ContributeHandler) ListContributes(c echo.Context) error {
+	var req v1.ContributeListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	resp, err := h.usecase.List(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list contributes", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// GetContributeDetail 读者贡献详情
+//
+//	@Tags			Contribute
+//	@Summary		读者贡献详情
+//	@Description	返回读者贡献及其与当前文档的差异
+//	@ID				v1-GetContributeDetail
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.ContributeDetailReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.ContributeDetailResp}
+//	@Router			/api/v1/contribute/detail [get]
+func (h *ContributeHandler) GetContributeDetail(c echo.Context) error {
+	var req v1.ContributeDetailReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	resp, err := h.usecase.Detail(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to get contribute detail", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// ApproveContribute 通过读者贡献
+//
+//	@Tags			Contribute
+//	@Summary		通过读者贡献
+//	@Description	将读者贡献合并到文档草稿，新页面创建为未发布文档，可选择立即发布
+//	@ID				v1-ApproveContribute
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.ContributeApproveReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.ContributeApproveResp}
+//	@Router			/api/v1/contribute/approve [post]
+func (h *ContributeHandler) ApproveContribute(c echo.Context) error {
+	var req v1.ContributeApproveReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	authInfo := domain.GetAuthInfoFromCtx(c.Request().Context())
+	resp, err := h.usecase.Approve(c.Request().Context(), &req, authInfo.UserId)
+	if err != nil {
+		return h.contributeError(c, "failed to approve contribute", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// RejectContribute 驳回读者贡献
+//
+//	@Tags			Contribute
+//	@Summary		驳回读者贡献
+//	@Description	驳回读者贡献并记录原因
+//	@ID				v1-RejectContribute
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.ContributeRejectReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse
+//	@Router			/api/v1/contribute/reject [post]
+func (h *ContributeHandler) RejectContribute(c echo.Context) error {
+	var req v1.ContributeRejectReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	authInfo := domain.GetAuthInfoFromCtx(c.Request().Context())
+	if err := h.usecase.Reject(c.Request().Context(), &req, authInfo.UserId); err != nil {
+		return h.contributeError(c, "failed to reject contribute", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+func (h *ContributeHandler) contributeError(c echo.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrContributeAudited):
+		return h.NewResponseWithError(c, "该贡献已审核", nil)
+	case errors.Is(err, domain.ErrMaxNodeLimitReached):
+		return h.NewResponseWithError(c, "已达到最大文档数量限制，请升级到更高版本", nil)
+	}
+	return h.NewResponseWithError(c, msg, err)
+}
@@ -14,23 +14,26 @@ import (
 
 type ConversationHandler struct {
 	*handler.BaseHandler
-	logger  *log.Logger
-	auth    middleware.AuthMiddleware
-	usecase *usecase.ConversationUsecase
+	logger      *log.Logger
+	auth        middleware.AuthMiddleware
+	usecase     *usecase.ConversationUsecase
+	chatUsecase *usecase.ChatUsecase
 }
 
-func NewConversationHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.ConversationUsecase) *ConversationHandler {
+func NewConversationHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.ConversationUsecase, chatUsecase *usecase.ChatUsecase) *ConversationHandler {
 	handler := &ConversationHandler{
 		BaseHandler: baseHandler,
 		logger:      logger.WithModule("handler_conversation"),
 		auth:        auth,
 		usecase:     usecase,
+		chatUsecase: chatUsecase,
 	}
 	group := echo.Group("/api/v1/conversation", handler.auth.Authorize, handler.auth.ValidateKBUserPerm(consts.UserKBPermissionDataOperate))
 	group.GET("", handler.GetConversationList)
 	group.GET("/detail", handler.GetConversationDetail)
 	group.GET("/message/list", handler.GetMessageFeedBackList)
 	group.GET("/message/detail", handler.GetMessageDetail)
+	group.POST("/retrieval/debug", handler.DebugRetrieval)
 
 	return handler
 }
@@ -142,3 +145,31 @@ func (h *ConversationHandler) GetMessageDetail(c echo.Context) error {
 
 	return h.NewResponseWithData(c, message)
 }
+
+// DebugRetrieval
+//
+//	@Summary		检索调试
+//	@Description	复现问题或对话消息的检索过程，返回改写后的问题、各文档片段得分、因权限被过滤的片段及最终提示词
+//	@Tags			conversation
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			body	body		v1.RetrievalDebugReq	true	"debug request"
+//	@Success		200		{object}	domain.PWResponse{data=v1.RetrievalDebugResp}
+//	@Router			/api/v1/conversation/retrieval/debug [post]
+func (h *ConversationHandler) DebugRetrieval(c echo.Context) error {
+	var req v1.RetrievalDebugReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request failed", err)
+	}
+
+	resp, err := h.chatUsecase.DebugRetrieval(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to debug retrieval", err)
+	}
+
+	return h.NewResponseWithData(c, resp)
+}
@@ -0,0 +1,431 @@
+package v1
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/eval/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type EvalHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.EvalUsecase
+}
+
+func NewEvalHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.EvalUsecase,
+) *EvalHandler {
+	h := &EvalHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.eval"),
+		usecase:     usecase,
+	}
+
+	group := e.Group("/api/v1/eval", h.V1Auth.Authorize, h.V1Auth.ValidateKBUserPerm(consts.UserKBPermissionDataOperate))
+	group.GET("/set/list", h.ListEvalSets)
+	group.POST("/set", h.CreateEvalSet)
+	group.PATCH("/set", h.UpdateEvalSet)
+	group.DELETE("/set", h.DeleteEvalSet)
+
+	group.GET("/question/list", h.ListEvalQuestions)
+	group.POST("/question", h.CreateEvalQuestions)
+	group.PATCH("/question", h.UpdateEvalQuestion)
+	group.DELETE("/question", h.DeleteEvalQuestions)
+
+	group.POST("/run", h.CreateEvalRun)
+	group.GET("/run/list", h.ListEvalRuns)
+	group.GET("/run/detail", h.GetEvalRunDetail)
+	group.POST("/run/cancel", h.CancelEvalRun)
+	group.DELETE("/run", h.DeleteEvalRun)
+	group.GET("/run/compare", h.CompareEvalRuns)
+
+	return h
+}
+
+// ListEvalSets 评测集列表
+//
+//	@Tags			Eval
+//	@Summary		评测集列表
+//	@Description	评测集列表
+//	@ID				v1-ListEvalSets
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.EvalSetListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=[]v1.EvalSetListItem}
+//	@Router			/api/v1/eval/set/list [get]
+func (h *EvalHandler) ListEvalSets(c echo.Context) error {
+	var req v1.EvalSetListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	sets, err := h.usecase.ListEvalSets(c.Request().Context(), req.KbId)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list eval sets", err)
+	}
+	return h.NewResponseWithData(c, sets)
+}
+
+// CreateEvalSet 创建评测集
+//
+//	@Tags			Eval
+//	@Summary		创建评测集
+//	@Description	创建评测集
+//	@ID				v1-CreateEvalSet
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.EvalSetCreateReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=string}
+//	@Router			/api/v1/eval/set [post]
+func (h *EvalHandler) CreateEvalSet(c echo.Context) error {
+	var req v1.EvalSetCreateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	id, err := h.usecase.CreateEvalSet(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to create eval set", err)
+	}
+	return h.NewResponseWithData(c, id)
+}
+
+// UpdateEvalSet 更新评测集
+//
+//	@Tags			Eval
+//	@Summary		更新评测集
+//	@Description	更新评测集
+//	@ID				v1-UpdateEvalSet
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.EvalSetUpdateReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/eval/set [patch]
+func (h *EvalHandler) UpdateEvalSet(c echo.Context) error {
+	var req v1.EvalSetUpdateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.UpdateEvalSet(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "failed to update eval set", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// DeleteEvalSet 删除评测集
+//
+//	@Tags			Eval
+//	@Summary		删除评测集
+//	@Description	删除评测集及其问题和运行记录
+//	@ID				v1-DeleteEvalSet
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.EvalSetDeleteReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/eval/set [delete]
+func (h *EvalHandler) DeleteEvalSet(c echo.Context) error {
+	var req v1.EvalSetDeleteReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.DeleteEvalSet(c.Request().Context(), req.KbId, req.ID); err != nil {
+		return h.NewResponseWithError(c, "failed to delete eval set", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// ListEvalQuestions 评测问题列表
+//
+//	@Tags			Eval
+//	@Summary		评测问题列表
+//	@Description	评测问题列表
+//	@ID				v1-ListEvalQuestions
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.EvalQuestionListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=[]domain.EvalQuestion}
+//	@Router			/api/v1/eval/question/list [get]
+func (h *EvalHandler) ListEvalQuestions(c echo.Context) error {
+	var req v1.EvalQuestionListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	questions, err := h.usecase.ListEvalQuestions(c.Request().Context(), req.KbId, req.SetId)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list eval questions", err)
+	}
+	return h.NewResponseWithData(c, questions)
+}
+
+// CreateEvalQuestions 批量添加评测问题
+//
+//	@Tags			Eval
+//	@Summary		批量添加评测问题
+//	@Description	批量添加评测问题
+//	@ID				v1-CreateEvalQuestions
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.EvalQuestionCreateReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=[]string}
+//	@Router			/api/v1/eval/question [post]
+func (h *EvalHandler) CreateEvalQuestions(c echo.Context) error {
+	var req v1.EvalQuestionCreateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	ids, err := h.usecase.CreateEvalQuestions(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to create eval questions", err)
+	}
+	return h.NewResponseWithData(c, ids)
+}
+
+// UpdateEvalQuestion 更新评测问题
+//
+//	@Tags			Eval
+//	@Summary		更新评测问题
+//	@Description	更新评测问题
+//	@ID				v1-UpdateEvalQuestion
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.EvalQuestionUpdateReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/eval/question [patch]
+func (h *EvalHandler) UpdateEvalQuestion(c echo.Context) error {
+	var req v1.EvalQuestionUpdateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.UpdateEvalQuestion(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "failed to update eval question", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// DeleteEvalQuestions 删除评测问题
+//
+//	@Tags			Eval
+//	@Summary		删除评测问题
+//	@Description	删除评测问题
+//	@ID				v1-DeleteEvalQuestions
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.EvalQuestionDeleteReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/eval/question [delete]
+func (h *EvalHandler) DeleteEvalQuestions(c echo.Context) error {
+	var req v1.EvalQuestionDeleteReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.DeleteEvalQuestions(c.Request().Context(), req.KbId, req.IDs); err != nil {
+		return h.NewResponseWithError(c, "failed to delete eval questions", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// CreateEvalRun 运行评测
+//
+//	@Tags			Eval
+//	@Summary		运行评测
+//	@Description	使用当前的模型、提示词和检索配置在后台运行评测集，计算 recall@k、MRR 和回答忠实度
+//	@ID				v1-CreateEvalRun
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.EvalRunCreateReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.EvalRunCreateResp}
+//	@Router			/api/v1/eval/run [post]
+func (h *EvalHandler) CreateEvalRun(c echo.Context) error {
+	var req v1.EvalRunCreateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	id, err := h.usecase.CreateEvalRun(c.Request().Context(), &req)
+	if err != nil {
+		if errors.Is(err, domain.ErrEvalRunBusy) {
+			return h.NewResponseWithError(c, "正在执行的评测过多，请稍后再试", nil)
+		}
+		return h.NewResponseWithError(c, "failed to create eval run", err)
+	}
+	return h.NewResponseWithData(c, v1.EvalRunCreateResp{ID: id})
+}
+
+// ListEvalRuns 评测运行列表
+//
+//	@Tags			Eval
+//	@Summary		评测运行列表
+//	@Description	评测运行列表
+//	@ID				v1-ListEvalRuns
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.EvalRunListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=domain.PaginatedResult[[]domain.EvalRun]}
+//	@Router			/api/v1/eval/run/list [get]
+func (h *EvalHandler) ListEvalRuns(c echo.Context) error {
+	var req v1.EvalRunListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	runs, err := h.usecase.ListEvalRuns(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list eval runs", err)
+	}
+	return h.NewResponseWithData(c, runs)
+}
+
+// GetEvalRunDetail 评测运行详情
+//
+//	@Tags			Eval
+//	@Summary		评测运行详情
+//	@Description	评测运行详情，包含每个问题的检索结果、回答和评分
+//	@ID				v1-GetEvalRunDetail
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.EvalRunDetailReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.EvalRunDetailResp}
+//	@Router			/api/v1/eval/run/detail [get]
+func (h *EvalHandler) GetEvalRunDetail(c echo.Context) error {
+	var req v1.EvalRunDetailReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	detail, err := h.usecase.GetEvalRunDetail(c.Request().Context(), req.KbId, req.ID)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to get eval run detail", err)
+	}
+	return h.NewResponseWithData(c, detail)
+}
+
+// CancelEvalRun 取消评测运行
+//
+//	@Tags			Eval
+//	@Summary		取消评测运行
+//	@Description	停止执行中的评测运行，已完成的问题结果保留
+//	@ID				v1-CancelEvalRun
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.EvalRunCancelReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/eval/run/cancel [post]
+func (h *EvalHandler) CancelEvalRun(c echo.Context) error {
+	var req v1.EvalRunCancelReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.CancelEvalRun(c.Request().Context(), req.KbId, req.ID); err != nil {
+		if errors.Is(err, domain.ErrEvalRunNotRunning) {
+			return h.NewResponseWithError(c, "评测运行已结束", nil)
+		}
+		return h.NewResponseWithError(c, "failed to cancel eval run", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// DeleteEvalRun 删除评测运行
+//
+//	@Tags			Eval
+//	@Summary		删除评测运行
+//	@Description	删除评测运行
+//	@ID				v1-DeleteEvalRun
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.EvalRunDeleteReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/eval/run [delete]
+func (h *EvalHandler) DeleteEvalRun(c echo.Context) error {
+	var req v1.EvalRunDeleteReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.DeleteEvalRun(c.Request().Context(), req.KbId, req.ID); err != nil {
+		return h.NewResponseWithError(c, "failed to delete eval run", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// CompareEvalRuns 对比评测运行
+//
+//	@Tags			Eval
+//	@Summary		对比评测运行
+//	@Description	按问题对比两次评测运行的结果及指标差异
+//	@ID				v1-CompareEvalRuns
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.EvalRunCompareReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.EvalRunCompareResp}
+//	@Router			/api/v1/eval/run/compare [get]
+func (h *EvalHandler) CompareEvalRuns(c echo.Context) error {
+	var req v1.EvalRunCompareReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	resp, err := h.usecase.CompareEvalRuns(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to compare eval runs", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
@@ -0,0 +1,172 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/faq/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type FAQHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.FAQUsecase
+}
+
+func NewFAQHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.FAQUsecase,
+) *FAQHandler {
+	h := &FAQHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.faq"),
+		usecase:     usecase,
+	}
+
+	group := e.Group("/api/v1/faq", h.V1Auth.Authorize, h.V1Auth.ValidateKBUserPerm(consts.UserKBPermissionDocManage))
+	group.GET("/list", h.ListFAQs)
+	group.POST("", h.CreateFAQ)
+	group.PATCH("", h.UpdateFAQ)
+	group.DELETE("", h.DeleteFAQ)
+	group.POST("/match", h.MatchFAQ)
+
+	return h
+}
+
+// ListFAQs 常见问题列表
+//
+//	@Tags			FAQ
+//	@Summary		常见问题列表
+//	@Description	常见问题列表
+//	@ID				v1-ListFAQs
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.FAQListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=[]domain.FAQ}
+//	@Router			/api/v1/faq/list [get]
+func (h *FAQHandler) ListFAQs(c echo.Context) error {
+	var req v1.FAQListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	faqs, err := h.usecase.List(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list faqs", err)
+	}
+	return h.NewResponseWithData(c, faqs)
+}
+
+// CreateFAQ 创建常见问题
+//
+//	@Tags			FAQ
+//	@Summary		创建常见问题
+//	@Description	问题命中时在对话中原样返回审核通过的回答
+//	@ID				v1-CreateFAQ
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.FAQCreateReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=domain.FAQ}
+//	@Router			/api/v1/faq [post]
+func (h *FAQHandler) CreateFAQ(c echo.Context) error {
+	var req v1.FAQCreateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	faq, err := h.usecase.Create(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to create faq", err)
+	}
+	return h.NewResponseWithData(c, faq)
+}
+
+// UpdateFAQ 更新常见问题
+//
+//	@Tags			FAQ
+//	@Summary		更新常见问题
+//	@Description	更新常见问题
+//	@ID				v1-UpdateFAQ
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.FAQUpdateReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/faq [patch]
+func (h *FAQHandler) UpdateFAQ(c echo.Context) error {
+	var req v1.FAQUpdateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.Update(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "failed to update faq", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// DeleteFAQ 删除常见问题
+//
+//	@Tags			FAQ
+//	@Summary		删除常见问题
+//	@Description	删除常见问题
+//	@ID				v1-DeleteFAQ
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.FAQDeleteReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/faq [delete]
+func (h *FAQHandler) DeleteFAQ(c echo.Context) error {
+	var req v1.FAQDeleteReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.Delete(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "failed to delete faq", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// MatchFAQ 测试常见问题匹配
+//
+//	@Tags			FAQ
+//	@Summary		测试常见问题匹配
+//	@Description	返回问题在对话中会命中的常见问题及相似度
+//	@ID				v1-MatchFAQ
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.FAQMatchReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.FAQMatchResp}
+//	@Router			/api/v1/faq/match [post]
+func (h *FAQHandler) MatchFAQ(c echo.Context) error {
+	var req v1.FAQMatchReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	resp, err := h.usecase.Match(c.Request().Context(), req.KbId, req.Question)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to match faq", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
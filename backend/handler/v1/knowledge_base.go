@@ -6,6 +6,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/samber/lo"
 
+	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
 	"github.com/chaitin/panda-wiki/consts"
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/handler"
@@ -56,6 +57,8 @@ func NewKnowledgeBaseHandler(
 	releaseGroup := group.Group("/release", h.auth.ValidateKBUserPerm(consts.UserKBPermissionDocManage))
 	releaseGroup.POST("", h.CreateKBRelease)
 	releaseGroup.GET("/list", h.GetKBReleaseList)
+	releaseGroup.GET("/diff", h.DiffKBRelease)
+	releaseGroup.POST("/rollback", h.RollbackKBRelease)
 
 	return h
 }
@@ -195,13 +198,17 @@ func (h *KnowledgeBaseHandler) GetKnowledgeBaseDetail(c echo.Context) error {
 	}
 
 	return h.NewResponseWithData(c, &domain.KnowledgeBaseDetail{
-		ID:             kb.ID,
-		Name:           kb.Name,
-		DatasetID:      kb.DatasetID,
-		Perm:           perm,
-		AccessSettings: kb.AccessSettings,
-		CreatedAt:      kb.CreatedAt,
-		UpdatedAt:      kb.UpdatedAt,
+		ID:                  kb.ID,
+		Name:                kb.Name,
+		DatasetID:           kb.DatasetID,
+		Perm:                perm,
+		AccessSettings:      kb.AccessSettings,
+		RetrievalSettings:   kb.RetrievalSettings,
+		ChatModel:           kb.ChatModel.Masked(),
+		AnswerCacheSettings: kb.AnswerCacheSettings,
+		AgentSettings:       kb.AgentSettings,
+		CreatedAt:           kb.CreatedAt,
+		UpdatedAt:           kb.UpdatedAt,
 	})
 }
 
@@ -290,3 +297,65 @@ func (h *KnowledgeBaseHandler) GetKBReleaseList(c echo.Context) error {
 
 	return h.NewResponseWithData(c, resp)
 }
+
+// DiffKBRelease
+//
+//	@Summary		知识库版本对比
+//	@Description	对比两个知识库版本中新增、删除和修改的文档
+//	@Tags			knowledge_base
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.KBReleaseDiffReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.KBReleaseDiffResp}
+//	@Router			/api/v1/knowledge_base/release/diff [get]
+func (h *KnowledgeBaseHandler) DiffKBRelease(c echo.Context) error {
+	var req v1.KBReleaseDiffReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "request params is invalid", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+
+	resp, err := h.usecase.DiffKBRelease(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "diff kb release failed", err)
+	}
+
+	return h.NewResponseWithData(c, resp)
+}
+
+// RollbackKBRelease
+//
+//	@Summary		知识库版本回滚
+//	@Description	以目标版本的文档生成新版本，前台阅读和问答索引随之切换
+//	@Tags			knowledge_base
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			body	body		v1.KBReleaseRollbackReq	true	"Rollback Request"
+//	@Success		200		{object}	domain.PWResponse{data=v1.KBReleaseRollbackResp}
+//	@Router			/api/v1/knowledge_base/release/rollback [post]
+func (h *KnowledgeBaseHandler) RollbackKBRelease(c echo.Context) error {
+	ctx := c.Request().Context()
+	authInfo := domain.GetAuthInfoFromCtx(ctx)
+	if authInfo == nil {
+		return h.NewResponseWithError(c, "authInfo not found in context", nil)
+	}
+
+	var req v1.KBReleaseRollbackReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "request body is invalid", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request body failed", err)
+	}
+
+	id, err := h.usecase.RollbackKBRelease(ctx, &req, authInfo.UserId)
+	if err != nil {
+		return h.NewResponseWithError(c, "rollback kb release failed", err)
+	}
+
+	return h.NewResponseWithData(c, v1.KBReleaseRollbackResp{ID: id})
+}
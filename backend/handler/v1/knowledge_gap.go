@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/conversation/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type KnowledgeGapHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.KnowledgeGapUsecase
+}
+
+func NewKnowledgeGapHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.KnowledgeGapUsecase,
+) *KnowledgeGapHandler {
+	h := &KnowledgeGapHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.knowledge_gap"),
+		usecase:     usecase,
+	}
+
+	group := e.Group("/api/v1/knowledge_gap", h.V1Auth.Authorize)
+	group.GET("/report", h.GetKnowledgeGapReport, h.V1Auth.ValidateKBUserPerm(consts.UserKBPermissionDataOperate))
+	group.POST("/draft", h.CreateKnowledgeGapDraft, h.V1Auth.ValidateKBUserPerm(consts.UserKBPermissionDocManage))
+
+	return h
+}
+
+// GetKnowledgeGapReport 知识缺口报告
+//
+//	@Tags			KnowledgeGap
+//	@Summary		知识缺口报告
+//	@Description	将未检索到文档、被点踩或模型无法回答的问题按语义聚类
+//	@ID				v1-GetKnowledgeGapReport
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.KnowledgeGapReportReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.KnowledgeGapReportResp}
+//	@Router			/api/v1/knowledge_gap/report [get]
+func (h *KnowledgeGapHandler) GetKnowledgeGapReport(c echo.Context) error {
+	var req v1.KnowledgeGapReportReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	report, err := h.usecase.GetReport(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to get knowledge gap report", err)
+	}
+	return h.NewResponseWithData(c, report)
+}
+
+// CreateKnowledgeGapDraft 根据知识缺口创建草稿文档
+//
+//	@Tags			KnowledgeGap
+//	@Summary		根据知识缺口创建草稿文档
+//	@Description	由模型根据一组问题生成文档大纲，并创建为未发布的文档
+//	@ID				v1-CreateKnowledgeGapDraft
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.KnowledgeGapDraftReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.KnowledgeGapDraftResp}
+//	@Router			/api/v1/knowledge_gap/draft [post]
+func (h *KnowledgeGapHandler) CreateKnowledgeGapDraft(c echo.Context) error {
+	ctx := c.Request().Context()
+	authInfo := domain.GetAuthInfoFromCtx(ctx)
+	if authInfo == nil {
+		return h.NewResponseWithError(c, "authInfo not found in context", nil)
+	}
+	var req v1.KnowledgeGapDraftReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	draft, err := h.usecase.CreateDraft(ctx, &req, authInfo.UserId, domain.GetBaseEditionLimitation(ctx).MaxNode)
+	if err != nil {
+		if errors.Is(err, domain.ErrMaxNodeLimitReached) {
+			return h.NewResponseWithError(c, "已达到最大文档数量限制，请升级到更高版本", nil)
+		}
+		return h.NewResponseWithError(c, "failed to create knowledge gap draft", err)
+	}
+	return h.NewResponseWithData(c, draft)
+}
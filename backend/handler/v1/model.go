@@ -43,6 +43,11 @@ func NewModelHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *
 	group.POST("/switch-mode", handler.SwitchMode)
 	group.GET("/mode-setting", handler.GetModelModeSetting)
 
+	group.GET("/fallback/list", handler.GetModelFallbackList)
+	group.POST("/fallback", handler.CreateModelFallback)
+	group.PUT("/fallback", handler.UpdateModelFallback)
+	group.DELETE("/fallback", handler.DeleteModelFallback)
+
 	return handler
 }
 
@@ -267,3 +272,117 @@ func (h *ModelHandler) GetModelModeSetting(c echo.Context) error {
 	}
 	return h.NewResponseWithData(c, setting)
 }
+
+// GetModelFallbackList
+//
+//	@Summary		get model fallback list
+//	@Description	get fallback models of the type in the order they are tried
+//	@Tags			model
+//	@Accept			json
+//	@Produce		json
+//	@Param			params	query		domain.GetModelFallbackListReq	true	"params"
+//	@Success		200		{object}	domain.PWResponse{data=[]domain.ModelFallback}
+//	@Router			/api/v1/model/fallback/list [get]
+func (h *ModelHandler) GetModelFallbackList(c echo.Context) error {
+	var req domain.GetModelFallbackListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	fallbacks, err := h.usecase.GetFallbackList(c.Request().Context(), req.Type)
+	if err != nil {
+		return h.NewResponseWithError(c, "get model fallback list failed", err)
+	}
+	return h.NewResponseWithData(c, fallbacks)
+}
+
+// CreateModelFallback
+//
+//	@Summary		create model fallback
+//	@Description	add a fallback model which is tried when the primary model fails
+//	@Tags			model
+//	@Accept			json
+//	@Produce		json
+//	@Param			model	body		domain.CreateModelFallbackReq	true	"create model fallback request"
+//	@Success		200		{object}	domain.PWResponse{data=domain.ModelFallback}
+//	@Router			/api/v1/model/fallback [post]
+func (h *ModelHandler) CreateModelFallback(c echo.Context) error {
+	var req domain.CreateModelFallbackReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+
+	param := domain.ModelParam{}
+	if req.Parameters != nil {
+		param = *req.Parameters
+	}
+	fallback := &domain.ModelFallback{
+		ID:         uuid.New().String(),
+		Type:       req.Type,
+		Priority:   req.Priority,
+		Provider:   req.Provider,
+		Model:      req.Model,
+		APIKey:     req.APIKey,
+		APIHeader:  req.APIHeader,
+		BaseURL:    req.BaseURL,
+		APIVersion: req.APIVersion,
+		Parameters: param,
+	}
+	if err := h.usecase.CreateFallback(c.Request().Context(), fallback); err != nil {
+		return h.NewResponseWithError(c, "create model fallback failed", err)
+	}
+	return h.NewResponseWithData(c, fallback)
+}
+
+// UpdateModelFallback
+//
+//	@Summary		update model fallback
+//	@Description	update model fallback
+//	@Tags			model
+//	@Accept			json
+//	@Produce		json
+//	@Param			model	body		domain.UpdateModelFallbackReq	true	"update model fallback request"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/model/fallback [put]
+func (h *ModelHandler) UpdateModelFallback(c echo.Context) error {
+	var req domain.UpdateModelFallbackReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := h.usecase.UpdateFallback(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "update model fallback failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// DeleteModelFallback
+//
+//	@Summary		delete model fallback
+//	@Description	delete model fallback
+//	@Tags			model
+//	@Accept			json
+//	@Produce		json
+//	@Param			params	query		domain.DeleteModelFallbackReq	true	"params"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/model/fallback [delete]
+func (h *ModelHandler) DeleteModelFallback(c echo.Context) error {
+	var req domain.DeleteModelFallbackReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := h.usecase.DeleteFallback(c.Request().Context(), req.ID); err != nil {
+		return h.NewResponseWithError(c, "delete model fallback failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
@@ -53,6 +53,17 @@ func NewNodeHandler(
 	group.GET("/permission", h.NodePermission)
 	group.PATCH("/permission/edit", h.NodePermissionEdit)
 
+	// node release history
+	group.GET("/release/list", h.NodeReleaseList)
+	group.GET("/release/detail", h.NodeReleaseDetail)
+	group.GET("/release/diff", h.NodeReleaseDiff)
+	group.POST("/release/restore", h.NodeReleaseRestore)
+
+	// recycle bin
+	group.GET("/trash/list", h.NodeTrashList)
+	group.POST("/trash/restore", h.NodeTrashRestore)
+	group.POST("/trash/purge", h.NodeTrashPurge)
+
 	return h
 }
 
@@ -413,3 +424,203 @@ func (h *NodeHandler) NodeRestudy(c echo.Context) error {
 
 	return h.NewResponseWithData(c, nil)
 }
+
+// NodeReleaseList 文档历史版本列表
+//
+//	@Tags			Node
+//	@Summary		文档历史版本列表
+//	@Description	文档历史版本列表
+//	@ID				v1-NodeReleaseList
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.NodeReleaseListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.NodeReleaseListResp}
+//	@Router			/api/v1/node/release/list [get]
+func (h *NodeHandler) NodeReleaseList(c echo.Context) error {
+	var req v1.NodeReleaseListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "request params is invalid", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+
+	resp, err := h.usecase.GetNodeReleaseList(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "get node release list failed", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// NodeReleaseDetail 文档历史版本详情
+//
+//	@Tags			Node
+//	@Summary		文档历史版本详情
+//	@Description	文档历史版本详情
+//	@ID				v1-NodeReleaseDetail
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.NodeReleaseDetailReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.NodeReleaseDetailResp}
+//	@Router			/api/v1/node/release/detail [get]
+func (h *NodeHandler) NodeReleaseDetail(c echo.Context) error {
+	var req v1.NodeReleaseDetailReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "request params is invalid", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+
+	resp, err := h.usecase.GetNodeReleaseDetail(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "get node release detail failed", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// NodeReleaseDiff 文档版本对比
+//
+//	@Tags			Node
+//	@Summary		文档版本对比
+//	@Description	对比文档的两个历史版本，new_id 为空时与当前草稿对比
+//	@ID				v1-NodeReleaseDiff
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.NodeReleaseDiffReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.NodeReleaseDiffResp}
+//	@Router			/api/v1/node/release/diff [get]
+func (h *NodeHandler) NodeReleaseDiff(c echo.Context) error {
+	var req v1.NodeReleaseDiffReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "request params is invalid", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+
+	resp, err := h.usecase.DiffNodeRelease(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "diff node release failed", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// NodeReleaseRestore 恢复文档历史版本
+//
+//	@Tags			Node
+//	@Summary		恢复文档历史版本
+//	@Description	将历史版本内容写回文档草稿并默认立即发布以更新向量，publish 为 false 时仅恢复为草稿
+//	@ID				v1-NodeReleaseRestore
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.NodeReleaseRestoreReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.NodeReleaseRestoreResp}
+//	@Router			/api/v1/node/release/restore [post]
+func (h *NodeHandler) NodeReleaseRestore(c echo.Context) error {
+	ctx := c.Request().Context()
+	authInfo := domain.GetAuthInfoFromCtx(ctx)
+	if authInfo == nil {
+		return h.NewResponseWithError(c, "authInfo not found in context", nil)
+	}
+
+	var req v1.NodeReleaseRestoreReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "request params is invalid", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+
+	resp, err := h.usecase.RestoreNodeRelease(ctx, &req, authInfo.UserId)
+	if err != nil {
+		return h.NewResponseWithError(c, "restore node release failed", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// NodeTrashList 回收站列表
+//
+//	@Tags			Node
+//	@Summary		回收站列表
+//	@Description	回收站列表
+//	@ID				v1-NodeTrashList
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.NodeTrashListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.NodeTrashListResp}
+//	@Router			/api/v1/node/trash/list [get]
+func (h *NodeHandler) NodeTrashList(c echo.Context) error {
+	var req v1.NodeTrashListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "request params is invalid", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+
+	resp, err := h.usecase.GetTrashList(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "get trash list failed", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// NodeTrashRestore 从回收站恢复
+//
+//	@Tags			Node
+//	@Summary		从回收站恢复
+//	@Description	恢复到原父节点和位置，原父节点已不存在时恢复到根目录
+//	@ID				v1-NodeTrashRestore
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.NodeTrashRestoreReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/node/trash/restore [post]
+func (h *NodeHandler) NodeTrashRestore(c echo.Context) error {
+	var req v1.NodeTrashRestoreReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "request params is invalid", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+
+	if err := h.usecase.RestoreTrash(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "restore trash failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// NodeTrashPurge 彻底删除
+//
+//	@Tags			Node
+//	@Summary		彻底删除
+//	@Description	彻底删除回收站中的文档，all 为 true 时清空回收站
+//	@ID				v1-NodeTrashPurge
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.NodeTrashPurgeReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/node/trash/purge [post]
+func (h *NodeHandler) NodeTrashPurge(c echo.Context) error {
+	var req v1.NodeTrashPurgeReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "request params is invalid", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+
+	if err := h.usecase.PurgeTrash(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "purge trash failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
@@ -21,6 +21,17 @@ type APIHandlers struct {
 	StatHandler          *StatHandler
 	CommentHandler       *CommentHandler
 	AuthV1Handler        *AuthV1Handler
+	EvalHandler          *EvalHandler
+	KnowledgeGapHandler  *KnowledgeGapHandler
+	AnswerCacheHandler   *AnswerCacheHandler
+	FAQHandler           *FAQHandler
+	AgentToolHandler     *AgentToolHandler
+	APIUsageHandler      *APIUsageHandler
+	APITokenHandler      *APITokenHandler
+	ContributeHandler    *ContributeHandler
+	WebhookHandler       *WebhookHandler
+	AuditLogHandler      *AuditLogHandler
+	VectorTaskHandler    *VectorTaskHandler
 }
 
 var ProviderSet = wire.NewSet(
@@ -40,6 +51,17 @@ var ProviderSet = wire.NewSet(
 	NewStatHandler,
 	NewCommentHandler,
 	NewAuthV1Handler,
+	NewEvalHandler,
+	NewKnowledgeGapHandler,
+	NewAnswerCacheHandler,
+	NewFAQHandler,
+	NewAgentToolHandler,
+	NewAPIUsageHandler,
+	NewAPITokenHandler,
+	NewContributeHandler,
+	NewWebhookHandler,
+	NewAuditLogHandler,
+	NewVectorTaskHandler,
 
 	wire.Struct(new(APIHandlers), "*"),
 )
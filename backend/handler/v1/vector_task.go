@@ -0,0 +1,118 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/vector/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type VectorTaskHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.VectorTaskUsecase
+}
+
+func NewVectorTaskHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.VectorTaskUsecase,
+) *VectorTaskHandler {
+	h := &VectorTaskHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.vector_task"),
+		usecase:     usecase,
+	}
+
+	group := e.Group("/api/v1/vector_task", h.V1Auth.Authorize, h.V1Auth.ValidateUserRole(consts.UserRoleAdmin))
+	group.GET("/dead_letter/list", h.ListDeadLetters)
+	group.POST("/dead_letter/replay", h.ReplayDeadLetters)
+	group.GET("/metrics", h.GetMetrics)
+
+	return h
+}
+
+// ListDeadLetters 向量任务死信列表
+//
+//	@Tags			VectorTask
+//	@Summary		向量任务死信列表
+//	@Description	多次重试仍失败的向量任务，包括原始任务和最后一次的错误，仅管理员可查看
+//	@ID				v1-ListVectorTaskDeadLetters
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.VectorTaskDeadLetterListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.VectorTaskDeadLetterListResp}
+//	@Router			/api/v1/vector_task/dead_letter/list [get]
+func (h *VectorTaskHandler) ListDeadLetters(c echo.Context) error {
+	var req v1.VectorTaskDeadLetterListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	resp, err := h.usecase.ListDeadLetters(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list vector task dead letters", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// ReplayDeadLetters 重放向量任务死信
+//
+//	@Tags			VectorTask
+//	@Summary		重放向量任务死信
+//	@Description	将待处理的死信重新投递到向量任务队列，重放后重新计算重试次数
+//	@ID				v1-ReplayVectorTaskDeadLetters
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.VectorTaskDeadLetterReplayReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.VectorTaskDeadLetterReplayResp}
+//	@Router			/api/v1/vector_task/dead_letter/replay [post]
+func (h *VectorTaskHandler) ReplayDeadLetters(c echo.Context) error {
+	var req v1.VectorTaskDeadLetterReplayReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	resp, err := h.usecase.ReplayDeadLetters(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to replay vector task dead letters", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// GetMetrics 向量任务指标
+//
+//	@Tags			VectorTask
+//	@Summary		向量任务指标
+//	@Description	队列积压、待处理死信数，以及按小时统计的成功、重试和进入死信的次数
+//	@ID				v1-GetVectorTaskMetrics
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.VectorTaskMetricsReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.VectorTaskMetricsResp}
+//	@Router			/api/v1/vector_task/metrics [get]
+func (h *VectorTaskHandler) GetMetrics(c echo.Context) error {
+	var req v1.VectorTaskMetricsReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	resp, err := h.usecase.Metrics(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to get vector task metrics", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
@@ -0,0 +1,211 @@
+package v1
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+
+	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type WebhookHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.WebhookUsecase
+}
+
+func NewWebhookHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.WebhookUsecase,
+) *WebhookHandler {
+	h := &WebhookHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.webhook"),
+		usecase:     usecase,
+	}
+
+	group := e.Group("/api/v1/knowledge_base/webhook", h.V1Auth.Authorize, h.V1Auth.ValidateKBUserPerm(consts.UserKBPermissionFullControl))
+	group.GET("/list", h.ListWebhooks)
+	group.POST("", h.CreateWebhook)
+	group.PATCH("", h.UpdateWebhook)
+	group.DELETE("", h.DeleteWebhook)
+	group.POST("/test", h.TestWebhook)
+	group.GET("/delivery/list", h.ListWebhookDeliveries)
+
+	return h
+}
+
+// ListWebhooks Webhook 列表
+//
+//	@Tags			Webhook
+//	@Summary		Webhook 列表
+//	@Description	知识库的 Webhook 订阅
+//	@ID				v1-ListWebhooks
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.WebhookListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.WebhookListResp}
+//	@Router			/api/v1/knowledge_base/webhook/list [get]
+func (h *WebhookHandler) ListWebhooks(c echo.Context) error {
+	var req v1.WebhookListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	webhooks, err := h.usecase.List(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list webhooks", err)
+	}
+	return h.NewResponseWithData(c, webhooks)
+}
+
+// CreateWebhook 创建 Webhook
+//
+//	@Tags			Webhook
+//	@Summary		创建 Webhook
+//	@Description	订阅知识库事件，事件以 JSON POST 到 url，请求头 X-PandaWiki-Signature 为 sha256=HMAC-SHA256(secret, "时间戳.请求体")，时间戳见 X-PandaWiki-Timestamp，失败时按指数退避重试
+//	@ID				v1-CreateWebhook
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.WebhookCreateReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=domain.Webhook}
+//	@Router			/api/v1/knowledge_base/webhook [post]
+func (h *WebhookHandler) CreateWebhook(c echo.Context) error {
+	var req v1.WebhookCreateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	webhook, err := h.usecase.Create(c.Request().Context(), &req)
+	if err != nil {
+		return h.webhookError(c, "failed to create webhook", err)
+	}
+	return h.NewResponseWithData(c, webhook)
+}
+
+// UpdateWebhook 更新 Webhook
+//
+//	@Tags			Webhook
+//	@Summary		更新 Webhook
+//	@Description	rotate_secret 为 true 时重新生成签名密钥
+//	@ID				v1-UpdateWebhook
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.WebhookUpdateReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=domain.Webhook}
+//	@Router			/api/v1/knowledge_base/webhook [patch]
+func (h *WebhookHandler) UpdateWebhook(c echo.Context) error {
+	var req v1.WebhookUpdateReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	webhook, err := h.usecase.Update(c.Request().Context(), &req)
+	if err != nil {
+		return h.webhookError(c, "failed to update webhook", err)
+	}
+	return h.NewResponseWithData(c, webhook)
+}
+
+// DeleteWebhook 删除 Webhook
+//
+//	@Tags			Webhook
+//	@Summary		删除 Webhook
+//	@Description	同时删除投递日志
+//	@ID				v1-DeleteWebhook
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.WebhookDeleteReq	true	"para"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/knowledge_base/webhook [delete]
+func (h *WebhookHandler) DeleteWebhook(c echo.Context) error {
+	var req v1.WebhookDeleteReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	if err := h.usecase.Delete(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "failed to delete webhook", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// TestWebhook 发送测试事件
+//
+//	@Tags			Webhook
+//	@Summary		发送测试事件
+//	@Description	立即投递一个 webhook.test 事件并返回投递结果，失败时不重试
+//	@ID				v1-TestWebhook
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	body		v1.WebhookTestReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=domain.WebhookDelivery}
+//	@Router			/api/v1/knowledge_base/webhook/test [post]
+func (h *WebhookHandler) TestWebhook(c echo.Context) error {
+	var req v1.WebhookTestReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	delivery, err := h.usecase.SendTest(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to send test event", err)
+	}
+	return h.NewResponseWithData(c, delivery)
+}
+
+// ListWebhookDeliveries Webhook 投递日志
+//
+//	@Tags			Webhook
+//	@Summary		Webhook 投递日志
+//	@Description	按时间倒序返回投递记录，包括尝试次数、响应状态码和下次重试时间
+//	@ID				v1-ListWebhookDeliveries
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerAuth
+//	@Param			param	query		v1.WebhookDeliveryListReq	true	"para"
+//	@Success		200		{object}	domain.PWResponse{data=v1.WebhookDeliveryListResp}
+//	@Router			/api/v1/knowledge_base/webhook/delivery/list [get]
+func (h *WebhookHandler) ListWebhookDeliveries(c echo.Context) error {
+	var req v1.WebhookDeliveryListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "validate request params failed", err)
+	}
+	deliveries, err := h.usecase.ListDeliveries(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to list webhook deliveries", err)
+	}
+	return h.NewResponseWithData(c, deliveries)
+}
+
+func (h *WebhookHandler) webhookError(c echo.Context, msg string, err error) error {
+	if errors.Is(err, domain.ErrWebhookEventInvalid) {
+		return h.NewResponseWithError(c, "不支持的事件类型", err)
+	}
+	return h.NewResponseWithError(c, msg, err)
+}
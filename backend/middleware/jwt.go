@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	echoMiddleware "github.com/labstack/echo-jwt/v4"
@@ -65,6 +66,7 @@ func (m *JWTMiddleware) Authorize(next echo.HandlerFunc) echo.HandlerFunc {
 					IsToken:    false,
 					Permission: consts.UserKBPermissionNull,
 					UserId:     userID,
+					RemoteIP:   c.RealIP(),
 				})
 
 				req := c.Request().WithContext(ctx)
@@ -95,17 +97,44 @@ func (m *JWTMiddleware) validateAPIToken(c echo.Context, token string, next echo
 			Message: "Unauthorized",
 		})
 	}
+	if apiToken.IsExpired(time.Now()) {
+		m.logger.Info("API token expired", log.String("token_id", apiToken.ID))
+		return c.JSON(http.StatusUnauthorized, domain.PWResponse{
+			Success: false,
+			Message: "Unauthorized token expired",
+		})
+	}
+
+	// token 只能访问声明了权限范围的接口，未声明的接口仅兼容升级前创建的 token
+	scope, ok := apiToken.RouteScope(c.Request().Method, c.Path())
+	if !ok && scope == "" {
+		return c.JSON(http.StatusForbidden, domain.PWResponse{
+			Success: false,
+			Message: "token not supported",
+		})
+	}
+	if !ok {
+		return c.JSON(http.StatusForbidden, domain.PWResponse{
+			Success: false,
+			Message: "Unauthorized token scope " + string(scope),
+		})
+	}
 
 	ctx := context.WithValue(c.Request().Context(), domain.CtxAuthInfoKey, &domain.CtxAuthInfo{
 		IsToken:    true,
 		Permission: apiToken.Permission,
+		Scopes:     apiToken.Scopes,
 		UserId:     apiToken.UserID,
 		KBId:       apiToken.KbId,
+		TokenId:    apiToken.ID,
+		RemoteIP:   c.RealIP(),
 	})
 
 	req := c.Request().WithContext(ctx)
 	c.SetRequest(req)
 
+	m.apiTokenRepo.UpdateLastUsed(apiToken.ID)
+
 	return next(c)
 }
 
@@ -202,6 +231,20 @@ func (m *JWTMiddleware) ValidateKBUserPerm(perm consts.UserKBPermission) echo.Mi
 	}
 }
 
+// RejectToken allows only users, e.g. for the management of the api tokens themselves
+func (m *JWTMiddleware) RejectToken(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		authInfo := domain.GetAuthInfoFromCtx(c.Request().Context())
+		if authInfo == nil || authInfo.IsToken {
+			return c.JSON(http.StatusForbidden, domain.PWResponse{
+				Success: false,
+				Message: "token not supported",
+			})
+		}
+		return next(c)
+	}
+}
+
 func (m *JWTMiddleware) ValidateLicenseEdition(needEditions ...consts.LicenseEdition) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
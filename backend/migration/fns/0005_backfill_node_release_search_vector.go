@@ -0,0 +1,52 @@
+package fns
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+type MigrationBackfillNodeReleaseSearchVector struct {
+	Name   string
+	logger *log.Logger
+}
+
+func NewMigrationBackfillNodeReleaseSearchVector(logger *log.Logger) *MigrationBackfillNodeReleaseSearchVector {
+	return &MigrationBackfillNodeReleaseSearchVector{
+		Name:   "0005_backfill_node_release_search_vector",
+		logger: logger,
+	}
+}
+
+func (m *MigrationBackfillNodeReleaseSearchVector) Execute(tx *gorm.DB) error {
+	// 为已有的文档发布版本生成关键词检索向量，分词在 Go 中完成，只能逐条更新
+	const batchSize = 100
+	total := 0
+	for {
+		var releases []*domain.NodeRelease
+		if err := tx.Model(&domain.NodeRelease{}).
+			Select("id, name, content").
+			Where("search_vector IS NULL").
+			Order("id").
+			Limit(batchSize).
+			Find(&releases).Error; err != nil {
+			return err
+		}
+		if len(releases) == 0 {
+			break
+		}
+		for _, release := range releases {
+			if err := tx.Model(&domain.NodeRelease{}).
+				Where("id = ?", release.ID).
+				UpdateColumn("search_vector", pg.NodeReleaseSearchVectorExpr(release.Name, release.Content)).Error; err != nil {
+				return err
+			}
+		}
+		total += len(releases)
+	}
+
+	m.logger.Info("migration backfill node release search vector", log.Int("affected_rows", total))
+	return nil
+}
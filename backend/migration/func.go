@@ -9,6 +9,7 @@ type MigrationFuncs struct {
 	BotAuthMigration                    *fns.MigrationCreateBotAuth
 	FixGroupIdsMigration                *fns.MigrationFixGroupIds
 	UpdateNodeStatusUnreleasedMigration *fns.MigrationUpdateNodeStatusUnreleased
+	BackfillSearchVectorMigration       *fns.MigrationBackfillNodeReleaseSearchVector
 }
 
 func (mf *MigrationFuncs) GetMigrationFuncs() []MigrationFunc {
@@ -29,5 +30,9 @@ func (mf *MigrationFuncs) GetMigrationFuncs() []MigrationFunc {
 		Name: mf.UpdateNodeStatusUnreleasedMigration.Name,
 		Fn:   mf.UpdateNodeStatusUnreleasedMigration.Execute,
 	})
+	funcs = append(funcs, MigrationFunc{
+		Name: mf.BackfillSearchVectorMigration.Name,
+		Fn:   mf.BackfillSearchVectorMigration.Execute,
+	})
 	return funcs
 }
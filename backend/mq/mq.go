@@ -16,6 +16,7 @@ import (
 type Message interface {
 	GetData() []byte
 	GetTopic() string
+	GetNumDelivered() int
 }
 
 type MQConsumer interface {
@@ -26,6 +27,7 @@ type MQConsumer interface {
 
 type MQProducer interface {
 	Produce(ctx context.Context, topic string, key string, value []byte) error
+	QueueStat(ctx context.Context, topic string) (*types.QueueStat, error)
 }
 
 func NewMQConsumer(config *config.Config, logger *log.Logger) (MQConsumer, error) {
@@ -2,6 +2,7 @@ package nats
 
 import (
 	"context"
+	"errors"
 	"sync"
 
 	"github.com/nats-io/nats.go"
@@ -110,6 +111,19 @@ func (c *MQConsumer) registerJetStreamHandler(topic string, handler func(ctx con
 			log.Int("data_size", len(msg.Data)))
 
 		if err := handler(context.Background(), &Message{msg: msg}); err != nil {
+			var retryErr *types.RetryError
+			if errors.As(err, &retryErr) {
+				c.logger.Warn("handle message failed, retry later",
+					log.String("topic", topic),
+					log.String("delay", retryErr.Delay.String()),
+					log.Error(retryErr.Err))
+				if err := msg.NakWithDelay(retryErr.Delay); err != nil {
+					c.logger.Error("failed to nak message",
+						log.String("topic", topic),
+						log.Error(err))
+				}
+				return
+			}
 			c.logger.Error("handle message failed",
 				log.String("topic", topic),
 				log.Error(err))
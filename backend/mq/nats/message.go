@@ -18,4 +18,13 @@ func (m *Message) GetTopic() string {
 	return m.msg.Subject
 }
 
+// GetNumDelivered returns the delivery count of JetStream, core NATS messages are delivered only once
+func (m *Message) GetNumDelivered() int {
+	meta, err := m.msg.Metadata()
+	if err != nil {
+		return 1
+	}
+	return int(meta.NumDelivered)
+}
+
 var _ types.Message = (*Message)(nil)
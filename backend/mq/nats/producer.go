@@ -8,7 +8,9 @@ import (
 	"github.com/nats-io/nats.go"
 
 	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/mq/types"
 )
 
 type MQProducer struct {
@@ -30,6 +32,14 @@ func (p *MQProducer) EnsureStreams() error {
 			name:     "scraper",
 			subjects: []string{"apps.panda-wiki.scraper.>"},
 		},
+		{
+			name:     "webhook",
+			subjects: []string{"apps.panda-wiki.webhook.>"},
+		},
+		{
+			name:     "dead_letter",
+			subjects: []string{domain.VectorTaskDeadLetterTopic}, // 通配符会与 scraper、webhook 等 stream 的主题重叠，只绑定确定的主题
+		},
 	}
 
 	for _, stream := range streams {
@@ -122,6 +132,23 @@ func (p *MQProducer) Produce(ctx context.Context, topic string, key string, valu
 	return nil
 }
 
+// QueueStat returns the backlog of the durable consumer of the topic
+func (p *MQProducer) QueueStat(ctx context.Context, topic string) (*types.QueueStat, error) {
+	stream, err := p.js.StreamNameBySubject(topic, nats.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find stream of %s: %w", topic, err)
+	}
+	info, err := p.js.ConsumerInfo(stream, domain.TopicConsumerName[topic], nats.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consumer info of %s: %w", topic, err)
+	}
+	return &types.QueueStat{
+		Pending:     info.NumPending,
+		AckPending:  info.NumAckPending,
+		Redelivered: info.NumRedelivered,
+	}, nil
+}
+
 func (p *MQProducer) Close() error {
 	p.conn.Close()
 	return nil
@@ -1,7 +1,39 @@
 package types
 
+import (
+	"fmt"
+	"time"
+)
+
 // Message represents a generic message that can be from either Kafka or NATS
 type Message interface {
 	GetData() []byte
 	GetTopic() string
+	// GetNumDelivered returns how many times the message has been delivered, starting from 1
+	GetNumDelivered() int
+}
+
+// RetryError asks the consumer to deliver the message again after Delay instead of acking it
+type RetryError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func NewRetryError(err error, delay time.Duration) *RetryError {
+	return &RetryError{Err: err, Delay: delay}
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("retry after %s: %v", e.Delay, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// QueueStat is the backlog of the durable consumer of a topic
+type QueueStat struct {
+	Pending     uint64
+	AckPending  int
+	Redelivered int
 }
@@ -7,3 +7,22 @@ import (
 )
 
 type GetQAFun func(ctx context.Context, msg string, info domain.ConversationInfo, ConversationID string) (chan string, error)
+
+// QAResult collects what is answered besides the content,
+// GetQAFun fills it before closing the answer channel
+type QAResult struct {
+	Suggestions []string // 推荐的追问，可作为快捷回复按钮
+}
+
+type qaResultCtxKey struct{}
+
+// WithQAResult returns a context for GetQAFun which fills result
+func WithQAResult(ctx context.Context, result *QAResult) context.Context {
+	return context.WithValue(ctx, qaResultCtxKey{}, result)
+}
+
+// QAResultFromCtx returns nil when the caller does not need the result
+func QAResultFromCtx(ctx context.Context) *QAResult {
+	result, _ := ctx.Value(qaResultCtxKey{}).(*QAResult)
+	return result
+}
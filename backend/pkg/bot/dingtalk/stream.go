@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -239,7 +240,8 @@ func (c *DingTalkClient) OnChatBotMessageReceived(ctx context.Context, data *cha
 		convInfo.UserInfo.From = domain.MessageFromPrivate
 	}
 
-	contentCh, err := c.getQA(ctx, question, *convInfo, "")
+	qaResult := &bot.QAResult{}
+	contentCh, err := c.getQA(bot.WithQAResult(ctx, qaResult), question, *convInfo, "")
 	if err != nil {
 		c.logger.Error("dingtalk client failed to get answer", log.Error(err))
 		if err := c.UpdateAIStreamCard(trackID, "出错了，请稍后再试", true); err != nil {
@@ -257,6 +259,7 @@ func (c *DingTalkClient) OnChatBotMessageReceived(ctx context.Context, data *cha
 		select {
 		case content, ok := <-contentCh:
 			if !ok {
+				fullContent += formatSuggestions(qaResult.Suggestions, data.ConversationType)
 				if err := c.UpdateAIStreamCard(trackID, fullContent, true); err != nil {
 					c.logger.Error("UpdateInteractiveCard in contentCh", log.Error(err))
 					if err := c.UpdateAIStreamCard(trackID, "出错了，请稍后再试", true); err != nil {
@@ -281,6 +284,23 @@ func (c *DingTalkClient) OnChatBotMessageReceived(ctx context.Context, data *cha
 	}
 }
 
+// formatSuggestions 单聊中使用 dtmd 链接，点击后以用户身份发送问题；群聊中需要 @机器人，只展示问题
+func formatSuggestions(suggestions []string, conversationType string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	content := strings.Builder{}
+	content.WriteString("\n\n---\n\n**你可能还想问：**\n\n")
+	for _, suggestion := range suggestions {
+		if conversationType == "1" {
+			content.WriteString(fmt.Sprintf("- [%s](dtmd://dingtalkclient/sendMessage?content=%s)\n", suggestion, url.QueryEscape(suggestion)))
+		} else {
+			content.WriteString(fmt.Sprintf("- %s\n", suggestion))
+		}
+	}
+	return content.String()
+}
+
 func (c *DingTalkClient) Start() error {
 	cli := client.NewStreamClient(client.WithAppCredential(client.NewAppCredentialConfig(
 		c.clientID,
@@ -11,6 +11,7 @@ import (
 	"github.com/google/uuid"
 	lark "github.com/larksuite/oapi-sdk-go/v3"
 	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher/callback"
 	larkcardkit "github.com/larksuite/oapi-sdk-go/v3/service/cardkit/v1"
 	larkcontact "github.com/larksuite/oapi-sdk-go/v3/service/contact/v3"
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
@@ -179,7 +180,8 @@ func (c *FeishuClient) sendQACard(ctx context.Context, receiveIdType string, rec
 		convInfo.UserInfo.From = domain.MessageFromGroup // 群聊
 	}
 
-	answerCh, err := c.getQA(ctx, question, convInfo, "")
+	qaResult := &bot.QAResult{}
+	answerCh, err := c.getQA(bot.WithQAResult(ctx, qaResult), question, convInfo, "")
 	if err != nil {
 		c.logger.Error("get QA failed", log.Error(err))
 		return
@@ -214,9 +216,93 @@ func (c *FeishuClient) sendQACard(ctx context.Context, receiveIdType string, rec
 			return
 		}
 	}
+	chatType := "p2p"
+	if receiveIdType == "chat_id" {
+		chatType = "group"
+	}
+	c.appendSuggestions(ctx, *resp.Data.CardId, seq, chatType, qaResult.Suggestions)
 	c.logger.Info("start processing QA", log.String("message_id", *res.Data.MessageId))
 }
 
+// appendSuggestions 在回答后追加推荐追问按钮，点击后以点击者的身份提问
+func (c *FeishuClient) appendSuggestions(ctx context.Context, cardID string, seq int, chatType string, suggestions []string) {
+	if len(suggestions) == 0 {
+		return
+	}
+	elements := []map[string]any{{"tag": "markdown", "element_id": "suggestion_title", "content": "**你可能还想问：**"}}
+	for i, suggestion := range suggestions {
+		elements = append(elements, map[string]any{
+			"tag":        "button",
+			"element_id": fmt.Sprintf("suggestion_%d", i+1),
+			"type":       "default",
+			"width":      "fill",
+			"text":       map[string]string{"tag": "plain_text", "content": suggestion},
+			"behaviors": []map[string]any{{
+				"type":  "callback",
+				"value": map[string]string{"question": suggestion, "chat_type": chatType},
+			}},
+		})
+	}
+	data, err := json.Marshal(elements)
+	if err != nil {
+		c.logger.Error("failed to marshal suggestion elements", log.Error(err))
+		return
+	}
+	createResp, err := c.client.Cardkit.V1.CardElement.Create(ctx, larkcardkit.NewCreateCardElementReqBuilder().
+		CardId(cardID).
+		Body(larkcardkit.NewCreateCardElementReqBodyBuilder().
+			Type("append").
+			Uuid(uuid.New().String()).
+			Sequence(seq+1).
+			Elements(string(data)).
+			Build()).
+		Build())
+	if err != nil {
+		c.logger.Error("failed to append suggestions", log.Error(err))
+		return
+	}
+	if !createResp.Success() {
+		c.logger.Error("failed to append suggestions", log.String("request_id", createResp.RequestId()), log.Any("code_error", createResp.CodeError))
+		return
+	}
+	// 流式更新模式下卡片不可交互，追加按钮后关闭
+	settingsResp, err := c.client.Cardkit.V1.Card.Settings(ctx, larkcardkit.NewSettingsCardReqBuilder().
+		CardId(cardID).
+		Body(larkcardkit.NewSettingsCardReqBodyBuilder().
+			Settings(`{"config":{"streaming_mode":false}}`).
+			Uuid(uuid.New().String()).
+			Sequence(seq+2).
+			Build()).
+		Build())
+	if err != nil {
+		c.logger.Error("failed to close card streaming mode", log.Error(err))
+		return
+	}
+	if !settingsResp.Success() {
+		c.logger.Error("failed to close card streaming mode", log.String("request_id", settingsResp.RequestId()), log.Any("code_error", settingsResp.CodeError))
+	}
+}
+
+// onSuggestionClicked 点击推荐追问按钮时，在原会话中以点击者的身份提问
+func (c *FeishuClient) onSuggestionClicked(ctx context.Context, event *callback.CardActionTriggerEvent) (*callback.CardActionTriggerResponse, error) {
+	if event.Event == nil || event.Event.Action == nil || event.Event.Operator == nil || event.Event.Context == nil {
+		return nil, nil
+	}
+	question, _ := event.Event.Action.Value["question"].(string)
+	if question == "" {
+		return nil, nil
+	}
+	chatType, _ := event.Event.Action.Value["chat_type"].(string)
+	c.logger.Info("suggestion clicked", log.String("question", question), log.String("chat_type", chatType))
+	// 回调需要在 3 秒内响应，异步回答
+	if chatType == "group" {
+		go c.sendQACard(c.ctx, "chat_id", event.Event.Context.OpenChatID, question, event.Event.Operator.OpenID)
+	} else {
+		go c.sendQACard(c.ctx, "open_id", event.Event.Operator.OpenID, question, event.Event.Context.OpenChatID)
+	}
+	return &callback.CardActionTriggerResponse{}, nil
+}
+
 type Message struct {
 	Text string `json:"text"`
 }
@@ -257,7 +343,8 @@ func (c *FeishuClient) Start() error {
 				c.logger.Warn("unsupported chat type", log.String("chat_type", *event.Event.Message.ChatType))
 			}
 			return nil
-		})
+		}).
+		OnP2CardActionTrigger(c.onSuggestionClicked)
 
 	cli := larkws.NewClient(c.clientID, c.clientSecret,
 		larkws.WithEventHandler(eventHandler),
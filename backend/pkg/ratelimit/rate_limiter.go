@@ -100,3 +100,74 @@ func (r *RateLimiter) ResetLoginAttempts(ctx context.Context, ip string) error {
 	}
 	return nil
 }
+
+// 开放 API 按分钟固定窗口限流，key 通常为知识库 ID
+const apiWindowExpiry = 2 * time.Minute
+
+func apiWindowKey(kind, key string) string {
+	return fmt.Sprintf("api_rate:%s:%s:%d", kind, key, time.Now().Unix()/60)
+}
+
+// untilNextWindow returns the time until the current minute window ends
+func untilNextWindow() time.Duration {
+	return time.Minute - time.Duration(time.Now().Unix()%60)*time.Second
+}
+
+// AllowRequest counts a request in the current minute, limit <= 0 means unlimited
+// Returns:
+// - bool: whether the request is allowed
+// - time.Duration: time until the limit resets when not allowed
+func (r *RateLimiter) AllowRequest(ctx context.Context, key string, limit int) (bool, time.Duration) {
+	if limit <= 0 {
+		return true, 0
+	}
+	windowKey := apiWindowKey("requests", key)
+	count, err := r.cache.Incr(ctx, windowKey).Result()
+	if err != nil {
+		// 缓存不可用时不拦截请求
+		r.logger.Error("failed to increment api requests", "error", err, "key", key)
+		return true, 0
+	}
+	if count == 1 {
+		if err := r.cache.Expire(ctx, windowKey, apiWindowExpiry).Err(); err != nil {
+			r.logger.Error("failed to set expiry on api requests key", "error", err, "key", key)
+		}
+	}
+	if count > int64(limit) {
+		return false, untilNextWindow()
+	}
+	return true, 0
+}
+
+// AllowTokens checks the tokens used in the current minute against limit, limit <= 0 means unlimited.
+// Tokens are only known after the request so they are added with AddTokens.
+func (r *RateLimiter) AllowTokens(ctx context.Context, key string, limit int) (bool, time.Duration) {
+	if limit <= 0 {
+		return true, 0
+	}
+	used, err := r.cache.Get(ctx, apiWindowKey("tokens", key)).Int64()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			r.logger.Error("failed to get api tokens", "error", err, "key", key)
+		}
+		return true, 0
+	}
+	if used >= int64(limit) {
+		return false, untilNextWindow()
+	}
+	return true, 0
+}
+
+// AddTokens adds the tokens used by a request to the current minute
+func (r *RateLimiter) AddTokens(ctx context.Context, key string, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	windowKey := apiWindowKey("tokens", key)
+	pipe := r.cache.Pipeline()
+	pipe.IncrBy(ctx, windowKey, int64(tokens))
+	pipe.Expire(ctx, windowKey, apiWindowExpiry)
+	if _, err := pipe.Exec(ctx); err != nil {
+		r.logger.Error("failed to add api tokens", "error", err, "key", key)
+	}
+}
@@ -28,3 +28,29 @@ func (r *RAGRepository) AsyncUpdateNodeReleaseVector(ctx context.Context, reques
 	}
 	return nil
 }
+
+// PublishVectorTaskDeadLetter sends the vector task that still failed after the last retry to the dead letter topic
+func (r *RAGRepository) PublishVectorTaskDeadLetter(ctx context.Context, deadLetter *domain.VectorTaskDeadLetter) error {
+	deadLetterBytes, err := json.Marshal(deadLetter)
+	if err != nil {
+		return err
+	}
+	return r.producer.Produce(ctx, domain.VectorTaskDeadLetterTopic, "", deadLetterBytes)
+}
+
+// ReplayVectorTask sends the raw payload of a dead lettered task to the vector task topic again
+func (r *RAGRepository) ReplayVectorTask(ctx context.Context, payload []byte) error {
+	return r.producer.Produce(ctx, domain.VectorTaskTopic, "", payload)
+}
+
+func (r *RAGRepository) VectorTaskQueueStat(ctx context.Context) (*domain.VectorTaskQueueStat, error) {
+	stat, err := r.producer.QueueStat(ctx, domain.VectorTaskTopic)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.VectorTaskQueueStat{
+		Pending:     stat.Pending,
+		AckPending:  stat.AckPending,
+		Redelivered: stat.Redelivered,
+	}, nil
+}
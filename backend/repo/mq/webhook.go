@@ -0,0 +1,30 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/mq"
+)
+
+type WebhookRepository struct {
+	producer mq.MQProducer
+}
+
+func NewWebhookRepository(producer mq.MQProducer) *WebhookRepository {
+	return &WebhookRepository{producer: producer}
+}
+
+// AsyncPublishEvent sends the event to the consumer, which delivers it to the subscribed webhooks of the kb
+func (r *WebhookRepository) AsyncPublishEvent(ctx context.Context, kbID string, eventType domain.WebhookEventType, data any) error {
+	event, err := domain.NewWebhookEvent(kbID, eventType, data)
+	if err != nil {
+		return err
+	}
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return r.producer.Produce(ctx, domain.WebhookEventTopic, "", eventBytes)
+}
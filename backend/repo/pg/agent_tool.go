@@ -0,0 +1,69 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type AgentToolRepository struct {
+	db     *pg.DB
+	logger *log.Logger
+}
+
+func NewAgentToolRepository(db *pg.DB, logger *log.Logger) *AgentToolRepository {
+	return &AgentToolRepository{db: db, logger: logger.WithModule("repo.pg.agent_tool")}
+}
+
+func (r *AgentToolRepository) Create(ctx context.Context, tool *domain.AgentTool) error {
+	return r.db.WithContext(ctx).Create(tool).Error
+}
+
+func (r *AgentToolRepository) Get(ctx context.Context, kbID, id string) (*domain.AgentTool, error) {
+	var tool domain.AgentTool
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		First(&tool).Error; err != nil {
+		return nil, err
+	}
+	return &tool, nil
+}
+
+func (r *AgentToolRepository) List(ctx context.Context, kbID string) ([]*domain.AgentTool, error) {
+	tools := make([]*domain.AgentTool, 0)
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ?", kbID).
+		Order("created_at ASC").
+		Find(&tools).Error; err != nil {
+		return nil, err
+	}
+	return tools, nil
+}
+
+func (r *AgentToolRepository) ListEnabled(ctx context.Context, kbID string) ([]*domain.AgentTool, error) {
+	tools := make([]*domain.AgentTool, 0)
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND enabled", kbID).
+		Order("created_at ASC").
+		Find(&tools).Error; err != nil {
+		return nil, err
+	}
+	return tools, nil
+}
+
+func (r *AgentToolRepository) Update(ctx context.Context, kbID, id string, updateMap map[string]any) error {
+	updateMap["updated_at"] = time.Now()
+	return r.db.WithContext(ctx).
+		Model(&domain.AgentTool{}).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		Updates(updateMap).Error
+}
+
+func (r *AgentToolRepository) Delete(ctx context.Context, kbID, id string) error {
+	return r.db.WithContext(ctx).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		Delete(&domain.AgentTool{}).Error
+}
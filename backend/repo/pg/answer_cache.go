@@ -0,0 +1,122 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type AnswerCacheRepository struct {
+	db     *pg.DB
+	logger *log.Logger
+}
+
+func NewAnswerCacheRepository(db *pg.DB, logger *log.Logger) *AnswerCacheRepository {
+	return &AnswerCacheRepository{db: db, logger: logger.WithModule("repo.pg.answer_cache")}
+}
+
+// GetByQuestionHash returns nil when no entry of the release, app and group matches the question
+func (r *AnswerCacheRepository) GetByQuestionHash(ctx context.Context, kbID, releaseID, appID, groupKey, questionHash string, since time.Time) (*domain.AnswerCache, error) {
+	var cache domain.AnswerCache
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND release_id = ? AND app_id = ? AND group_key = ? AND question_hash = ?", kbID, releaseID, appID, groupKey, questionHash).
+		Where("created_at > ?", since).
+		First(&cache).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cache, nil
+}
+
+// GetEmbeddings returns the id and embedding of the entries of the release, app and group for similarity matching
+func (r *AnswerCacheRepository) GetEmbeddings(ctx context.Context, kbID, releaseID, appID, groupKey string, since time.Time) ([]*domain.AnswerCache, error) {
+	caches := make([]*domain.AnswerCache, 0)
+	if err := r.db.WithContext(ctx).
+		Model(&domain.AnswerCache{}).
+		Select("id, embedding").
+		Where("kb_id = ? AND release_id = ? AND app_id = ? AND group_key = ?", kbID, releaseID, appID, groupKey).
+		Where("created_at > ? AND embedding IS NOT NULL", since).
+		Find(&caches).Error; err != nil {
+		return nil, err
+	}
+	return caches, nil
+}
+
+func (r *AnswerCacheRepository) GetByID(ctx context.Context, id string) (*domain.AnswerCache, error) {
+	var cache domain.AnswerCache
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&cache).Error; err != nil {
+		return nil, err
+	}
+	return &cache, nil
+}
+
+func (r *AnswerCacheRepository) CountByRelease(ctx context.Context, kbID, releaseID string) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&domain.AnswerCache{}).
+		Where("kb_id = ? AND release_id = ?", kbID, releaseID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Create keeps the existing entry when the same question was cached concurrently
+func (r *AnswerCacheRepository) Create(ctx context.Context, cache *domain.AnswerCache) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(cache).Error
+}
+
+func (r *AnswerCacheRepository) RecordHit(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.AnswerCache{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"hit_count":   gorm.Expr("hit_count + 1"),
+			"last_hit_at": time.Now(),
+		}).Error
+}
+
+func (r *AnswerCacheRepository) List(ctx context.Context, req *v1.AnswerCacheListReq) (int64, []*domain.AnswerCache, error) {
+	query := r.db.WithContext(ctx).
+		Model(&domain.AnswerCache{}).
+		Where("kb_id = ?", req.KBId)
+	if req.Question != "" {
+		query = query.Where("question ILIKE ?", "%"+req.Question+"%")
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+	caches := make([]*domain.AnswerCache, 0)
+	if err := query.
+		Omit("embedding").
+		Order("hit_count DESC, created_at DESC").
+		Offset(req.Offset()).
+		Limit(req.Limit()).
+		Find(&caches).Error; err != nil {
+		return 0, nil, err
+	}
+	return total, caches, nil
+}
+
+// Delete deletes the entries of the kb, all of them when ids is empty
+func (r *AnswerCacheRepository) Delete(ctx context.Context, kbID string, ids []string) (int64, error) {
+	query := r.db.WithContext(ctx).Where("kb_id = ?", kbID)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	result := query.Delete(&domain.AnswerCache{})
+	return result.RowsAffected, result.Error
+}
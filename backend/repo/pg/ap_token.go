@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
@@ -15,21 +16,29 @@ import (
 )
 
 type APITokenRepo struct {
-	db     *pg.DB
-	logger *log.Logger
-	cache  *cache.Cache
+	db       *pg.DB
+	logger   *log.Logger
+	cache    *cache.Cache
+	usageMap sync.Map // token id -> last used time, synced to database periodically
 }
 
 func NewAPITokenRepo(db *pg.DB, logger *log.Logger, cache *cache.Cache) *APITokenRepo {
-	return &APITokenRepo{
+	repo := &APITokenRepo{
 		db:     db,
-		logger: logger,
+		logger: logger.WithModule("repo.pg.api_token"),
 		cache:  cache,
 	}
+	go repo.startSyncTask()
+	return repo
+}
+
+func apiTokenCacheKey(tokenHash string) string {
+	return fmt.Sprintf("api_token:%s", tokenHash)
 }
 
 func (r *APITokenRepo) GetByTokenWithCache(ctx context.Context, token string) (*domain.APIToken, error) {
-	cacheKey := fmt.Sprintf("api_token:%s", token)
+	tokenHash := domain.HashAPIToken(token)
+	cacheKey := apiTokenCacheKey(tokenHash)
 
 	cachedData, err := r.cache.Get(ctx, cacheKey).Result()
 	if err == nil && cachedData != "" {
@@ -41,7 +50,7 @@ func (r *APITokenRepo) GetByTokenWithCache(ctx context.Context, token string) (*
 
 	// 缓存未命中，从数据库查询
 	var apiToken domain.APIToken
-	if err := r.db.WithContext(ctx).Where("token = ?", token).First(&apiToken).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("token = ?", tokenHash).First(&apiToken).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -56,3 +65,110 @@ func (r *APITokenRepo) GetByTokenWithCache(ctx context.Context, token string) (*
 
 	return &apiToken, nil
 }
+
+func (r *APITokenRepo) Create(ctx context.Context, apiToken *domain.APIToken) error {
+	return r.db.WithContext(ctx).Create(apiToken).Error
+}
+
+func (r *APITokenRepo) List(ctx context.Context, kbID string) ([]*domain.APIToken, error) {
+	var apiTokens []*domain.APIToken
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ?", kbID).
+		Order("created_at DESC").
+		Find(&apiTokens).Error; err != nil {
+		return nil, err
+	}
+	return apiTokens, nil
+}
+
+func (r *APITokenRepo) GetByID(ctx context.Context, kbID, id string) (*domain.APIToken, error) {
+	var apiToken domain.APIToken
+	if err := r.db.WithContext(ctx).Where("kb_id = ? AND id = ?", kbID, id).First(&apiToken).Error; err != nil {
+		return nil, err
+	}
+	return &apiToken, nil
+}
+
+// Update saves the changes of the token and drops its cached copy
+func (r *APITokenRepo) Update(ctx context.Context, apiToken *domain.APIToken, oldTokenHash string) error {
+	if err := r.db.WithContext(ctx).
+		Model(&domain.APIToken{}).
+		Where("kb_id = ? AND id = ?", apiToken.KbId, apiToken.ID).
+		Updates(map[string]any{
+			"name":         apiToken.Name,
+			"token":        apiToken.Token,
+			"token_prefix": apiToken.TokenPrefix,
+			"scopes":       apiToken.Scopes,
+			"expires_at":   apiToken.ExpiresAt,
+			"updated_at":   apiToken.UpdatedAt,
+		}).Error; err != nil {
+		return err
+	}
+	r.invalidate(ctx, oldTokenHash)
+	return nil
+}
+
+// Delete revokes the token, the cached copy is dropped so that it stops working at once
+func (r *APITokenRepo) Delete(ctx context.Context, kbID, id string) error {
+	apiToken, err := r.GetByID(ctx, kbID, id)
+	if err != nil {
+		return err
+	}
+	if err := r.db.WithContext(ctx).Where("kb_id = ? AND id = ?", kbID, id).Delete(&domain.APIToken{}).Error; err != nil {
+		return err
+	}
+	r.usageMap.Delete(id)
+	r.invalidate(ctx, apiToken.Token)
+	return nil
+}
+
+func (r *APITokenRepo) invalidate(ctx context.Context, tokenHash string) {
+	if err := r.cache.Del(ctx, apiTokenCacheKey(tokenHash)).Err(); err != nil {
+		r.logger.Error("failed to delete cached API token", log.Error(err))
+	}
+}
+
+// UpdateLastUsed records the use of a token, the time is written to database by the sync task
+func (r *APITokenRepo) UpdateLastUsed(id string) {
+	r.usageMap.Store(id, time.Now())
+}
+
+func (r *APITokenRepo) startSyncTask() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		r.syncToDatabase()
+	}
+}
+
+func (r *APITokenRepo) syncToDatabase() {
+	updates := make(map[string]time.Time)
+	r.usageMap.Range(func(key, value any) bool {
+		updates[key.(string)] = value.(time.Time)
+		return true
+	})
+	if len(updates) == 0 {
+		return
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for id, lastUsedAt := range updates {
+			if err := tx.Model(&domain.APIToken{}).
+				Where("id = ?", id).
+				Update("last_used_at", lastUsedAt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.logger.Error("failed to sync api token last used time to database", log.Error(err), log.Int("update_count", len(updates)))
+		return
+	}
+
+	for id, lastUsedAt := range updates {
+		// 同步期间再次使用的保留到下次同步
+		r.usageMap.CompareAndDelete(id, lastUsedAt)
+	}
+}
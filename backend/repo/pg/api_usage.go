@@ -0,0 +1,53 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type APIUsageRepository struct {
+	db     *pg.DB
+	logger *log.Logger
+}
+
+func NewAPIUsageRepository(db *pg.DB, logger *log.Logger) *APIUsageRepository {
+	return &APIUsageRepository{db: db, logger: logger.WithModule("repo.pg.api_usage")}
+}
+
+// Add counts a request and its tokens on the usage of the day
+func (r *APIUsageRepository) Add(ctx context.Context, kbID, endpoint string, date time.Time, tokens int) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "kb_id"}, {Name: "date"}, {Name: "endpoint"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"requests": gorm.Expr("api_usages.requests + 1"),
+				"tokens":   gorm.Expr("api_usages.tokens + ?", tokens),
+			}),
+		}).
+		Create(&domain.APIUsage{
+			KBID:     kbID,
+			Date:     date,
+			Endpoint: endpoint,
+			Requests: 1,
+			Tokens:   int64(tokens),
+		}).Error
+}
+
+func (r *APIUsageRepository) List(ctx context.Context, kbID string, since time.Time) ([]*domain.APIUsage, error) {
+	var usages []*domain.APIUsage
+	if err := r.db.WithContext(ctx).
+		Model(&domain.APIUsage{}).
+		Where("kb_id = ? AND date >= ?", kbID, since).
+		Order("date DESC, endpoint").
+		Find(&usages).Error; err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
@@ -89,6 +89,20 @@ func (r *AppRepository) GetAppsByTypes(ctx context.Context, appTypes []domain.Ap
 	return apps, nil
 }
 
+// GetEnabledOpenAIAPIAppsBySecretKey returns the enabled openai api apps whose secret key is secretKey
+func (r *AppRepository) GetEnabledOpenAIAPIAppsBySecretKey(ctx context.Context, secretKey string) ([]*domain.App, error) {
+	var apps []*domain.App
+	if err := r.db.WithContext(ctx).
+		Model(&domain.App{}).
+		Where("type = ?", domain.AppTypeOpenAIAPI).
+		Where("settings->'openai_api_bot_settings'->>'secret_key' = ?", secretKey).
+		Where("(settings->'openai_api_bot_settings'->>'is_enabled')::boolean").
+		Find(&apps).Error; err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
 func (r *AppRepository) GetAppList(ctx context.Context, kbID string) (map[string]*domain.App, error) {
 	var apps []*domain.App
 	if err := r.db.WithContext(ctx).
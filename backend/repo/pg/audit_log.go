@@ -0,0 +1,109 @@
+package pg
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	v1 "github.com/chaitin/panda-wiki/api/audit/v1"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type AuditLogRepository struct {
+	db     *pg.DB
+	logger *log.Logger
+}
+
+func NewAuditLogRepository(db *pg.DB, logger *log.Logger) *AuditLogRepository {
+	return &AuditLogRepository{db: db, logger: logger.WithModule("repo.pg.audit_log")}
+}
+
+func (r *AuditLogRepository) Create(ctx context.Context, auditLog *domain.AuditLog) error {
+	return r.db.WithContext(ctx).Create(auditLog).Error
+}
+
+// List returns the audit logs matching the filter, newest first
+func (r *AuditLogRepository) List(ctx context.Context, filter *v1.AuditLogFilter, offset, limit int) ([]*domain.AuditLogListItem, int64, error) {
+	var total int64
+	if err := r.filter(ctx, filter).Model(&domain.AuditLog{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	items := make([]*domain.AuditLogListItem, 0)
+	if err := r.withActorName(r.filter(ctx, filter)).
+		Order("audit_logs.created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// Iterate walks through the audit logs matching the filter in batches, oldest first
+func (r *AuditLogRepository) Iterate(ctx context.Context, filter *v1.AuditLogFilter, batchSize int, fn func([]*domain.AuditLogListItem) error) error {
+	var last *domain.AuditLogListItem
+	for {
+		query := r.withActorName(r.filter(ctx, filter))
+		if last != nil {
+			query = query.Where("(audit_logs.created_at, audit_logs.id) > (?, ?)", last.CreatedAt, last.ID)
+		}
+		items := make([]*domain.AuditLogListItem, 0, batchSize)
+		if err := query.
+			Order("audit_logs.created_at ASC, audit_logs.id ASC").
+			Limit(batchSize).
+			Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		if err := fn(items); err != nil {
+			return err
+		}
+		if len(items) < batchSize {
+			return nil
+		}
+		last = items[len(items)-1]
+	}
+}
+
+func (r *AuditLogRepository) filter(ctx context.Context, filter *v1.AuditLogFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Table("audit_logs")
+	if filter.KBId != "" {
+		query = query.Where("audit_logs.kb_id = ?", filter.KBId)
+	}
+	if filter.ActorType != "" {
+		query = query.Where("audit_logs.actor_type = ?", filter.ActorType)
+	}
+	if filter.ActorId != "" {
+		query = query.Where("audit_logs.actor_id = ?", filter.ActorId)
+	}
+	if filter.Action != "" {
+		query = query.Where("audit_logs.action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("audit_logs.target_type = ?", filter.TargetType)
+	}
+	if filter.TargetId != "" {
+		query = query.Where("audit_logs.target_id = ?", filter.TargetId)
+	}
+	if filter.RemoteIP != "" {
+		query = query.Where("audit_logs.remote_ip = ?", filter.RemoteIP)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("audit_logs.created_at >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("audit_logs.created_at < ?", *filter.EndTime)
+	}
+	return query
+}
+
+func (r *AuditLogRepository) withActorName(query *gorm.DB) *gorm.DB {
+	return query.
+		Select("audit_logs.*, COALESCE(users.account, api_tokens.name, '') AS actor_name").
+		Joins("LEFT JOIN users ON audit_logs.actor_type = ? AND users.id = audit_logs.actor_id", domain.AuditActorTypeUser).
+		Joins("LEFT JOIN api_tokens ON audit_logs.actor_type = ? AND api_tokens.id = audit_logs.actor_id", domain.AuditActorTypeAPIToken)
+}
@@ -159,6 +159,17 @@ func (r *AuthRepo) GetAuthGroupIdsByAuthId(ctx context.Context, authID uint) ([]
 	return groupIds, nil
 }
 
+// GetAuthGroupIdsByKBID returns the ids of all auth groups in the knowledge base
+func (r *AuthRepo) GetAuthGroupIdsByKBID(ctx context.Context, kbID string) ([]int, error) {
+	groupIds := make([]int, 0)
+	if err := r.db.WithContext(ctx).Model(&domain.AuthGroup{}).
+		Where("kb_id = ?", kbID).
+		Pluck("id", &groupIds).Error; err != nil {
+		return nil, err
+	}
+	return groupIds, nil
+}
+
 // GetAuthGroupIdsWithParentsByAuthId retrieves user's auth group IDs and all parent group IDs (for permission inheritance)
 func (r *AuthRepo) GetAuthGroupIdsWithParentsByAuthId(ctx context.Context, authID uint) ([]int, error) {
 	groupsMap, err := r.getAuthGroupsWithParentsByAuthId(ctx, authID)
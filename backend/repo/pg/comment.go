@@ -3,6 +3,9 @@ package pg
 import (
 	"context"
 
+	"github.com/samber/lo"
+	"gorm.io/gorm/clause"
+
 	"github.com/chaitin/panda-wiki/consts"
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
@@ -29,7 +32,9 @@ func (r *CommentRepository) CreateComment(ctx context.Context, comment *domain.C
 func (r *CommentRepository) GetCommentList(ctx context.Context, nodeID string) ([]*domain.ShareCommentListItem, int64, error) {
 	// 按照时间排序来查询node_id的comments
 	var comments []*domain.ShareCommentListItem
-	query := r.db.WithContext(ctx).Model(&domain.Comment{}).Where("node_id = ?", nodeID)
+	query := r.db.WithContext(ctx).Model(&domain.Comment{}).
+		Where("node_id = ?", nodeID).
+		Where("EXISTS (SELECT 1 FROM nodes WHERE nodes.id = comments.node_id AND nodes.deleted_at IS NULL)")
 
 	if domain.GetBaseEditionLimitation(ctx).AllowCommentAudit {
 		query = query.Where("status = ?", domain.CommentStatusAccepted) //accepted
@@ -50,7 +55,11 @@ func (r *CommentRepository) GetCommentList(ctx context.Context, nodeID string) (
 
 func (r *CommentRepository) GetCommentListByKbID(ctx context.Context, req *domain.CommentListReq, edition consts.LicenseEdition) ([]*domain.CommentListItem, int64, error) {
 	comments := []*domain.CommentListItem{}
-	query := r.db.WithContext(ctx).Model(&domain.Comment{}).Where("comments.kb_id = ?", req.KbID)
+	// 回收站中文档的评论不展示，恢复后重新出现
+	query := r.db.WithContext(ctx).Model(&domain.Comment{}).
+		Joins("left join nodes on comments.node_id = nodes.id").
+		Where("comments.kb_id = ?", req.KbID).
+		Where("nodes.deleted_at IS NULL")
 	var count int64
 	if req.Status == nil {
 		if err := query.Count(&count).Error; err != nil {
@@ -68,7 +77,6 @@ func (r *CommentRepository) GetCommentListByKbID(ctx context.Context, req *domai
 
 	// select
 	if err := query.
-		Joins("left join nodes on comments.node_id = nodes.id").
 		Select("comments.*, nodes.name as node_name, nodes.type as app_type").
 		Offset(req.Offset()).
 		Limit(req.Limit()).
@@ -82,6 +90,19 @@ func (r *CommentRepository) GetCommentListByKbID(ctx context.Context, req *domai
 
 }
 
+// UpdateCommentStatus updates the status of the comments in the kb and returns the ids actually updated
+func (r *CommentRepository) UpdateCommentStatus(ctx context.Context, kbID string, commentIDs []string, status domain.CommentStatus) ([]string, error) {
+	var comments []*domain.Comment
+	if err := r.db.WithContext(ctx).
+		Model(&comments).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("kb_id = ? AND id IN (?) AND status <> ?", kbID, commentIDs, status).
+		Update("status", status).Error; err != nil {
+		return nil, err
+	}
+	return lo.Map(comments, func(comment *domain.Comment, _ int) string { return comment.ID }), nil
+}
+
 func (r *CommentRepository) DeleteCommentList(ctx context.Context, commentID []string) error {
 	// 批量删除指定id的comment,获取删除的总的数量、
 	query := r.db.WithContext(ctx).Model(&domain.Comment{}).Where("id IN (?)", commentID)
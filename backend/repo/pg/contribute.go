@@ -0,0 +1,112 @@
+package pg
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type ContributeRepository struct {
+	db     *pg.DB
+	logger *log.Logger
+}
+
+func NewContributeRepository(db *pg.DB, logger *log.Logger) *ContributeRepository {
+	return &ContributeRepository{db: db, logger: logger.WithModule("repo.pg.contribute")}
+}
+
+func (r *ContributeRepository) Create(ctx context.Context, contribute *domain.Contribute) error {
+	return r.db.WithContext(ctx).Create(contribute).Error
+}
+
+// List returns the contributes of the kb, newest first, all statuses when status is empty
+func (r *ContributeRepository) List(ctx context.Context, kbID string, status consts.ContributeStatus, offset, limit int) ([]*domain.Contribute, int64, error) {
+	query := r.db.WithContext(ctx).Model(&domain.Contribute{}).Where("kb_id = ?", kbID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var contributes []*domain.Contribute
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&contributes).Error; err != nil {
+		return nil, 0, err
+	}
+	return contributes, total, nil
+}
+
+func (r *ContributeRepository) GetByID(ctx context.Context, kbID, id string) (*domain.Contribute, error) {
+	var contribute domain.Contribute
+	if err := r.db.WithContext(ctx).Where("kb_id = ? AND id = ?", kbID, id).First(&contribute).Error; err != nil {
+		return nil, err
+	}
+	return &contribute, nil
+}
+
+// ReserveNodeID records nodeID as the node a pending new page is merged into, unless an earlier approve already
+// reserved one. The merge creates the node outside of the audit transaction, a retry after a failed audit finds
+// the node by the reserved id instead of creating the page again.
+func (r *ContributeRepository) ReserveNodeID(ctx context.Context, kbID, id, nodeID string) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.Contribute{}).
+		Where("kb_id = ? AND id = ? AND status = ?", kbID, id, consts.ContributeStatusPending).
+		Where("COALESCE(node_id, '') = ''").
+		Update("node_id", nodeID).Error
+}
+
+// AuditWithLock locks the pending contribute, calls apply to merge it and saves the result of the review in one
+// transaction. A concurrent review waits for the lock and gets ErrContributeAudited, an apply error rolls back the claim.
+func (r *ContributeRepository) AuditWithLock(ctx context.Context, kbID, id string, apply func(contribute *domain.Contribute) error) (*domain.Contribute, error) {
+	var contribute domain.Contribute
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("kb_id = ? AND id = ?", kbID, id).
+			First(&contribute).Error; err != nil {
+			return err
+		}
+		if contribute.Status != consts.ContributeStatusPending {
+			return domain.ErrContributeAudited
+		}
+		if err := apply(&contribute); err != nil {
+			return err
+		}
+		return audit(tx, &contribute)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &contribute, nil
+}
+
+// Audit saves the result of the review, only a pending contribute can be audited
+func (r *ContributeRepository) Audit(ctx context.Context, contribute *domain.Contribute) error {
+	return audit(r.db.WithContext(ctx), contribute)
+}
+
+func audit(db *gorm.DB, contribute *domain.Contribute) error {
+	result := db.
+		Model(&domain.Contribute{}).
+		Where("kb_id = ? AND id = ? AND status = ?", contribute.KBId, contribute.Id, consts.ContributeStatusPending).
+		Updates(map[string]any{
+			"status":        contribute.Status,
+			"node_id":       contribute.NodeId,
+			"audit_user_id": contribute.AuditUserID,
+			"audit_time":    contribute.AuditTime,
+			"audit_reason":  contribute.AuditReason,
+			"updated_at":    contribute.AuditTime,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrContributeAudited
+	}
+	return nil
+}
@@ -3,8 +3,11 @@ package pg
 import (
 	"context"
 	"strconv"
+	"time"
 
 	"github.com/cloudwego/eino/schema"
+	"github.com/lib/pq"
+	"github.com/samber/lo"
 	"gorm.io/gorm"
 
 	"github.com/chaitin/panda-wiki/domain"
@@ -84,6 +87,32 @@ func (r *ConversationRepository) GetConversationDetail(ctx context.Context, kbID
 	return conversation, nil
 }
 
+func (r *ConversationRepository) GetConversationByID(ctx context.Context, kbID, conversationID string) (*domain.Conversation, error) {
+	conversation := &domain.Conversation{}
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Conversation{}).
+		Where("id = ?", conversationID).
+		Where("kb_id = ?", kbID).
+		First(conversation).Error; err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
+
+func (r *ConversationRepository) UpdateConversationHistorySummary(ctx context.Context, conversationID string, summary *domain.ConversationHistorySummary) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.Conversation{}).
+		Where("id = ?", conversationID).
+		Update("history_summary", summary).Error
+}
+
+func (r *ConversationRepository) UpdateMessageSuggestions(ctx context.Context, messageID string, suggestions []string) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.ConversationMessage{}).
+		Where("id = ?", messageID).
+		Update("suggestions", pq.StringArray(suggestions)).Error
+}
+
 func (r *ConversationRepository) GetConversationReferences(ctx context.Context, conversationID string) ([]*domain.ConversationReference, error) {
 	references := []*domain.ConversationReference{}
 	if err := r.db.WithContext(ctx).
@@ -292,3 +321,37 @@ func (r *ConversationRepository) GetConversationCountByAppType(ctx context.Conte
 	}
 	return result, nil
 }
+
+// KnowledgeGapMessage is an answer which suggests the knowledge base lacks content, with its question
+type KnowledgeGapMessage struct {
+	MessageID      string
+	ConversationID string
+	Question       string
+	Answer         string
+	RetrievedCount *int
+	Score          domain.ScoreType
+	CreatedAt      time.Time
+}
+
+// GetKnowledgeGapMessages returns the latest answers since the time which retrieved no documents,
+// were disliked, or contain one of the unanswered patterns
+func (r *ConversationRepository) GetKnowledgeGapMessages(ctx context.Context, kbID string, since time.Time, unansweredPatterns []string, limit int) ([]*KnowledgeGapMessage, error) {
+	patterns := lo.Map(unansweredPatterns, func(pattern string, _ int) string {
+		return "%" + pattern + "%"
+	})
+	var messages []*KnowledgeGapMessage
+	if err := r.db.WithContext(ctx).
+		Table("conversation_messages AS a").
+		Select("a.id AS message_id, a.conversation_id, q.content AS question, a.content AS answer, a.retrieved_count, COALESCE((a.info->>'score')::int, 0) AS score, a.created_at").
+		Joins("JOIN conversation_messages AS q ON q.id = a.parent_id").
+		Where("a.kb_id = ?", kbID).
+		Where("a.role = ?", schema.Assistant).
+		Where("a.created_at >= ?", since).
+		Where("(a.retrieved_count = 0 OR a.info->>'score' = ? OR a.content LIKE ANY(?))", strconv.Itoa(int(domain.DisLike)), pq.Array(patterns)).
+		Order("a.created_at DESC").
+		Limit(limit).
+		Scan(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
@@ -0,0 +1,228 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	v1 "github.com/chaitin/panda-wiki/api/eval/v1"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type EvalRepository struct {
+	db     *pg.DB
+	logger *log.Logger
+}
+
+func NewEvalRepository(db *pg.DB, logger *log.Logger) *EvalRepository {
+	return &EvalRepository{db: db, logger: logger.WithModule("repo.pg.eval")}
+}
+
+func (r *EvalRepository) CreateEvalSet(ctx context.Context, set *domain.EvalSet) error {
+	return r.db.WithContext(ctx).Create(set).Error
+}
+
+func (r *EvalRepository) GetEvalSet(ctx context.Context, kbID, id string) (*domain.EvalSet, error) {
+	var set domain.EvalSet
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		First(&set).Error; err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+func (r *EvalRepository) ListEvalSets(ctx context.Context, kbID string) ([]*v1.EvalSetListItem, error) {
+	sets := make([]*v1.EvalSetListItem, 0)
+	if err := r.db.WithContext(ctx).
+		Model(&domain.EvalSet{}).
+		Select("eval_sets.*, (SELECT COUNT(*) FROM eval_questions WHERE eval_questions.set_id = eval_sets.id) AS question_count").
+		Where("kb_id = ?", kbID).
+		Order("created_at DESC").
+		Find(&sets).Error; err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+func (r *EvalRepository) UpdateEvalSet(ctx context.Context, req *v1.EvalSetUpdateReq) error {
+	updateMap := map[string]any{
+		"updated_at": time.Now(),
+	}
+	if req.Name != nil {
+		updateMap["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updateMap["description"] = *req.Description
+	}
+	return r.db.WithContext(ctx).
+		Model(&domain.EvalSet{}).
+		Where("kb_id = ? AND id = ?", req.KbId, req.ID).
+		Updates(updateMap).Error
+}
+
+// DeleteEvalSet deletes the set with its questions and runs
+func (r *EvalRepository) DeleteEvalSet(ctx context.Context, kbID, id string) error {
+	return r.db.WithContext(ctx).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		Delete(&domain.EvalSet{}).Error
+}
+
+func (r *EvalRepository) CreateEvalQuestions(ctx context.Context, questions []*domain.EvalQuestion) error {
+	return r.db.WithContext(ctx).CreateInBatches(&questions, 100).Error
+}
+
+func (r *EvalRepository) ListEvalQuestions(ctx context.Context, kbID, setID string) ([]*domain.EvalQuestion, error) {
+	questions := make([]*domain.EvalQuestion, 0)
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND set_id = ?", kbID, setID).
+		Order("created_at ASC, id ASC").
+		Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+func (r *EvalRepository) UpdateEvalQuestion(ctx context.Context, req *v1.EvalQuestionUpdateReq) error {
+	expectedNodeIDs := req.ExpectedNodeIds
+	if expectedNodeIDs == nil {
+		expectedNodeIDs = []string{}
+	}
+	result := r.db.WithContext(ctx).
+		Model(&domain.EvalQuestion{}).
+		Where("kb_id = ? AND id = ?", req.KbId, req.ID).
+		Updates(map[string]any{
+			"question":          req.Question,
+			"expected_node_ids": expectedNodeIDs,
+			"reference_answer":  req.ReferenceAnswer,
+			"updated_at":        time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *EvalRepository) DeleteEvalQuestions(ctx context.Context, kbID string, ids []string) error {
+	return r.db.WithContext(ctx).
+		Where("kb_id = ? AND id IN ?", kbID, ids).
+		Delete(&domain.EvalQuestion{}).Error
+}
+
+func (r *EvalRepository) CreateEvalRun(ctx context.Context, run *domain.EvalRun) error {
+	return r.db.WithContext(ctx).Create(run).Error
+}
+
+func (r *EvalRepository) GetEvalRun(ctx context.Context, kbID, id string) (*domain.EvalRun, error) {
+	var run domain.EvalRun
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *EvalRepository) ListEvalRuns(ctx context.Context, req *v1.EvalRunListReq) ([]*domain.EvalRun, int64, error) {
+	query := r.db.WithContext(ctx).
+		Model(&domain.EvalRun{}).
+		Where("kb_id = ?", req.KbId)
+	if req.SetId != "" {
+		query = query.Where("set_id = ?", req.SetId)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	runs := make([]*domain.EvalRun, 0)
+	if err := query.
+		Order("created_at DESC").
+		Offset(req.Offset()).
+		Limit(req.Limit()).
+		Find(&runs).Error; err != nil {
+		return nil, 0, err
+	}
+	return runs, total, nil
+}
+
+// FinishEvalRun saves the result of a running run, a canceled or interrupted run is left as is
+func (r *EvalRepository) FinishEvalRun(ctx context.Context, id string, status domain.EvalRunStatus, metrics domain.EvalMetrics, errMsg string) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.EvalRun{}).
+		Where("id = ? AND status = ?", id, domain.EvalRunStatusRunning).
+		Updates(map[string]any{
+			"status":      status,
+			"metrics":     metrics,
+			"error":       errMsg,
+			"finished_at": time.Now(),
+		}).Error
+}
+
+// HeartbeatEvalRun renews the lease of the run, false when the run is no longer running, e.g. canceled or deleted
+func (r *EvalRepository) HeartbeatEvalRun(ctx context.Context, id string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.EvalRun{}).
+		Where("id = ? AND status = ?", id, domain.EvalRunStatusRunning).
+		Update("heartbeat_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FailStaleEvalRuns marks the running runs whose heartbeat stopped before the time as failed, their process has exited
+func (r *EvalRepository) FailStaleEvalRuns(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.EvalRun{}).
+		Where("status = ? AND heartbeat_at < ?", domain.EvalRunStatusRunning, before).
+		Updates(map[string]any{
+			"status":      domain.EvalRunStatusFailed,
+			"error":       "interrupted, the server stopped while running",
+			"finished_at": time.Now(),
+		}).Error
+}
+
+// CancelEvalRun stops a running run, the process executing it notices on the next heartbeat
+func (r *EvalRepository) CancelEvalRun(ctx context.Context, kbID, id string) error {
+	result := r.db.WithContext(ctx).
+		Model(&domain.EvalRun{}).
+		Where("kb_id = ? AND id = ? AND status = ?", kbID, id, domain.EvalRunStatusRunning).
+		Updates(map[string]any{
+			"status":      domain.EvalRunStatusCanceled,
+			"finished_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrEvalRunNotRunning
+	}
+	return nil
+}
+
+func (r *EvalRepository) DeleteEvalRun(ctx context.Context, kbID, id string) error {
+	return r.db.WithContext(ctx).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		Delete(&domain.EvalRun{}).Error
+}
+
+func (r *EvalRepository) CreateEvalRunResult(ctx context.Context, result *domain.EvalRunResult) error {
+	return r.db.WithContext(ctx).Create(result).Error
+}
+
+func (r *EvalRepository) ListEvalRunResults(ctx context.Context, runID string) ([]*domain.EvalRunResult, error) {
+	results := make([]*domain.EvalRunResult, 0)
+	if err := r.db.WithContext(ctx).
+		Where("run_id = ?", runID).
+		Order("created_at ASC, id ASC").
+		Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
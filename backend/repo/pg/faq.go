@@ -0,0 +1,85 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type FAQRepository struct {
+	db     *pg.DB
+	logger *log.Logger
+}
+
+func NewFAQRepository(db *pg.DB, logger *log.Logger) *FAQRepository {
+	return &FAQRepository{db: db, logger: logger.WithModule("repo.pg.faq")}
+}
+
+func (r *FAQRepository) Create(ctx context.Context, faq *domain.FAQ) error {
+	return r.db.WithContext(ctx).Create(faq).Error
+}
+
+func (r *FAQRepository) Get(ctx context.Context, kbID, id string) (*domain.FAQ, error) {
+	var faq domain.FAQ
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		First(&faq).Error; err != nil {
+		return nil, err
+	}
+	return &faq, nil
+}
+
+func (r *FAQRepository) List(ctx context.Context, kbID, question string) ([]*domain.FAQ, error) {
+	query := r.db.WithContext(ctx).Where("kb_id = ?", kbID)
+	if question != "" {
+		query = query.Where("array_to_string(questions, ' ') ILIKE ? OR answer ILIKE ?", "%"+question+"%", "%"+question+"%")
+	}
+	faqs := make([]*domain.FAQ, 0)
+	if err := query.
+		Order("created_at DESC").
+		Find(&faqs).Error; err != nil {
+		return nil, err
+	}
+	return faqs, nil
+}
+
+// ListEnabled returns the enabled faqs of the kb with their embeddings for matching
+func (r *FAQRepository) ListEnabled(ctx context.Context, kbID string) ([]*domain.FAQ, error) {
+	faqs := make([]*domain.FAQ, 0)
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND enabled", kbID).
+		Order("created_at ASC").
+		Find(&faqs).Error; err != nil {
+		return nil, err
+	}
+	return faqs, nil
+}
+
+func (r *FAQRepository) Update(ctx context.Context, kbID, id string, updateMap map[string]any) error {
+	updateMap["updated_at"] = time.Now()
+	return r.db.WithContext(ctx).
+		Model(&domain.FAQ{}).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		Updates(updateMap).Error
+}
+
+func (r *FAQRepository) Delete(ctx context.Context, kbID, id string) error {
+	return r.db.WithContext(ctx).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		Delete(&domain.FAQ{}).Error
+}
+
+func (r *FAQRepository) RecordHit(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.FAQ{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"hit_count":   gorm.Expr("hit_count + 1"),
+			"last_hit_at": time.Now(),
+		}).Error
+}
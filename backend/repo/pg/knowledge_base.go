@@ -156,7 +156,7 @@ func (r *KnowledgeBaseRepository) SyncKBAccessSettingsToCaddy(ctx context.Contex
 							{
 								"match": []map[string]any{
 									{
-										"path": []string{"/share/v1/chat/message"},
+										"path": []string{"/share/v1/chat/message", "/v1/*"},
 									},
 								},
 								"handle": []map[string]any{
@@ -562,6 +562,22 @@ func (r *KnowledgeBaseRepository) UpdateKnowledgeBase(ctx context.Context, req *
 	if req.AccessSettings != nil {
 		updateMap["access_settings"] = req.AccessSettings
 	}
+	if req.RetrievalSettings != nil {
+		updateMap["retrieval_settings"] = req.RetrievalSettings
+	}
+	if req.AnswerCacheSettings != nil {
+		updateMap["answer_cache_settings"] = req.AnswerCacheSettings
+	}
+	if req.AgentSettings != nil {
+		updateMap["agent_settings"] = req.AgentSettings
+	}
+	if req.ChatModel != nil {
+		if req.ChatModel.IsEmpty() {
+			updateMap["chat_model"] = gorm.Expr("NULL")
+		} else {
+			updateMap["chat_model"] = req.ChatModel
+		}
+	}
 
 	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Model(&domain.KnowledgeBase{}).Where("id = ?", req.ID).Updates(updateMap).Error; err != nil {
@@ -607,7 +623,7 @@ func (r *KnowledgeBaseRepository) GetKnowledgeBaseByID(ctx context.Context, kbID
 
 func (r *KnowledgeBaseRepository) DeleteKnowledgeBase(ctx context.Context, kbID string) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		if err := tx.Where("kb_id = ?", kbID).Delete(&domain.Node{}).Error; err != nil {
+		if err := tx.Unscoped().Where("kb_id = ?", kbID).Delete(&domain.Node{}).Error; err != nil {
 			return err
 		}
 		if err := tx.Where("kb_id = ?", kbID).Delete(&domain.App{}).Error; err != nil {
@@ -632,42 +648,142 @@ func (r *KnowledgeBaseRepository) DeleteKnowledgeBase(ctx context.Context, kbID
 
 func (r *KnowledgeBaseRepository) CreateKBRelease(ctx context.Context, release *domain.KBRelease) error {
 	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the previous release is the base of the new snapshot, so a rollback stays in effect
+		var prevRelease domain.KBRelease
+		if err := tx.Where("kb_id = ?", release.KBID).
+			Order("created_at DESC").
+			Limit(1).
+			Find(&prevRelease).Error; err != nil {
+			return err
+		}
 		// create new release
 		if err := tx.Create(release).Error; err != nil {
 			return err
 		}
+		if err := deleteAnswerCaches(tx, release.KBID); err != nil {
+			return err
+		}
+
+		nodeReleaseIDs := make(map[string]string) // node_id -> node_release_id
+		publishedQuery := tx.Model(&domain.NodeRelease{}).Where("kb_id = ?", release.KBID)
+		if prevRelease.ID != "" {
+			var prevNodeReleases []*domain.KBReleaseNodeRelease
+			if err := tx.Model(&domain.KBReleaseNodeRelease{}).
+				Joins("JOIN node_releases ON node_releases.id = kb_release_node_releases.node_release_id").
+				Where("kb_release_node_releases.release_id = ?", prevRelease.ID).
+				Select("kb_release_node_releases.node_id, kb_release_node_releases.node_release_id").
+				Find(&prevNodeReleases).Error; err != nil {
+				return err
+			}
+			for _, nodeRelease := range prevNodeReleases {
+				nodeReleaseIDs[nodeRelease.NodeID] = nodeRelease.NodeReleaseID
+			}
+			// node releases published after the previous release
+			publishedQuery = publishedQuery.Where("updated_at > ?", prevRelease.CreatedAt)
+		}
 		// create release node for all released nodes
 		var nodeReleases []*domain.NodeRelease
-		if err := tx.Where("kb_id = ?", release.KBID).
+		if err := publishedQuery.
 			Select("DISTINCT ON (node_id) id, node_id").
 			Order("node_id, updated_at DESC").
 			Find(&nodeReleases).Error; err != nil {
 			return err
 		}
-		if len(nodeReleases) == 0 {
-			return nil
-		}
-		kbReleaseNodeReleases := make([]*domain.KBReleaseNodeRelease, len(nodeReleases))
-		for i, nodeRelease := range nodeReleases {
-			kbReleaseNodeReleases[i] = &domain.KBReleaseNodeRelease{
-				ID:            uuid.New().String(),
-				KBID:          release.KBID,
-				ReleaseID:     release.ID,
-				NodeID:        nodeRelease.NodeID,
-				NodeReleaseID: nodeRelease.ID,
-				CreatedAt:     time.Now(),
-			}
+		for _, nodeRelease := range nodeReleases {
+			nodeReleaseIDs[nodeRelease.NodeID] = nodeRelease.ID
 		}
-		if err := tx.CreateInBatches(&kbReleaseNodeReleases, 2000).Error; err != nil {
-			return err
-		}
-		return nil
+		return r.createKBReleaseNodeReleases(tx, release, nodeReleaseIDs)
 	}); err != nil {
 		return err
 	}
 	return nil
 }
 
+// CreateKBReleaseFromRelease creates a new release with the same node releases as the source release
+func (r *KnowledgeBaseRepository) CreateKBReleaseFromRelease(ctx context.Context, release *domain.KBRelease, sourceReleaseID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(release).Error; err != nil {
+			return err
+		}
+		if err := deleteAnswerCaches(tx, release.KBID); err != nil {
+			return err
+		}
+		var sourceNodeReleases []*domain.KBReleaseNodeRelease
+		if err := tx.Model(&domain.KBReleaseNodeRelease{}).
+			Joins("JOIN node_releases ON node_releases.id = kb_release_node_releases.node_release_id").
+			Where("kb_release_node_releases.release_id = ?", sourceReleaseID).
+			Select("kb_release_node_releases.node_id, kb_release_node_releases.node_release_id").
+			Find(&sourceNodeReleases).Error; err != nil {
+			return err
+		}
+		nodeReleaseIDs := make(map[string]string, len(sourceNodeReleases))
+		for _, nodeRelease := range sourceNodeReleases {
+			nodeReleaseIDs[nodeRelease.NodeID] = nodeRelease.NodeReleaseID
+		}
+		return r.createKBReleaseNodeReleases(tx, release, nodeReleaseIDs)
+	})
+}
+
+// DeleteAnswerCaches drops the cached answers of the kb, e.g. after the node permissions change
+// which documents the auth groups may get answers from
+func (r *KnowledgeBaseRepository) DeleteAnswerCaches(ctx context.Context, kbID string) error {
+	return deleteAnswerCaches(r.db.WithContext(ctx), kbID)
+}
+
+// deleteAnswerCaches drops the cached answers of the kb, they were answered with the documents of the previous release
+func deleteAnswerCaches(tx *gorm.DB, kbID string) error {
+	return tx.Where("kb_id = ?", kbID).Delete(&domain.AnswerCache{}).Error
+}
+
+func (r *KnowledgeBaseRepository) createKBReleaseNodeReleases(tx *gorm.DB, release *domain.KBRelease, nodeReleaseIDs map[string]string) error {
+	if len(nodeReleaseIDs) == 0 {
+		return nil
+	}
+	kbReleaseNodeReleases := make([]*domain.KBReleaseNodeRelease, 0, len(nodeReleaseIDs))
+	for nodeID, nodeReleaseID := range nodeReleaseIDs {
+		kbReleaseNodeReleases = append(kbReleaseNodeReleases, &domain.KBReleaseNodeRelease{
+			ID:            uuid.New().String(),
+			KBID:          release.KBID,
+			ReleaseID:     release.ID,
+			NodeID:        nodeID,
+			NodeReleaseID: nodeReleaseID,
+			CreatedAt:     time.Now(),
+		})
+	}
+	return tx.CreateInBatches(&kbReleaseNodeReleases, 2000).Error
+}
+
+func (r *KnowledgeBaseRepository) GetKBReleaseByID(ctx context.Context, kbID, id string) (*domain.KBRelease, error) {
+	var release domain.KBRelease
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ?", kbID).
+		Where("id = ?", id).
+		First(&release).Error; err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// GetKBReleaseNodeReleases returns the node releases of a kb release, keyed by node id.
+// Nodes in the recycle bin are left out, so a rollback does not put them back into rag
+func (r *KnowledgeBaseRepository) GetKBReleaseNodeReleases(ctx context.Context, releaseID string) (map[string]*domain.NodeRelease, error) {
+	var nodeReleases []*domain.NodeRelease
+	if err := r.db.WithContext(ctx).
+		Model(&domain.KBReleaseNodeRelease{}).
+		Joins("JOIN node_releases ON node_releases.id = kb_release_node_releases.node_release_id").
+		Joins("JOIN nodes ON nodes.id = kb_release_node_releases.node_id AND nodes.deleted_at IS NULL").
+		Where("kb_release_node_releases.release_id = ?", releaseID).
+		Select("node_releases.id, node_releases.kb_id, node_releases.node_id, node_releases.doc_id, node_releases.type, node_releases.name, node_releases.parent_id, node_releases.updated_at").
+		Find(&nodeReleases).Error; err != nil {
+		return nil, err
+	}
+	nodeReleaseMap := make(map[string]*domain.NodeRelease, len(nodeReleases))
+	for _, nodeRelease := range nodeReleases {
+		nodeReleaseMap[nodeRelease.NodeID] = nodeRelease
+	}
+	return nodeReleaseMap, nil
+}
+
 func (r *KnowledgeBaseRepository) GetKBReleaseList(ctx context.Context, kbID string, offset, limit int) (int64, []domain.KBReleaseListItemResp, error) {
 	var total int64
 	if err := r.db.Model(&domain.KBRelease{}).Where("kb_id = ?", kbID).Count(&total).Error; err != nil {
@@ -3,6 +3,7 @@ package pg
 import (
 	"context"
 
+	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
 	"github.com/chaitin/panda-wiki/store/pg"
 )
@@ -16,10 +17,17 @@ func NewMCPRepository(db *pg.DB, logger *log.Logger) *MCPRepository {
 	return &MCPRepository{db: db, logger: logger}
 }
 
+// GetMCPCallCount returns the count of mcp tool calls
 func (r *MCPRepository) GetMCPCallCount(ctx context.Context) (int64, error) {
 	var count int64
-	if err := r.db.WithContext(ctx).Table("mcp_calls").Count(&count).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&domain.MCPCall{}).
+		Where("tool_call_req IS NOT NULL").
+		Count(&count).Error; err != nil {
 		return 0, err
 	}
 	return count, nil
 }
+
+func (r *MCPRepository) CreateMCPCall(ctx context.Context, call *domain.MCPCall) error {
+	return r.db.WithContext(ctx).Create(call).Error
+}
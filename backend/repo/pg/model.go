@@ -93,6 +93,17 @@ func (r *ModelRepository) GetChatModel(ctx context.Context) (*domain.Model, erro
 	return &model, nil
 }
 
+func (r *ModelRepository) GetModelByID(ctx context.Context, id string) (*domain.Model, error) {
+	var model domain.Model
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Model{}).
+		Where("id = ?", id).
+		First(&model).Error; err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
 func (r *ModelRepository) GetModelByType(ctx context.Context, modelType domain.ModelType) (*domain.Model, error) {
 	var model domain.Model
 	if err := r.db.WithContext(ctx).
@@ -106,16 +117,71 @@ func (r *ModelRepository) GetModelByType(ctx context.Context, modelType domain.M
 
 func (r *ModelRepository) UpdateUsage(ctx context.Context, modelID string, usage *schema.TokenUsage) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		updates := map[string]any{
+			"prompt_tokens":     gorm.Expr("prompt_tokens + ?", usage.PromptTokens),
+			"completion_tokens": gorm.Expr("completion_tokens + ?", usage.CompletionTokens),
+			"total_tokens":      gorm.Expr("total_tokens + ?", usage.TotalTokens),
+		}
 		// update model usage
-		if err := tx.Model(&domain.Model{}).
+		result := tx.Model(&domain.Model{}).
+			Where("id = ?", modelID).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		// the answer may come from a fallback model
+		if err := tx.Model(&domain.ModelFallback{}).
 			Where("id = ?", modelID).
-			Updates(map[string]any{
-				"prompt_tokens":     gorm.Expr("prompt_tokens + ?", usage.PromptTokens),
-				"completion_tokens": gorm.Expr("completion_tokens + ?", usage.CompletionTokens),
-				"total_tokens":      gorm.Expr("total_tokens + ?", usage.TotalTokens),
-			}).Error; err != nil {
+			Updates(updates).Error; err != nil {
 			return err
 		}
 		return nil
 	})
 }
+
+func (r *ModelRepository) CreateFallback(ctx context.Context, fallback *domain.ModelFallback) error {
+	return r.db.WithContext(ctx).Create(fallback).Error
+}
+
+func (r *ModelRepository) UpdateFallback(ctx context.Context, req *domain.UpdateModelFallbackReq) error {
+	param := domain.ModelParam{}
+	if req.Parameters != nil {
+		param = *req.Parameters
+	}
+	return r.db.WithContext(ctx).
+		Model(&domain.ModelFallback{}).
+		Where("id = ?", req.ID).
+		Updates(map[string]any{
+			"priority":    req.Priority,
+			"model":       req.Model,
+			"api_key":     req.APIKey,
+			"api_header":  req.APIHeader,
+			"base_url":    req.BaseURL,
+			"api_version": req.APIVersion,
+			"provider":    req.Provider,
+			"type":        req.Type,
+			"parameters":  param,
+		}).Error
+}
+
+func (r *ModelRepository) DeleteFallback(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&domain.ModelFallback{}).Error
+}
+
+// GetFallbackList returns the fallback models of the type in the order they should be tried
+func (r *ModelRepository) GetFallbackList(ctx context.Context, modelType domain.ModelType) ([]*domain.ModelFallback, error) {
+	var fallbacks []*domain.ModelFallback
+	if err := r.db.WithContext(ctx).
+		Model(&domain.ModelFallback{}).
+		Where("type = ?", modelType).
+		Order("priority ASC, created_at ASC").
+		Find(&fallbacks).Error; err != nil {
+		return nil, err
+	}
+	return fallbacks, nil
+}
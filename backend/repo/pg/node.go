@@ -21,6 +21,7 @@ import (
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
 	"github.com/chaitin/panda-wiki/store/pg"
+	"github.com/chaitin/panda-wiki/utils"
 )
 
 type NodeRepository struct {
@@ -33,12 +34,15 @@ func NewNodeRepository(db *pg.DB, logger *log.Logger) *NodeRepository {
 }
 
 func (r *NodeRepository) Create(ctx context.Context, req *domain.CreateNodeReq, userId string) (string, error) {
-	nodeID, err := uuid.NewV7()
-	if err != nil {
-		return "", err
+	nodeIDStr := req.ID
+	if nodeIDStr == "" {
+		nodeID, err := uuid.NewV7()
+		if err != nil {
+			return "", err
+		}
+		nodeIDStr = nodeID.String()
 	}
-	nodeIDStr := nodeID.String()
-	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// check count
 		var count int64
 		if err := tx.Model(&domain.Node{}).
@@ -296,33 +300,212 @@ func (r *NodeRepository) GetByID(ctx context.Context, id, kbId string) (*v1.Node
 	return node, nil
 }
 
-func (r *NodeRepository) Delete(ctx context.Context, kbID string, ids []string) ([]string, error) {
+// Delete moves the nodes and their subtrees into the recycle bin and detaches them from rag,
+// returns the rag doc_ids of the nodes and their releases to be removed
+func (r *NodeRepository) Delete(ctx context.Context, kbID string, ids []string, userID string) ([]string, error) {
 	docIDs := make([]string, 0)
 	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// recursively collect all child node IDs
-		allIDs := r.collectAllChildNodeIDs(tx, kbID, ids)
+		now := time.Now()
+		allIDs := make([]string, 0)
+		for _, id := range ids {
+			// nodes already in the recycle bin are skipped by the scoped queries
+			subtreeIDs := r.collectAllChildNodeIDs(tx, kbID, []string{id})
+			var nodeDocIDs []string
+			if err := tx.Model(&domain.Node{}).
+				Where("id IN ?", subtreeIDs).
+				Where("kb_id = ?", kbID).
+				Where("doc_id != ''").
+				Pluck("doc_id", &nodeDocIDs).Error; err != nil {
+				return err
+			}
+			docIDs = append(docIDs, nodeDocIDs...)
+			if err := tx.Model(&domain.Node{}).
+				Where("id IN ?", subtreeIDs).
+				Where("kb_id = ?", kbID).
+				Updates(map[string]any{
+					"deleted_at":      now,
+					"deleted_by":      userID,
+					"deleted_root_id": id,
+					"doc_id":          "",
+				}).Error; err != nil {
+				return err
+			}
+			allIDs = append(allIDs, subtreeIDs...)
+		}
 
-		var nodes []*domain.Node
-		if err := tx.Model(&domain.Node{}).
-			Where("id IN ?", allIDs).
+		// detach node releases from rag, the latest ones are upserted again on restore
+		var releaseDocIDs []string
+		if err := tx.Model(&domain.NodeRelease{}).
+			Where("node_id IN ?", allIDs).
+			Where("doc_id != ''").
+			Pluck("doc_id", &releaseDocIDs).Error; err != nil {
+			return err
+		}
+		if len(releaseDocIDs) == 0 {
+			return nil
+		}
+		docIDs = append(docIDs, releaseDocIDs...)
+		return tx.Model(&domain.NodeRelease{}).
+			Where("node_id IN ?", allIDs).
+			Where("doc_id != ''").
+			Omit("updated_at").
+			Update("doc_id", "").Error
+	}); err != nil {
+		return nil, err
+	}
+	return lo.Uniq(docIDs), nil
+}
+
+// GetTrashList returns the nodes deleted by users, children deleted along with them are counted only
+func (r *NodeRepository) GetTrashList(ctx context.Context, kbID string, offset, limit int) (int64, []*v1.NodeTrashListItem, error) {
+	query := r.db.WithContext(ctx).Unscoped().
+		Model(&domain.Node{}).
+		Where("nodes.kb_id = ?", kbID).
+		Where("nodes.deleted_at IS NOT NULL").
+		Where("nodes.id = nodes.deleted_root_id")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+
+	var items []*v1.NodeTrashListItem
+	if err := query.
+		Select("nodes.id, nodes.name, nodes.type, nodes.meta->>'emoji' as emoji, nodes.parent_id, nodes.deleted_at, nodes.deleted_by, users.account as deleted_by_account, " +
+			"(SELECT COUNT(*) FROM nodes children WHERE children.deleted_root_id = nodes.id AND children.id != nodes.id AND children.deleted_at IS NOT NULL) as child_count").
+		Joins("left join users on users.id = nodes.deleted_by").
+		Order("nodes.deleted_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&items).Error; err != nil {
+		return 0, nil, err
+	}
+	return total, items, nil
+}
+
+// RestoreTrash restores the deleted subtrees, a subtree whose parent is gone is re-attached to the root.
+// returns the restored node ids
+func (r *NodeRepository) RestoreTrash(ctx context.Context, kbID string, rootIDs []string) ([]string, error) {
+	restoredIDs := make([]string, 0)
+	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var roots []*domain.Node
+		if err := tx.Unscoped().
+			Model(&domain.Node{}).
 			Where("kb_id = ?", kbID).
-			Clauses(clause.Returning{Columns: []clause.Column{{Name: "doc_id"}}}).
+			Where("id IN ?", rootIDs).
+			Where("id = deleted_root_id").
+			Where("deleted_at IS NOT NULL").
+			Find(&roots).Error; err != nil {
+			return err
+		}
+		for _, root := range sortTrashRoots(roots) {
+			if root.ParentID != "" {
+				var count int64
+				if err := tx.Model(&domain.Node{}).
+					Where("kb_id = ?", kbID).
+					Where("id = ?", root.ParentID).
+					Count(&count).Error; err != nil {
+					return err
+				}
+				if count == 0 {
+					if err := tx.Unscoped().
+						Model(&domain.Node{}).
+						Where("id = ?", root.ID).
+						Update("parent_id", "").Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			var ids []string
+			if err := tx.Unscoped().
+				Model(&domain.Node{}).
+				Where("kb_id = ?", kbID).
+				Where("deleted_root_id = ?", root.ID).
+				Where("deleted_at IS NOT NULL").
+				Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().
+				Model(&domain.Node{}).
+				Where("id IN ?", ids).
+				Updates(map[string]any{
+					"deleted_at":      nil,
+					"deleted_by":      "",
+					"deleted_root_id": "",
+				}).Error; err != nil {
+				return err
+			}
+			restoredIDs = append(restoredIDs, ids...)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return restoredIDs, nil
+}
+
+// sortTrashRoots puts parents before their children, so that a child restored together
+// with its parent stays under it
+func sortTrashRoots(roots []*domain.Node) []*domain.Node {
+	sorted := make([]*domain.Node, 0, len(roots))
+	pending := roots
+	for len(pending) > 0 {
+		pendingIDs := lo.SliceToMap(pending, func(n *domain.Node) (string, struct{}) {
+			return n.ID, struct{}{}
+		})
+		next := make([]*domain.Node, 0)
+		for _, root := range pending {
+			if _, ok := pendingIDs[root.ParentID]; ok {
+				next = append(next, root)
+			} else {
+				sorted = append(sorted, root)
+			}
+		}
+		if len(next) == len(pending) { // cycle, should not happen
+			return append(sorted, next...)
+		}
+		pending = next
+	}
+	return sorted
+}
+
+// PurgeTrash permanently deletes the deleted subtrees, all of the kb recycle bin when rootIDs is nil.
+// returns the rag doc_ids to be removed
+func (r *NodeRepository) PurgeTrash(ctx context.Context, kbID string, rootIDs []string) ([]string, error) {
+	docIDs := make([]string, 0)
+	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Unscoped().
+			Model(&domain.Node{}).
+			Where("kb_id = ?", kbID).
+			Where("deleted_at IS NOT NULL")
+		if rootIDs != nil {
+			query = query.Where("deleted_root_id IN ?", rootIDs)
+		}
+		var nodes []*domain.Node
+		if err := query.
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "doc_id"}}}).
 			Delete(&nodes).Error; err != nil {
 			return err
 		}
+		if len(nodes) == 0 {
+			return nil
+		}
+		nodeIDs := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			nodeIDs = append(nodeIDs, node.ID)
+			if node.DocID != "" {
+				docIDs = append(docIDs, node.DocID)
+			}
+		}
 		// delete node release
 		var nodeReleases []*domain.NodeRelease
 		if err := tx.Model(&domain.NodeRelease{}).
-			Where("node_id IN ?", allIDs).
+			Where("node_id IN ?", nodeIDs).
 			Clauses(clause.Returning{Columns: []clause.Column{{Name: "doc_id"}}}).
 			Delete(&nodeReleases).Error; err != nil {
 			return err
 		}
-		for _, node := range nodes {
-			if node.DocID != "" {
-				docIDs = append(docIDs, node.DocID)
-			}
-		}
 		for _, nodeRelease := range nodeReleases {
 			if nodeRelease.DocID != "" {
 				docIDs = append(docIDs, nodeRelease.DocID)
@@ -335,6 +518,25 @@ func (r *NodeRepository) Delete(ctx context.Context, kbID string, ids []string)
 	return lo.Uniq(docIDs), nil
 }
 
+// GetExpiredTrashRootIDs returns the recycle bin entries deleted before the given time, grouped by kb id
+func (r *NodeRepository) GetExpiredTrashRootIDs(ctx context.Context, before time.Time) (map[string][]string, error) {
+	var nodes []*domain.Node
+	if err := r.db.WithContext(ctx).Unscoped().
+		Model(&domain.Node{}).
+		Where("deleted_at IS NOT NULL").
+		Where("deleted_at < ?", before).
+		Where("id = deleted_root_id").
+		Select("id, kb_id").
+		Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	rootIDs := make(map[string][]string)
+	for _, node := range nodes {
+		rootIDs[node.KBID] = append(rootIDs[node.KBID], node.ID)
+	}
+	return rootIDs, nil
+}
+
 // collectAllChildNodeIDs recursively collects all child node IDs for the given parent IDs
 func (r *NodeRepository) collectAllChildNodeIDs(tx *gorm.DB, kbID string, parentIDs []string) []string {
 	allIDs := make([]string, 0)
@@ -488,6 +690,31 @@ func (r *NodeRepository) GetLatestNodeReleaseWithPublishAccount(ctx context.Cont
 	return nodeRelease, nil
 }
 
+func (r *NodeRepository) GetNodeReleaseListByNodeID(ctx context.Context, kbID, nodeID string, offset, limit int) (int64, []*v1.NodeReleaseListItem, error) {
+	query := r.db.WithContext(ctx).
+		Model(&domain.NodeRelease{}).
+		Where("node_releases.kb_id = ?", kbID).
+		Where("node_releases.node_id = ?", nodeID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+
+	var releases []*v1.NodeReleaseListItem
+	if err := query.
+		Select("node_releases.id, node_releases.node_id, node_releases.name, node_releases.meta, node_releases.publisher_id, node_releases.editor_id, node_releases.created_at, node_releases.updated_at, publisher.account as publisher_account, editor.account as editor_account").
+		Joins("left join users publisher on publisher.id = node_releases.publisher_id").
+		Joins("left join users editor on editor.id = node_releases.editor_id").
+		Order("node_releases.updated_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&releases).Error; err != nil {
+		return 0, nil, err
+	}
+	return total, releases, nil
+}
+
 // GetNodeReleaseWithDirPathByID gets a node release by ID and includes its directory path
 func (r *NodeRepository) GetNodeReleaseWithDirPathByID(ctx context.Context, id string) (*domain.NodeReleaseWithDirPath, error) {
 	// First get the node release
@@ -589,6 +816,14 @@ func (r *NodeRepository) GetNodeReleasesWithPathsByDocIDs(ctx context.Context, i
 	return result, nil
 }
 
+// GetNodeReleasePathsByIDs returns the path of node releases keyed by node release id
+func (r *NodeRepository) GetNodeReleasePathsByIDs(ctx context.Context, ids []string) (map[string]*NodePathInfo, error) {
+	if len(ids) == 0 {
+		return make(map[string]*NodePathInfo), nil
+	}
+	return r.getNodePathsBatchBy(ctx, "id", ids)
+}
+
 // NodePathInfo contains path information for a node
 type NodePathInfo struct {
 	DocID     string
@@ -599,6 +834,11 @@ type NodePathInfo struct {
 
 // getNodePathsBatch batch query node paths
 func (r *NodeRepository) getNodePathsBatch(ctx context.Context, docIDs []string) (map[string]*NodePathInfo, error) {
+	return r.getNodePathsBatchBy(ctx, "doc_id", docIDs)
+}
+
+// getNodePathsBatchBy batch query node paths of node releases keyed by keyColumn, which is doc_id or id
+func (r *NodeRepository) getNodePathsBatchBy(ctx context.Context, keyColumn string, keys []string) (map[string]*NodePathInfo, error) {
 	type pathResult struct {
 		DocID     string         `gorm:"column:doc_id"`
 		PathIDs   pq.StringArray `gorm:"column:path_ids;type:text[]"`
@@ -614,12 +854,12 @@ func (r *NodeRepository) getNodePathsBatch(ctx context.Context, docIDs []string)
 				node_id,
 				parent_id,
 				name,
-				doc_id as root_doc_id,
+				` + keyColumn + ` as root_doc_id,
 				ARRAY[node_id] as path_ids,
 				ARRAY[name] as path_names,
 				1 as depth
 			FROM node_releases
-			WHERE doc_id = ANY($1)
+			WHERE ` + keyColumn + ` = ANY($1)
 
 			UNION ALL
 
@@ -645,7 +885,7 @@ func (r *NodeRepository) getNodePathsBatch(ctx context.Context, docIDs []string)
 	`
 
 	if err := r.db.WithContext(ctx).
-		Raw(query, pq.Array(docIDs)).
+		Raw(query, pq.Array(keys)).
 		Scan(&results).Error; err != nil {
 		return nil, err
 	}
@@ -671,6 +911,7 @@ func (r *NodeRepository) GetRecommendNodeListByIDs(ctx context.Context, kbID str
 		Model(&domain.KBReleaseNodeRelease{}).
 		Joins("LEFT JOIN node_releases ON node_releases.id = kb_release_node_releases.node_release_id").
 		Joins("LEFT JOIN nodes ON nodes.id = node_releases.node_id").
+		Where("nodes.deleted_at IS NULL").
 		Where("node_releases.kb_id = ?", kbID).
 		Where("kb_release_node_releases.release_id = ?", releaseID).
 		Where("node_releases.node_id IN ?", ids).
@@ -687,6 +928,7 @@ func (r *NodeRepository) GetRecommendNodeListByParentIDs(ctx context.Context, kb
 		Model(&domain.KBReleaseNodeRelease{}).
 		Joins("LEFT JOIN node_releases ON node_releases.id = kb_release_node_releases.node_release_id").
 		Joins("LEFT JOIN nodes ON nodes.id = node_releases.node_id").
+		Where("nodes.deleted_at IS NULL").
 		Where("node_releases.kb_id = ?", kbID).
 		Where("kb_release_node_releases.release_id = ?", releaseID).
 		Where("node_releases.parent_id IN ?", parentIDs).
@@ -725,6 +967,7 @@ func (r *NodeRepository) GetNodeReleaseListByKBID(ctx context.Context, kbID stri
 		Model(&domain.KBReleaseNodeRelease{}).
 		Joins("LEFT JOIN node_releases ON node_releases.id = kb_release_node_releases.node_release_id").
 		Joins("LEFT JOIN nodes ON nodes.id = kb_release_node_releases.node_id").
+		Where("nodes.deleted_at IS NULL").
 		Where("kb_release_node_releases.kb_id = ?", kbID).
 		Where("kb_release_node_releases.release_id = ?", kbRelease.ID).
 		Where("nodes.permissions->>'visible' != ?", consts.NodeAccessPermClosed).
@@ -752,6 +995,7 @@ func (r *NodeRepository) GetNodeReleaseDetailByKBIDAndID(ctx context.Context, kb
 		Select("node_releases.*, nodes.permissions, nodes.creator_id").
 		Joins("LEFT JOIN node_releases ON node_releases.id = kb_release_node_releases.node_release_id").
 		Joins("LEFT JOIN nodes ON nodes.id = kb_release_node_releases.node_id").
+		Where("nodes.deleted_at IS NULL").
 		Where("kb_release_node_releases.release_id = ?", kbRelease.ID).
 		Where("node_releases.node_id = ?", id).
 		Where("node_releases.kb_id = ?", kbID).
@@ -855,6 +1099,7 @@ func (r *NodeRepository) UpdateNodeStatus(ctx context.Context, kbID, nodeID stri
 func (r *NodeRepository) TraverseNodesByCursor(ctx context.Context, callback func(*domain.NodeRelease) error) error {
 	rows, err := r.db.WithContext(ctx).
 		Model(&domain.NodeRelease{}).
+		Where("node_id NOT IN (?)", r.db.Unscoped().Model(&domain.Node{}).Select("id").Where("deleted_at IS NOT NULL")).
 		Select("DISTINCT ON (node_id) id, node_id, kb_id").
 		Order("node_id, updated_at DESC").
 		Rows()
@@ -921,6 +1166,13 @@ func (r *NodeRepository) CreateNodeReleases(ctx context.Context, kbID, userId st
 		if err := tx.CreateInBatches(&nodeReleases, 100).Error; err != nil {
 			return err
 		}
+		for _, nodeRelease := range nodeReleases {
+			if err := tx.Model(&domain.NodeRelease{}).
+				Where("id = ?", nodeRelease.ID).
+				UpdateColumn("search_vector", NodeReleaseSearchVectorExpr(nodeRelease.Name, nodeRelease.Content)).Error; err != nil {
+				return err
+			}
+		}
 		return nil
 	}); err != nil {
 		return nil, err
@@ -928,6 +1180,117 @@ func (r *NodeRepository) CreateNodeReleases(ctx context.Context, kbID, userId st
 	return releaseIDs, nil
 }
 
+// NodeReleaseSearchVectorExpr builds the full-text search vector of a node release, name tokens weigh more than content
+func NodeReleaseSearchVectorExpr(name, content string) clause.Expr {
+	return gorm.Expr("setweight(to_tsvector('simple', ?), 'A') || to_tsvector('simple', ?)",
+		strings.Join(utils.SegmentText(name), " "),
+		strings.Join(utils.SegmentText(utils.ContentToMarkdown(content)), " "),
+	)
+}
+
+// NodeReleaseKeywordHit is a node release matched by the keyword leg of hybrid retrieval
+type NodeReleaseKeywordHit struct {
+	ID      string
+	NodeID  string
+	DocID   string
+	Name    string
+	Meta    domain.NodeMeta `gorm:"type:jsonb"`
+	Content string
+	Rank    float64
+}
+
+// SearchNodeReleasesByKeyword full-text searches the node releases of the latest kb release
+// which are answerable by groupIDs, ordered by rank
+func (r *NodeRepository) SearchNodeReleasesByKeyword(ctx context.Context, kbID string, tokens []string, groupIDs []int, limit int) ([]*NodeReleaseKeywordHit, error) {
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = `"` + strings.ReplaceAll(token, `"`, "") + `"`
+	}
+	var hits []*NodeReleaseKeywordHit
+	if err := r.db.WithContext(ctx).Raw(`
+SELECT node_releases.id, node_releases.node_id, node_releases.doc_id, node_releases.name, node_releases.meta, node_releases.content,
+    ts_rank_cd(node_releases.search_vector, q, 1) AS rank
+FROM kb_release_node_releases
+JOIN node_releases ON node_releases.id = kb_release_node_releases.node_release_id
+JOIN nodes ON nodes.id = node_releases.node_id AND nodes.deleted_at IS NULL,
+    websearch_to_tsquery('simple', ?) q
+WHERE kb_release_node_releases.release_id = (SELECT id FROM kb_releases WHERE kb_id = ? ORDER BY created_at DESC LIMIT 1)
+    AND node_releases.type != ?
+    AND node_releases.search_vector @@ q
+    AND (
+        COALESCE(nodes.permissions->>'answerable', '') IN (?, '')
+        OR (nodes.permissions->>'answerable' = ? AND nodes.id IN (
+            SELECT node_id FROM node_auth_groups WHERE perm = ? AND auth_group_id = ANY(?)
+        ))
+    )
+ORDER BY rank DESC
+LIMIT ?`,
+		strings.Join(terms, " OR "), kbID, domain.NodeTypeFolder,
+		consts.NodeAccessPermOpen, consts.NodeAccessPermPartial, consts.NodePermNameAnswerable, pq.Array(groupIDs),
+		limit,
+	).Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// GetAnswerableNodeReleasesByNodeIDs returns the node releases of the latest kb release which the auth groups may get answers from
+func (r *NodeRepository) GetAnswerableNodeReleasesByNodeIDs(ctx context.Context, kbID string, nodeIDs []string, groupIDs []int) ([]*domain.NodeRelease, error) {
+	if len(nodeIDs) == 0 {
+		return nil, nil
+	}
+	var nodeReleases []*domain.NodeRelease
+	if err := r.db.WithContext(ctx).Raw(`
+SELECT node_releases.id, node_releases.node_id, node_releases.name, node_releases.meta
+FROM kb_release_node_releases
+JOIN node_releases ON node_releases.id = kb_release_node_releases.node_release_id
+JOIN nodes ON nodes.id = node_releases.node_id AND nodes.deleted_at IS NULL
+WHERE kb_release_node_releases.release_id = (SELECT id FROM kb_releases WHERE kb_id = ? ORDER BY created_at DESC LIMIT 1)
+    AND node_releases.node_id IN ?
+    AND (
+        COALESCE(nodes.permissions->>'answerable', '') IN (?, '')
+        OR (nodes.permissions->>'answerable' = ? AND nodes.id IN (
+            SELECT node_id FROM node_auth_groups WHERE perm = ? AND auth_group_id = ANY(?)
+        ))
+    )`,
+		kbID, nodeIDs,
+		consts.NodeAccessPermOpen, consts.NodeAccessPermPartial, consts.NodePermNameAnswerable, pq.Array(groupIDs),
+	).Scan(&nodeReleases).Error; err != nil {
+		return nil, err
+	}
+	return nodeReleases, nil
+}
+
+// GetUnanswerableNodeIDs returns the nodes of nodeIDs the auth groups may not get answers from,
+// deleted nodes are not returned
+func (r *NodeRepository) GetUnanswerableNodeIDs(ctx context.Context, kbID string, nodeIDs []string, groupIDs []int) ([]string, error) {
+	if len(nodeIDs) == 0 {
+		return nil, nil
+	}
+	var ids []string
+	if err := r.db.WithContext(ctx).Raw(`
+SELECT nodes.id
+FROM nodes
+WHERE nodes.kb_id = ?
+    AND nodes.id IN ?
+    AND nodes.deleted_at IS NULL
+    AND NOT (
+        COALESCE(nodes.permissions->>'answerable', '') IN (?, '')
+        OR (nodes.permissions->>'answerable' = ? AND nodes.id IN (
+            SELECT node_id FROM node_auth_groups WHERE perm = ? AND auth_group_id = ANY(?)
+        ))
+    )`,
+		kbID, nodeIDs,
+		consts.NodeAccessPermOpen, consts.NodeAccessPermPartial, consts.NodePermNameAnswerable, pq.Array(groupIDs),
+	).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 func (r *NodeRepository) GetOldNodeDocIDsByNodeID(ctx context.Context, nodeReleaseID, nodeID string) ([]string, error) {
 	var docIDs []string
 	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
@@ -955,6 +1318,75 @@ func (r *NodeRepository) GetOldNodeDocIDsByNodeID(ctx context.Context, nodeRelea
 	return docIDs, nil
 }
 
+// DetachNodeReleaseDocIDsExcept clears the rag doc_ids of nodes which are not in keepNodeIDs, and returns the doc_ids to be removed
+func (r *NodeRepository) DetachNodeReleaseDocIDsExcept(ctx context.Context, kbID string, keepNodeIDs []string) ([]string, error) {
+	var docIDs []string
+	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := func() *gorm.DB {
+			q := tx.Model(&domain.NodeRelease{}).
+				Where("kb_id = ?", kbID).
+				Where("doc_id != ''")
+			if len(keepNodeIDs) > 0 {
+				q = q.Where("node_id NOT IN ?", keepNodeIDs)
+			}
+			return q
+		}
+		if err := query().Pluck("doc_id", &docIDs).Error; err != nil {
+			return err
+		}
+		if len(docIDs) == 0 {
+			return nil
+		}
+		return query().Omit("updated_at").Update("doc_id", "").Error
+	}); err != nil {
+		return nil, err
+	}
+	return lo.Uniq(docIDs), nil
+}
+
+// SyncNodeStatusWithRelease updates the node status after the kb is rolled back to nodeReleaseIDs (node_id -> node_release_id):
+// nodes out of the release become unreleased, nodes whose latest node release differs from the release become draft
+func (r *NodeRepository) SyncNodeStatusWithRelease(ctx context.Context, kbID string, nodeReleaseIDs map[string]string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		nodeIDs := lo.Keys(nodeReleaseIDs)
+		unreleasedQuery := tx.Model(&domain.Node{}).
+			Where("kb_id = ?", kbID).
+			Where("status != ?", domain.NodeStatusUnreleased)
+		if len(nodeIDs) > 0 {
+			unreleasedQuery = unreleasedQuery.Where("id NOT IN ?", nodeIDs)
+		}
+		if err := unreleasedQuery.Update("status", domain.NodeStatusUnreleased).Error; err != nil {
+			return err
+		}
+		if len(nodeIDs) == 0 {
+			return nil
+		}
+
+		var latestNodeReleases []*domain.NodeRelease
+		if err := tx.Model(&domain.NodeRelease{}).
+			Where("kb_id = ?", kbID).
+			Where("node_id IN ?", nodeIDs).
+			Select("DISTINCT ON (node_id) id, node_id").
+			Order("node_id, updated_at DESC").
+			Find(&latestNodeReleases).Error; err != nil {
+			return err
+		}
+		draftNodeIDs := make([]string, 0)
+		for _, nodeRelease := range latestNodeReleases {
+			if nodeReleaseIDs[nodeRelease.NodeID] != nodeRelease.ID {
+				draftNodeIDs = append(draftNodeIDs, nodeRelease.NodeID)
+			}
+		}
+		if len(draftNodeIDs) == 0 {
+			return nil
+		}
+		return tx.Model(&domain.Node{}).
+			Where("kb_id = ?", kbID).
+			Where("id IN ?", draftNodeIDs).
+			Update("status", domain.NodeStatusDraft).Error
+	})
+}
+
 func (r *NodeRepository) BatchMove(ctx context.Context, req *domain.BatchMoveReq) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
 		// update node parent_id
@@ -33,7 +33,7 @@ func (r *NodeRepository) GetNodeAuthGroupIdsByNodeId(ctx context.Context, nodeId
 
 		if err := r.db.WithContext(ctx).
 			Model(&domain.NodeAuthGroup{}).
-			Joins("left join nodes on nodes.id = node_auth_groups.node_id").
+			Joins("left join nodes on nodes.id = node_auth_groups.node_id and nodes.deleted_at is null").
 			Where("nodes.permissions->>'answerable' = ?", consts.NodeAccessPermPartial).
 			Where("node_auth_groups.node_id = ? and node_auth_groups.perm = ?", nodeId, perm).
 			Pluck("node_auth_groups.auth_group_id", &authGroupIds).Error; err != nil {
@@ -25,4 +25,13 @@ var ProviderSet = wire.NewSet(
 	NewAPITokenRepo,
 	NewSystemSettingRepo,
 	NewMCPRepository,
+	NewEvalRepository,
+	NewAnswerCacheRepository,
+	NewFAQRepository,
+	NewAgentToolRepository,
+	NewAPIUsageRepository,
+	NewContributeRepository,
+	NewWebhookRepository,
+	NewAuditLogRepository,
+	NewVectorTaskRepository,
 )
@@ -0,0 +1,144 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	v1 "github.com/chaitin/panda-wiki/api/vector/v1"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type VectorTaskRepository struct {
+	db     *pg.DB
+	logger *log.Logger
+}
+
+func NewVectorTaskRepository(db *pg.DB, logger *log.Logger) *VectorTaskRepository {
+	return &VectorTaskRepository{db: db, logger: logger.WithModule("repo.pg.vector_task")}
+}
+
+// CreateDeadLetter saves the dead letter, a redelivered one is ignored
+func (r *VectorTaskRepository) CreateDeadLetter(ctx context.Context, deadLetter *domain.VectorTaskDeadLetter) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(deadLetter).Error
+}
+
+func (r *VectorTaskRepository) ListDeadLetters(ctx context.Context, req *v1.VectorTaskDeadLetterListReq) ([]*domain.VectorTaskDeadLetter, int64, error) {
+	query := r.db.WithContext(ctx).Model(&domain.VectorTaskDeadLetter{})
+	if req.KBId != "" {
+		query = query.Where("kb_id = ?", req.KBId)
+	}
+	if req.Action != "" {
+		query = query.Where("action = ?", req.Action)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	deadLetters := make([]*domain.VectorTaskDeadLetter, 0)
+	if err := query.
+		Order("created_at DESC").
+		Offset(req.Offset()).
+		Limit(req.Limit()).
+		Find(&deadLetters).Error; err != nil {
+		return nil, 0, err
+	}
+	return deadLetters, total, nil
+}
+
+func (r *VectorTaskRepository) CountDeadLetters(ctx context.Context, status domain.VectorTaskDeadLetterStatus) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&domain.VectorTaskDeadLetter{}).
+		Where("status = ?", status).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ClaimDeadLettersForReplay marks the pending dead letters as replayed and returns them,
+// so that concurrent replays never send a task twice
+func (r *VectorTaskRepository) ClaimDeadLettersForReplay(ctx context.Context, ids []string) ([]*domain.VectorTaskDeadLetter, error) {
+	deadLetters := make([]*domain.VectorTaskDeadLetter, 0)
+	if err := r.db.WithContext(ctx).
+		Model(&deadLetters).
+		Clauses(clause.Returning{}).
+		Where("id IN ? AND status = ?", ids, domain.VectorTaskDeadLetterStatusPending).
+		Updates(map[string]any{
+			"status":      domain.VectorTaskDeadLetterStatusReplayed,
+			"replayed_at": time.Now(),
+		}).Error; err != nil {
+		return nil, err
+	}
+	return deadLetters, nil
+}
+
+// ResetDeadLetters puts the dead letters back to pending, used when the replay could not be sent
+func (r *VectorTaskRepository) ResetDeadLetters(ctx context.Context, ids []string) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.VectorTaskDeadLetter{}).
+		Where("id IN ?", ids).
+		Updates(map[string]any{
+			"status":      domain.VectorTaskDeadLetterStatusPending,
+			"replayed_at": nil,
+		}).Error
+}
+
+// DeleteDeadLettersBefore removes the replayed dead letters created before the given time
+func (r *VectorTaskRepository) DeleteDeadLettersBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("created_at < ? AND status = ?", before, domain.VectorTaskDeadLetterStatusReplayed).
+		Delete(&domain.VectorTaskDeadLetter{})
+	return result.RowsAffected, result.Error
+}
+
+// AddStat counts the outcome of a task on the stat of its hour
+func (r *VectorTaskRepository) AddStat(ctx context.Context, hour time.Time, action string, outcome domain.VectorTaskOutcome) error {
+	stat := &domain.VectorTaskStat{Hour: hour, Action: action}
+	switch outcome {
+	case domain.VectorTaskOutcomeSucceeded:
+		stat.Succeeded = 1
+	case domain.VectorTaskOutcomeRetried:
+		stat.Retried = 1
+	case domain.VectorTaskOutcomeDeadLettered:
+		stat.DeadLettered = 1
+	}
+	column := string(outcome)
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "hour"}, {Name: "action"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				column: gorm.Expr("vector_task_stats." + column + " + 1"),
+			}),
+		}).
+		Create(stat).Error
+}
+
+func (r *VectorTaskRepository) ListStats(ctx context.Context, since time.Time) ([]*domain.VectorTaskStat, error) {
+	stats := make([]*domain.VectorTaskStat, 0)
+	if err := r.db.WithContext(ctx).
+		Model(&domain.VectorTaskStat{}).
+		Where("hour >= ?", since).
+		Order("hour ASC, action").
+		Find(&stats).Error; err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (r *VectorTaskRepository) DeleteStatsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("hour < ?", before).
+		Delete(&domain.VectorTaskStat{})
+	return result.RowsAffected, result.Error
+}
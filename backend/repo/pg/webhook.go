@@ -0,0 +1,145 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type WebhookRepository struct {
+	db     *pg.DB
+	logger *log.Logger
+}
+
+func NewWebhookRepository(db *pg.DB, logger *log.Logger) *WebhookRepository {
+	return &WebhookRepository{db: db, logger: logger.WithModule("repo.pg.webhook")}
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, webhook *domain.Webhook) error {
+	return r.db.WithContext(ctx).Create(webhook).Error
+}
+
+func (r *WebhookRepository) Get(ctx context.Context, kbID, id string) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		First(&webhook).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *WebhookRepository) List(ctx context.Context, kbID string) ([]*domain.Webhook, error) {
+	webhooks := make([]*domain.Webhook, 0)
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ?", kbID).
+		Order("created_at ASC").
+		Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// ListSubscribed returns the enabled webhooks of the kb subscribed to the event
+func (r *WebhookRepository) ListSubscribed(ctx context.Context, kbID string, eventType domain.WebhookEventType) ([]*domain.Webhook, error) {
+	webhooks := make([]*domain.Webhook, 0)
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND enabled AND ? = ANY(events)", kbID, string(eventType)).
+		Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) Update(ctx context.Context, kbID, id string, updateMap map[string]any) error {
+	updateMap["updated_at"] = time.Now()
+	return r.db.WithContext(ctx).
+		Model(&domain.Webhook{}).
+		Where("kb_id = ? AND id = ?", kbID, id).
+		Updates(updateMap).Error
+}
+
+// Delete removes the webhook together with its delivery log
+func (r *WebhookRepository) Delete(ctx context.Context, kbID, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("kb_id = ? AND id = ?", kbID, id).Delete(&domain.Webhook{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("kb_id = ? AND webhook_id = ?", kbID, id).Delete(&domain.WebhookDelivery{}).Error
+	})
+}
+
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	return r.db.WithContext(ctx).Create(delivery).Error
+}
+
+// SaveDeliveryResult saves the result of an attempt
+func (r *WebhookRepository) SaveDeliveryResult(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(map[string]any{
+			"status":          delivery.Status,
+			"attempts":        delivery.Attempts,
+			"next_retry_at":   delivery.NextRetryAt,
+			"response_status": delivery.ResponseStatus,
+			"response_body":   delivery.ResponseBody,
+			"error":           delivery.Error,
+			"duration_ms":     delivery.DurationMs,
+			"updated_at":      delivery.UpdatedAt,
+		}).Error
+}
+
+// ListDeliveries returns the delivery log of the kb, newest first, all webhooks when webhookID is empty
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, kbID, webhookID string, status domain.WebhookDeliveryStatus, offset, limit int) ([]*domain.WebhookDelivery, int64, error) {
+	query := r.db.WithContext(ctx).Model(&domain.WebhookDelivery{}).Where("kb_id = ?", kbID)
+	if webhookID != "" {
+		query = query.Where("webhook_id = ?", webhookID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	deliveries := make([]*domain.WebhookDelivery, 0)
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// ClaimDueDeliveries picks the pending deliveries whose retry time has come and pushes their
+// next_retry_at forward by lease, so that concurrent consumers don't send the same delivery twice
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WebhookDelivery, error) {
+	deliveries := make([]*domain.WebhookDelivery, 0)
+	if err := r.db.WithContext(ctx).Raw(`
+		UPDATE webhook_deliveries SET next_retry_at = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_retry_at <= ?
+			ORDER BY next_retry_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		now.Add(lease), now, domain.WebhookDeliveryStatusPending, now, limit,
+	).Scan(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// DeleteDeliveriesBefore removes the finished deliveries created before the given time
+func (r *WebhookRepository) DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("created_at < ? AND status <> ?", before, domain.WebhookDeliveryStatusPending).
+		Delete(&domain.WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
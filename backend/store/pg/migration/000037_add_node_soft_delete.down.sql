@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_nodes_deleted_at;
+ALTER TABLE nodes DROP COLUMN IF EXISTS deleted_root_id;
+ALTER TABLE nodes DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE nodes DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+ALTER TABLE nodes ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+ALTER TABLE nodes ADD COLUMN IF NOT EXISTS deleted_by text default '';
+ALTER TABLE nodes ADD COLUMN IF NOT EXISTS deleted_root_id text default '';
+CREATE INDEX IF NOT EXISTS idx_nodes_deleted_at ON nodes(deleted_at);
@@ -0,0 +1,3 @@
+ALTER TABLE knowledge_bases DROP COLUMN IF EXISTS retrieval_settings;
+DROP INDEX IF EXISTS idx_node_releases_search_vector;
+ALTER TABLE node_releases DROP COLUMN IF EXISTS search_vector;
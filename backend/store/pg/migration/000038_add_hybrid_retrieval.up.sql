@@ -0,0 +1,3 @@
+ALTER TABLE node_releases ADD COLUMN IF NOT EXISTS search_vector tsvector;
+CREATE INDEX IF NOT EXISTS idx_node_releases_search_vector ON node_releases USING gin(search_vector);
+ALTER TABLE knowledge_bases ADD COLUMN IF NOT EXISTS retrieval_settings jsonb NOT NULL DEFAULT '{}';
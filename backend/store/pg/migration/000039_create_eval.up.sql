@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS eval_sets (
+    id TEXT PRIMARY KEY,
+    kb_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_eval_sets_kb_id ON eval_sets(kb_id);
+
+CREATE TABLE IF NOT EXISTS eval_questions (
+    id TEXT PRIMARY KEY,
+    set_id TEXT NOT NULL REFERENCES eval_sets(id) ON DELETE CASCADE,
+    kb_id TEXT NOT NULL,
+    question TEXT NOT NULL,
+    expected_node_ids TEXT[] NOT NULL DEFAULT '{}',
+    reference_answer TEXT NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_eval_questions_set_id ON eval_questions(set_id);
+
+CREATE TABLE IF NOT EXISTS eval_runs (
+    id TEXT PRIMARY KEY,
+    set_id TEXT NOT NULL REFERENCES eval_sets(id) ON DELETE CASCADE,
+    kb_id TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    config jsonb NOT NULL DEFAULT '{}',
+    metrics jsonb NOT NULL DEFAULT '{}',
+    error TEXT NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    finished_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_eval_runs_set_id ON eval_runs(set_id);
+
+CREATE TABLE IF NOT EXISTS eval_run_results (
+    id TEXT PRIMARY KEY,
+    run_id TEXT NOT NULL REFERENCES eval_runs(id) ON DELETE CASCADE,
+    question_id TEXT NOT NULL,
+    question TEXT NOT NULL,
+    expected_node_ids TEXT[] NOT NULL DEFAULT '{}',
+    retrieved_node_ids TEXT[] NOT NULL DEFAULT '{}',
+    recall DOUBLE PRECISION,
+    reciprocal_rank DOUBLE PRECISION,
+    answer TEXT NOT NULL DEFAULT '',
+    faithfulness DOUBLE PRECISION,
+    judge_reason TEXT NOT NULL DEFAULT '',
+    latency_ms BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_eval_run_results_run_id ON eval_run_results(run_id);
@@ -0,0 +1 @@
+ALTER TABLE knowledge_bases ADD COLUMN IF NOT EXISTS chat_model jsonb;
@@ -0,0 +1,3 @@
+ALTER TABLE conversation_messages DROP COLUMN IF EXISTS fallback_from;
+
+DROP TABLE IF EXISTS model_fallbacks;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS model_fallbacks (
+    id TEXT PRIMARY KEY,
+    type TEXT NOT NULL,
+    priority INT NOT NULL DEFAULT 0,
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    api_key TEXT NOT NULL DEFAULT '',
+    api_header TEXT NOT NULL DEFAULT '',
+    base_url TEXT NOT NULL DEFAULT '',
+    api_version TEXT NOT NULL DEFAULT '',
+    parameters jsonb,
+    prompt_tokens BIGINT NOT NULL DEFAULT 0,
+    completion_tokens BIGINT NOT NULL DEFAULT 0,
+    total_tokens BIGINT NOT NULL DEFAULT 0,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_model_fallbacks_type_priority ON model_fallbacks(type, priority);
+
+ALTER TABLE conversation_messages ADD COLUMN IF NOT EXISTS fallback_from TEXT NOT NULL DEFAULT '';
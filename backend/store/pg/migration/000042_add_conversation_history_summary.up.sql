@@ -0,0 +1 @@
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS history_summary jsonb;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_conversation_references_message_id;
+ALTER TABLE conversation_references DROP COLUMN IF EXISTS chunk_ids;
+ALTER TABLE conversation_references DROP COLUMN IF EXISTS citation_index;
+ALTER TABLE conversation_references DROP COLUMN IF EXISTS message_id;
@@ -0,0 +1,4 @@
+ALTER TABLE conversation_references ADD COLUMN IF NOT EXISTS message_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE conversation_references ADD COLUMN IF NOT EXISTS citation_index INT NOT NULL DEFAULT 0;
+ALTER TABLE conversation_references ADD COLUMN IF NOT EXISTS chunk_ids TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_conversation_references_message_id ON conversation_references(message_id);
@@ -0,0 +1 @@
+ALTER TABLE conversation_messages ADD COLUMN IF NOT EXISTS retrieved_count INT;
@@ -0,0 +1,5 @@
+ALTER TABLE conversation_messages DROP COLUMN IF EXISTS answer_cache_id;
+
+DROP TABLE IF EXISTS answer_caches;
+
+ALTER TABLE knowledge_bases DROP COLUMN IF EXISTS answer_cache_settings;
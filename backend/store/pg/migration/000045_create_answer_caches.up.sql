@@ -0,0 +1,23 @@
+ALTER TABLE knowledge_bases ADD COLUMN IF NOT EXISTS answer_cache_settings jsonb NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS answer_caches (
+    id TEXT PRIMARY KEY,
+    kb_id TEXT NOT NULL,
+    release_id TEXT NOT NULL,
+    group_key TEXT NOT NULL DEFAULT '',
+    question TEXT NOT NULL,
+    question_hash TEXT NOT NULL,
+    embedding REAL[],
+    answer TEXT NOT NULL,
+    chunk_results JSONB NOT NULL DEFAULT '[]',
+    citations JSONB NOT NULL DEFAULT '[]',
+    provider TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    hit_count INT NOT NULL DEFAULT 0,
+    last_hit_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_answer_caches_question ON answer_caches(kb_id, release_id, group_key, question_hash);
+
+ALTER TABLE conversation_messages ADD COLUMN IF NOT EXISTS answer_cache_id TEXT;
@@ -0,0 +1,3 @@
+ALTER TABLE conversation_messages DROP COLUMN IF EXISTS faq_id;
+
+DROP TABLE IF EXISTS faqs;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS faqs (
+    id TEXT PRIMARY KEY,
+    kb_id TEXT NOT NULL,
+    questions TEXT[] NOT NULL DEFAULT '{}',
+    embeddings JSONB,
+    answer TEXT NOT NULL,
+    node_ids TEXT[] NOT NULL DEFAULT '{}',
+    threshold DOUBLE PRECISION NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    hit_count INT NOT NULL DEFAULT 0,
+    last_hit_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_faqs_kb_id ON faqs(kb_id);
+
+ALTER TABLE conversation_messages ADD COLUMN IF NOT EXISTS faq_id TEXT;
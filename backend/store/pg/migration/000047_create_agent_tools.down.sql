@@ -0,0 +1,5 @@
+ALTER TABLE conversation_messages DROP COLUMN IF EXISTS tool_traces;
+
+DROP TABLE IF EXISTS agent_tools;
+
+ALTER TABLE knowledge_bases DROP COLUMN IF EXISTS agent_settings;
@@ -0,0 +1,20 @@
+ALTER TABLE knowledge_bases ADD COLUMN IF NOT EXISTS agent_settings jsonb NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS agent_tools (
+    id TEXT PRIMARY KEY,
+    kb_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    parameters JSONB NOT NULL DEFAULT '{}',
+    method TEXT NOT NULL DEFAULT 'POST',
+    url TEXT NOT NULL,
+    headers JSONB NOT NULL DEFAULT '{}',
+    timeout_seconds INT NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_agent_tools_kb_id_name ON agent_tools(kb_id, name);
+
+ALTER TABLE conversation_messages ADD COLUMN IF NOT EXISTS tool_traces jsonb;
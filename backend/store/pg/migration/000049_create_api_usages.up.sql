@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS api_usages (
+    kb_id TEXT NOT NULL,
+    date DATE NOT NULL,
+    endpoint TEXT NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    tokens BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (kb_id, date, endpoint)
+);
@@ -0,0 +1,5 @@
+-- token 哈希无法还原，回滚后已有 token 需要重新创建
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS last_used_at;
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS scopes;
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS token_prefix;
@@ -0,0 +1,11 @@
+ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS token_prefix TEXT NOT NULL DEFAULT '';
+ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ;
+
+-- 已有 token 改为保存 sha256，保留全部权限范围以兼容现有调用方
+UPDATE api_tokens
+SET token_prefix = LEFT(token, 8),
+    token = encode(sha256(convert_to(token, 'UTF8')), 'hex'),
+    scopes = ARRAY['node:read', 'node:write', 'release:read', 'release:create', 'stats:read']
+WHERE token_prefix = '';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_contributes_kb_id_status;
+ALTER TABLE contributes DROP COLUMN IF EXISTS audit_reason;
+ALTER TABLE contributes DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,3 @@
+ALTER TABLE contributes ADD COLUMN IF NOT EXISTS parent_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE contributes ADD COLUMN IF NOT EXISTS audit_reason TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_contributes_kb_id_status ON contributes (kb_id, status, created_at DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS vector_task_stats;
+DROP TABLE IF EXISTS vector_task_dead_letters;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS vector_task_dead_letters (
+    id TEXT PRIMARY KEY,
+    kb_id TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL DEFAULT '',
+    node_id TEXT NOT NULL DEFAULT '',
+    node_release_id TEXT NOT NULL DEFAULT '',
+    doc_id TEXT NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'pending',
+    replayed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_vector_task_dead_letters_status_created_at ON vector_task_dead_letters(status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_vector_task_dead_letters_kb_id ON vector_task_dead_letters(kb_id);
+
+CREATE TABLE IF NOT EXISTS vector_task_stats (
+    hour TIMESTAMPTZ NOT NULL,
+    action TEXT NOT NULL,
+    succeeded BIGINT NOT NULL DEFAULT 0,
+    retried BIGINT NOT NULL DEFAULT 0,
+    dead_lettered BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (hour, action)
+);
@@ -0,0 +1,6 @@
+DELETE FROM answer_caches;
+
+DROP INDEX IF EXISTS uniq_answer_caches_question;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_answer_caches_question ON answer_caches(kb_id, release_id, group_key, question_hash);
+
+ALTER TABLE answer_caches DROP COLUMN IF EXISTS app_id;
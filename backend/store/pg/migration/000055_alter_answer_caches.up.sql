@@ -0,0 +1,7 @@
+-- 不同应用的提示词和模型不同，缓存按应用区分；已有的缓存无法确定应用，直接清空
+DELETE FROM answer_caches;
+
+ALTER TABLE answer_caches ADD COLUMN IF NOT EXISTS app_id TEXT NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS uniq_answer_caches_question;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_answer_caches_question ON answer_caches(kb_id, release_id, app_id, group_key, question_hash);
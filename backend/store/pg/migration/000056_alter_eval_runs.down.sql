@@ -0,0 +1 @@
+ALTER TABLE eval_runs DROP COLUMN IF EXISTS heartbeat_at;
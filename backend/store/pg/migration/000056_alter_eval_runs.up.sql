@@ -0,0 +1,3 @@
+-- 运行中的评测定期更新心跳，超过租约未更新的视为中断
+ALTER TABLE eval_runs ADD COLUMN IF NOT EXISTS heartbeat_at timestamptz;
+UPDATE eval_runs SET heartbeat_at = created_at WHERE heartbeat_at IS NULL;
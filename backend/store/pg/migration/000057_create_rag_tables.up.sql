@@ -0,0 +1,54 @@
+-- pgvector 检索的表，未安装 pgvector 扩展时跳过，使用 ct 检索的部署不需要安装
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'vector') THEN
+        RAISE NOTICE 'extension vector is not available, skip the rag tables';
+        RETURN;
+    END IF;
+
+    CREATE EXTENSION IF NOT EXISTS vector;
+
+    CREATE TABLE IF NOT EXISTS rag_documents (
+        id TEXT PRIMARY KEY,
+        dataset_id TEXT NOT NULL,
+        title TEXT NOT NULL DEFAULT '',
+        group_ids INTEGER[],
+        tags TEXT[],
+        status TEXT NOT NULL DEFAULT '',
+        progress_msg TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+    CREATE INDEX IF NOT EXISTS idx_rag_documents_dataset_id ON rag_documents(dataset_id);
+
+    CREATE TABLE IF NOT EXISTS rag_chunks (
+        id TEXT PRIMARY KEY,
+        dataset_id TEXT NOT NULL,
+        document_id TEXT NOT NULL REFERENCES rag_documents(id) ON DELETE CASCADE,
+        seq INTEGER NOT NULL,
+        content TEXT NOT NULL,
+        embedding vector NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+    CREATE INDEX IF NOT EXISTS idx_rag_chunks_dataset_id ON rag_chunks(dataset_id);
+    CREATE INDEX IF NOT EXISTS idx_rag_chunks_document_id ON rag_chunks(document_id);
+
+    -- 嵌入模型的维度不固定，常见维度各建一个部分索引，其他维度精确检索
+    CREATE INDEX IF NOT EXISTS idx_rag_chunks_embedding_768 ON rag_chunks USING hnsw ((embedding::vector(768)) vector_cosine_ops) WHERE vector_dims(embedding) = 768;
+    CREATE INDEX IF NOT EXISTS idx_rag_chunks_embedding_1024 ON rag_chunks USING hnsw ((embedding::vector(1024)) vector_cosine_ops) WHERE vector_dims(embedding) = 1024;
+    CREATE INDEX IF NOT EXISTS idx_rag_chunks_embedding_1536 ON rag_chunks USING hnsw ((embedding::vector(1536)) vector_cosine_ops) WHERE vector_dims(embedding) = 1536;
+
+    CREATE TABLE IF NOT EXISTS rag_models (
+        type TEXT PRIMARY KEY,
+        id TEXT NOT NULL DEFAULT '',
+        provider TEXT NOT NULL DEFAULT '',
+        model TEXT NOT NULL DEFAULT '',
+        base_url TEXT NOT NULL DEFAULT '',
+        api_key TEXT NOT NULL DEFAULT '',
+        api_header TEXT NOT NULL DEFAULT '',
+        api_version TEXT NOT NULL DEFAULT '',
+        parameters JSONB,
+        is_active BOOLEAN NOT NULL DEFAULT false,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+END $$;
@@ -0,0 +1,4 @@
+-- 相似度阈值 0 原表示使用默认值，现在表示不过滤，已保存的 0 改为未设置以保持原有行为
+UPDATE knowledge_bases
+SET retrieval_settings = retrieval_settings - 'similarity_threshold'
+WHERE (retrieval_settings->>'similarity_threshold')::float8 <= 0;
@@ -0,0 +1 @@
+UPDATE api_tokens SET scopes = array_remove(scopes, 'legacy');
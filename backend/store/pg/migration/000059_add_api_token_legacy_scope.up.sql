@@ -0,0 +1,6 @@
+-- 升级前创建的 token 在 000050 中以原 token 前 8 位作为前缀，新 token 均以 pw_ 开头；
+-- 为其保留 legacy 权限范围，继续访问未声明权限范围的接口
+UPDATE api_tokens
+SET scopes = array_append(scopes, 'legacy')
+WHERE (char_length(token_prefix) <> 10 OR token_prefix NOT LIKE 'pw\_%')
+  AND NOT ('legacy' = ANY(scopes));
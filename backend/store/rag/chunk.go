@@ -0,0 +1,132 @@
+package rag
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+type textChunk struct {
+	Heading string // nearest markdown heading of the chunk
+	Content string
+}
+
+// splitMarkdown splits markdown into chunks of at most chunkSize runes along paragraph boundaries,
+// adjacent chunks share up to overlap runes of trailing paragraphs
+func splitMarkdown(markdown string, chunkSize, overlap int) []textChunk {
+	if chunkSize <= 0 {
+		chunkSize = 800
+	}
+	if overlap < 0 || overlap >= chunkSize {
+		overlap = 0
+	}
+
+	chunks := make([]textChunk, 0)
+	var (
+		heading string
+		current []string
+		size    int
+		fresh   bool // current holds paragraphs not emitted yet
+	)
+	flush := func() {
+		if !fresh {
+			return
+		}
+		fresh = false
+		chunks = append(chunks, textChunk{Heading: heading, Content: strings.Join(current, "\n\n")})
+		// keep trailing paragraphs as the overlap of the next chunk
+		kept := make([]string, 0)
+		keptSize := 0
+		for i := len(current) - 1; i >= 0; i-- {
+			n := utf8.RuneCountInString(current[i])
+			if keptSize+n > overlap {
+				break
+			}
+			kept = append([]string{current[i]}, kept...)
+			keptSize += n
+		}
+		current, size = kept, keptSize
+	}
+
+	for _, para := range splitParagraphs(markdown) {
+		if strings.HasPrefix(para, "#") {
+			// a new section never carries the overlap of the previous one
+			flush()
+			current, size = nil, 0
+			heading = strings.TrimSpace(strings.TrimLeft(para, "#"))
+		}
+		for _, piece := range splitLongText(para, chunkSize) {
+			n := utf8.RuneCountInString(piece)
+			if size > 0 && size+n > chunkSize {
+				flush()
+				if size+n > chunkSize {
+					current, size = nil, 0
+				}
+			}
+			current = append(current, piece)
+			size += n
+			fresh = true
+		}
+	}
+	flush()
+	return chunks
+}
+
+// splitParagraphs splits markdown by blank lines, fenced code blocks are kept as a whole
+func splitParagraphs(markdown string) []string {
+	paras := make([]string, 0)
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	current := make([]string, 0)
+	inFence := false
+	flush := func() {
+		if para := strings.TrimSpace(strings.Join(current, "\n")); para != "" {
+			paras = append(paras, para)
+		}
+		current = current[:0]
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if !inFence && trimmed == "" {
+			flush()
+			continue
+		}
+		if !inFence && strings.HasPrefix(trimmed, "#") {
+			// headings are paragraphs of their own
+			flush()
+			current = append(current, trimmed)
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+	return paras
+}
+
+// splitLongText cuts text longer than size runes, preferring line and sentence ends
+func splitLongText(text string, size int) []string {
+	runes := []rune(text)
+	if len(runes) <= size {
+		return []string{text}
+	}
+	pieces := make([]string, 0, len(runes)/size+1)
+	for len(runes) > size {
+		cut := size
+		for i := size - 1; i > size/2; i-- {
+			if strings.ContainsRune("\n。！？.!?；;", runes[i]) {
+				cut = i + 1
+				break
+			}
+		}
+		if piece := strings.TrimSpace(string(runes[:cut])); piece != "" {
+			pieces = append(pieces, piece)
+		}
+		runes = runes[cut:]
+	}
+	if piece := strings.TrimSpace(string(runes)); piece != "" {
+		pieces = append(pieces, piece)
+	}
+	return pieces
+}
@@ -0,0 +1,44 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitMarkdown_Headings(t *testing.T) {
+	chunks := splitMarkdown("# 安装\n\n下载安装包。\n\n# 配置\n\n修改配置文件。", 800, 100)
+
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, "安装", chunks[0].Heading)
+	assert.Equal(t, "# 安装\n\n下载安装包。", chunks[0].Content)
+	assert.Equal(t, "配置", chunks[1].Heading)
+}
+
+func TestSplitMarkdown_SizeAndOverlap(t *testing.T) {
+	paras := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		paras = append(paras, strings.Repeat(string(rune('a'+i)), 30))
+	}
+	chunks := splitMarkdown(strings.Join(paras, "\n\n"), 100, 40)
+
+	assert.Greater(t, len(chunks), 1)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, utf8.RuneCountInString(chunk.Content), 100)
+	}
+	// the last paragraph of a chunk starts the next one
+	first := strings.Split(chunks[0].Content, "\n\n")
+	assert.True(t, strings.HasPrefix(chunks[1].Content, first[len(first)-1]))
+	assert.True(t, strings.HasSuffix(chunks[len(chunks)-1].Content, paras[9]))
+}
+
+func TestSplitMarkdown_LongParagraph(t *testing.T) {
+	chunks := splitMarkdown(strings.Repeat("很长的句子。", 100), 120, 0)
+
+	assert.Len(t, chunks, 5)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, utf8.RuneCountInString(chunk.Content), 120)
+	}
+}
@@ -0,0 +1,469 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JohannesKaufmann/html-to-markdown/v2/converter"
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+// PGVectorRAG stores chunks and embeddings in postgres with the pgvector extension,
+// embedding and rerank go through the openai compatible models synced by UpsertModel
+type PGVectorRAG struct {
+	db     *pg.DB
+	config config.PGVectorConfig
+	models *ModelClient
+	logger *log.Logger
+	mdConv *converter.Converter
+}
+
+// table: rag_documents
+type pgvectorDocument struct {
+	ID          string         `gorm:"primaryKey"`
+	DatasetID   string         `gorm:"index"`
+	Title       string         `gorm:"column:title"`
+	GroupIDs    pq.Int64Array  `gorm:"column:group_ids;type:integer[]"` // NULL means open to everyone, empty means answerable by no group
+	Tags        pq.StringArray `gorm:"column:tags;type:text[]"`
+	Status      string
+	ProgressMsg string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (pgvectorDocument) TableName() string {
+	return "rag_documents"
+}
+
+// table: rag_models
+type pgvectorModel struct {
+	Type       domain.ModelType  `gorm:"primaryKey"`
+	ID         string            `gorm:"column:id"`
+	Provider   string            `gorm:"column:provider"`
+	Model      string            `gorm:"column:model"`
+	BaseURL    string            `gorm:"column:base_url"`
+	APIKey     string            `gorm:"column:api_key"`
+	APIHeader  string            `gorm:"column:api_header"`
+	APIVersion string            `gorm:"column:api_version"`
+	Parameters domain.ModelParam `gorm:"column:parameters;type:jsonb"`
+	IsActive   bool              `gorm:"column:is_active"`
+	UpdatedAt  time.Time
+}
+
+func (pgvectorModel) TableName() string {
+	return "rag_models"
+}
+
+func (m *pgvectorModel) toDomain() *domain.Model {
+	return &domain.Model{
+		ID:         m.ID,
+		Provider:   domain.ModelProvider(m.Provider),
+		Model:      m.Model,
+		BaseURL:    m.BaseURL,
+		APIKey:     m.APIKey,
+		APIHeader:  m.APIHeader,
+		APIVersion: m.APIVersion,
+		Type:       m.Type,
+		IsActive:   m.IsActive,
+		Parameters: m.Parameters,
+	}
+}
+
+// pgvectorIndexedDimensions are the embedding dimensions with a hnsw index, see migration 000057
+var pgvectorIndexedDimensions = []int{768, 1024, 1536}
+
+func NewPGVectorRAG(config *config.Config, db *pg.DB, logger *log.Logger) (*PGVectorRAG, error) {
+	s := &PGVectorRAG{
+		db:     db,
+		config: config.RAG.PGVector,
+		models: NewModelClient(),
+		logger: logger.WithModule("store.vector.pgvector"),
+		mdConv: NewHTML2MDConverter(),
+	}
+	// 表由数据库迁移创建，迁移时未安装 pgvector 扩展则不存在
+	var table string
+	if err := db.Raw("SELECT COALESCE(to_regclass('rag_chunks')::text, '')").Scan(&table).Error; err != nil {
+		return nil, fmt.Errorf("check pgvector schema failed: %w", err)
+	}
+	if table == "" {
+		return nil, errors.New("pgvector schema not found, install the vector extension and rerun migration 57")
+	}
+	if s.config.Dimension > 0 && !slices.Contains(pgvectorIndexedDimensions, s.config.Dimension) {
+		s.logger.Warn("no hnsw index for the embedding dimension, chunks are searched exactly",
+			log.Int("dimension", s.config.Dimension), log.Any("indexed", pgvectorIndexedDimensions))
+	}
+	return s, nil
+}
+
+func (s *PGVectorRAG) CreateKnowledgeBase(ctx context.Context) (string, error) {
+	// datasets are implicit, documents and chunks carry the dataset id
+	return uuid.New().String(), nil
+}
+
+func (s *PGVectorRAG) QueryRecords(ctx context.Context, req *QueryRecordsRequest) (string, []*domain.NodeContentChunk, error) {
+	embeddingModel, err := s.getModel(ctx, domain.ModelTypeEmbedding)
+	if err != nil {
+		return "", nil, err
+	}
+	// follow-up questions are embedded with the previous user question as context
+	queryText := req.Query
+	for i := len(req.HistoryMsgs) - 1; i >= 0; i-- {
+		if msg := req.HistoryMsgs[i]; msg.Role == schema.User && msg.Content != req.Query {
+			queryText = msg.Content + "\n" + req.Query
+			break
+		}
+	}
+	embeddings, err := s.models.Embed(ctx, embeddingModel, []string{queryText})
+	if err != nil {
+		return "", nil, err
+	}
+	queryVector := vectorLiteral(embeddings[0])
+
+	topK := req.TopK
+	if topK <= 0 {
+		topK = s.config.TopK
+	}
+	if topK <= 0 {
+		topK = 10
+	}
+	rerankModel, err := s.getModel(ctx, domain.ModelTypeRerank)
+	if err != nil && !errors.Is(err, errModelNotFound) {
+		return "", nil, err
+	}
+	limit := topK
+	if rerankModel != nil {
+		limit = topK * 3
+	}
+
+	type scoredChunk struct {
+		ID         string
+		DocumentID string
+		Seq        uint
+		Content    string
+		Score      float64
+	}
+	dim := len(embeddings[0])
+	distance := "c.embedding <=> ?::vector"
+	if dim == s.config.Dimension && slices.Contains(pgvectorIndexedDimensions, dim) {
+		// match the hnsw expression index
+		distance = fmt.Sprintf("c.embedding::vector(%d) <=> ?::vector(%d)", dim, dim)
+	}
+	// chunks embedded by a previous model may have another dimension, <=> fails on them
+	filters := []string{"c.dataset_id = ?", fmt.Sprintf("vector_dims(c.embedding) = %d", dim)}
+	args := []any{queryVector, req.DatasetID}
+	// NULL group_ids is open to everyone, an empty one is answerable by no group,
+	// a nil or empty req.GroupIDs both only see the open documents
+	if len(req.GroupIDs) > 0 {
+		filters = append(filters, "(d.group_ids IS NULL OR d.group_ids && ?::integer[])")
+		args = append(args, toInt64Array(req.GroupIDs))
+	} else {
+		filters = append(filters, "d.group_ids IS NULL")
+	}
+	if len(req.Tags) > 0 {
+		filters = append(filters, "d.tags && ?::text[]")
+		args = append(args, pq.Array(req.Tags))
+	}
+	maxPerDoc := req.MaxChunksPerDoc
+	if maxPerDoc <= 0 {
+		maxPerDoc = limit
+	}
+	args = append(args, req.SimilarityThreshold, maxPerDoc, limit)
+	sql := fmt.Sprintf(`
+SELECT id, document_id, seq, content, score FROM (
+    SELECT *, 1 - distance AS score, row_number() OVER (PARTITION BY document_id ORDER BY distance) AS doc_rank FROM (
+        SELECT c.id, c.document_id, c.seq, c.content, %s AS distance
+        FROM rag_chunks c JOIN rag_documents d ON d.id = c.document_id
+        WHERE %s
+        ORDER BY distance
+        LIMIT 200
+    ) candidates
+) ranked
+WHERE score >= ? AND doc_rank <= ?
+ORDER BY score DESC
+LIMIT ?`, distance, strings.Join(filters, " AND "))
+	var chunks []scoredChunk
+	if err := s.db.WithContext(ctx).Raw(sql, args...).Scan(&chunks).Error; err != nil {
+		return "", nil, fmt.Errorf("query chunks failed: %w", err)
+	}
+
+	if rerankModel != nil && len(chunks) > 0 {
+		documents := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			documents[i] = chunk.Content
+		}
+		results, err := s.models.rerank(ctx, rerankModel, req.Query, documents)
+		if err != nil {
+			// retrieval still works without rerank
+			s.logger.Warn("rerank chunks failed, use vector order", log.Error(err))
+		} else {
+			reranked := make([]scoredChunk, 0, len(results))
+			for _, result := range results {
+				chunk := chunks[result.Index]
+				chunk.Score = result.Score
+				reranked = append(reranked, chunk)
+			}
+			chunks = reranked
+		}
+	}
+	if len(chunks) > topK {
+		chunks = chunks[:topK]
+	}
+
+	s.logger.Info("retrieve chunks result", log.Int("chunks count", len(chunks)), log.String("query", queryText))
+	nodeChunks := make([]*domain.NodeContentChunk, len(chunks))
+	for i, chunk := range chunks {
+		nodeChunks[i] = &domain.NodeContentChunk{
+			ID:      chunk.ID,
+			Content: chunk.Content,
+			DocID:   chunk.DocumentID,
+			Seq:     chunk.Seq,
+			Score:   chunk.Score,
+		}
+	}
+	return req.Query, nodeChunks, nil
+}
+
+func (s *PGVectorRAG) UpsertRecords(ctx context.Context, req *UpsertRecordsRequest) (string, error) {
+	markdown := req.Content
+	// if the content is html, convert it to markdown first
+	if utils.IsLikelyHTML(req.Content) {
+		var err error
+		markdown, err = s.mdConv.ConvertString(req.Content)
+		if err != nil {
+			return "", fmt.Errorf("convert html to markdown failed: %w", err)
+		}
+	}
+	docID := req.DocID
+	if docID == "" {
+		docID = uuid.New().String()
+	}
+	doc := &pgvectorDocument{
+		ID:        docID,
+		DatasetID: req.DatasetID,
+		Title:     req.Title,
+		Status:    string(consts.NodeRagStatusRunning),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if req.GroupIDs != nil {
+		doc.GroupIDs = toInt64Array(req.GroupIDs)
+	}
+	if req.Tags != nil {
+		doc.Tags = req.Tags
+	}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"dataset_id", "title", "group_ids", "tags", "status", "progress_msg", "updated_at"}),
+	}).Create(doc).Error; err != nil {
+		return "", fmt.Errorf("save document failed: %w", err)
+	}
+
+	if err := s.indexDocument(ctx, doc, markdown); err != nil {
+		if updateErr := s.updateDocumentStatus(ctx, docID, consts.NodeRagStatusFailed, err.Error()); updateErr != nil {
+			s.logger.Error("update document status failed", log.String("doc_id", docID), log.Error(updateErr))
+		}
+		return "", err
+	}
+	if err := s.updateDocumentStatus(ctx, docID, consts.NodeRagStatusSucceeded, ""); err != nil {
+		return "", err
+	}
+	return docID, nil
+}
+
+func (s *PGVectorRAG) indexDocument(ctx context.Context, doc *pgvectorDocument, markdown string) error {
+	embeddingModel, err := s.getModel(ctx, domain.ModelTypeEmbedding)
+	if err != nil {
+		return err
+	}
+	chunks := splitMarkdown(markdown, s.config.ChunkSize, s.config.ChunkOverlap)
+	inputs := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		// title and heading give the chunk its context in the vector space
+		input := doc.Title
+		if chunk.Heading != "" && chunk.Heading != doc.Title {
+			input += "\n" + chunk.Heading
+		}
+		inputs[i] = input + "\n\n" + chunk.Content
+	}
+	embeddings, err := s.models.Embed(ctx, embeddingModel, inputs)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM rag_chunks WHERE document_id = ?", doc.ID).Error; err != nil {
+			return err
+		}
+		for i, chunk := range chunks {
+			if err := tx.Exec(
+				"INSERT INTO rag_chunks (id, dataset_id, document_id, seq, content, embedding) VALUES (?, ?, ?, ?, ?, ?::vector)",
+				uuid.New().String(), doc.DatasetID, doc.ID, i, chunk.Content, vectorLiteral(embeddings[i]),
+			).Error; err != nil {
+				return fmt.Errorf("save chunk failed: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *PGVectorRAG) updateDocumentStatus(ctx context.Context, docID string, status consts.NodeRagInfoStatus, msg string) error {
+	return s.db.WithContext(ctx).
+		Model(&pgvectorDocument{}).
+		Where("id = ?", docID).
+		Updates(map[string]any{
+			"status":       string(status),
+			"progress_msg": msg,
+		}).Error
+}
+
+func (s *PGVectorRAG) DeleteRecords(ctx context.Context, datasetID string, docIDs []string) error {
+	if len(docIDs) == 0 {
+		return nil
+	}
+	// chunks are removed by the foreign key cascade
+	return s.db.WithContext(ctx).
+		Where("dataset_id = ?", datasetID).
+		Where("id IN ?", docIDs).
+		Delete(&pgvectorDocument{}).Error
+}
+
+func (s *PGVectorRAG) DeleteKnowledgeBase(ctx context.Context, datasetID string) error {
+	return s.db.WithContext(ctx).
+		Where("dataset_id = ?", datasetID).
+		Delete(&pgvectorDocument{}).Error
+}
+
+func (s *PGVectorRAG) UpdateDocumentGroupIDs(ctx context.Context, datasetID string, docID string, groupIds []int) error {
+	var groupIDs any
+	if groupIds != nil {
+		groupIDs = toInt64Array(groupIds)
+	}
+	if err := s.db.WithContext(ctx).
+		Model(&pgvectorDocument{}).
+		Where("dataset_id = ?", datasetID).
+		Where("id = ?", docID).
+		Update("group_ids", groupIDs).Error; err != nil {
+		return fmt.Errorf("update document group IDs failed: %w", err)
+	}
+	return nil
+}
+
+func (s *PGVectorRAG) ListDocuments(ctx context.Context, datasetID string, documentIDs []string) ([]Document, error) {
+	var docs []*pgvectorDocument
+	query := s.db.WithContext(ctx).Where("dataset_id = ?", datasetID)
+	if len(documentIDs) > 0 {
+		query = query.Where("id IN ?", documentIDs)
+	}
+	if err := query.Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	documents := make([]Document, len(docs))
+	for i, doc := range docs {
+		groupIDs := make([]int, 0, len(doc.GroupIDs))
+		for _, id := range doc.GroupIDs {
+			groupIDs = append(groupIDs, int(id))
+		}
+		documents[i] = Document{
+			ID:          doc.ID,
+			Name:        doc.Title,
+			DatasetID:   doc.DatasetID,
+			Status:      doc.Status,
+			ProgressMsg: doc.ProgressMsg,
+			Tags:        doc.Tags,
+			MetaData:    DocumentMetadata{GroupIDs: groupIDs},
+		}
+	}
+	return documents, nil
+}
+
+var errModelNotFound = errors.New("model not found")
+
+func (s *PGVectorRAG) getModel(ctx context.Context, modelType domain.ModelType) (*domain.Model, error) {
+	var model pgvectorModel
+	if err := s.db.WithContext(ctx).
+		Where("type = ?", modelType).
+		Where("is_active = ?", true).
+		First(&model).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%s %w", modelType, errModelNotFound)
+		}
+		return nil, err
+	}
+	return model.toDomain(), nil
+}
+
+func (s *PGVectorRAG) AddModel(ctx context.Context, model *domain.Model) (string, error) {
+	if model.ID == "" {
+		model.ID = uuid.New().String()
+	}
+	if err := s.UpsertModel(ctx, model); err != nil {
+		return "", err
+	}
+	return model.ID, nil
+}
+
+// UpsertModel keeps one model per type, which is what the rag pipeline uses
+func (s *PGVectorRAG) UpsertModel(ctx context.Context, model *domain.Model) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "type"}},
+		UpdateAll: true,
+	}).Create(&pgvectorModel{
+		Type:       model.Type,
+		ID:         model.ID,
+		Provider:   string(model.Provider),
+		Model:      model.Model,
+		BaseURL:    model.BaseURL,
+		APIKey:     model.APIKey,
+		APIHeader:  model.APIHeader,
+		APIVersion: model.APIVersion,
+		Parameters: model.Parameters,
+		IsActive:   model.IsActive,
+		UpdatedAt:  time.Now(),
+	}).Error
+}
+
+func (s *PGVectorRAG) UpdateModel(ctx context.Context, model *domain.Model) error {
+	return s.UpsertModel(ctx, model)
+}
+
+func (s *PGVectorRAG) DeleteModel(ctx context.Context, model *domain.Model) error {
+	return s.db.WithContext(ctx).
+		Where("type = ?", model.Type).
+		Where("id = ?", model.ID).
+		Delete(&pgvectorModel{}).Error
+}
+
+func (s *PGVectorRAG) GetModelList(ctx context.Context) ([]*domain.Model, error) {
+	var models []*pgvectorModel
+	if err := s.db.WithContext(ctx).Find(&models).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*domain.Model, len(models))
+	for i, model := range models {
+		result[i] = model.toDomain()
+	}
+	return result, nil
+}
+
+func toInt64Array(ids []int) pq.Int64Array {
+	arr := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		arr[i] = int64(id)
+	}
+	return arr
+}
@@ -0,0 +1,151 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+const embeddingBatchSize = 16
+
+// modelURL follows the ModelKit convention: a base url ending with "#" is the full endpoint
+func modelURL(baseURL, path string) string {
+	if strings.HasSuffix(baseURL, "#") {
+		return strings.TrimSuffix(baseURL, "#")
+	}
+	return strings.TrimSuffix(baseURL, "/") + path
+}
+
+// ModelClient calls the openai compatible embedding and rerank apis of the configured models
+type ModelClient struct {
+	httpClient *http.Client
+}
+
+func NewModelClient() *ModelClient {
+	return &ModelClient{httpClient: &http.Client{Timeout: 60 * time.Second}}
+}
+
+func (c *ModelClient) postModel(ctx context.Context, model *domain.Model, path string, body, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, modelURL(model.BaseURL, path), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if model.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+model.APIKey)
+	}
+	// api header is one "Key: Value" per line
+	for _, line := range strings.Split(model.APIHeader, "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) != "" {
+			req.Header.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("request %s failed: %s %s", model.Model, resp.Status, string(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// Embed returns the embeddings of texts in order through the openai compatible embeddings api
+func (c *ModelClient) Embed(ctx context.Context, model *domain.Model, texts []string) ([][]float32, error) {
+	embeddings, _, err := c.EmbedWithUsage(ctx, model, texts)
+	return embeddings, err
+}
+
+// EmbedWithUsage is Embed which also returns the prompt tokens reported by the api, zero when it reports none
+func (c *ModelClient) EmbedWithUsage(ctx context.Context, model *domain.Model, texts []string) ([][]float32, int, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	tokens := 0
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(texts))
+		var resp struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+			Usage struct {
+				PromptTokens int `json:"prompt_tokens"`
+			} `json:"usage"`
+		}
+		if err := c.postModel(ctx, model, "/embeddings", map[string]any{
+			"model":           model.Model,
+			"input":           texts[start:end],
+			"encoding_format": "float",
+		}, &resp); err != nil {
+			return nil, 0, fmt.Errorf("embedding failed: %w", err)
+		}
+		if len(resp.Data) != end-start {
+			return nil, 0, fmt.Errorf("embedding failed: got %d embeddings for %d inputs", len(resp.Data), end-start)
+		}
+		sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+		for _, item := range resp.Data {
+			embeddings = append(embeddings, item.Embedding)
+		}
+		tokens += resp.Usage.PromptTokens
+	}
+	return embeddings, tokens, nil
+}
+
+type rerankResult struct {
+	Index int
+	Score float64
+}
+
+// rerank scores documents against the query through the jina/cohere style rerank api
+func (c *ModelClient) rerank(ctx context.Context, model *domain.Model, query string, documents []string) ([]rerankResult, error) {
+	var resp struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err := c.postModel(ctx, model, "/rerank", map[string]any{
+		"model":     model.Model,
+		"query":     query,
+		"documents": documents,
+		"top_n":     len(documents),
+	}, &resp); err != nil {
+		return nil, fmt.Errorf("rerank failed: %w", err)
+	}
+	results := make([]rerankResult, 0, len(resp.Results))
+	for _, item := range resp.Results {
+		if item.Index < 0 || item.Index >= len(documents) {
+			continue
+		}
+		results = append(results, rerankResult{Index: item.Index, Score: item.RelevanceScore})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results, nil
+}
+
+// vectorLiteral formats an embedding as the pgvector text representation
+func vectorLiteral(embedding []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range embedding {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%g", v)
+	}
+	b.WriteByte(']')
+	return b.String()
+}
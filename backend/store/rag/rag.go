@@ -10,6 +10,7 @@ import (
 	"github.com/chaitin/panda-wiki/config"
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
 )
 
 type QueryRecordsRequest struct {
@@ -20,6 +21,7 @@ type QueryRecordsRequest struct {
 	SimilarityThreshold float64
 	HistoryMsgs         []*schema.Message
 	MaxChunksPerDoc     int
+	TopK                int // 0 uses the provider default
 }
 
 type UpsertRecordsRequest struct {
@@ -62,10 +64,12 @@ type RAGService interface {
 	DeleteModel(ctx context.Context, model *domain.Model) error
 }
 
-func NewRAGService(config *config.Config, logger *log.Logger) (RAGService, error) {
+func NewRAGService(config *config.Config, db *pg.DB, logger *log.Logger) (RAGService, error) {
 	switch config.RAG.Provider {
 	case "ct":
 		return NewCTRAG(config, logger)
+	case "pgvector":
+		return NewPGVectorRAG(config, db, logger)
 	default:
 		return nil, fmt.Errorf("unsupported vector provider: %s", config.RAG.Provider)
 	}
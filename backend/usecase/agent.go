@@ -0,0 +1,532 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+
+	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+const (
+	agentToolResultMaxRunes   = 8000      // 返回给模型的工具结果长度上限
+	agentToolResponseMaxBytes = 256 << 10 // HTTP 工具读取的响应体上限
+)
+
+var agentEmptyParameters = json.RawMessage(`{"type":"object","properties":{}}`)
+
+type AgentUsecase struct {
+	repo        *pg.AgentToolRepository
+	llmUsecase  *LLMUsecase
+	nodeUsecase *NodeUsecase
+	httpClient  *http.Client
+	logger      *log.Logger
+}
+
+func NewAgentUsecase(repo *pg.AgentToolRepository, llmUsecase *LLMUsecase, nodeUsecase *NodeUsecase, logger *log.Logger) *AgentUsecase {
+	return &AgentUsecase{
+		repo:        repo,
+		llmUsecase:  llmUsecase,
+		nodeUsecase: nodeUsecase,
+		httpClient:  utils.NewPublicHTTPClient(0), // 超时由每个工具的配置控制
+		logger:      logger.WithModule("usecase.agent"),
+	}
+}
+
+func (u *AgentUsecase) List(ctx context.Context, req *v1.AgentToolListReq) ([]*domain.AgentTool, error) {
+	tools, err := u.repo.List(ctx, req.KBId)
+	if err != nil {
+		return nil, err
+	}
+	for _, tool := range tools {
+		tool.Headers = tool.Headers.Masked()
+	}
+	return tools, nil
+}
+
+func (u *AgentUsecase) Create(ctx context.Context, req *v1.AgentToolCreateReq) (*domain.AgentTool, error) {
+	if err := u.validateName(ctx, req.KBId, "", req.Name); err != nil {
+		return nil, err
+	}
+	parameters, err := normalizeAgentToolParameters(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tool := &domain.AgentTool{
+		ID:             uuid.New().String(),
+		KBID:           req.KBId,
+		Name:           req.Name,
+		Description:    req.Description,
+		Parameters:     parameters,
+		Method:         lo.CoalesceOrEmpty(req.Method, http.MethodPost),
+		URL:            req.URL,
+		Headers:        lo.CoalesceMapOrEmpty(req.Headers),
+		TimeoutSeconds: req.TimeoutSeconds,
+		Enabled:        req.Enabled == nil || *req.Enabled,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := u.repo.Create(ctx, tool); err != nil {
+		return nil, err
+	}
+	tool.Headers = tool.Headers.Masked()
+	return tool, nil
+}
+
+func (u *AgentUsecase) Update(ctx context.Context, req *v1.AgentToolUpdateReq) error {
+	tool, err := u.repo.Get(ctx, req.KBId, req.ID)
+	if err != nil {
+		return err
+	}
+	updateMap := map[string]any{}
+	if req.Name != nil {
+		if err := u.validateName(ctx, req.KBId, req.ID, *req.Name); err != nil {
+			return err
+		}
+		updateMap["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updateMap["description"] = *req.Description
+	}
+	if req.Parameters != nil {
+		parameters, err := normalizeAgentToolParameters(req.Parameters)
+		if err != nil {
+			return err
+		}
+		updateMap["parameters"] = parameters
+	}
+	if req.Method != nil {
+		updateMap["method"] = *req.Method
+	}
+	if req.URL != nil {
+		// 已保存的请求头不会发往新的地址
+		if *req.URL != tool.URL && req.Headers == nil && len(tool.Headers) > 0 {
+			return domain.ErrAgentToolHeaderRequired
+		}
+		updateMap["url"] = *req.URL
+	}
+	if req.Headers != nil {
+		headers := domain.AgentToolHeaders(req.Headers)
+		if err := headers.KeepMasked(tool, lo.FromPtrOr(req.URL, tool.URL)); err != nil {
+			return err
+		}
+		updateMap["headers"] = headers
+	}
+	if req.TimeoutSeconds != nil {
+		updateMap["timeout_seconds"] = *req.TimeoutSeconds
+	}
+	if req.Enabled != nil {
+		updateMap["enabled"] = *req.Enabled
+	}
+	return u.repo.Update(ctx, req.KBId, req.ID, updateMap)
+}
+
+func (u *AgentUsecase) Delete(ctx context.Context, req *v1.AgentToolDeleteReq) error {
+	return u.repo.Delete(ctx, req.KBId, req.ID)
+}
+
+func (u *AgentUsecase) validateName(ctx context.Context, kbID, id, name string) error {
+	if !domain.AgentToolNameRegexp.MatchString(name) || slices.Contains(domain.AgentBuiltinTools, name) {
+		return domain.ErrAgentToolNameInvalid
+	}
+	tools, err := u.repo.List(ctx, kbID)
+	if err != nil {
+		return err
+	}
+	if lo.SomeBy(tools, func(tool *domain.AgentTool) bool { return tool.Name == name && tool.ID != id }) {
+		return domain.ErrAgentToolNameExists
+	}
+	return nil
+}
+
+// normalizeAgentToolParameters checks the parameters is a json schema of an object, empty means no parameters
+func normalizeAgentToolParameters(parameters json.RawMessage) (json.RawMessage, error) {
+	if len(parameters) == 0 || string(parameters) == "null" {
+		return agentEmptyParameters, nil
+	}
+	if _, err := parseAgentToolParameters(parameters); err != nil {
+		return nil, err
+	}
+	return parameters, nil
+}
+
+func parseAgentToolParameters(parameters json.RawMessage) (*jsonschema.Schema, error) {
+	var s jsonschema.Schema
+	if err := json.Unmarshal(parameters, &s); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrAgentToolParametersInvalid, err)
+	}
+	if s.Type != "object" {
+		return nil, fmt.Errorf("%w: type must be object", domain.ErrAgentToolParametersInvalid)
+	}
+	return &s, nil
+}
+
+type agentToolHandler func(ctx context.Context, arguments string) (string, error)
+
+// AgentToolset is the tools the model may call while answering one question,
+// it records every call and reports it with onEvent
+type AgentToolset struct {
+	tools    []*schema.ToolInfo
+	handlers map[string]agentToolHandler
+	maxSteps int
+	onEvent  func(eventType string, trace *domain.AgentToolTrace)
+	traces   domain.AgentToolTraces
+
+	// 调用方的工具不在服务端执行，模型调用后结束回答并返回调用
+	external   bool
+	toolChoice schema.ToolChoice
+	toolCalls  []schema.ToolCall
+}
+
+// NewExternalToolset wraps the tools of an API caller, the tool calls of the model are returned
+// by ToolCalls for the caller to execute instead of being executed
+func NewExternalToolset(tools []*schema.ToolInfo, toolChoice schema.ToolChoice) *AgentToolset {
+	return &AgentToolset{
+		tools:      tools,
+		maxSteps:   1,
+		external:   true,
+		toolChoice: toolChoice,
+	}
+}
+
+// Tools returns the tool definitions sent to the model
+func (t *AgentToolset) Tools() []*schema.ToolInfo {
+	if t == nil {
+		return nil
+	}
+	return t.tools
+}
+
+// Traces returns the tool calls of the model, nil when no tool has been called
+func (t *AgentToolset) Traces() domain.AgentToolTraces {
+	if t == nil {
+		return nil
+	}
+	return t.traces
+}
+
+// ToolCalls returns the calls of the external tools which the caller has to execute
+func (t *AgentToolset) ToolCalls() []schema.ToolCall {
+	if t == nil {
+		return nil
+	}
+	return t.toolCalls
+}
+
+// returnToolCalls records the calls of the external tools without executing them
+func (t *AgentToolset) returnToolCalls(step int, toolCalls []schema.ToolCall) {
+	t.toolCalls = toolCalls
+	for _, toolCall := range toolCalls {
+		t.traces = append(t.traces, &domain.AgentToolTrace{
+			Step:       step,
+			ToolCallID: toolCall.ID,
+			Name:       toolCall.Function.Name,
+			Arguments:  toolCall.Function.Arguments,
+		})
+	}
+}
+
+func (t *AgentToolset) add(info *schema.ToolInfo, handler agentToolHandler) {
+	t.tools = append(t.tools, info)
+	t.handlers[info.Name] = handler
+}
+
+// call runs the tool call and returns the result for the model, errors are returned to the model as the result
+func (t *AgentToolset) call(ctx context.Context, step int, toolCall schema.ToolCall) string {
+	trace := &domain.AgentToolTrace{
+		Step:       step,
+		ToolCallID: toolCall.ID,
+		Name:       toolCall.Function.Name,
+		Arguments:  toolCall.Function.Arguments,
+	}
+	t.emit("tool_call", trace)
+
+	start := time.Now()
+	result, err := "", fmt.Errorf("tool %s not found", toolCall.Function.Name)
+	if handler, ok := t.handlers[toolCall.Function.Name]; ok {
+		result, err = handler(ctx, toolCall.Function.Arguments)
+	}
+	trace.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		trace.Error = err.Error()
+		result = "error: " + err.Error()
+	} else {
+		result = truncateRunes(result, agentToolResultMaxRunes)
+		trace.Result = result
+	}
+	t.traces = append(t.traces, trace)
+	t.emit("tool_result", trace)
+	return result
+}
+
+func (t *AgentToolset) emit(eventType string, trace *domain.AgentToolTrace) {
+	if t.onEvent != nil {
+		// 事件异步发送，传递副本避免之后的修改
+		t.onEvent(eventType, lo.ToPtr(*trace))
+	}
+}
+
+// NewToolset returns the tools of the kb for the asker, nil when the agent is disabled
+func (u *AgentUsecase) NewToolset(
+	ctx context.Context,
+	kb *domain.KnowledgeBase,
+	authID uint,
+	groupIDs []int,
+	onEvent func(eventType string, trace *domain.AgentToolTrace),
+) (*AgentToolset, error) {
+	settings := kb.AgentSettings.WithDefaults()
+	if !settings.Enabled {
+		return nil, nil
+	}
+	toolset := &AgentToolset{
+		handlers: make(map[string]agentToolHandler),
+		maxSteps: settings.MaxSteps,
+		onEvent:  onEvent,
+	}
+	enabled := func(name string) bool { return !slices.Contains(settings.DisabledTools, name) }
+
+	if enabled(domain.MCPToolSearchDefaultName) {
+		toolset.add(&schema.ToolInfo{
+			Name: domain.MCPToolSearchDefaultName,
+			Desc: "使用新的关键词重新搜索知识库，返回相关文档的 ID、标题、路径和相关片段",
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"query": {Type: schema.String, Desc: "要搜索的问题或关键词", Required: true},
+			}),
+		}, func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Query string `json:"query"`
+			}
+			if err := unmarshalToolArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			return u.searchDocs(ctx, kb, groupIDs, args.Query)
+		})
+	}
+	if enabled(domain.MCPToolGetNodeName) {
+		toolset.add(&schema.ToolInfo{
+			Name: domain.MCPToolGetNodeName,
+			Desc: domain.MCPToolGetNodeDesc,
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"id": {Type: schema.String, Desc: "文档 ID", Required: true},
+			}),
+		}, func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				ID string `json:"id"`
+			}
+			if err := unmarshalToolArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			return u.getDoc(ctx, kb, authID, args.ID)
+		})
+	}
+	if enabled(domain.MCPToolListNodesName) {
+		toolset.add(&schema.ToolInfo{
+			Name: domain.MCPToolListNodesName,
+			Desc: domain.MCPToolListNodesDesc,
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"parent_id": {Type: schema.String, Desc: "父节点 ID，为空时返回整个目录树"},
+			}),
+		}, func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				ParentID string `json:"parent_id"`
+			}
+			if err := unmarshalToolArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			nodes, err := u.nodeUsecase.GetNodeReleaseListByParentID(ctx, kb.ID, args.ParentID, authID)
+			if err != nil {
+				return "", fmt.Errorf("list docs failed: %w", err)
+			}
+			return marshalToolResult(nodes)
+		})
+	}
+
+	tools, err := u.repo.ListEnabled(ctx, kb.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, tool := range tools {
+		if _, ok := toolset.handlers[tool.Name]; ok || slices.Contains(domain.AgentBuiltinTools, tool.Name) {
+			continue
+		}
+		parameters, err := parseAgentToolParameters(tool.Parameters)
+		if err != nil {
+			u.logger.Warn("skip agent tool with invalid parameters", log.String("kb_id", kb.ID), log.String("tool", tool.Name), log.Error(err))
+			continue
+		}
+		toolset.add(&schema.ToolInfo{
+			Name:        tool.Name,
+			Desc:        tool.Description,
+			ParamsOneOf: schema.NewParamsOneOfByJSONSchema(parameters),
+		}, func(ctx context.Context, arguments string) (string, error) {
+			return callAgentHTTPTool(ctx, u.httpClient, tool, arguments)
+		})
+	}
+	return toolset, nil
+}
+
+type agentSearchResult struct {
+	NodeID        string   `json:"node_id"`
+	Name          string   `json:"name"`
+	NodePathNames []string `json:"node_path_names"`
+	Content       string   `json:"content"`
+}
+
+func (u *AgentUsecase) searchDocs(ctx context.Context, kb *domain.KnowledgeBase, groupIDs []int, query string) (string, error) {
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	// 检索结果已按用户组的可问答权限过滤
+	_, rankedNodes, err := u.llmUsecase.GetRankNodes(ctx, NewGetRankNodesRequest(kb, query, groupIDs))
+	if err != nil {
+		return "", fmt.Errorf("search docs failed: %w", err)
+	}
+	results := make([]agentSearchResult, 0, len(rankedNodes))
+	for _, node := range rankedNodes {
+		results = append(results, agentSearchResult{
+			NodeID:        node.NodeID,
+			Name:          node.NodeName,
+			NodePathNames: node.NodePathNames,
+			Content: strings.Join(lo.Map(node.Chunks, func(chunk *domain.NodeContentChunk, _ int) string {
+				return chunk.Content
+			}), "\n"),
+		})
+	}
+	return marshalToolResult(results)
+}
+
+func (u *AgentUsecase) getDoc(ctx context.Context, kb *domain.KnowledgeBase, authID uint, nodeID string) (string, error) {
+	if nodeID == "" {
+		return "", fmt.Errorf("id is required")
+	}
+	if errCode := u.nodeUsecase.ValidateNodePerm(ctx, kb.ID, nodeID, authID); errCode != nil {
+		return "", fmt.Errorf("%s", errCode.Message)
+	}
+	node, err := u.nodeUsecase.GetNodeReleaseDetailByKBIDAndID(ctx, kb.ID, nodeID, "raw")
+	if err != nil {
+		return "", fmt.Errorf("doc not found")
+	}
+	ok, err := u.nodeUsecase.IsNodeAnswerable(ctx, node.ID, node.Permissions, authID)
+	if err != nil {
+		return "", fmt.Errorf("get doc failed: %w", err)
+	}
+	if !ok {
+		return "", fmt.Errorf("%s", domain.ErrCodePermissionDenied.Message)
+	}
+	detail := domain.MCPNodeDetail{
+		ID:        node.ID,
+		Name:      node.Name,
+		Type:      node.Type,
+		ParentID:  node.ParentID,
+		Summary:   node.Meta.Summary,
+		Content:   node.Content,
+		UpdatedAt: node.UpdatedAt,
+	}
+	if kb.AccessSettings.BaseURL != "" {
+		detail.URL = fmt.Sprintf("%s/node/%s", kb.AccessSettings.BaseURL, node.ID)
+	}
+	return marshalToolResult(detail)
+}
+
+// callAgentHTTPTool sends the arguments as query params for GET and as the json body for POST,
+// a non 2xx response is an error of the call
+func callAgentHTTPTool(ctx context.Context, client *http.Client, tool *domain.AgentTool, arguments string) (string, error) {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	args := map[string]any{}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tool.Timeout())
+	defer cancel()
+
+	var body io.Reader
+	reqURL := tool.URL
+	if tool.Method == http.MethodGet {
+		parsed, err := url.Parse(tool.URL)
+		if err != nil {
+			return "", fmt.Errorf("invalid url: %w", err)
+		}
+		query := parsed.Query()
+		for key, value := range args {
+			if s, ok := value.(string); ok {
+				query.Set(key, s)
+				continue
+			}
+			b, _ := json.Marshal(value)
+			query.Set(key, string(b))
+		}
+		parsed.RawQuery = query.Encode()
+		reqURL = parsed.String()
+	} else {
+		body = strings.NewReader(arguments)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, tool.Method, reqURL, body)
+	if err != nil {
+		return "", fmt.Errorf("create request failed: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range tool.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, agentToolResponseMaxBytes))
+	if err != nil {
+		return "", fmt.Errorf("read response failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("status %d: %s", resp.StatusCode, truncateRunes(string(data), 500))
+	}
+	return string(data), nil
+}
+
+func unmarshalToolArguments(arguments string, v any) error {
+	if strings.TrimSpace(arguments) == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(arguments), v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+func marshalToolResult(data any) (string, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit]) + "..."
+}
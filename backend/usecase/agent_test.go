@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+// fakeToolModel calls the lookup tool until it has been called calls times, then answers
+type fakeToolModel struct {
+	calls int
+	tools []*schema.ToolInfo
+}
+
+func (m *fakeToolModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return nil, nil
+}
+
+func (m *fakeToolModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	toolResults := 0
+	for _, msg := range input {
+		if msg.Role == schema.Tool {
+			toolResults++
+		}
+	}
+	if m.tools != nil && toolResults < m.calls {
+		index := 0
+		return schema.StreamReaderFromArray([]*schema.Message{
+			{Role: schema.Assistant, ToolCalls: []schema.ToolCall{{Index: &index, ID: "call", Function: schema.FunctionCall{Name: "lookup", Arguments: `{"q":`}}}},
+			{Role: schema.Assistant, ToolCalls: []schema.ToolCall{{Index: &index, Function: schema.FunctionCall{Arguments: `"sla"}`}}}},
+		}), nil
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{
+		{Role: schema.Assistant, Content: "answer", ResponseMeta: &schema.ResponseMeta{Usage: &schema.TokenUsage{TotalTokens: 1}}},
+	}), nil
+}
+
+func (m *fakeToolModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return &fakeToolModel{calls: m.calls, tools: tools}, nil
+}
+
+func TestChatWithTools(t *testing.T) {
+	newToolset := func(maxSteps int) *AgentToolset {
+		toolset := &AgentToolset{handlers: map[string]agentToolHandler{}, maxSteps: maxSteps}
+		toolset.add(&schema.ToolInfo{Name: "lookup"}, func(ctx context.Context, arguments string) (string, error) {
+			return "result of " + arguments, nil
+		})
+		return toolset
+	}
+	u := &LLMUsecase{}
+	answer := ""
+	onChunk := func(ctx context.Context, dataType, chunk string) error {
+		answer += chunk
+		return nil
+	}
+
+	toolset := newToolset(5)
+	usage := schema.TokenUsage{}
+	require.NoError(t, u.ChatWithTools(context.Background(), &fakeToolModel{calls: 2}, nil, toolset, &usage, onChunk))
+	assert.Equal(t, "answer", answer)
+	assert.Equal(t, 1, usage.TotalTokens)
+	require.Len(t, toolset.Traces(), 2)
+	assert.Equal(t, 2, toolset.Traces()[1].Step)
+	assert.Equal(t, `{"q":"sla"}`, toolset.Traces()[0].Arguments)
+	assert.Equal(t, `result of {"q":"sla"}`, toolset.Traces()[0].Result)
+
+	// 达到最大轮数后不带工具回答
+	answer = ""
+	toolset = newToolset(1)
+	require.NoError(t, u.ChatWithTools(context.Background(), &fakeToolModel{calls: 3}, nil, toolset, &usage, onChunk))
+	assert.Equal(t, "answer", answer)
+	assert.Len(t, toolset.Traces(), 1)
+
+	// 调用方的工具不执行，返回工具调用
+	answer = ""
+	toolset = NewExternalToolset([]*schema.ToolInfo{{Name: "lookup"}}, "")
+	require.NoError(t, u.ChatWithTools(context.Background(), &fakeToolModel{calls: 1}, nil, toolset, &usage, onChunk))
+	assert.Empty(t, answer)
+	require.Len(t, toolset.ToolCalls(), 1)
+	assert.Equal(t, `{"q":"sla"}`, toolset.ToolCalls()[0].Function.Arguments)
+	assert.Empty(t, toolset.Traces()[0].Result)
+}
+
+func TestCallAgentHTTPTool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(r.URL.RawQuery))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	tool := &domain.AgentTool{Method: http.MethodGet, URL: server.URL + "?lang=zh", Headers: domain.AgentToolHeaders{"X-Token": "secret"}}
+	result, err := callAgentHTTPTool(context.Background(), server.Client(), tool, `{"id":42,"name":"a b"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "id=42&lang=zh&name=a+b", result)
+
+	tool.Method = http.MethodPost
+	result, err = callAgentHTTPTool(context.Background(), server.Client(), tool, `{"id":42}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":42}`, result)
+
+	tool.Headers = nil
+	_, err = callAgentHTTPTool(context.Background(), server.Client(), tool, `{}`)
+	assert.ErrorContains(t, err, "status 401")
+
+	_, err = callAgentHTTPTool(context.Background(), server.Client(), tool, `not json`)
+	assert.ErrorContains(t, err, "invalid arguments")
+}
+
+func TestNormalizeAgentToolParameters(t *testing.T) {
+	parameters, err := normalizeAgentToolParameters(nil)
+	require.NoError(t, err)
+	assert.Equal(t, agentEmptyParameters, parameters)
+
+	_, err = normalizeAgentToolParameters([]byte(`{"type":"object","properties":{"city":{"type":"string"}}}`))
+	assert.NoError(t, err)
+	_, err = normalizeAgentToolParameters([]byte(`{"type":"string"}`))
+	assert.ErrorIs(t, err, domain.ErrAgentToolParametersInvalid)
+	_, err = normalizeAgentToolParameters([]byte(`[1]`))
+	assert.ErrorIs(t, err, domain.ErrAgentToolParametersInvalid)
+}
@@ -0,0 +1,195 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+type AnswerCacheUsecase struct {
+	repo         *pg.AnswerCacheRepository
+	kbRepo       *pg.KnowledgeBaseRepository
+	modelUsecase *ModelUsecase
+	logger       *log.Logger
+}
+
+func NewAnswerCacheUsecase(repo *pg.AnswerCacheRepository, kbRepo *pg.KnowledgeBaseRepository, modelUsecase *ModelUsecase, logger *log.Logger) *AnswerCacheUsecase {
+	return &AnswerCacheUsecase{
+		repo:         repo,
+		kbRepo:       kbRepo,
+		modelUsecase: modelUsecase,
+		logger:       logger.WithModule("usecase.answer_cache"),
+	}
+}
+
+// questionEmbedding embeds the question on first use, the faq match and the answer cache lookup of a chat share it
+// so the question is embedded at most once
+type questionEmbedding struct {
+	modelUsecase *ModelUsecase
+	text         string
+	embedding    []float32
+	err          error
+	done         bool
+}
+
+func newQuestionEmbedding(modelUsecase *ModelUsecase, text string) *questionEmbedding {
+	return &questionEmbedding{modelUsecase: modelUsecase, text: text}
+}
+
+func (q *questionEmbedding) Get(ctx context.Context) ([]float32, error) {
+	if !q.done {
+		q.done = true
+		embeddings, err := q.modelUsecase.Embed(ctx, []string{q.text})
+		if err == nil && len(embeddings) == 0 {
+			err = errors.New("empty embedding")
+		}
+		if err != nil {
+			q.err = err
+		} else {
+			q.embedding = embeddings[0]
+		}
+	}
+	return q.embedding, q.err
+}
+
+// answerCacheKey is resolved by Lookup and reused by Store, so a missed question is embedded only once
+type answerCacheKey struct {
+	kbID         string
+	releaseID    string
+	appID        string
+	groupKey     string
+	question     string
+	questionHash string
+	embedding    []float32
+	settings     domain.AnswerCacheSettings
+	since        time.Time
+}
+
+// Lookup returns the cached answer of the question asked in the app, or nil with the key to store the answer under.
+// The key is nil when the cache is disabled or the kb has not been released.
+func (u *AnswerCacheUsecase) Lookup(ctx context.Context, kb *domain.KnowledgeBase, appID string, groupIDs []int, embedding *questionEmbedding) (*domain.AnswerCache, *answerCacheKey, error) {
+	question := embedding.text
+	if !kb.AnswerCacheSettings.Enabled || domain.NormalizeQuestion(question) == "" {
+		return nil, nil, nil
+	}
+	release, err := u.kbRepo.GetLatestRelease(ctx, kb.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	key := &answerCacheKey{
+		kbID:         kb.ID,
+		releaseID:    release.ID,
+		appID:        appID,
+		groupKey:     domain.AnswerCacheGroupKey(groupIDs),
+		question:     question,
+		questionHash: domain.AnswerCacheQuestionHash(question),
+		settings:     kb.AnswerCacheSettings.WithDefaults(),
+	}
+	if key.settings.TTLHours > 0 {
+		key.since = time.Now().Add(-time.Duration(key.settings.TTLHours) * time.Hour)
+	}
+
+	cache, err := u.repo.GetByQuestionHash(ctx, key.kbID, key.releaseID, key.appID, key.groupKey, key.questionHash, key.since)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cache == nil {
+		cache, err = u.lookupSimilar(ctx, key, embedding)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if cache == nil {
+		return nil, key, nil
+	}
+	if err := u.repo.RecordHit(ctx, cache.ID); err != nil {
+		u.logger.Warn("record answer cache hit failed", log.String("id", cache.ID), log.Error(err))
+	}
+	return cache, key, nil
+}
+
+func (u *AnswerCacheUsecase) lookupSimilar(ctx context.Context, key *answerCacheKey, embedding *questionEmbedding) (*domain.AnswerCache, error) {
+	vector, err := embedding.Get(ctx)
+	if err != nil {
+		// 向量化失败时仅按问题原文匹配
+		u.logger.Warn("embed question for answer cache failed", log.String("kb_id", key.kbID), log.Error(err))
+		return nil, nil
+	}
+	key.embedding = vector
+	candidates, err := u.repo.GetEmbeddings(ctx, key.kbID, key.releaseID, key.appID, key.groupKey, key.since)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := mostSimilarCache(key.embedding, candidates, key.settings.SimilarityThreshold)
+	if !ok {
+		return nil, nil
+	}
+	return u.repo.GetByID(ctx, id)
+}
+
+// mostSimilarCache returns the entry whose embedding is the most similar to the question and reaches threshold
+func mostSimilarCache(embedding []float32, candidates []*domain.AnswerCache, threshold float64) (string, bool) {
+	vector := normalizeVector(embedding)
+	bestID, bestScore := "", threshold
+	for _, candidate := range candidates {
+		if score := cosineSimilarity(vector, normalizeVector(candidate.Embedding)); score >= bestScore {
+			bestID, bestScore = candidate.ID, score
+		}
+	}
+	return bestID, bestID != ""
+}
+
+// Store caches the answer under the key, answers the model could not give from the documents are not cached
+func (u *AnswerCacheUsecase) Store(ctx context.Context, key *answerCacheKey, cache *domain.AnswerCache) error {
+	if strings.TrimSpace(cache.Answer) == "" || len(cache.ChunkResults) == 0 {
+		return nil
+	}
+	if domain.IsUnansweredAnswer(cache.Answer) {
+		return nil
+	}
+	count, err := u.repo.CountByRelease(ctx, key.kbID, key.releaseID)
+	if err != nil {
+		return err
+	}
+	if count >= int64(key.settings.MaxEntries) {
+		return nil
+	}
+	cache.ID = uuid.New().String()
+	cache.KBID = key.kbID
+	cache.ReleaseID = key.releaseID
+	cache.AppID = key.appID
+	cache.GroupKey = key.groupKey
+	cache.Question = key.question
+	cache.QuestionHash = key.questionHash
+	cache.Embedding = key.embedding
+	cache.CreatedAt = time.Now()
+	return u.repo.Create(ctx, cache)
+}
+
+func (u *AnswerCacheUsecase) List(ctx context.Context, req *v1.AnswerCacheListReq) (*v1.AnswerCacheListResp, error) {
+	total, caches, err := u.repo.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPaginatedResult(caches, uint64(total)), nil
+}
+
+func (u *AnswerCacheUsecase) Delete(ctx context.Context, req *v1.AnswerCacheDeleteReq) (*v1.AnswerCacheDeleteResp, error) {
+	deleted, err := u.repo.Delete(ctx, req.KBId, req.IDs)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.AnswerCacheDeleteResp{Deleted: deleted}, nil
+}
@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+func TestMostSimilarCache(t *testing.T) {
+	candidates := []*domain.AnswerCache{
+		{ID: "a", Embedding: []float32{0, 1, 0}},
+		{ID: "b", Embedding: []float32{0.9, 0.1, 0}},
+		{ID: "c", Embedding: []float32{1, 0.01, 0}},
+	}
+	id, ok := mostSimilarCache([]float32{1, 0, 0}, candidates, 0.95)
+	assert.True(t, ok)
+	assert.Equal(t, "c", id)
+
+	_, ok = mostSimilarCache([]float32{0, 0, 1}, candidates, 0.95)
+	assert.False(t, ok)
+}
+
+func TestAnswerCacheKey(t *testing.T) {
+	assert.Equal(t,
+		domain.AnswerCacheQuestionHash("如何 部署 PandaWiki？"),
+		domain.AnswerCacheQuestionHash("如何部署pandawiki"))
+	assert.NotEqual(t,
+		domain.AnswerCacheQuestionHash("如何部署"),
+		domain.AnswerCacheQuestionHash("如何升级"))
+	assert.Equal(t, "1,2,5", domain.AnswerCacheGroupKey([]int{5, 1, 2, 1}))
+	assert.Equal(t, "", domain.AnswerCacheGroupKey(nil))
+}
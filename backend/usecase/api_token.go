@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/samber/lo"
+
+	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+const (
+	apiTokenPrefix       = "pw_"
+	apiTokenBytes        = 24
+	apiTokenPrefixLength = 10 // pw_ 加 7 位
+)
+
+type APITokenUsecase struct {
+	repo   *pg.APITokenRepo
+	logger *log.Logger
+}
+
+func NewAPITokenUsecase(repo *pg.APITokenRepo, logger *log.Logger) *APITokenUsecase {
+	return &APITokenUsecase{
+		repo:   repo,
+		logger: logger.WithModule("usecase.api_token"),
+	}
+}
+
+func (u *APITokenUsecase) List(ctx context.Context, req *v1.APITokenListReq) (v1.APITokenListResp, error) {
+	return u.repo.List(ctx, req.KBId)
+}
+
+// Create issues a token owned by userID, the token is only returned here
+func (u *APITokenUsecase) Create(ctx context.Context, userID string, req *v1.APITokenCreateReq) (*v1.APITokenCreateResp, error) {
+	if err := validateAPITokenExpiresAt(req.ExpiresAt); err != nil {
+		return nil, err
+	}
+	token, err := generateAPIToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	apiToken := &domain.APIToken{
+		ID:          uuid.New().String(),
+		Name:        req.Name,
+		UserID:      userID,
+		Token:       domain.HashAPIToken(token),
+		TokenPrefix: token[:apiTokenPrefixLength],
+		KbId:        req.KBId,
+		Permission:  req.Permission,
+		Scopes:      apiTokenScopes(req.Scopes),
+		ExpiresAt:   req.ExpiresAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := u.repo.Create(ctx, apiToken); err != nil {
+		return nil, err
+	}
+	return &v1.APITokenCreateResp{APIToken: apiToken, Token: token}, nil
+}
+
+// Update changes the name, scopes and expiry, the permission is fixed at creation.
+// The legacy scope can be kept or dropped but is never granted to a token without it.
+func (u *APITokenUsecase) Update(ctx context.Context, req *v1.APITokenUpdateReq) error {
+	if err := validateAPITokenExpiresAt(req.ExpiresAt); err != nil {
+		return err
+	}
+	apiToken, err := u.repo.GetByID(ctx, req.KBId, req.TokenId)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(req.Scopes, consts.APITokenScopeLegacy) && !slices.Contains(apiToken.Scopes, string(consts.APITokenScopeLegacy)) {
+		return domain.ErrAPITokenScopeLegacy
+	}
+	apiToken.Name = req.Name
+	apiToken.Scopes = apiTokenScopes(req.Scopes)
+	apiToken.ExpiresAt = req.ExpiresAt
+	apiToken.UpdatedAt = time.Now()
+	return u.repo.Update(ctx, apiToken, apiToken.Token)
+}
+
+// Rotate replaces the token, the old one stops working at once
+func (u *APITokenUsecase) Rotate(ctx context.Context, req *v1.APITokenRotateReq) (*v1.APITokenCreateResp, error) {
+	apiToken, err := u.repo.GetByID(ctx, req.KBId, req.TokenId)
+	if err != nil {
+		return nil, err
+	}
+	token, err := generateAPIToken()
+	if err != nil {
+		return nil, err
+	}
+	oldTokenHash := apiToken.Token
+	apiToken.Token = domain.HashAPIToken(token)
+	apiToken.TokenPrefix = token[:apiTokenPrefixLength]
+	apiToken.UpdatedAt = time.Now()
+	if err := u.repo.Update(ctx, apiToken, oldTokenHash); err != nil {
+		return nil, err
+	}
+	return &v1.APITokenCreateResp{APIToken: apiToken, Token: token}, nil
+}
+
+func (u *APITokenUsecase) Delete(ctx context.Context, req *v1.APITokenDeleteReq) error {
+	return u.repo.Delete(ctx, req.KBId, req.TokenId)
+}
+
+func generateAPIToken() (string, error) {
+	b := make([]byte, apiTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate api token failed: %w", err)
+	}
+	return apiTokenPrefix + hex.EncodeToString(b), nil
+}
+
+func validateAPITokenExpiresAt(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return domain.ErrAPITokenExpiresAtInvalid
+	}
+	return nil
+}
+
+func apiTokenScopes(scopes []consts.APITokenScope) pq.StringArray {
+	return pq.StringArray(lo.Uniq(lo.Map(scopes, func(scope consts.APITokenScope, _ int) string {
+		return string(scope)
+	})))
+}
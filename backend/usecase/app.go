@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"slices"
 	"sync"
@@ -31,6 +32,7 @@ type AppUsecase struct {
 	logger        *log.Logger
 	config        *config.Config
 	cache         *cache.Cache
+	auditRepo     *pg.AuditLogRepository
 	dingTalkBots  map[string]*dingtalk.DingTalkClient
 	dingTalkMutex sync.RWMutex
 	feishuBots    map[string]*feishu.FeishuClient
@@ -51,6 +53,7 @@ func NewAppUsecase(
 	config *config.Config,
 	chatUsecase *ChatUsecase,
 	cache *cache.Cache,
+	auditRepo *pg.AuditLogRepository,
 ) *AppUsecase {
 	u := &AppUsecase{
 		repo:         repo,
@@ -62,6 +65,7 @@ func NewAppUsecase(
 		logger:       logger.WithModule("usecase.app"),
 		config:       config,
 		cache:        cache,
+		auditRepo:    auditRepo,
 		dingTalkBots: make(map[string]*dingtalk.DingTalkClient),
 		feishuBots:   make(map[string]*feishu.FeishuClient),
 		larkBots:     make(map[string]*lark.LarkClient),
@@ -159,6 +163,16 @@ func (u *AppUsecase) ValidateUpdateApp(ctx context.Context, id string, req *doma
 }
 
 func (u *AppUsecase) UpdateApp(ctx context.Context, id string, appRequest *domain.UpdateAppReq) error {
+	before, err := u.repo.GetAppDetail(ctx, id)
+	if err != nil {
+		return err
+	}
+	if appRequest.Settings != nil {
+		if err := appRequest.Settings.ChatModel.KeepAPIKey(before.Settings.ChatModel); err != nil {
+			return err
+		}
+	}
+
 	if err := u.handleBotAuths(ctx, id, appRequest.Settings); err != nil {
 		return err
 	}
@@ -167,11 +181,13 @@ func (u *AppUsecase) UpdateApp(ctx context.Context, id string, appRequest *domai
 		return err
 	}
 
+	app, err := u.repo.GetAppDetail(ctx, id)
+	if err != nil {
+		return err
+	}
+	recordAuditLog(ctx, u.logger, u.auditRepo, app.KBID, domain.AuditActionAppUpdate, domain.AuditTargetApp, id, before, app)
+
 	if appRequest.Settings != nil {
-		app, err := u.repo.GetAppDetail(ctx, id)
-		if err != nil {
-			return err
-		}
 		switch app.Type {
 		case domain.AppTypeDingTalkBot:
 			u.updateDingTalkBot(app)
@@ -213,9 +229,12 @@ func (u *AppUsecase) getQAFunc(kbID string, appType domain.AppType) bot.GetQAFun
 		}
 
 		var feedback = "\n\n---  \n\n本回答由 PandaWiki 基于 AI 生成，仅供参考。\n[👍 满意](%s) | [👎 不满意](%s)"
+		var faqFeedback = "\n\n---  \n\n[👍 满意](%s) | [👎 不满意](%s)"
 		var likeUrl = "%s/feedback?score=1&message_id=%s"
 		var dislikeUrl = "%s/feedback?score=-1&message_id=%s"
 		var messageId string
+		var isFAQ bool // 常见问题的回答经过人工审核，不提示由 AI 生成
+		var citations []*domain.ConversationReference
 		var kb *domain.KnowledgeBase
 
 		if appinfo.Settings.AIFeedbackSettings.AIFeedbackIsEnabled == nil || *appinfo.Settings.AIFeedbackSettings.AIFeedbackIsEnabled { // open
@@ -229,8 +248,9 @@ func (u *AppUsecase) getQAFunc(kbID string, appType domain.AppType) bot.GetQAFun
 		contentCh := make(chan string, 10)
 		go func() {
 			defer close(contentCh)
+			// 推荐追问在 done 之后返回，读取到通道关闭为止
 			for event := range eventCh {
-				if event.Type == "done" || event.Type == "error" {
+				if event.Type == "error" {
 					break
 				}
 				if event.Type == "data" {
@@ -239,6 +259,20 @@ func (u *AppUsecase) getQAFunc(kbID string, appType domain.AppType) bot.GetQAFun
 				if event.Type == "message_id" {
 					messageId = event.Content
 				}
+				if event.Type == "citations" {
+					citations = event.Citations
+				}
+				if event.Type == "faq" {
+					isFAQ = true
+				}
+				if event.Type == "suggestions" {
+					if result := bot.QAResultFromCtx(ctx); result != nil {
+						result.Suggestions = event.Suggestions
+					}
+				}
+			}
+			if footnotes := domain.FormatCitationFootnotes(citations); footnotes != "" {
+				contentCh <- footnotes
 			}
 			// check again
 			// contact --> send
@@ -246,6 +280,9 @@ func (u *AppUsecase) getQAFunc(kbID string, appType domain.AppType) bot.GetQAFun
 				like := fmt.Sprintf(likeUrl, kb.AccessSettings.BaseURL, messageId)
 				dislike := fmt.Sprintf(dislikeUrl, kb.AccessSettings.BaseURL, messageId)
 				feedback_data := fmt.Sprintf(feedback, like, dislike)
+				if isFAQ {
+					feedback_data = fmt.Sprintf(faqFeedback, like, dislike)
+				}
 				contentCh <- feedback_data
 			}
 		}()
@@ -552,6 +589,8 @@ func (u *AppUsecase) GetAppDetailByKBIDAndAppType(ctx context.Context, kbID stri
 
 		MCPServerSettings: app.Settings.MCPServerSettings,
 		StatsSetting:      app.Settings.StatsSetting,
+
+		ChatModel: app.Settings.ChatModel.Masked(),
 	}
 
 	if !domain.GetBaseEditionLimitation(ctx).AllowCustomCopyright {
@@ -852,6 +891,14 @@ func (u *AppUsecase) handleBotAuths(ctx context.Context, id string, newSettings
 		}
 	}
 
+	// Handle MCP Server
+	if currentApp.Settings.MCPServerSettings.IsEnabled != newSettings.MCPServerSettings.IsEnabled {
+		if err := u.handleBotAuth(ctx, currentApp.KBID, currentApp.ID, &currentApp.Settings.MCPServerSettings.IsEnabled,
+			&newSettings.MCPServerSettings.IsEnabled, consts.SourceTypeMcpServer); err != nil {
+			u.logger.Error("failed to handle mcp server auth", log.Error(err))
+		}
+	}
+
 	return nil
 }
 
@@ -903,6 +950,46 @@ func (u *AppUsecase) GetOpenAIAPIAppInfo(ctx context.Context, kbID string) (*dom
 	return appInfo, nil
 }
 
+// GetOpenAIAPIKnowledgeBases returns the kbs whose openai api app is enabled with the secret key,
+// only kbID is checked when it is not empty
+func (u *AppUsecase) GetOpenAIAPIKnowledgeBases(ctx context.Context, kbID, secretKey string) ([]*domain.KnowledgeBase, error) {
+	if secretKey == "" {
+		return nil, domain.ErrOpenAIAPIKeyInvalid
+	}
+	kbIDs := make([]string, 0, 1)
+	if kbID != "" {
+		apiApp, err := u.repo.GetOrCreateAppByKBIDAndType(ctx, kbID, domain.AppTypeOpenAIAPI)
+		if err != nil {
+			return nil, err
+		}
+		settings := apiApp.Settings.OpenAIAPIBotSettings
+		if !settings.IsEnabled || subtle.ConstantTimeCompare([]byte(settings.SecretKey), []byte(secretKey)) != 1 {
+			return nil, domain.ErrOpenAIAPIKeyInvalid
+		}
+		kbIDs = append(kbIDs, kbID)
+	} else {
+		apps, err := u.repo.GetEnabledOpenAIAPIAppsBySecretKey(ctx, secretKey)
+		if err != nil {
+			return nil, err
+		}
+		for _, app := range apps {
+			kbIDs = append(kbIDs, app.KBID)
+		}
+	}
+	if len(kbIDs) == 0 {
+		return nil, domain.ErrOpenAIAPIKeyInvalid
+	}
+	kbs := make([]*domain.KnowledgeBase, 0, len(kbIDs))
+	for _, id := range kbIDs {
+		kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		kbs = append(kbs, kb)
+	}
+	return kbs, nil
+}
+
 // GetRecommendNodesByIds 根据nodeIds获取nodes详情（需要authId对node验证权限)
 func (u *AppUsecase) GetRecommendNodesByIds(ctx context.Context, kbId string, nodeIds []string, authId uint) ([]*domain.RecommendNodeListResp, error) {
 	nodes, err := u.nodeUsecase.GetRecommendNodeList(ctx, &domain.GetRecommendNodeListReq{
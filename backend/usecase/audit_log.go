@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	v1 "github.com/chaitin/panda-wiki/api/audit/v1"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+const auditLogExportBatchSize = 500
+
+var auditLogCSVHeader = []string{
+	"created_at", "kb_id", "actor_type", "actor_id", "actor_name", "user_id", "remote_ip",
+	"action", "target_type", "target_id", "before", "after",
+}
+
+type AuditLogUsecase struct {
+	repo   *pg.AuditLogRepository
+	logger *log.Logger
+}
+
+func NewAuditLogUsecase(repo *pg.AuditLogRepository, logger *log.Logger) *AuditLogUsecase {
+	return &AuditLogUsecase{
+		repo:   repo,
+		logger: logger.WithModule("usecase.audit_log"),
+	}
+}
+
+func (u *AuditLogUsecase) List(ctx context.Context, req *v1.AuditLogListReq) (*v1.AuditLogListResp, error) {
+	items, total, err := u.repo.List(ctx, &req.AuditLogFilter, req.Offset(), req.Limit())
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPaginatedResult(items, uint64(total)), nil
+}
+
+// Export writes all the audit logs matching the filter to w as csv or json lines, oldest first
+func (u *AuditLogUsecase) Export(ctx context.Context, req *v1.AuditLogExportReq, w io.Writer) error {
+	switch req.Format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(auditLogCSVHeader); err != nil {
+			return err
+		}
+		if err := u.repo.Iterate(ctx, &req.AuditLogFilter, auditLogExportBatchSize, func(items []*domain.AuditLogListItem) error {
+			for _, item := range items {
+				if err := writer.Write(auditLogCSVRecord(item)); err != nil {
+					return err
+				}
+			}
+			writer.Flush()
+			return writer.Error()
+		}); err != nil {
+			return err
+		}
+		writer.Flush()
+		return writer.Error()
+	case "jsonl":
+		encoder := json.NewEncoder(w)
+		return u.repo.Iterate(ctx, &req.AuditLogFilter, auditLogExportBatchSize, func(items []*domain.AuditLogListItem) error {
+			for _, item := range items {
+				if err := encoder.Encode(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return fmt.Errorf("unsupported export format: %s", req.Format)
+}
+
+func auditLogCSVRecord(item *domain.AuditLogListItem) []string {
+	record := []string{
+		item.CreatedAt.Format(time.RFC3339),
+		item.KBID,
+		string(item.ActorType),
+		item.ActorID,
+		item.ActorName,
+		item.UserID,
+		item.RemoteIP,
+		string(item.Action),
+		string(item.TargetType),
+		item.TargetID,
+		string(item.Before),
+		string(item.After),
+	}
+	for i, value := range record {
+		// 防止表格软件把单元格当作公式执行
+		if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+			record[i] = "'" + value
+		}
+	}
+	return record
+}
+
+// recordAuditLog appends an audit log for the caller in ctx, a failure is only logged so that it never breaks the caller
+func recordAuditLog(ctx context.Context, logger *log.Logger, repo *pg.AuditLogRepository, kbID string, action domain.AuditAction, targetType domain.AuditTargetType, targetID string, before, after any) {
+	auditLog, err := domain.NewAuditLog(ctx, kbID, action, targetType, targetID, before, after)
+	if err == nil {
+		err = repo.Create(ctx, auditLog)
+	}
+	if err != nil {
+		logger.Error("record audit log failed", log.String("kb_id", kbID), log.String("action", string(action)), log.String("target_id", targetID), log.Error(err))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/url"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,18 +24,20 @@ import (
 )
 
 type AuthUsecase struct {
-	AuthRepo *pg.AuthRepo
-	logger   *log.Logger
-	kbRepo   *pg.KnowledgeBaseRepository
-	cache    *cache.Cache
+	AuthRepo  *pg.AuthRepo
+	logger    *log.Logger
+	kbRepo    *pg.KnowledgeBaseRepository
+	cache     *cache.Cache
+	auditRepo *pg.AuditLogRepository
 }
 
-func NewAuthUsecase(authRepo *pg.AuthRepo, logger *log.Logger, kbRepo *pg.KnowledgeBaseRepository, cache *cache.Cache) (*AuthUsecase, error) {
+func NewAuthUsecase(authRepo *pg.AuthRepo, logger *log.Logger, kbRepo *pg.KnowledgeBaseRepository, cache *cache.Cache, auditRepo *pg.AuditLogRepository) (*AuthUsecase, error) {
 	u := &AuthUsecase{
-		AuthRepo: authRepo,
-		kbRepo:   kbRepo,
-		logger:   logger.WithModule("usecase.auth"),
-		cache:    cache,
+		AuthRepo:  authRepo,
+		kbRepo:    kbRepo,
+		logger:    logger.WithModule("usecase.auth"),
+		cache:     cache,
+		auditRepo: auditRepo,
 	}
 	return u, nil
 }
@@ -50,11 +53,25 @@ func (u *AuthUsecase) GetAuthBySourceType(ctx context.Context, sourceType consts
 }
 
 func (u *AuthUsecase) DeleteAuth(ctx context.Context, req v1.AuthDeleteReq) error {
-	return u.AuthRepo.DeleteAuth(ctx, req.KbID, req.ID)
+	before, err := u.AuthRepo.GetAuthById(ctx, req.KbID, uint(req.ID))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err := u.AuthRepo.DeleteAuth(ctx, req.KbID, req.ID); err != nil {
+		return err
+	}
+	if before != nil {
+		recordAuditLog(ctx, u.logger, u.auditRepo, req.KbID, domain.AuditActionAuthDelete, domain.AuditTargetAuth, strconv.FormatInt(req.ID, 10), before, nil)
+	}
+	return nil
 }
 
 func (u *AuthUsecase) SetAuth(ctx context.Context, req v1.AuthSetReq) error {
-	if err := u.AuthRepo.CreateAuthConfig(ctx, &domain.AuthConfig{
+	before, err := u.AuthRepo.GetAuthConfig(ctx, req.KBID, req.SourceType)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	after := &domain.AuthConfig{
 		AuthSetting: domain.AuthSetting{
 			ClientID:     req.ClientID,
 			ClientSecret: req.ClientSecret,
@@ -62,9 +79,11 @@ func (u *AuthUsecase) SetAuth(ctx context.Context, req v1.AuthSetReq) error {
 		},
 		KbID:       req.KBID,
 		SourceType: req.SourceType,
-	}); err != nil {
+	}
+	if err := u.AuthRepo.CreateAuthConfig(ctx, after); err != nil {
 		return err
 	}
+	recordAuditLog(ctx, u.logger, u.auditRepo, req.KBID, domain.AuditActionAuthConfigUpdate, domain.AuditTargetAuthConfig, string(req.SourceType), before, after)
 	return nil
 }
 
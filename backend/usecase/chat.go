@@ -7,7 +7,6 @@ import (
 	"strings"
 	"time"
 
-	modelkit "github.com/chaitin/ModelKit/v2/usecase"
 	"github.com/cloudwego/eino/schema"
 	"github.com/google/uuid"
 	"github.com/samber/lo"
@@ -20,6 +19,9 @@ import (
 	"github.com/chaitin/panda-wiki/utils"
 )
 
+// replayChunkRunes is the runes of a data event when a cached or faq answer is streamed
+const replayChunkRunes = 32
+
 type ChatUsecase struct {
 	llmUsecase          *LLMUsecase
 	conversationUsecase *ConversationUsecase
@@ -29,13 +31,14 @@ type ChatUsecase struct {
 	kbRepo              *pg.KnowledgeBaseRepository
 	nodeRepo            *pg.NodeRepository
 	AuthRepo            *pg.AuthRepo
+	answerCacheUsecase  *AnswerCacheUsecase
+	faqUsecase          *FAQUsecase
+	agentUsecase        *AgentUsecase
 	logger              *log.Logger
-	modelkit            *modelkit.ModelKit
 }
 
 func NewChatUsecase(llmUsecase *LLMUsecase, kbRepo *pg.KnowledgeBaseRepository, conversationUsecase *ConversationUsecase, modelUsecase *ModelUsecase, appRepo *pg.AppRepository,
-	blockWordRepo *pg.BlockWordRepo, nodeRepo *pg.NodeRepository, authRepo *pg.AuthRepo, logger *log.Logger) (*ChatUsecase, error) {
-	modelkit := modelkit.NewModelKit(logger.Logger)
+	blockWordRepo *pg.BlockWordRepo, nodeRepo *pg.NodeRepository, authRepo *pg.AuthRepo, answerCacheUsecase *AnswerCacheUsecase, faqUsecase *FAQUsecase, agentUsecase *AgentUsecase, logger *log.Logger) (*ChatUsecase, error) {
 	u := &ChatUsecase{
 		llmUsecase:          llmUsecase,
 		conversationUsecase: conversationUsecase,
@@ -45,8 +48,10 @@ func NewChatUsecase(llmUsecase *LLMUsecase, kbRepo *pg.KnowledgeBaseRepository,
 		kbRepo:              kbRepo,
 		nodeRepo:            nodeRepo,
 		AuthRepo:            authRepo,
+		answerCacheUsecase:  answerCacheUsecase,
+		faqUsecase:          faqUsecase,
+		agentUsecase:        agentUsecase,
 		logger:              logger.WithModule("usecase.chat"),
-		modelkit:            modelkit,
 	}
 	if err := u.initDFA(); err != nil {
 		u.logger.Error("failed to init dfa", log.Error(err))
@@ -90,7 +95,7 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 		req.AppID = app.ID
 		req.AppType = app.Type
 		// 2. get model and validate model
-		model, err := u.modelUsecase.GetChatModel(ctx)
+		models, err := u.modelUsecase.GetKBChatModelChain(ctx, req.KBID, app)
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
 				eventCh <- domain.SSEEvent{Type: "error", Content: "请前往管理后台，点击右上角的“系统设置”配置推理大模型。"}
@@ -99,8 +104,9 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 			}
 			return
 		}
-		req.ModelInfo = model
+		req.ModelInfo = models[0]
 		// 3. conversation management
+		newConversation := true
 		if req.AppType == domain.AppTypeWechatServiceBot || req.AppType == domain.AppTypeWechatBot || req.AppType == domain.AppTypeWecomAIBot { // wechat service has its own id
 			nonce := uuid.New().String()
 			eventCh <- domain.SSEEvent{Type: "conversation_id", Content: req.ConversationID}
@@ -147,6 +153,7 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 				return
 			}
 		} else {
+			newConversation = false
 			if req.Nonce == "" {
 				eventCh <- domain.SSEEvent{Type: "error", Content: "nonce is required"}
 				return
@@ -172,7 +179,7 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 			Content:        req.Message,
 			ImagePaths:     req.ImagePaths,
 			RemoteIP:       req.RemoteIP,
-		}); err != nil {
+		}, nil); err != nil {
 			u.logger.Error("failed to save user question to conversation message", log.Error(err))
 			eventCh <- domain.SSEEvent{Type: "error", Content: "failed to save user question to conversation message"}
 			return
@@ -201,7 +208,7 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 					Model:          string(req.ModelInfo.Model),
 					RemoteIP:       req.RemoteIP,
 					ParentID:       userMessageId,
-				}); err != nil {
+				}, nil); err != nil {
 					u.logger.Error("failed to save assistant answer to conversation message", log.Error(err))
 					eventCh <- domain.SSEEvent{Type: "error", Content: "failed to save assistant answer to conversation message"}
 					return
@@ -224,7 +231,70 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 			return
 		}
 
-		messages, rankedNodes, err := u.llmUsecase.BuildConversationMessageWithRAG(ctx, req.ConversationID, req.KBID, groupIds, req.Prompt)
+		baseURL := ""
+		kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, req.KBID)
+		if err == nil {
+			baseURL = kb.AccessSettings.BaseURL
+		}
+		// 调用方维护上下文时回答依赖历史对话和工具，不使用常见问题和缓存
+		callerContext := len(req.History) > 0 || len(req.ToolMessages) > 0 || len(req.Tools) > 0
+		// 常见问题和回答缓存共用问题的向量，问题最多向量化一次
+		questionEmbedding := newQuestionEmbedding(u.modelUsecase, req.Message)
+		// 命中常见问题时原样返回审核通过的回答，不经过检索和模型，关联文档对当前用户不可回答的常见问题不参与匹配
+		if len(req.ImagePaths) == 0 && !callerContext {
+			match, err := u.faqUsecase.MatchAnswerable(ctx, req.KBID, groupIds, questionEmbedding)
+			if err != nil {
+				u.logger.Warn("match faq failed", log.String("kb_id", req.KBID), log.Error(err))
+			} else if match.Matched {
+				u.answerFAQ(ctx, req, match.FAQ, groupIds, messageId, userMessageId, eventCh)
+				return
+			}
+		}
+		// 新对话的首个问题可以直接使用缓存的回答，多轮对话的回答依赖上下文不做缓存
+		var cacheKey *answerCacheKey
+		if newConversation && len(req.ImagePaths) == 0 && !callerContext && kb != nil {
+			cache, key, err := u.answerCacheUsecase.Lookup(ctx, kb, req.AppID, groupIds, questionEmbedding)
+			if err != nil {
+				u.logger.Warn("lookup answer cache failed", log.String("kb_id", req.KBID), log.Error(err))
+			}
+			if cache != nil {
+				u.replayAnswerCache(ctx, req, cache, messageId, userMessageId, blockWords, eventCh)
+				return
+			}
+			cacheKey = key
+		}
+
+		// 开启智能体时模型可以调用工具获取更多信息，调用过程通过 tool_call 和 tool_result 事件返回
+		// 调用方提供工具时只使用调用方的工具，模型的调用通过 tool_calls 事件返回
+		var toolset *AgentToolset
+		extraPrompt := req.ExtraPrompt
+		if len(req.Tools) > 0 {
+			toolset = NewExternalToolset(req.Tools, req.ToolChoice)
+		} else if kb != nil {
+			toolset, err = u.agentUsecase.NewToolset(ctx, kb, req.Info.UserInfo.AuthUserID, groupIds, func(eventType string, trace *domain.AgentToolTrace) {
+				eventCh <- domain.SSEEvent{Type: eventType, ToolTrace: trace}
+			})
+			if err != nil {
+				u.logger.Warn("build agent toolset failed, chat without tools", log.String("kb_id", req.KBID), log.Error(err))
+			}
+			if toolset != nil {
+				extraPrompt += domain.AgentToolsPrompt
+			}
+		}
+
+		// 提示词的预算包含追加的提示词、工具定义及工具消息，并按回答链中上下文最小的模型计算
+		messages, rankedNodes, err := u.llmUsecase.BuildConversationMessageWithRAG(ctx, &BuildMessagesRequest{
+			Messages:       req.History,
+			KBID:           req.KBID,
+			GroupIDs:       groupIds,
+			SystemPrompt:   req.Prompt,
+			Model:          req.ModelInfo,
+			FallbackModels: models[1:],
+			ConversationID: req.ConversationID,
+			ExtraPrompt:    extraPrompt,
+			ExtraMessages:  req.ToolMessages,
+			Tools:          toolset.Tools(),
+		})
 		if err != nil {
 			u.logger.Error("build messages failed", log.Error(err))
 			eventCh <- domain.SSEEvent{Type: "error", Content: err.Error()}
@@ -232,6 +302,7 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 		}
 
 		u.logger.Debug("message:", log.Any("schema", messages))
+		chunkResults := make(domain.AnswerCacheChunks, 0, len(rankedNodes))
 		for _, node := range rankedNodes {
 			chunkResult := domain.NodeContentChunkSSE{
 				NodeID:        node.NodeID,
@@ -239,34 +310,46 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 				Summary:       node.NodeSummary,
 				NodePathNames: node.NodePathNames,
 			}
+			chunkResults = append(chunkResults, &chunkResult)
 			eventCh <- domain.SSEEvent{Type: "chunk_result", ChunkResult: &chunkResult}
 		}
 		// 5. LLM inference (streaming callback), message storage, token statistics
 		answer := ""
 		usage := schema.TokenUsage{}
 
-		modelkitModel, err := req.ModelInfo.ToModelkitModel()
-		if err != nil {
-			u.logger.Error("failed to convert model to modelkit model", log.Error(err))
-			eventCh <- domain.SSEEvent{Type: "error", Content: "failed to convert model to modelkit model"}
-			return
-		}
-		chatModel, err := u.modelkit.GetChatModel(ctx, modelkitModel)
-
-		if err != nil {
-			u.logger.Error("failed to get chat model", log.Error(err))
-			eventCh <- domain.SSEEvent{Type: "error", Content: "failed to get chat model"}
-			return
-		}
 		// get words
 		onChunkAC, flushBuffer := u.CreateAcOnChunk(ctx, req.KBID, &answer, eventCh, blockWords)
+		// 记录回答中引用的检索文档
+		citationTracker := newCitationTracker(len(rankedNodes))
+		onChunk := func(ctx context.Context, dataType, chunk string) error {
+			citationTracker.Write(chunk)
+			return onChunkAC(ctx, dataType, chunk)
+		}
 
-		chatErr := u.llmUsecase.ChatWithAgent(ctx, chatModel, messages, &usage, onChunkAC)
+		// 首选模型失败时依次尝试备用模型，记录实际回答的模型
+		answerModel, chatErr := u.llmUsecase.ChatWithFailover(ctx, models, messages, toolset, &usage, onChunk)
+		fallbackFrom := ""
+		if answerModel != nil && answerModel != req.ModelInfo {
+			fallbackFrom = req.ModelInfo.Model
+			req.ModelInfo = answerModel
+		}
 
 		// 处理缓冲区中剩余的内容
 		if flushBuffer != nil {
 			flushBuffer(ctx, "data")
 		}
+		// 已流式返回的内容无法撤回，保存和缓存的回答去掉不对应任何检索文档的引用标记
+		answer = stripInvalidCitations(answer, len(rankedNodes))
+
+		citations := citationTracker.Citations(req.ConversationID, req.AppID, messageId, rankedNodes, baseURL)
+		if len(citations) > 0 {
+			eventCh <- domain.SSEEvent{Type: "citations", Citations: citations}
+		}
+		// 根据检索到的文档推荐追问，模型无法回答时不推荐；与保存回答并行生成，在 done 之后单独返回
+		var suggestionsCh <-chan []string
+		if chatErr == nil && !req.SkipSuggestions && len(toolset.ToolCalls()) == 0 && len(rankedNodes) > 0 && !domain.IsUnansweredAnswer(answer) {
+			suggestionsCh = u.generateSuggestions(ctx, answerModel, req.Message, answer, rankedNodes)
+		}
 
 		// save assistant answer to conversation message
 
@@ -282,9 +365,12 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 			PromptTokens:     usage.PromptTokens,
 			CompletionTokens: usage.CompletionTokens,
 			TotalTokens:      usage.TotalTokens,
+			FallbackFrom:     fallbackFrom,
+			RetrievedCount:   lo.ToPtr(len(rankedNodes)),
+			ToolTraces:       toolset.Traces(),
 			RemoteIP:         req.RemoteIP,
 			ParentID:         userMessageId,
-		}); err != nil {
+		}, citations); err != nil {
 			u.logger.Error("failed to save assistant answer to conversation message", log.Error(err))
 			eventCh <- domain.SSEEvent{Type: "error", Content: "failed to save assistant answer to conversation message"}
 			return
@@ -301,11 +387,147 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 			eventCh <- domain.SSEEvent{Type: "error", Content: "对话失败，请稍后再试"}
 			return
 		}
+		if toolCalls := toolset.ToolCalls(); len(toolCalls) > 0 {
+			eventCh <- domain.SSEEvent{Type: "tool_calls", ToolCalls: toolCalls}
+		}
 		eventCh <- domain.SSEEvent{Type: "done"}
+
+		// 调用过工具的回答可能依赖外部接口的实时结果，不做缓存
+		// 非流式接口收到 done 后即返回并取消请求的 ctx，缓存不能随之中断
+		if cacheKey != nil && len(toolset.Traces()) == 0 {
+			if err := u.answerCacheUsecase.Store(context.WithoutCancel(ctx), cacheKey, &domain.AnswerCache{
+				Answer:       answer,
+				ChunkResults: chunkResults,
+				Citations:    citations,
+				Provider:     req.ModelInfo.Provider,
+				Model:        req.ModelInfo.Model,
+			}); err != nil {
+				u.logger.Warn("store answer cache failed", log.String("kb_id", req.KBID), log.Error(err))
+			}
+		}
+
+		if suggestionsCh != nil {
+			if suggestions := <-suggestionsCh; len(suggestions) > 0 {
+				if err := u.conversationUsecase.UpdateMessageSuggestions(context.WithoutCancel(ctx), messageId, suggestions); err != nil {
+					u.logger.Warn("save suggestions failed", log.String("message_id", messageId), log.Error(err))
+				}
+				eventCh <- domain.SSEEvent{Type: "suggestions", Suggestions: suggestions}
+			}
+		}
 	}()
 	return eventCh, nil
 }
 
+// generateSuggestions generates the follow-up questions in the background with the model which answered,
+// the channel receives nil when the generation fails
+func (u *ChatUsecase) generateSuggestions(ctx context.Context, model *domain.Model, question, answer string, nodes []*domain.RankedNodeChunks) <-chan []string {
+	ch := make(chan []string, 1)
+	go func() {
+		defer close(ch)
+		// 调用方断开后仍然生成并保存，在对话记录中展示
+		suggestions, err := u.llmUsecase.GenerateSuggestions(context.WithoutCancel(ctx), model, question, answer, nodes)
+		if err != nil {
+			u.logger.Warn("generate suggestions failed", log.String("model", model.Model), log.Error(err))
+			return
+		}
+		ch <- suggestions
+	}()
+	return ch
+}
+
+// answerFAQ streams the approved answer of the faq verbatim with its linked documents
+func (u *ChatUsecase) answerFAQ(
+	ctx context.Context,
+	req *domain.ChatRequest,
+	faq *domain.FAQ,
+	groupIDs []int,
+	messageID, userMessageID string,
+	eventCh chan<- domain.SSEEvent,
+) {
+	u.faqUsecase.RecordHit(ctx, faq.ID)
+	eventCh <- domain.SSEEvent{Type: "faq", Content: faq.ID}
+	chunkResults, err := u.faqUsecase.LinkedChunkResults(ctx, faq, groupIDs)
+	if err != nil {
+		u.logger.Warn("get faq linked nodes failed", log.String("faq_id", faq.ID), log.Error(err))
+	}
+	for _, chunkResult := range chunkResults {
+		eventCh <- domain.SSEEvent{Type: "chunk_result", ChunkResult: chunkResult}
+	}
+	for _, chunk := range lo.ChunkString(faq.Answer, replayChunkRunes) {
+		eventCh <- domain.SSEEvent{Type: "data", Content: chunk}
+	}
+	if err := u.conversationUsecase.CreateChatConversationMessage(ctx, req.KBID, &domain.ConversationMessage{
+		ID:             messageID,
+		ConversationID: req.ConversationID,
+		KBID:           req.KBID,
+		AppID:          req.AppID,
+		Role:           schema.Assistant,
+		Content:        faq.Answer,
+		FAQID:          faq.ID,
+		RemoteIP:       req.RemoteIP,
+		ParentID:       userMessageID,
+	}, nil); err != nil {
+		u.logger.Error("failed to save assistant answer to conversation message", log.Error(err))
+		eventCh <- domain.SSEEvent{Type: "error", Content: "failed to save assistant answer to conversation message"}
+		return
+	}
+	eventCh <- domain.SSEEvent{Type: "done"}
+}
+
+// replayAnswerCache streams the cached answer like a normal answer and saves it to the conversation
+func (u *ChatUsecase) replayAnswerCache(
+	ctx context.Context,
+	req *domain.ChatRequest,
+	cache *domain.AnswerCache,
+	messageID, userMessageID string,
+	blockWords []string,
+	eventCh chan<- domain.SSEEvent,
+) {
+	for _, chunkResult := range cache.ChunkResults {
+		eventCh <- domain.SSEEvent{Type: "chunk_result", ChunkResult: chunkResult}
+	}
+	// 敏感词可能在缓存后新增，回放时同样过滤
+	answer := ""
+	onChunk, flushBuffer := u.CreateAcOnChunk(ctx, req.KBID, &answer, eventCh, blockWords)
+	for _, chunk := range lo.ChunkString(cache.Answer, replayChunkRunes) {
+		_ = onChunk(ctx, "data", chunk)
+	}
+	if flushBuffer != nil {
+		flushBuffer(ctx, "data")
+	}
+
+	citations := make([]*domain.ConversationReference, 0, len(cache.Citations))
+	for _, citation := range cache.Citations {
+		citation := *citation
+		citation.ConversationID = req.ConversationID
+		citation.AppID = req.AppID
+		citation.MessageID = messageID
+		citations = append(citations, &citation)
+	}
+	if len(citations) > 0 {
+		eventCh <- domain.SSEEvent{Type: "citations", Citations: citations}
+	}
+	if err := u.conversationUsecase.CreateChatConversationMessage(ctx, req.KBID, &domain.ConversationMessage{
+		ID:             messageID,
+		ConversationID: req.ConversationID,
+		KBID:           req.KBID,
+		AppID:          req.AppID,
+		Role:           schema.Assistant,
+		Content:        answer,
+		Provider:       cache.Provider,
+		Model:          cache.Model,
+		RetrievedCount: lo.ToPtr(len(cache.ChunkResults)),
+		AnswerCacheID:  cache.ID,
+		RemoteIP:       req.RemoteIP,
+		ParentID:       userMessageID,
+	}, citations); err != nil {
+		u.logger.Error("failed to save assistant answer to conversation message", log.Error(err))
+		eventCh <- domain.SSEEvent{Type: "error", Content: "failed to save assistant answer to conversation message"}
+		return
+	}
+	eventCh <- domain.SSEEvent{Type: "done"}
+}
+
 func (u *ChatUsecase) ChatRagOnly(ctx context.Context, req *domain.ChatRagOnlyRequest) (<-chan domain.SSEEvent, error) {
 	eventCh := make(chan domain.SSEEvent, 100)
 	go func() {
@@ -348,14 +570,10 @@ func (u *ChatUsecase) ChatRagOnly(ctx context.Context, req *domain.ChatRagOnlyRe
 			eventCh <- domain.SSEEvent{Type: "error", Content: "failed to get kb"}
 			return
 		}
-		_, rankedNodes, err := u.llmUsecase.GetRankNodes(ctx, GetRankNodesRequest{
-			DatasetID:           kb.DatasetID,
-			Question:            req.Message,
-			GroupIDs:            groupIds,
-			HistoryMessages:     nil,
-			SimilarityThreshold: 0,
-			MaxChunksPerDoc:     1,
-		})
+		rankReq := NewGetRankNodesRequest(kb, req.Message, groupIds)
+		rankReq.SimilarityThreshold = 0
+		rankReq.MaxChunksPerDoc = 1
+		_, rankedNodes, err := u.llmUsecase.GetRankNodes(ctx, rankReq)
 		if err != nil {
 			u.logger.Error("failed to get rank nodes", log.Error(err))
 			eventCh <- domain.SSEEvent{Type: "error", Content: "failed to get rank nodes"}
@@ -442,13 +660,7 @@ func (u *ChatUsecase) Search(ctx context.Context, req *domain.ChatSearchReq) (*d
 	if err != nil {
 		return nil, err
 	}
-	_, rankedNodes, err := u.llmUsecase.GetRankNodes(ctx, GetRankNodesRequest{
-		DatasetID:           kb.DatasetID,
-		Question:            req.Message,
-		GroupIDs:            groupIds,
-		SimilarityThreshold: 0.2,
-		HistoryMessages:     nil,
-	})
+	_, rankedNodes, err := u.llmUsecase.GetRankNodes(ctx, NewGetRankNodesRequest(kb, req.Message, groupIds))
 	if err != nil {
 		return nil, err
 	}
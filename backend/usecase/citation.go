@@ -0,0 +1,198 @@
+package usecase
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+// maxCitationMarkerDigits is the longest index of a citation marker
+const maxCitationMarkerDigits = 4
+
+// citationTracker records the documents cited by the markers [n] while the answer streams, in order of first appearance.
+// A marker only counts as a citation when it matches a document of the prompt, is not preceded by an identifier or
+// a link text (e.g. arr[1], [text][1]), and is outside of code and the reasoning. Markers whose index matches no
+// document are recorded as well, so that stripInvalidCitations can remove them from the saved answer
+type citationTracker struct {
+	documents int
+	cited     []int
+	invalid   [][2]int // byte ranges of the markers matching no document
+
+	offset      int // bytes of the input written so far
+	markerStart int
+
+	started  bool
+	thinking bool
+
+	prev         rune // the last rune outside of a marker
+	prevCitation bool // prev is the ] of a citation, so that [1][2] are both citations
+	lineStart    bool // only whitespace since the last line break
+	marker       bool // reading a marker, from [ to ]
+	markerDigits []rune
+	ticks        int // length of the backtick run being read
+	ticksAtLine  bool
+	fence        int // length of the opening fence, 0 outside fenced code
+	inlineTicks  int // length of the opening backticks, 0 outside inline code
+}
+
+func newCitationTracker(documents int) *citationTracker {
+	return &citationTracker{documents: documents, lineStart: true}
+}
+
+func (t *citationTracker) Write(chunk string) {
+	// 推理内容以 <think> 开头、</think> 结尾，整体位于回答之前
+	if !t.started {
+		t.started = true
+		if rest, ok := strings.CutPrefix(chunk, "<think>"); ok {
+			t.thinking = true
+			t.offset += len(chunk) - len(rest)
+			chunk = rest
+		}
+	}
+	if t.thinking {
+		_, rest, ok := strings.Cut(chunk, "</think>")
+		t.offset += len(chunk) - len(rest)
+		if !ok {
+			return
+		}
+		t.thinking = false
+		chunk = rest
+	}
+	for _, r := range chunk {
+		t.scan(r)
+		t.offset += utf8.RuneLen(r)
+	}
+}
+
+func (t *citationTracker) scan(r rune) {
+	if r == '`' {
+		t.marker = false
+		if t.ticks == 0 {
+			t.ticksAtLine = t.lineStart
+		}
+		t.ticks++
+		t.advance(r)
+		return
+	}
+	if t.ticks > 0 {
+		t.endTicks()
+	}
+	if t.fence > 0 || t.inlineTicks > 0 {
+		if r == '\n' {
+			// 未闭合的行内代码不跨行
+			t.inlineTicks = 0
+		}
+		t.advance(r)
+		return
+	}
+	if t.marker {
+		switch {
+		case r >= '0' && r <= '9' && len(t.markerDigits) < maxCitationMarkerDigits:
+			t.markerDigits = append(t.markerDigits, r)
+			return
+		case r == ']' && len(t.markerDigits) > 0:
+			t.marker = false
+			index, _ := strconv.Atoi(string(t.markerDigits))
+			if index >= 1 && index <= t.documents {
+				if !slices.Contains(t.cited, index) {
+					t.cited = append(t.cited, index)
+				}
+			} else {
+				t.invalid = append(t.invalid, [2]int{t.markerStart, t.offset + 1})
+			}
+			t.advance(r)
+			t.prevCitation = true
+			return
+		}
+		t.marker = false
+	}
+	if r == '[' && !isIdentifierRune(t.prev) && (t.prev != ']' || t.prevCitation) {
+		t.marker = true
+		t.markerStart = t.offset
+		t.markerDigits = t.markerDigits[:0]
+	}
+	t.advance(r)
+}
+
+func (t *citationTracker) advance(r rune) {
+	t.prev = r
+	t.prevCitation = false
+	switch {
+	case r == '\n':
+		t.lineStart = true
+	case !unicode.IsSpace(r):
+		t.lineStart = false
+	}
+}
+
+// endTicks opens or closes the code when a backtick run ends, fences need at least 3 backticks at the line start
+func (t *citationTracker) endTicks() {
+	switch {
+	case t.fence > 0:
+		if t.ticks >= t.fence && t.ticksAtLine {
+			t.fence = 0
+		}
+	case t.inlineTicks > 0:
+		if t.ticks == t.inlineTicks {
+			t.inlineTicks = 0
+		}
+	case t.ticks >= 3 && t.ticksAtLine:
+		t.fence = t.ticks
+	default:
+		t.inlineTicks = t.ticks
+	}
+	t.ticks = 0
+}
+
+// stripInvalidCitations removes the markers [n] whose index matches none of the documents from the answer,
+// the model sometimes cites a document which is not in the prompt
+func stripInvalidCitations(answer string, documents int) string {
+	tracker := newCitationTracker(documents)
+	tracker.Write(answer)
+	if len(tracker.invalid) == 0 {
+		return answer
+	}
+	var sb strings.Builder
+	sb.Grow(len(answer))
+	last := 0
+	for _, span := range tracker.invalid {
+		sb.WriteString(answer[last:span[0]])
+		last = span[1]
+	}
+	sb.WriteString(answer[last:])
+	return sb.String()
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// Citations maps the cited markers to the documents of the prompt
+func (t *citationTracker) Citations(conversationID, appID, messageID string, nodes []*domain.RankedNodeChunks, baseURL string) []*domain.ConversationReference {
+	citations := make([]*domain.ConversationReference, 0, len(t.cited))
+	for _, index := range t.cited {
+		if index > len(nodes) {
+			continue
+		}
+		node := nodes[index-1]
+		citations = append(citations, &domain.ConversationReference{
+			ConversationID: conversationID,
+			AppID:          appID,
+			MessageID:      messageID,
+			Index:          index,
+			NodeID:         node.NodeID,
+			Name:           node.NodeName,
+			URL:            node.GetURL(baseURL),
+			ChunkIDs: lo.Map(node.Chunks, func(chunk *domain.NodeContentChunk, _ int) string {
+				return chunk.ID
+			}),
+		})
+	}
+	return citations
+}
@@ -0,0 +1,38 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+func TestCitationTracker(t *testing.T) {
+	tracker := newCitationTracker(2)
+	for _, chunk := range []string{"<think>参考[2]", "</think>\nPandaWiki 支持 AI 问答[", "1]。还支持[3]导入[2][1]", "，见[文档](/node/a)。"} {
+		tracker.Write(chunk)
+	}
+	assert.Equal(t, []int{1, 2}, tracker.cited)
+
+	code := newCitationTracker(3)
+	code.Write("取值 arr[1]、[说明][2] 和 `list[3]`，\n```go\nx := a [2]\n```\n见 [3]")
+	assert.Equal(t, []int{3}, code.cited)
+
+	citations := tracker.Citations("c", "app", "m", []*domain.RankedNodeChunks{
+		{NodeID: "a", NodeName: "A", Chunks: []*domain.NodeContentChunk{{ID: "a-1"}}},
+		{NodeID: "b", NodeName: "B"},
+	}, "https://wiki.example.com")
+	assert.Len(t, citations, 2)
+	assert.Equal(t, 1, citations[0].Index)
+	assert.Equal(t, "https://wiki.example.com/node/a", citations[0].URL)
+	assert.Equal(t, []string{"a-1"}, []string(citations[0].ChunkIDs))
+	assert.Equal(t, 2, citations[1].Index)
+	assert.Equal(t, "m", citations[1].MessageID)
+}
+
+func TestStripInvalidCitations(t *testing.T) {
+	assert.Equal(t, "支持问答[1]，导入[2]。", stripInvalidCitations("支持问答[1][7]，导入[2][0]。", 2))
+	assert.Equal(t, "<think>参考[9]</think>见 arr[9] 和 `[9]`[1]", stripInvalidCitations("<think>参考[9]</think>见 arr[9] 和 `[9]`[1][3]", 1))
+	assert.Equal(t, "没有引用", stripInvalidCitations("没有引用", 0))
+}
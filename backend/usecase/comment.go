@@ -12,6 +12,7 @@ import (
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
 	"github.com/chaitin/panda-wiki/repo/ipdb"
+	"github.com/chaitin/panda-wiki/repo/mq"
 	"github.com/chaitin/panda-wiki/repo/pg"
 )
 
@@ -21,16 +22,18 @@ type CommentUsecase struct {
 	NodeRepo    *pg.NodeRepository
 	ipRepo      *ipdb.IPAddressRepo
 	authRepo    *pg.AuthRepo
+	webhookRepo *mq.WebhookRepository
 }
 
 func NewCommentUsecase(commentRepo *pg.CommentRepository, logger *log.Logger,
-	nodeRepo *pg.NodeRepository, ipRepo *ipdb.IPAddressRepo, authRepo *pg.AuthRepo) *CommentUsecase {
+	nodeRepo *pg.NodeRepository, ipRepo *ipdb.IPAddressRepo, authRepo *pg.AuthRepo, webhookRepo *mq.WebhookRepository) *CommentUsecase {
 	return &CommentUsecase{
 		logger:      logger.WithModule("usecase.comment"),
 		CommentRepo: commentRepo,
 		NodeRepo:    nodeRepo,
 		ipRepo:      ipRepo,
 		authRepo:    authRepo,
+		webhookRepo: webhookRepo,
 	}
 }
 
@@ -67,6 +70,14 @@ func (u *CommentUsecase) CreateComment(ctx context.Context, commentReq *domain.C
 	if err != nil {
 		return "", err
 	}
+	publishWebhookEvent(ctx, u.logger, u.webhookRepo, KbID, domain.WebhookEventCommentCreated, &domain.WebhookCommentCreatedData{
+		CommentID: CommentStr,
+		NodeID:    commentReq.NodeID,
+		ParentID:  commentReq.ParentID,
+		UserName:  commentReq.UserName,
+		Content:   commentReq.Content,
+		Status:    status,
+	})
 
 	// success
 	return CommentStr, nil
@@ -168,6 +179,21 @@ func (u *CommentUsecase) DeleteCommentList(ctx context.Context, req *domain.Dele
 	return nil
 }
 
+// ModerateComments 审核通过或拒绝评论，只处理当前知识库下的评论
+func (u *CommentUsecase) ModerateComments(ctx context.Context, req *domain.ModerateCommentReq) error {
+	ids, err := u.CommentRepo.UpdateCommentStatus(ctx, req.KbID, req.IDs, req.Status)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		publishWebhookEvent(ctx, u.logger, u.webhookRepo, req.KbID, domain.WebhookEventCommentModerated, &domain.WebhookCommentModeratedData{
+			CommentIDs: ids,
+			Status:     req.Status,
+		})
+	}
+	return nil
+}
+
 func maskIP(ip string) string {
 	if ip == "" {
 		return ""
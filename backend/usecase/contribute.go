@@ -0,0 +1,282 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	v1 "github.com/chaitin/panda-wiki/api/contribute/v1"
+	shareV1 "github.com/chaitin/panda-wiki/api/share/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+// ContributeUsecase handles the changes proposed by readers, approved changes are merged into the node as a draft
+type ContributeUsecase struct {
+	repo        *pg.ContributeRepository
+	nodeRepo    *pg.NodeRepository
+	authRepo    *pg.AuthRepo
+	nodeUsecase *NodeUsecase
+	logger      *log.Logger
+}
+
+func NewContributeUsecase(
+	repo *pg.ContributeRepository,
+	nodeRepo *pg.NodeRepository,
+	authRepo *pg.AuthRepo,
+	nodeUsecase *NodeUsecase,
+	logger *log.Logger,
+) *ContributeUsecase {
+	return &ContributeUsecase{
+		repo:        repo,
+		nodeRepo:    nodeRepo,
+		authRepo:    authRepo,
+		nodeUsecase: nodeUsecase,
+		logger:      logger.WithModule("usecase.contribute"),
+	}
+}
+
+// Submit saves a pending contribute, an edit must target a released document and a new page a released folder
+func (u *ContributeUsecase) Submit(ctx context.Context, kbID string, authID uint, remoteIP string, req *shareV1.ShareContributeReq) (string, error) {
+	contribute := &domain.Contribute{
+		Id:       uuid.New().String(),
+		KBId:     kbID,
+		Status:   consts.ContributeStatusPending,
+		Type:     req.Type,
+		Name:     req.Name,
+		Content:  req.Content,
+		Meta:     domain.NodeMeta{ContentType: req.ContentType},
+		Reason:   req.Reason,
+		RemoteIP: remoteIP,
+	}
+	if authID != 0 {
+		contribute.AuthId = lo.ToPtr(int64(authID))
+	}
+
+	switch req.Type {
+	case consts.ContributeTypeEdit:
+		node, err := u.nodeRepo.GetNodeReleaseDetailByKBIDAndID(ctx, kbID, req.NodeId)
+		if err != nil {
+			return "", fmt.Errorf("get released node failed: %w", err)
+		}
+		if node.Type != domain.NodeTypeDocument {
+			return "", fmt.Errorf("only documents can be edited")
+		}
+		contribute.NodeId = node.ID
+		if contribute.Meta.ContentType == "" {
+			contribute.Meta.ContentType = node.Meta.ContentType
+		}
+	case consts.ContributeTypeAdd:
+		if req.ParentId != "" {
+			parent, err := u.nodeRepo.GetNodeReleaseDetailByKBIDAndID(ctx, kbID, req.ParentId)
+			if err != nil {
+				return "", fmt.Errorf("get released parent failed: %w", err)
+			}
+			if parent.Type != domain.NodeTypeFolder {
+				return "", fmt.Errorf("parent must be a folder")
+			}
+			contribute.ParentId = parent.ID
+		}
+	}
+
+	if err := u.repo.Create(ctx, contribute); err != nil {
+		return "", err
+	}
+	return contribute.Id, nil
+}
+
+func (u *ContributeUsecase) List(ctx context.Context, req *v1.ContributeListReq) (*v1.ContributeListResp, error) {
+	contributes, total, err := u.repo.List(ctx, req.KbId, req.Status, req.Offset(), req.Limit())
+	if err != nil {
+		return nil, err
+	}
+	items, err := u.toListItems(ctx, req.KbId, contributes)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPaginatedResult(items, uint64(total)), nil
+}
+
+// Detail returns the contribute with its diff against the current node
+func (u *ContributeUsecase) Detail(ctx context.Context, req *v1.ContributeDetailReq) (*v1.ContributeDetailResp, error) {
+	contribute, err := u.repo.GetByID(ctx, req.KbId, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := u.toListItems(ctx, req.KbId, []*domain.Contribute{contribute})
+	if err != nil {
+		return nil, err
+	}
+	resp := &v1.ContributeDetailResp{ContributeListItem: *items[0]}
+
+	var oldName, oldContent string
+	if contribute.Type == consts.ContributeTypeEdit {
+		node, err := u.nodeRepo.GetByID(ctx, contribute.NodeId, req.KbId)
+		if err != nil {
+			return nil, fmt.Errorf("get node failed: %w", err)
+		}
+		oldName, oldContent = node.Name, node.Content
+		resp.NodeChanged = contribute.Status == consts.ContributeStatusPending && node.UpdatedAt.After(contribute.CreatedAt)
+	}
+	resp.NameChanged = oldName != contribute.Name
+	resp.Lines, resp.Stats = utils.DiffContent(oldContent, contribute.Content)
+	return resp, nil
+}
+
+// Approve merges the contribute into the node as a draft, a new page is created as an unpublished node.
+// The node is published right away when req.Publish is set.
+func (u *ContributeUsecase) Approve(ctx context.Context, req *v1.ContributeApproveReq, userID string) (*v1.ContributeApproveResp, error) {
+	contribute, err := u.repo.GetByID(ctx, req.KbId, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if contribute.Status != consts.ContributeStatusPending {
+		return nil, domain.ErrContributeAudited
+	}
+	// 合并在审核事务之外执行，新页面先记录节点 id，审核保存失败后重试时沿用已创建的节点
+	if contribute.Type == consts.ContributeTypeAdd {
+		nodeID, err := uuid.NewV7()
+		if err != nil {
+			return nil, err
+		}
+		if err := u.repo.ReserveNodeID(ctx, req.KbId, req.ID, nodeID.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	// 合并期间锁住贡献，并发的审核等待后返回已审核，合并失败时贡献仍为待审核
+	now := time.Now()
+	contribute, err = u.repo.AuditWithLock(ctx, req.KbId, req.ID, func(contribute *domain.Contribute) error {
+		if err := u.merge(ctx, contribute, userID); err != nil {
+			return err
+		}
+		return contribute.Approve(userID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &v1.ContributeApproveResp{NodeID: contribute.NodeId}
+	if !req.Publish {
+		return resp, nil
+	}
+	resp.ReleaseID, err = u.nodeUsecase.PublishNodes(ctx, req.KbId, userID, []string{contribute.NodeId},
+		fmt.Sprintf("contribute-%s", now.Format("20060102150405")),
+		fmt.Sprintf("合并读者贡献「%s」", contribute.Name))
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// merge applies the contribute to the node, a new page is created as an unpublished node with the reserved id.
+// Applying a contribute again is a no-op, an edit writes the same content and a created page is kept.
+func (u *ContributeUsecase) merge(ctx context.Context, contribute *domain.Contribute, userID string) error {
+	switch contribute.Type {
+	case consts.ContributeTypeEdit:
+		updateReq := &domain.UpdateNodeReq{
+			ID:      contribute.NodeId,
+			KBID:    contribute.KBId,
+			Name:    &contribute.Name,
+			Content: &contribute.Content,
+		}
+		if contribute.Meta.ContentType != "" {
+			updateReq.ContentType = &contribute.Meta.ContentType
+		}
+		if err := u.nodeUsecase.Update(ctx, updateReq, userID); err != nil {
+			return fmt.Errorf("merge contribute failed: %w", err)
+		}
+	case consts.ContributeTypeAdd:
+		if contribute.NodeId != "" {
+			_, err := u.nodeRepo.GetByID(ctx, contribute.NodeId, contribute.KBId)
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("get merged node failed: %w", err)
+			}
+		}
+		createReq := &domain.CreateNodeReq{
+			ID:       contribute.NodeId,
+			KBID:     contribute.KBId,
+			ParentID: contribute.ParentId,
+			Type:     domain.NodeTypeDocument,
+			Name:     contribute.Name,
+			Content:  contribute.Content,
+			MaxNode:  domain.GetBaseEditionLimitation(ctx).MaxNode,
+		}
+		if contribute.Meta.ContentType != "" {
+			createReq.ContentType = &contribute.Meta.ContentType
+		}
+		nodeID, err := u.nodeUsecase.Create(ctx, createReq, userID)
+		if err != nil {
+			return err
+		}
+		contribute.NodeId = nodeID
+	}
+	return nil
+}
+
+func (u *ContributeUsecase) Reject(ctx context.Context, req *v1.ContributeRejectReq, userID string) error {
+	contribute, err := u.repo.GetByID(ctx, req.KbId, req.ID)
+	if err != nil {
+		return err
+	}
+	if err := contribute.Reject(userID, req.Reason, time.Now()); err != nil {
+		return err
+	}
+	return u.repo.Audit(ctx, contribute)
+}
+
+// toListItems fills the names of the readers and the current names of the nodes
+func (u *ContributeUsecase) toListItems(ctx context.Context, kbID string, contributes []*domain.Contribute) ([]*v1.ContributeListItem, error) {
+	authIDs := make([]uint, 0, len(contributes))
+	for _, contribute := range contributes {
+		if contribute.AuthId != nil {
+			authIDs = append(authIDs, uint(*contribute.AuthId))
+		}
+	}
+	authMap, err := u.authRepo.GetAuthUserinfoByIDs(ctx, lo.Uniq(authIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*v1.ContributeListItem, 0, len(contributes))
+	nodeNames := make(map[string]string)
+	for _, contribute := range contributes {
+		item := &v1.ContributeListItem{Contribute: contribute}
+		if contribute.AuthId != nil {
+			if auth, ok := authMap[uint(*contribute.AuthId)]; ok {
+				item.AuthName = auth.AuthUserInfo.Username
+			}
+		}
+		nodeID := contribute.NodeId
+		if contribute.Type == consts.ContributeTypeAdd {
+			nodeID = contribute.ParentId
+		}
+		if nodeID != "" {
+			name, ok := nodeNames[nodeID]
+			if !ok {
+				node, err := u.nodeRepo.GetByID(ctx, nodeID, kbID)
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, err
+				}
+				if node != nil {
+					name = node.Name
+				}
+				nodeNames[nodeID] = name
+			}
+			item.NodeName = name
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strconv"
 
 	"github.com/samber/lo"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/chaitin/panda-wiki/log"
 	"github.com/chaitin/panda-wiki/repo/cache"
 	"github.com/chaitin/panda-wiki/repo/ipdb"
+	"github.com/chaitin/panda-wiki/repo/mq"
 	"github.com/chaitin/panda-wiki/repo/pg"
 )
 
@@ -21,6 +23,7 @@ type ConversationUsecase struct {
 	logger       *log.Logger
 	ipRepo       *ipdb.IPAddressRepo
 	authRepo     *pg.AuthRepo
+	webhookRepo  *mq.WebhookRepository
 }
 
 func NewConversationUsecase(
@@ -30,6 +33,7 @@ func NewConversationUsecase(
 	logger *log.Logger,
 	ipRepo *ipdb.IPAddressRepo,
 	authRepo *pg.AuthRepo,
+	webhookRepo *mq.WebhookRepository,
 ) *ConversationUsecase {
 	return &ConversationUsecase{
 		repo:         repo,
@@ -37,15 +41,26 @@ func NewConversationUsecase(
 		geoCacheRepo: geoCacheRepo,
 		ipRepo:       ipRepo,
 		authRepo:     authRepo,
+		webhookRepo:  webhookRepo,
 		logger:       logger.WithModule("usecase.conversation"),
 	}
 }
 
-func (u *ConversationUsecase) CreateChatConversationMessage(ctx context.Context, kbID string, conversation *domain.ConversationMessage) error {
-	references := extractReferencesBlock(conversation.ID, conversation.AppID, conversation.Content)
+// CreateChatConversationMessage saves the message with its citations,
+// the references are parsed from the reference list at the end of the answer when citations is empty
+func (u *ConversationUsecase) CreateChatConversationMessage(ctx context.Context, kbID string, conversation *domain.ConversationMessage, citations []*domain.ConversationReference) error {
+	references := citations
+	if len(references) == 0 {
+		references = extractReferencesBlock(conversation)
+	}
 	return u.repo.CreateConversationMessage(ctx, conversation, references)
 }
 
+// UpdateMessageSuggestions saves the follow-up questions generated after the answer is saved
+func (u *ConversationUsecase) UpdateMessageSuggestions(ctx context.Context, messageID string, suggestions []string) error {
+	return u.repo.UpdateMessageSuggestions(ctx, messageID, suggestions)
+}
+
 func (u *ConversationUsecase) GetConversationList(ctx context.Context, request *domain.ConversationListReq) (*domain.PaginatedResult[[]*domain.ConversationListItem], error) {
 	conversations, total, err := u.repo.GetConversationList(ctx, request)
 	if err != nil {
@@ -131,7 +146,8 @@ func (u *ConversationUsecase) GetConversationDetail(ctx context.Context, kbID, c
 	return conversation, nil
 }
 
-func extractReferencesBlock(conversationID, appID, text string) []*domain.ConversationReference {
+func extractReferencesBlock(message *domain.ConversationMessage) []*domain.ConversationReference {
+	text := message.Content
 	// match whole reference block
 	reBlock := regexp.MustCompile(`(?ms)((?:>|\\u003e)\s*\[\d+\]\.\s*\[.*?\]\(.*?\)\s*\n?)+$`)
 	// find the last match index
@@ -153,12 +169,15 @@ func extractReferencesBlock(conversationID, appID, text string) []*domain.Conver
 	refs := make([]*domain.ConversationReference, 0)
 	for _, match := range matches {
 		if len(match) == 4 {
+			index, _ := strconv.Atoi(match[1])
 			refs = append(refs, &domain.ConversationReference{
-				Name: match[2],
-				URL:  match[3],
+				Index: index,
+				Name:  match[2],
+				URL:   match[3],
 
-				ConversationID: conversationID,
-				AppID:          appID,
+				ConversationID: message.ConversationID,
+				AppID:          message.AppID,
+				MessageID:      message.ID,
 			})
 		}
 	}
@@ -200,6 +219,13 @@ func (u *ConversationUsecase) FeedBack(ctx context.Context, feedback *domain.Fee
 		if err := u.repo.UpdateMessageFeedback(ctx, feedback); err != nil {
 			return err
 		}
+		publishWebhookEvent(ctx, u.logger, u.webhookRepo, messages.KBID, domain.WebhookEventFeedbackSubmitted, &domain.WebhookFeedbackSubmittedData{
+			ConversationID: messages.ConversationID,
+			MessageID:      messages.ID,
+			Score:          feedback.Score,
+			Type:           feedback.Type,
+			Content:        feedback.FeedbackContent,
+		})
 	} else {
 		return fmt.Errorf("already voted for this message, please do not vote again")
 	}
@@ -272,10 +298,11 @@ func (u *ConversationUsecase) GetShareConversationDetail(ctx context.Context, kb
 	var shareMessages []*domain.ShareConversationMessage
 	for _, message := range messages {
 		shareMessages = append(shareMessages, &domain.ShareConversationMessage{
-			Role:       message.Role,
-			Content:    message.Content,
-			ImagePaths: message.ImagePaths,
-			CreatedAt:  message.CreatedAt,
+			Role:        message.Role,
+			Content:     message.Content,
+			ImagePaths:  message.ImagePaths,
+			Suggestions: message.Suggestions,
+			CreatedAt:   message.CreatedAt,
 		})
 	}
 	shareConversationDetail := domain.ShareConversationDetailResp{
@@ -30,7 +30,7 @@ func NewCreationUsecase(logger *log.Logger, llm *LLMUsecase, model *ModelUsecase
 }
 
 func (u *CreationUsecase) TextCreation(ctx context.Context, req *domain.TextReq, onChunk func(ctx context.Context, dataType, chunk string) error) error {
-	model, err := u.model.GetChatModel(ctx)
+	model, err := u.model.GetKBChatModel(ctx, req.KBID, nil)
 	if err != nil {
 		u.logger.Error("get chat model failed", log.Error(err))
 		return domain.ErrModelNotConfigured
@@ -84,7 +84,7 @@ func (u *CreationUsecase) TextCreation(ctx context.Context, req *domain.TextReq,
 func (u *CreationUsecase) TabComplete(ctx context.Context, req *domain.CompleteReq) (string, error) {
 	// For FIM (Fill in Middle) style completion, we need to handle prefix and suffix
 	if req.Prefix != "" || req.Suffix != "" {
-		model, err := u.model.GetChatModel(ctx)
+		model, err := u.model.GetKBChatModel(ctx, req.KBID, nil)
 		if err != nil {
 			u.logger.Error("get chat model failed", log.Error(err))
 			return "", domain.ErrModelNotConfigured
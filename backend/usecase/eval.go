@@ -0,0 +1,514 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	modelkit "github.com/chaitin/ModelKit/v2/usecase"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+
+	v1 "github.com/chaitin/panda-wiki/api/eval/v1"
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+const evalJudgePrompt = `你是一名严格的评审，负责评估知识库问答系统的回答质量。
+你将收到用户问题、检索到的参考文档、系统的回答，以及可能提供的参考答案。
+请评估回答的忠实度：回答中的每个事实性陈述是否都能由参考文档支持，且与参考答案（如有）不矛盾。
+- 1 表示完全忠实，所有陈述均有文档依据
+- 0 表示回答与文档无关或存在编造、矛盾的内容
+- 如果文档中没有答案且回答明确表示无法回答，视为忠实
+只输出 JSON，格式为 {"score": 0 到 1 之间的小数, "reason": "简要理由"}，不要输出其他内容。`
+
+type EvalUsecase struct {
+	evalRepo     *pg.EvalRepository
+	kbRepo       *pg.KnowledgeBaseRepository
+	authRepo     *pg.AuthRepo
+	promptRepo   *pg.PromptRepo
+	llmUsecase   *LLMUsecase
+	modelUsecase *ModelUsecase
+	logger       *log.Logger
+	modelkit     *modelkit.ModelKit
+	runSlots     chan struct{} // 限制同时执行的运行数
+}
+
+func NewEvalUsecase(
+	evalRepo *pg.EvalRepository,
+	kbRepo *pg.KnowledgeBaseRepository,
+	authRepo *pg.AuthRepo,
+	promptRepo *pg.PromptRepo,
+	llmUsecase *LLMUsecase,
+	modelUsecase *ModelUsecase,
+	logger *log.Logger,
+) *EvalUsecase {
+	return &EvalUsecase{
+		evalRepo:     evalRepo,
+		kbRepo:       kbRepo,
+		authRepo:     authRepo,
+		promptRepo:   promptRepo,
+		llmUsecase:   llmUsecase,
+		modelUsecase: modelUsecase,
+		logger:       logger.WithModule("usecase.eval"),
+		modelkit:     modelkit.NewModelKit(logger.Logger),
+		runSlots:     make(chan struct{}, domain.EvalMaxConcurrentRuns),
+	}
+}
+
+func (u *EvalUsecase) ListEvalSets(ctx context.Context, kbID string) ([]*v1.EvalSetListItem, error) {
+	return u.evalRepo.ListEvalSets(ctx, kbID)
+}
+
+func (u *EvalUsecase) CreateEvalSet(ctx context.Context, req *v1.EvalSetCreateReq) (string, error) {
+	set := &domain.EvalSet{
+		ID:          uuid.New().String(),
+		KBID:        req.KbId,
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := u.evalRepo.CreateEvalSet(ctx, set); err != nil {
+		return "", err
+	}
+	return set.ID, nil
+}
+
+func (u *EvalUsecase) UpdateEvalSet(ctx context.Context, req *v1.EvalSetUpdateReq) error {
+	return u.evalRepo.UpdateEvalSet(ctx, req)
+}
+
+func (u *EvalUsecase) DeleteEvalSet(ctx context.Context, kbID, id string) error {
+	return u.evalRepo.DeleteEvalSet(ctx, kbID, id)
+}
+
+func (u *EvalUsecase) ListEvalQuestions(ctx context.Context, kbID, setID string) ([]*domain.EvalQuestion, error) {
+	return u.evalRepo.ListEvalQuestions(ctx, kbID, setID)
+}
+
+func (u *EvalUsecase) CreateEvalQuestions(ctx context.Context, req *v1.EvalQuestionCreateReq) ([]string, error) {
+	if _, err := u.evalRepo.GetEvalSet(ctx, req.KbId, req.SetId); err != nil {
+		return nil, fmt.Errorf("get eval set failed: %w", err)
+	}
+	now := time.Now()
+	questions := make([]*domain.EvalQuestion, 0, len(req.Questions))
+	for i, item := range req.Questions {
+		expectedNodeIDs := item.ExpectedNodeIds
+		if expectedNodeIDs == nil {
+			expectedNodeIDs = []string{}
+		}
+		questions = append(questions, &domain.EvalQuestion{
+			ID:              uuid.New().String(),
+			SetID:           req.SetId,
+			KBID:            req.KbId,
+			Question:        item.Question,
+			ExpectedNodeIDs: expectedNodeIDs,
+			ReferenceAnswer: item.ReferenceAnswer,
+			// keep the order of the request
+			CreatedAt: now.Add(time.Duration(i) * time.Microsecond),
+			UpdatedAt: now,
+		})
+	}
+	if err := u.evalRepo.CreateEvalQuestions(ctx, questions); err != nil {
+		return nil, err
+	}
+	return lo.Map(questions, func(q *domain.EvalQuestion, _ int) string { return q.ID }), nil
+}
+
+func (u *EvalUsecase) UpdateEvalQuestion(ctx context.Context, req *v1.EvalQuestionUpdateReq) error {
+	return u.evalRepo.UpdateEvalQuestion(ctx, req)
+}
+
+func (u *EvalUsecase) DeleteEvalQuestions(ctx context.Context, kbID string, ids []string) error {
+	return u.evalRepo.DeleteEvalQuestions(ctx, kbID, ids)
+}
+
+// CreateEvalRun snapshots the current model and retrieval settings and runs the set in background
+func (u *EvalUsecase) CreateEvalRun(ctx context.Context, req *v1.EvalRunCreateReq) (string, error) {
+	if _, err := u.evalRepo.GetEvalSet(ctx, req.KbId, req.SetId); err != nil {
+		return "", fmt.Errorf("get eval set failed: %w", err)
+	}
+	questions, err := u.evalRepo.ListEvalQuestions(ctx, req.KbId, req.SetId)
+	if err != nil {
+		return "", err
+	}
+	if len(questions) == 0 {
+		return "", errors.New("eval set has no questions")
+	}
+	kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, req.KbId)
+	if err != nil {
+		return "", err
+	}
+	chatModel, err := u.modelUsecase.GetKBChatModel(ctx, req.KbId, nil)
+	if err != nil {
+		u.logger.Error("get chat model failed", log.Error(err))
+		return "", domain.ErrModelNotConfigured
+	}
+	config, err := u.snapshotRunConfig(ctx, kb, chatModel)
+	if err != nil {
+		return "", err
+	}
+	config.Judge = req.Judge
+
+	select {
+	case u.runSlots <- struct{}{}:
+	default:
+		return "", domain.ErrEvalRunBusy
+	}
+	now := time.Now()
+	run := &domain.EvalRun{
+		ID:          uuid.New().String(),
+		SetID:       req.SetId,
+		KBID:        req.KbId,
+		Name:        req.Name,
+		Status:      domain.EvalRunStatusRunning,
+		Config:      config,
+		CreatedAt:   now,
+		HeartbeatAt: &now,
+	}
+	if run.Name == "" {
+		run.Name = fmt.Sprintf("%s %s", config.ChatModel, run.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	if err := u.evalRepo.CreateEvalRun(ctx, run); err != nil {
+		<-u.runSlots
+		return "", err
+	}
+	go func() {
+		defer func() { <-u.runSlots }()
+		u.executeEvalRun(run, questions, chatModel)
+	}()
+	return run.ID, nil
+}
+
+func (u *EvalUsecase) snapshotRunConfig(ctx context.Context, kb *domain.KnowledgeBase, chatModel *domain.Model) (domain.EvalRunConfig, error) {
+	config := domain.EvalRunConfig{
+		ModelMode: string(consts.ModelSettingModeManual),
+		ChatModel: chatModel.Model,
+		Retrieval: kb.RetrievalSettings.WithDefaults(),
+	}
+	modeSetting, err := u.modelUsecase.GetModelModeSetting(ctx)
+	if err == nil && modeSetting.Mode == consts.ModelSettingModeAuto {
+		config.ModelMode = string(consts.ModelSettingModeAuto)
+		config.EmbeddingModel = string(consts.AutoModeDefaultEmbeddingModel)
+		config.RerankModel = string(consts.AutoModeDefaultRerankModel)
+	} else {
+		if embedding, err := u.modelUsecase.GetModelByType(ctx, domain.ModelTypeEmbedding); err == nil {
+			config.EmbeddingModel = embedding.Model
+		}
+		if rerank, err := u.modelUsecase.GetModelByType(ctx, domain.ModelTypeRerank); err == nil {
+			config.RerankModel = rerank.Model
+		}
+	}
+	prompt, err := u.promptRepo.GetPrompt(ctx, kb.ID)
+	if err != nil {
+		return config, fmt.Errorf("get prompt failed: %w", err)
+	}
+	if prompt == "" {
+		prompt = domain.SystemDefaultPrompt
+	}
+	config.Prompt = prompt
+	return config, nil
+}
+
+// executeEvalRun runs the questions one by one and renews the lease of the run meanwhile,
+// it stops once the run is canceled or deleted
+func (u *EvalUsecase) executeEvalRun(run *domain.EvalRun, questions []*domain.EvalQuestion, chatModelInfo *domain.Model) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go u.heartbeatEvalRun(ctx, cancel, run.ID)
+
+	fail := func(err error) {
+		if ctx.Err() != nil {
+			u.logger.Info("eval run stopped", log.String("run_id", run.ID))
+			return
+		}
+		u.logger.Error("eval run failed", log.String("run_id", run.ID), log.Error(err))
+		if err := u.evalRepo.FinishEvalRun(ctx, run.ID, domain.EvalRunStatusFailed, domain.EvalMetrics{}, err.Error()); err != nil {
+			u.logger.Error("finish eval run failed", log.String("run_id", run.ID), log.Error(err))
+		}
+	}
+	modelkitModel, err := chatModelInfo.ToModelkitModel()
+	if err != nil {
+		fail(fmt.Errorf("convert chat model failed: %w", err))
+		return
+	}
+	chatModel, err := u.modelkit.GetChatModel(ctx, modelkitModel)
+	if err != nil {
+		fail(fmt.Errorf("get chat model failed: %w", err))
+		return
+	}
+	// evaluate as an administrator who can read every document
+	groupIDs, err := u.authRepo.GetAuthGroupIdsByKBID(ctx, run.KBID)
+	if err != nil {
+		fail(fmt.Errorf("get kb auth group ids failed: %w", err))
+		return
+	}
+
+	results := make([]*domain.EvalRunResult, 0, len(questions))
+	for _, question := range questions {
+		result := u.evalQuestion(ctx, run, question, groupIDs, chatModelInfo, chatModel)
+		if ctx.Err() != nil {
+			u.logger.Info("eval run stopped", log.String("run_id", run.ID))
+			return
+		}
+		if err := u.evalRepo.CreateEvalRunResult(ctx, result); err != nil {
+			fail(fmt.Errorf("save eval result failed: %w", err))
+			return
+		}
+		results = append(results, result)
+	}
+	metrics := computeEvalMetrics(results, run.Config.Retrieval.TopK)
+	if err := u.evalRepo.FinishEvalRun(ctx, run.ID, domain.EvalRunStatusSucceeded, metrics, ""); err != nil {
+		u.logger.Error("finish eval run failed", log.String("run_id", run.ID), log.Error(err))
+	}
+	u.logger.Info("eval run finished", log.String("run_id", run.ID), log.Any("metrics", metrics))
+}
+
+// heartbeatEvalRun renews the lease until ctx is done, and cancels the run when it is no longer running
+func (u *EvalUsecase) heartbeatEvalRun(ctx context.Context, cancel context.CancelFunc, runID string) {
+	ticker := time.NewTicker(domain.EvalRunHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			running, err := u.evalRepo.HeartbeatEvalRun(ctx, runID)
+			if err != nil {
+				u.logger.Warn("eval run heartbeat failed", log.String("run_id", runID), log.Error(err))
+				continue
+			}
+			if !running {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func (u *EvalUsecase) evalQuestion(ctx context.Context, run *domain.EvalRun, question *domain.EvalQuestion, groupIDs []int, chatModelInfo *domain.Model, chatModel model.BaseChatModel) *domain.EvalRunResult {
+	result := &domain.EvalRunResult{
+		ID:               uuid.New().String(),
+		RunID:            run.ID,
+		QuestionID:       question.ID,
+		Question:         question.Question,
+		ExpectedNodeIDs:  question.ExpectedNodeIDs,
+		RetrievedNodeIDs: []string{},
+		CreatedAt:        time.Now(),
+	}
+	start := time.Now()
+	buildResult, err := u.llmUsecase.BuildMessagesWithRAG(ctx, &BuildMessagesRequest{
+		Messages: []*domain.ConversationMessage{{
+			KBID:    run.KBID,
+			Role:    schema.User,
+			Content: question.Question,
+		}},
+		KBID:         run.KBID,
+		GroupIDs:     groupIDs,
+		SystemPrompt: run.Config.Prompt,
+		Model:        chatModelInfo,
+	})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.RetrievedNodeIDs = lo.Map(buildResult.Rank.Nodes, func(node *domain.RankedNodeChunks, _ int) string {
+		return node.NodeID
+	})
+	if len(question.ExpectedNodeIDs) > 0 {
+		recall, reciprocalRank := retrievalScores(question.ExpectedNodeIDs, result.RetrievedNodeIDs)
+		result.Recall = &recall
+		result.ReciprocalRank = &reciprocalRank
+	}
+
+	answer, err := u.llmUsecase.Generate(ctx, chatModel, buildResult.Messages)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Answer = answer
+
+	if run.Config.Judge {
+		documents := domain.FormatNodeChunks(buildResult.Nodes, "")
+		score, reason, err := u.judgeFaithfulness(ctx, chatModel, question, documents, answer)
+		if err != nil {
+			u.logger.Warn("judge eval answer failed", log.String("run_id", run.ID), log.String("question_id", question.ID), log.Error(err))
+			result.JudgeReason = "judge failed: " + err.Error()
+		} else {
+			result.Faithfulness = &score
+			result.JudgeReason = reason
+		}
+	}
+	return result
+}
+
+func (u *EvalUsecase) judgeFaithfulness(ctx context.Context, chatModel model.BaseChatModel, question *domain.EvalQuestion, documents, answer string) (float64, string, error) {
+	var content strings.Builder
+	fmt.Fprintf(&content, "问题：\n%s\n\n参考文档：\n%s\n\n回答：\n%s\n", question.Question, documents, answer)
+	if question.ReferenceAnswer != "" {
+		fmt.Fprintf(&content, "\n参考答案：\n%s\n", question.ReferenceAnswer)
+	}
+	output, err := u.llmUsecase.Generate(ctx, chatModel, []*schema.Message{
+		schema.SystemMessage(evalJudgePrompt),
+		schema.UserMessage(content.String()),
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	return parseJudgeOutput(output)
+}
+
+// parseJudgeOutput extracts the json verdict, models often wrap it in markdown or add text around it
+func parseJudgeOutput(output string) (float64, string, error) {
+	start, end := strings.Index(output, "{"), strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return 0, "", fmt.Errorf("invalid judge output: %s", output)
+	}
+	var verdict struct {
+		Score  float64 `json:"score"`
+		Reason string  `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(output[start:end+1]), &verdict); err != nil {
+		return 0, "", fmt.Errorf("invalid judge output: %w", err)
+	}
+	return math.Min(math.Max(verdict.Score, 0), 1), verdict.Reason, nil
+}
+
+// retrievalScores returns the recall of expected nodes in retrieved and the reciprocal rank of the first hit
+func retrievalScores(expected, retrieved []string) (float64, float64) {
+	expectedSet := lo.SliceToMap(expected, func(id string) (string, struct{}) { return id, struct{}{} })
+	hits, reciprocalRank := 0, 0.0
+	for i, id := range lo.Uniq(retrieved) {
+		if _, ok := expectedSet[id]; !ok {
+			continue
+		}
+		if hits == 0 {
+			reciprocalRank = 1 / float64(i+1)
+		}
+		hits++
+	}
+	return float64(hits) / float64(len(expectedSet)), reciprocalRank
+}
+
+func computeEvalMetrics(results []*domain.EvalRunResult, k int) domain.EvalMetrics {
+	metrics := domain.EvalMetrics{
+		QuestionCount: len(results),
+		K:             k,
+	}
+	var latencySum int64
+	answered := 0
+	for _, result := range results {
+		if result.Error != "" {
+			metrics.ErrorCount++
+		} else {
+			latencySum += result.LatencyMs
+			answered++
+		}
+		if result.Recall != nil && result.ReciprocalRank != nil {
+			metrics.RetrievalCount++
+			metrics.RecallAtK += *result.Recall
+			metrics.MRR += *result.ReciprocalRank
+		}
+		if result.Faithfulness != nil {
+			metrics.JudgedCount++
+			metrics.Faithfulness += *result.Faithfulness
+		}
+	}
+	if metrics.RetrievalCount > 0 {
+		metrics.RecallAtK /= float64(metrics.RetrievalCount)
+		metrics.MRR /= float64(metrics.RetrievalCount)
+	}
+	if metrics.JudgedCount > 0 {
+		metrics.Faithfulness /= float64(metrics.JudgedCount)
+	}
+	if answered > 0 {
+		metrics.AvgLatencyMs = latencySum / int64(answered)
+	}
+	return metrics
+}
+
+// failStaleEvalRuns marks the runs whose process has exited as failed before they are shown
+func (u *EvalUsecase) failStaleEvalRuns(ctx context.Context) {
+	if err := u.evalRepo.FailStaleEvalRuns(ctx, time.Now().Add(-domain.EvalRunLease)); err != nil {
+		u.logger.Warn("fail stale eval runs failed", log.Error(err))
+	}
+}
+
+func (u *EvalUsecase) ListEvalRuns(ctx context.Context, req *v1.EvalRunListReq) (*domain.PaginatedResult[[]*domain.EvalRun], error) {
+	u.failStaleEvalRuns(ctx)
+	runs, total, err := u.evalRepo.ListEvalRuns(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPaginatedResult(runs, uint64(total)), nil
+}
+
+func (u *EvalUsecase) GetEvalRunDetail(ctx context.Context, kbID, id string) (*v1.EvalRunDetailResp, error) {
+	u.failStaleEvalRuns(ctx)
+	run, err := u.evalRepo.GetEvalRun(ctx, kbID, id)
+	if err != nil {
+		return nil, err
+	}
+	results, err := u.evalRepo.ListEvalRunResults(ctx, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.EvalRunDetailResp{EvalRun: run, Results: results}, nil
+}
+
+// CancelEvalRun stops a running run, the results evaluated so far are kept
+func (u *EvalUsecase) CancelEvalRun(ctx context.Context, kbID, id string) error {
+	return u.evalRepo.CancelEvalRun(ctx, kbID, id)
+}
+
+// DeleteEvalRun deletes the run, a running run stops on its next heartbeat
+func (u *EvalUsecase) DeleteEvalRun(ctx context.Context, kbID, id string) error {
+	return u.evalRepo.DeleteEvalRun(ctx, kbID, id)
+}
+
+// CompareEvalRuns lines up the results of two runs by question
+func (u *EvalUsecase) CompareEvalRuns(ctx context.Context, req *v1.EvalRunCompareReq) (*v1.EvalRunCompareResp, error) {
+	base, err := u.GetEvalRunDetail(ctx, req.KbId, req.BaseId)
+	if err != nil {
+		return nil, fmt.Errorf("get base run failed: %w", err)
+	}
+	target, err := u.GetEvalRunDetail(ctx, req.KbId, req.TargetId)
+	if err != nil {
+		return nil, fmt.Errorf("get target run failed: %w", err)
+	}
+	resp := &v1.EvalRunCompareResp{
+		Base:   base.EvalRun,
+		Target: target.EvalRun,
+		Delta: v1.EvalMetricsDelta{
+			RecallAtK:    target.Metrics.RecallAtK - base.Metrics.RecallAtK,
+			MRR:          target.Metrics.MRR - base.Metrics.MRR,
+			Faithfulness: target.Metrics.Faithfulness - base.Metrics.Faithfulness,
+			AvgLatencyMs: target.Metrics.AvgLatencyMs - base.Metrics.AvgLatencyMs,
+		},
+		Items: make([]*v1.EvalRunCompareItem, 0, len(base.Results)),
+	}
+	items := make(map[string]*v1.EvalRunCompareItem)
+	for _, result := range base.Results {
+		item := &v1.EvalRunCompareItem{QuestionID: result.QuestionID, Question: result.Question, Base: result}
+		items[result.QuestionID] = item
+		resp.Items = append(resp.Items, item)
+	}
+	for _, result := range target.Results {
+		if item, ok := items[result.QuestionID]; ok {
+			item.Target = result
+			continue
+		}
+		resp.Items = append(resp.Items, &v1.EvalRunCompareItem{QuestionID: result.QuestionID, Question: result.Question, Target: result})
+	}
+	return resp, nil
+}
@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrievalScores(t *testing.T) {
+	recall, reciprocalRank := retrievalScores([]string{"a", "b"}, []string{"c", "b", "d", "a"})
+	assert.Equal(t, 1.0, recall)
+	assert.Equal(t, 0.5, reciprocalRank)
+
+	recall, reciprocalRank = retrievalScores([]string{"a", "b"}, []string{"c", "a"})
+	assert.Equal(t, 0.5, recall)
+	assert.Equal(t, 0.5, reciprocalRank)
+
+	recall, reciprocalRank = retrievalScores([]string{"a"}, []string{"c"})
+	assert.Zero(t, recall)
+	assert.Zero(t, reciprocalRank)
+}
+
+func TestParseJudgeOutput(t *testing.T) {
+	score, reason, err := parseJudgeOutput("```json\n{\"score\": 1.5, \"reason\": \"ok\"}\n```")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, score)
+	assert.Equal(t, "ok", reason)
+
+	_, _, err = parseJudgeOutput("no verdict")
+	assert.Error(t, err)
+}
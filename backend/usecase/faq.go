@@ -0,0 +1,221 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/samber/lo"
+
+	v1 "github.com/chaitin/panda-wiki/api/faq/v1"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+type FAQUsecase struct {
+	repo         *pg.FAQRepository
+	nodeRepo     *pg.NodeRepository
+	modelUsecase *ModelUsecase
+	logger       *log.Logger
+}
+
+func NewFAQUsecase(repo *pg.FAQRepository, nodeRepo *pg.NodeRepository, modelUsecase *ModelUsecase, logger *log.Logger) *FAQUsecase {
+	return &FAQUsecase{
+		repo:         repo,
+		nodeRepo:     nodeRepo,
+		modelUsecase: modelUsecase,
+		logger:       logger.WithModule("usecase.faq"),
+	}
+}
+
+func (u *FAQUsecase) List(ctx context.Context, req *v1.FAQListReq) ([]*domain.FAQ, error) {
+	return u.repo.List(ctx, req.KbId, req.Question)
+}
+
+func (u *FAQUsecase) Create(ctx context.Context, req *v1.FAQCreateReq) (*domain.FAQ, error) {
+	now := time.Now()
+	faq := &domain.FAQ{
+		ID:         uuid.New().String(),
+		KBID:       req.KbId,
+		Questions:  req.Questions,
+		Embeddings: u.embedQuestions(ctx, req.KbId, req.Questions),
+		Answer:     req.Answer,
+		NodeIDs:    pq.StringArray(lo.Uniq(req.NodeIds)),
+		Threshold:  req.Threshold,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if faq.NodeIDs == nil {
+		faq.NodeIDs = pq.StringArray{}
+	}
+	if err := u.repo.Create(ctx, faq); err != nil {
+		return nil, err
+	}
+	return faq, nil
+}
+
+func (u *FAQUsecase) Update(ctx context.Context, req *v1.FAQUpdateReq) error {
+	if _, err := u.repo.Get(ctx, req.KbId, req.ID); err != nil {
+		return err
+	}
+	updateMap := map[string]any{}
+	if len(req.Questions) > 0 {
+		updateMap["questions"] = pq.StringArray(req.Questions)
+		updateMap["embeddings"] = u.embedQuestions(ctx, req.KbId, req.Questions)
+	}
+	if req.Answer != nil {
+		updateMap["answer"] = *req.Answer
+	}
+	if req.NodeIds != nil {
+		updateMap["node_ids"] = pq.StringArray(lo.Uniq(req.NodeIds))
+	}
+	if req.Threshold != nil {
+		updateMap["threshold"] = *req.Threshold
+	}
+	if req.Enabled != nil {
+		updateMap["enabled"] = *req.Enabled
+	}
+	return u.repo.Update(ctx, req.KbId, req.ID, updateMap)
+}
+
+func (u *FAQUsecase) Delete(ctx context.Context, req *v1.FAQDeleteReq) error {
+	return u.repo.Delete(ctx, req.KbId, req.ID)
+}
+
+// embedQuestions returns nil when the embedding model fails, the faq is then matched by question text only
+func (u *FAQUsecase) embedQuestions(ctx context.Context, kbID string, questions []string) domain.FAQEmbeddings {
+	embeddings, err := u.modelUsecase.Embed(ctx, questions)
+	if err != nil || len(embeddings) != len(questions) {
+		u.logger.Warn("embed faq questions failed, match by question text only", log.String("kb_id", kbID), log.Error(err))
+		return nil
+	}
+	return embeddings
+}
+
+// Match returns the enabled faq whose question variants best match the question,
+// resp.Matched is false when none reaches its threshold
+func (u *FAQUsecase) Match(ctx context.Context, kbID, question string) (*v1.FAQMatchResp, error) {
+	faqs, err := u.repo.ListEnabled(ctx, kbID)
+	if err != nil {
+		return nil, err
+	}
+	return u.match(ctx, kbID, faqs, newQuestionEmbedding(u.modelUsecase, question)), nil
+}
+
+// MatchAnswerable matches the question against the faqs the auth groups may get answers from,
+// a faq linked to a document which is not answerable for the groups is skipped
+func (u *FAQUsecase) MatchAnswerable(ctx context.Context, kbID string, groupIDs []int, question *questionEmbedding) (*v1.FAQMatchResp, error) {
+	faqs, err := u.repo.ListEnabled(ctx, kbID)
+	if err != nil {
+		return nil, err
+	}
+	nodeIDs := lo.Uniq(lo.FlatMap(faqs, func(faq *domain.FAQ, _ int) []string {
+		return faq.NodeIDs
+	}))
+	restricted, err := u.nodeRepo.GetUnanswerableNodeIDs(ctx, kbID, nodeIDs, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(restricted) > 0 {
+		faqs = lo.Filter(faqs, func(faq *domain.FAQ, _ int) bool {
+			return !lo.Some(faq.NodeIDs, restricted)
+		})
+	}
+	return u.match(ctx, kbID, faqs, question), nil
+}
+
+func (u *FAQUsecase) match(ctx context.Context, kbID string, faqs []*domain.FAQ, question *questionEmbedding) *v1.FAQMatchResp {
+	resp := &v1.FAQMatchResp{}
+	if matchFAQByText(faqs, question.text, resp) {
+		return resp
+	}
+	if !lo.SomeBy(faqs, func(faq *domain.FAQ) bool { return len(faq.Embeddings) > 0 }) {
+		return resp
+	}
+	embedding, err := question.Get(ctx)
+	if err != nil {
+		u.logger.Warn("embed question for faq failed", log.String("kb_id", kbID), log.Error(err))
+		resp.EmbeddingError = err.Error()
+		return resp
+	}
+	matchFAQBySimilarity(faqs, embedding, resp)
+	return resp
+}
+
+// matchFAQByText reports whether a question variant equals the question ignoring case, whitespace and punctuation
+func matchFAQByText(faqs []*domain.FAQ, question string, resp *v1.FAQMatchResp) bool {
+	normalized := domain.NormalizeQuestion(question)
+	if normalized == "" {
+		return false
+	}
+	for _, faq := range faqs {
+		for _, variant := range faq.Questions {
+			if domain.NormalizeQuestion(variant) == normalized {
+				resp.Matched, resp.FAQ, resp.MatchedQuestion, resp.Score = true, faq, variant, 1
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchFAQBySimilarity picks the most similar question variant which reaches the threshold of its faq
+func matchFAQBySimilarity(faqs []*domain.FAQ, embedding []float32, resp *v1.FAQMatchResp) {
+	vector := normalizeVector(embedding)
+	for _, faq := range faqs {
+		for i, variant := range faq.Questions {
+			if i >= len(faq.Embeddings) {
+				break
+			}
+			score := cosineSimilarity(vector, normalizeVector(faq.Embeddings[i]))
+			if score >= faq.SimilarityThreshold() && score > resp.Score {
+				resp.Matched, resp.FAQ, resp.MatchedQuestion, resp.Score = true, faq, variant, score
+			}
+		}
+	}
+}
+
+func (u *FAQUsecase) RecordHit(ctx context.Context, id string) {
+	if err := u.repo.RecordHit(ctx, id); err != nil {
+		u.logger.Warn("record faq hit failed", log.String("id", id), log.Error(err))
+	}
+}
+
+// LinkedChunkResults returns the linked documents of the faq which the auth groups may get answers from
+func (u *FAQUsecase) LinkedChunkResults(ctx context.Context, faq *domain.FAQ, groupIDs []int) ([]*domain.NodeContentChunkSSE, error) {
+	nodeReleases, err := u.nodeRepo.GetAnswerableNodeReleasesByNodeIDs(ctx, faq.KBID, faq.NodeIDs, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := u.nodeRepo.GetNodeReleasePathsByIDs(ctx, lo.Map(nodeReleases, func(nodeRelease *domain.NodeRelease, _ int) string {
+		return nodeRelease.ID
+	}))
+	if err != nil {
+		return nil, err
+	}
+	byNodeID := lo.KeyBy(nodeReleases, func(nodeRelease *domain.NodeRelease) string {
+		return nodeRelease.NodeID
+	})
+	// 按关联时的顺序返回
+	chunkResults := make([]*domain.NodeContentChunkSSE, 0, len(nodeReleases))
+	for _, nodeID := range faq.NodeIDs {
+		nodeRelease, ok := byNodeID[nodeID]
+		if !ok {
+			continue
+		}
+		chunkResult := &domain.NodeContentChunkSSE{
+			NodeID:  nodeRelease.NodeID,
+			Name:    nodeRelease.Name,
+			Summary: nodeRelease.Meta.Summary,
+			Emoji:   nodeRelease.Meta.Emoji,
+		}
+		if path, ok := paths[nodeRelease.ID]; ok {
+			chunkResult.NodePathNames = path.PathNames
+		}
+		chunkResults = append(chunkResults, chunkResult)
+	}
+	return chunkResults, nil
+}
@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	v1 "github.com/chaitin/panda-wiki/api/faq/v1"
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+func TestMatchFAQ(t *testing.T) {
+	refund := &domain.FAQ{
+		ID:         "refund",
+		Questions:  []string{"退款政策是什么？", "如何退款"},
+		Embeddings: domain.FAQEmbeddings{{1, 0, 0}, {0.9, 0.1, 0}},
+	}
+	sla := &domain.FAQ{
+		ID:         "sla",
+		Questions:  []string{"SLA 是多少"},
+		Embeddings: domain.FAQEmbeddings{{0, 1, 0}},
+		Threshold:  0.99,
+	}
+	faqs := []*domain.FAQ{refund, sla}
+
+	resp := &v1.FAQMatchResp{}
+	assert.True(t, matchFAQByText(faqs, "sla是多少?", resp))
+	assert.Equal(t, "sla", resp.FAQ.ID)
+
+	resp = &v1.FAQMatchResp{}
+	matchFAQBySimilarity(faqs, []float32{1, 0.02, 0}, resp)
+	assert.True(t, resp.Matched)
+	assert.Equal(t, "refund", resp.FAQ.ID)
+	assert.Equal(t, "退款政策是什么？", resp.MatchedQuestion)
+
+	// below the threshold of the faq
+	resp = &v1.FAQMatchResp{}
+	matchFAQBySimilarity(faqs, []float32{0.3, 1, 0}, resp)
+	assert.False(t, resp.Matched)
+}
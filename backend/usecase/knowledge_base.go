@@ -3,9 +3,12 @@ package usecase
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/samber/lo"
 
 	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
 	"github.com/chaitin/panda-wiki/config"
@@ -16,29 +19,34 @@ import (
 	"github.com/chaitin/panda-wiki/repo/mq"
 	"github.com/chaitin/panda-wiki/repo/pg"
 	"github.com/chaitin/panda-wiki/store/rag"
+	"github.com/chaitin/panda-wiki/utils"
 )
 
 type KnowledgeBaseUsecase struct {
-	repo     *pg.KnowledgeBaseRepository
-	nodeRepo *pg.NodeRepository
-	ragRepo  *mq.RAGRepository
-	userRepo *pg.UserRepository
-	rag      rag.RAGService
-	kbCache  *cache.KBRepo
-	logger   *log.Logger
-	config   *config.Config
+	repo        *pg.KnowledgeBaseRepository
+	nodeRepo    *pg.NodeRepository
+	ragRepo     *mq.RAGRepository
+	userRepo    *pg.UserRepository
+	webhookRepo *mq.WebhookRepository
+	auditRepo   *pg.AuditLogRepository
+	rag         rag.RAGService
+	kbCache     *cache.KBRepo
+	logger      *log.Logger
+	config      *config.Config
 }
 
-func NewKnowledgeBaseUsecase(repo *pg.KnowledgeBaseRepository, nodeRepo *pg.NodeRepository, ragRepo *mq.RAGRepository, userRepo *pg.UserRepository, rag rag.RAGService, kbCache *cache.KBRepo, logger *log.Logger, config *config.Config) (*KnowledgeBaseUsecase, error) {
+func NewKnowledgeBaseUsecase(repo *pg.KnowledgeBaseRepository, nodeRepo *pg.NodeRepository, ragRepo *mq.RAGRepository, userRepo *pg.UserRepository, rag rag.RAGService, kbCache *cache.KBRepo, logger *log.Logger, config *config.Config, webhookRepo *mq.WebhookRepository, auditRepo *pg.AuditLogRepository) (*KnowledgeBaseUsecase, error) {
 	u := &KnowledgeBaseUsecase{
-		repo:     repo,
-		nodeRepo: nodeRepo,
-		ragRepo:  ragRepo,
-		userRepo: userRepo,
-		rag:      rag,
-		logger:   logger.WithModule("usecase.knowledge_base"),
-		config:   config,
-		kbCache:  kbCache,
+		repo:        repo,
+		nodeRepo:    nodeRepo,
+		ragRepo:     ragRepo,
+		userRepo:    userRepo,
+		rag:         rag,
+		logger:      logger.WithModule("usecase.knowledge_base"),
+		config:      config,
+		kbCache:     kbCache,
+		webhookRepo: webhookRepo,
+		auditRepo:   auditRepo,
 	}
 	return u, nil
 }
@@ -86,10 +94,22 @@ func (u *KnowledgeBaseUsecase) GetKnowledgeBaseListByUserId(ctx context.Context)
 }
 
 func (u *KnowledgeBaseUsecase) UpdateKnowledgeBase(ctx context.Context, req *domain.UpdateKnowledgeBaseReq) error {
+	before, err := u.repo.GetKnowledgeBaseByID(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	if err := req.ChatModel.KeepAPIKey(before.ChatModel); err != nil {
+		return err
+	}
 	isChange, err := u.repo.UpdateKnowledgeBase(ctx, req)
 	if err != nil {
 		return err
 	}
+	after, err := u.repo.GetKnowledgeBaseByID(ctx, req.ID)
+	if err != nil {
+		u.logger.Warn("get knowledge base for audit log failed", log.String("kb_id", req.ID), log.Error(err))
+	}
+	recordAuditLog(ctx, u.logger, u.auditRepo, req.ID, domain.AuditActionKBUpdate, domain.AuditTargetKB, req.ID, before, after)
 
 	if isChange {
 		if err := u.kbCache.ClearSession(ctx); err != nil {
@@ -180,6 +200,7 @@ func (u *KnowledgeBaseUsecase) CreateKBRelease(ctx context.Context, req *domain.
 	if err := u.repo.CreateKBRelease(ctx, release); err != nil {
 		return "", fmt.Errorf("failed to create kb release: %w", err)
 	}
+	publishKBReleaseWebhookEvents(ctx, u.logger, u.webhookRepo, release, req.NodeIDs)
 
 	return release.ID, nil
 }
@@ -193,6 +214,183 @@ func (u *KnowledgeBaseUsecase) GetKBReleaseList(ctx context.Context, req *domain
 	return domain.NewPaginatedResult(releases, uint64(total)), nil
 }
 
+// DiffKBRelease compares the node releases of two kb releases
+func (u *KnowledgeBaseUsecase) DiffKBRelease(ctx context.Context, req *v1.KBReleaseDiffReq) (*v1.KBReleaseDiffResp, error) {
+	oldRelease, err := u.repo.GetKBReleaseByID(ctx, req.KBId, req.OldReleaseId)
+	if err != nil {
+		return nil, fmt.Errorf("get old kb release failed: %w", err)
+	}
+	var newRelease *domain.KBRelease
+	if req.NewReleaseId == "" {
+		newRelease, err = u.repo.GetLatestRelease(ctx, req.KBId)
+	} else {
+		newRelease, err = u.repo.GetKBReleaseByID(ctx, req.KBId, req.NewReleaseId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get new kb release failed: %w", err)
+	}
+
+	oldNodeReleases, err := u.repo.GetKBReleaseNodeReleases(ctx, oldRelease.ID)
+	if err != nil {
+		return nil, err
+	}
+	newNodeReleases, err := u.repo.GetKBReleaseNodeReleases(ctx, newRelease.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &v1.KBReleaseDiffResp{
+		Old:      newKBReleaseDiffSide(oldRelease),
+		New:      newKBReleaseDiffSide(newRelease),
+		Added:    make([]*v1.KBReleaseDiffNode, 0),
+		Removed:  make([]*v1.KBReleaseDiffNode, 0),
+		Modified: make([]*v1.KBReleaseDiffNode, 0),
+	}
+	for nodeID, newNodeRelease := range newNodeReleases {
+		oldNodeRelease, ok := oldNodeReleases[nodeID]
+		if !ok {
+			resp.Added = append(resp.Added, &v1.KBReleaseDiffNode{
+				NodeID:           nodeID,
+				Name:             newNodeRelease.Name,
+				Type:             newNodeRelease.Type,
+				NewNodeReleaseID: newNodeRelease.ID,
+			})
+			continue
+		}
+		if oldNodeRelease.ID == newNodeRelease.ID {
+			continue
+		}
+		node, err := u.diffNodeRelease(ctx, oldNodeRelease.ID, newNodeRelease.ID, req.WithLines)
+		if err != nil {
+			return nil, err
+		}
+		// node releases differ only in position are not treated as modified
+		if node.NameChanged || node.Stats.Added > 0 || node.Stats.Removed > 0 {
+			resp.Modified = append(resp.Modified, node)
+		}
+	}
+	for nodeID, oldNodeRelease := range oldNodeReleases {
+		if _, ok := newNodeReleases[nodeID]; !ok {
+			resp.Removed = append(resp.Removed, &v1.KBReleaseDiffNode{
+				NodeID:           nodeID,
+				Name:             oldNodeRelease.Name,
+				Type:             oldNodeRelease.Type,
+				OldNodeReleaseID: oldNodeRelease.ID,
+			})
+		}
+	}
+	for _, nodes := range [][]*v1.KBReleaseDiffNode{resp.Added, resp.Removed, resp.Modified} {
+		slices.SortFunc(nodes, func(a, b *v1.KBReleaseDiffNode) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+	}
+	return resp, nil
+}
+
+func (u *KnowledgeBaseUsecase) diffNodeRelease(ctx context.Context, oldID, newID string, withLines bool) (*v1.KBReleaseDiffNode, error) {
+	oldNodeRelease, err := u.nodeRepo.GetNodeReleaseByID(ctx, oldID)
+	if err != nil {
+		return nil, fmt.Errorf("get node release %s failed: %w", oldID, err)
+	}
+	newNodeRelease, err := u.nodeRepo.GetNodeReleaseByID(ctx, newID)
+	if err != nil {
+		return nil, fmt.Errorf("get node release %s failed: %w", newID, err)
+	}
+	lines, stats := utils.DiffContent(oldNodeRelease.Content, newNodeRelease.Content)
+	node := &v1.KBReleaseDiffNode{
+		NodeID:           newNodeRelease.NodeID,
+		Name:             newNodeRelease.Name,
+		Type:             newNodeRelease.Type,
+		OldNodeReleaseID: oldNodeRelease.ID,
+		NewNodeReleaseID: newNodeRelease.ID,
+		OldName:          oldNodeRelease.Name,
+		NameChanged:      oldNodeRelease.Name != newNodeRelease.Name,
+		Stats:            stats,
+	}
+	if withLines {
+		node.Lines = lines
+	}
+	return node, nil
+}
+
+func newKBReleaseDiffSide(release *domain.KBRelease) v1.KBReleaseDiffSide {
+	return v1.KBReleaseDiffSide{
+		ID:        release.ID,
+		Tag:       release.Tag,
+		Message:   release.Message,
+		CreatedAt: release.CreatedAt,
+	}
+}
+
+// RollbackKBRelease creates a new kb release with the node releases of the target release,
+// so the shared reader and the rag index serve the target release again
+func (u *KnowledgeBaseUsecase) RollbackKBRelease(ctx context.Context, req *v1.KBReleaseRollbackReq, userId string) (string, error) {
+	target, err := u.repo.GetKBReleaseByID(ctx, req.KBId, req.ID)
+	if err != nil {
+		return "", fmt.Errorf("get kb release failed: %w", err)
+	}
+	nodeReleases, err := u.repo.GetKBReleaseNodeReleases(ctx, target.ID)
+	if err != nil {
+		return "", err
+	}
+
+	message := req.Message
+	if message == "" {
+		message = fmt.Sprintf("回滚到版本 %s", target.Tag)
+	}
+	release := &domain.KBRelease{
+		ID:          uuid.New().String(),
+		KBID:        req.KBId,
+		Message:     message,
+		Tag:         fmt.Sprintf("rollback-%s", time.Now().Format("20060102150405")),
+		PublisherId: userId,
+		CreatedAt:   time.Now(),
+	}
+	if err := u.repo.CreateKBReleaseFromRelease(ctx, release, target.ID); err != nil {
+		return "", fmt.Errorf("failed to create kb release: %w", err)
+	}
+
+	nodeReleaseIDs := make(map[string]string, len(nodeReleases))
+	vectorRequests := make([]*domain.NodeReleaseVectorRequest, 0)
+	for nodeID, nodeRelease := range nodeReleases {
+		nodeReleaseIDs[nodeID] = nodeRelease.ID
+		// upserting a node release removes the other releases of the node from rag
+		if nodeRelease.DocID == "" && nodeRelease.Type != domain.NodeTypeFolder {
+			vectorRequests = append(vectorRequests, &domain.NodeReleaseVectorRequest{
+				KBID:          req.KBId,
+				NodeReleaseID: nodeRelease.ID,
+				NodeID:        nodeID,
+				Action:        "upsert",
+			})
+		}
+	}
+	docIDs, err := u.nodeRepo.DetachNodeReleaseDocIDsExcept(ctx, req.KBId, lo.Keys(nodeReleaseIDs))
+	if err != nil {
+		return "", fmt.Errorf("detach node release docs failed: %w", err)
+	}
+	for _, docID := range docIDs {
+		vectorRequests = append(vectorRequests, &domain.NodeReleaseVectorRequest{
+			KBID:   req.KBId,
+			DocID:  docID,
+			Action: "delete",
+		})
+	}
+	if len(vectorRequests) > 0 {
+		if err := u.ragRepo.AsyncUpdateNodeReleaseVector(ctx, vectorRequests); err != nil {
+			return "", err
+		}
+	}
+
+	if err := u.nodeRepo.SyncNodeStatusWithRelease(ctx, req.KBId, nodeReleaseIDs); err != nil {
+		return "", fmt.Errorf("sync node status failed: %w", err)
+	}
+	// 回滚后目标版本的文档重新对外发布
+	nodeIDs := lo.Keys(nodeReleaseIDs)
+	slices.Sort(nodeIDs)
+	publishKBReleaseWebhookEvents(ctx, u.logger, u.webhookRepo, release, nodeIDs)
+	return release.ID, nil
+}
+
 func (u *KnowledgeBaseUsecase) GetKBUserList(ctx context.Context, req v1.KBUserListReq) ([]v1.KBUserListItemResp, error) {
 	users, err := u.repo.GetKBUserlist(ctx, req.KBId)
 	if err != nil {
@@ -211,14 +409,16 @@ func (u *KnowledgeBaseUsecase) KBUserInvite(ctx context.Context, req v1.KBUserIn
 		return fmt.Errorf("knowledge base can not invite to admin user")
 	}
 
-	if err := u.repo.CreateKBUser(ctx, &domain.KBUsers{
+	kbUser := &domain.KBUsers{
 		KBId:      req.KBId,
 		UserId:    req.UserId,
 		Perm:      req.Perm,
 		CreatedAt: time.Now(),
-	}); err != nil {
+	}
+	if err := u.repo.CreateKBUser(ctx, kbUser); err != nil {
 		return err
 	}
+	recordAuditLog(ctx, u.logger, u.auditRepo, req.KBId, domain.AuditActionKBUserInvite, domain.AuditTargetKBUser, req.UserId, nil, kbUser)
 
 	return nil
 }
@@ -249,7 +449,13 @@ func (u *KnowledgeBaseUsecase) UpdateUserKB(ctx context.Context, req v1.KBUserUp
 			return fmt.Errorf("only admin can update user from knowledge base")
 		}
 	}
-	return u.repo.UpdateKBUserPerm(ctx, req.KBId, req.UserId, req.Perm)
+	if err := u.repo.UpdateKBUserPerm(ctx, req.KBId, req.UserId, req.Perm); err != nil {
+		return err
+	}
+	after := *kbUser
+	after.Perm = req.Perm
+	recordAuditLog(ctx, u.logger, u.auditRepo, req.KBId, domain.AuditActionKBUserUpdate, domain.AuditTargetKBUser, req.UserId, kbUser, &after)
+	return nil
 }
 
 func (u *KnowledgeBaseUsecase) KBUserDelete(ctx context.Context, req v1.KBUserDeleteReq) error {
@@ -281,6 +487,7 @@ func (u *KnowledgeBaseUsecase) KBUserDelete(ctx context.Context, req v1.KBUserDe
 	if err := u.repo.DeleteKBUser(ctx, req.KBId, req.UserId); err != nil {
 		return err
 	}
+	recordAuditLog(ctx, u.logger, u.auditRepo, req.KBId, domain.AuditActionKBUserRemove, domain.AuditTargetKBUser, req.UserId, kbUser, nil)
 
 	return nil
 }
@@ -0,0 +1,281 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/samber/lo"
+
+	v1 "github.com/chaitin/panda-wiki/api/conversation/v1"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+const (
+	knowledgeGapDefaultDays      = 30
+	knowledgeGapDefaultThreshold = 0.85
+	// knowledgeGapMaxMessages bounds the questions embedded for one report
+	knowledgeGapMaxMessages = 500
+)
+
+type KnowledgeGapUsecase struct {
+	conversationRepo *pg.ConversationRepository
+	nodeRepo         *pg.NodeRepository
+	llmUsecase       *LLMUsecase
+	modelUsecase     *ModelUsecase
+	logger           *log.Logger
+}
+
+func NewKnowledgeGapUsecase(conversationRepo *pg.ConversationRepository, nodeRepo *pg.NodeRepository, llmUsecase *LLMUsecase, modelUsecase *ModelUsecase, logger *log.Logger) *KnowledgeGapUsecase {
+	return &KnowledgeGapUsecase{
+		conversationRepo: conversationRepo,
+		nodeRepo:         nodeRepo,
+		llmUsecase:       llmUsecase,
+		modelUsecase:     modelUsecase,
+		logger:           logger.WithModule("usecase.knowledge_gap"),
+	}
+}
+
+// GetReport clusters the recent questions which the knowledge base failed to answer
+func (u *KnowledgeGapUsecase) GetReport(ctx context.Context, req *v1.KnowledgeGapReportReq) (*v1.KnowledgeGapReportResp, error) {
+	days := req.Days
+	if days == 0 {
+		days = knowledgeGapDefaultDays
+	}
+	threshold := req.Threshold
+	if threshold == 0 {
+		threshold = knowledgeGapDefaultThreshold
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	messages, err := u.conversationRepo.GetKnowledgeGapMessages(ctx, req.KbId, since, domain.UnansweredAnswerPatterns, knowledgeGapMaxMessages)
+	if err != nil {
+		return nil, fmt.Errorf("get knowledge gap messages failed: %w", err)
+	}
+	resp := &v1.KnowledgeGapReportResp{
+		Total:    len(messages),
+		Clusters: make([]*v1.KnowledgeGapCluster, 0),
+	}
+	if len(messages) == 0 {
+		return resp, nil
+	}
+	questions := lo.Map(messages, func(msg *pg.KnowledgeGapMessage, _ int) *v1.KnowledgeGapQuestion {
+		return &v1.KnowledgeGapQuestion{
+			MessageId:      msg.MessageID,
+			ConversationId: msg.ConversationID,
+			Question:       msg.Question,
+			Answer:         msg.Answer,
+			Reasons:        knowledgeGapReasons(msg),
+			CreatedAt:      msg.CreatedAt,
+		}
+	})
+
+	var groups [][]int
+	embeddings, err := u.modelUsecase.Embed(ctx, lo.Map(questions, func(q *v1.KnowledgeGapQuestion, _ int) string {
+		return q.Question
+	}))
+	if err != nil {
+		u.logger.Warn("embed knowledge gap questions failed, group by question text", log.String("kb_id", req.KbId), log.Error(err))
+		resp.EmbeddingError = err.Error()
+		groups = groupByText(questions)
+	} else {
+		groups = clusterEmbeddings(embeddings, threshold)
+	}
+
+	for _, group := range groups {
+		cluster := &v1.KnowledgeGapCluster{
+			Count:     len(group),
+			Reasons:   make(map[v1.KnowledgeGapReason]int),
+			Questions: make([]*v1.KnowledgeGapQuestion, 0, len(group)),
+		}
+		for _, i := range group {
+			question := questions[i]
+			cluster.Questions = append(cluster.Questions, question)
+			for _, reason := range question.Reasons {
+				cluster.Reasons[reason]++
+			}
+			if question.CreatedAt.After(cluster.LatestAt) {
+				cluster.LatestAt = question.CreatedAt
+			}
+		}
+		// the first member is the closest to the centroid
+		cluster.Question = questions[group[0]].Question
+		resp.Clusters = append(resp.Clusters, cluster)
+	}
+	sort.SliceStable(resp.Clusters, func(i, j int) bool {
+		if resp.Clusters[i].Count != resp.Clusters[j].Count {
+			return resp.Clusters[i].Count > resp.Clusters[j].Count
+		}
+		return resp.Clusters[i].LatestAt.After(resp.Clusters[j].LatestAt)
+	})
+	return resp, nil
+}
+
+// CreateDraft creates a document whose content is an outline generated from the questions,
+// the document is a draft until the editor publishes it
+func (u *KnowledgeGapUsecase) CreateDraft(ctx context.Context, req *v1.KnowledgeGapDraftReq, userID string, maxNode int) (*v1.KnowledgeGapDraftResp, error) {
+	model, err := u.modelUsecase.GetKBChatModel(ctx, req.KbId, nil)
+	if err != nil {
+		u.logger.Error("get chat model failed", log.Error(err))
+		return nil, domain.ErrModelNotConfigured
+	}
+	modelkitModel, err := model.ToModelkitModel()
+	if err != nil {
+		return nil, err
+	}
+	chatModel, err := u.llmUsecase.modelkit.GetChatModel(ctx, modelkitModel)
+	if err != nil {
+		return nil, err
+	}
+	questions := strings.Builder{}
+	for i, question := range req.Questions {
+		questions.WriteString(fmt.Sprintf("%d. %s\n", i+1, question))
+	}
+	outline, err := u.llmUsecase.Generate(ctx, chatModel, []*schema.Message{
+		schema.SystemMessage(domain.SystemKnowledgeGapOutlinePrompt),
+		schema.UserMessage(questions.String()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("generate outline failed: %w", err)
+	}
+	title, content := splitOutlineTitle(u.llmUsecase.trimThinking(outline))
+	name := req.Name
+	if name == "" {
+		name = title
+	}
+	if name == "" {
+		name = req.Questions[0]
+	}
+
+	contentType := domain.ContentTypeMD
+	nodeID, err := u.nodeRepo.Create(ctx, &domain.CreateNodeReq{
+		KBID:        req.KbId,
+		ParentID:    req.ParentId,
+		Type:        domain.NodeTypeDocument,
+		Name:        name,
+		Content:     content,
+		ContentType: &contentType,
+		MaxNode:     maxNode,
+	}, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.KnowledgeGapDraftResp{
+		NodeId:  nodeID,
+		Name:    name,
+		Content: content,
+	}, nil
+}
+
+func knowledgeGapReasons(msg *pg.KnowledgeGapMessage) []v1.KnowledgeGapReason {
+	reasons := make([]v1.KnowledgeGapReason, 0, 3)
+	if msg.RetrievedCount != nil && *msg.RetrievedCount == 0 {
+		reasons = append(reasons, v1.KnowledgeGapReasonNoRetrieval)
+	}
+	if msg.Score == domain.DisLike {
+		reasons = append(reasons, v1.KnowledgeGapReasonDisliked)
+	}
+	if domain.IsUnansweredAnswer(msg.Answer) {
+		reasons = append(reasons, v1.KnowledgeGapReasonUnanswered)
+	}
+	return reasons
+}
+
+// splitOutlineTitle takes the leading "# title" line of the outline as the title
+func splitOutlineTitle(outline string) (string, string) {
+	outline = strings.TrimSpace(outline)
+	firstLine, rest, _ := strings.Cut(outline, "\n")
+	if title, ok := strings.CutPrefix(strings.TrimSpace(firstLine), "# "); ok {
+		return strings.TrimSpace(title), strings.TrimSpace(rest)
+	}
+	return "", outline
+}
+
+// clusterEmbeddings groups the vectors whose cosine similarity to the centroid of a group reaches threshold,
+// each group is ordered by the similarity to its centroid
+func clusterEmbeddings(embeddings [][]float32, threshold float64) [][]int {
+	type cluster struct {
+		sum     []float64
+		members []int
+	}
+	vectors := lo.Map(embeddings, func(embedding []float32, _ int) []float64 {
+		return normalizeVector(embedding)
+	})
+	clusters := make([]*cluster, 0)
+	for i, vector := range vectors {
+		var best *cluster
+		bestScore := threshold
+		for _, c := range clusters {
+			if score := cosineSimilarity(vector, c.sum); score >= bestScore {
+				best, bestScore = c, score
+			}
+		}
+		if best == nil {
+			best = &cluster{sum: make([]float64, len(vector))}
+			clusters = append(clusters, best)
+		}
+		for k := range vector {
+			if k < len(best.sum) {
+				best.sum[k] += vector[k]
+			}
+		}
+		best.members = append(best.members, i)
+	}
+	return lo.Map(clusters, func(c *cluster, _ int) []int {
+		sort.SliceStable(c.members, func(i, j int) bool {
+			return cosineSimilarity(vectors[c.members[i]], c.sum) > cosineSimilarity(vectors[c.members[j]], c.sum)
+		})
+		return c.members
+	})
+}
+
+// groupByText groups the questions with the same text ignoring case and surrounding punctuation
+func groupByText(questions []*v1.KnowledgeGapQuestion) [][]int {
+	groups := make([][]int, 0)
+	index := make(map[string]int)
+	for i, question := range questions {
+		key := strings.ToLower(strings.Trim(strings.TrimSpace(question.Question), "?？!！。.，, "))
+		if j, ok := index[key]; ok {
+			groups[j] = append(groups[j], i)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, []int{i})
+	}
+	return groups
+}
+
+func normalizeVector(embedding []float32) []float64 {
+	vector := make([]float64, len(embedding))
+	var norm float64
+	for i, v := range embedding {
+		vector[i] = float64(v)
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := 0; i < len(a) && i < len(b); i++ {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	v1 "github.com/chaitin/panda-wiki/api/conversation/v1"
+)
+
+func TestClusterEmbeddings(t *testing.T) {
+	embeddings := [][]float32{
+		{1, 0, 0},
+		{0, 1, 0},
+		{0.98, 0.05, 0},
+		{0, 0.99, 0.1},
+		{0, 0, 1},
+	}
+	groups := clusterEmbeddings(embeddings, 0.9)
+	if assert.Len(t, groups, 3) {
+		assert.ElementsMatch(t, []int{0, 2}, groups[0])
+		assert.ElementsMatch(t, []int{1, 3}, groups[1])
+		assert.Equal(t, []int{4}, groups[2])
+	}
+}
+
+func TestGroupByText(t *testing.T) {
+	questions := []*v1.KnowledgeGapQuestion{
+		{Question: "如何部署？"},
+		{Question: "怎么升级"},
+		{Question: " 如何部署 "},
+	}
+	assert.Equal(t, [][]int{{0, 2}, {1}}, groupByText(questions))
+}
+
+func TestSplitOutlineTitle(t *testing.T) {
+	title, content := splitOutlineTitle("# 部署指南\n\n## 环境要求\n")
+	assert.Equal(t, "部署指南", title)
+	assert.Equal(t, "## 环境要求", content)
+
+	title, content = splitOutlineTitle("## 环境要求")
+	assert.Empty(t, title)
+	assert.Equal(t, "## 环境要求", content)
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	modelkit "github.com/chaitin/ModelKit/v2/usecase"
@@ -35,6 +37,8 @@ type LLMUsecase struct {
 	config           *config.Config
 	logger           *log.Logger
 	modelkit         *modelkit.ModelKit
+	breaker          *circuitBreaker
+	summarizing      sync.Map // conversation id -> struct{}, 正在后台生成历史摘要的会话
 }
 
 const (
@@ -55,88 +59,157 @@ func NewLLMUsecase(config *config.Config, rag rag.RAGService, conversationRepo *
 		promptRepo:       promptRepo,
 		logger:           logger.WithModule("usecase.llm"),
 		modelkit:         modelkit,
+		breaker:          newCircuitBreaker(circuitFailureThreshold, circuitCooldown),
 	}
 }
 
-func (u *LLMUsecase) BuildConversationMessageWithRAG(
-	ctx context.Context,
-	conversationID string,
-	kbID string,
-	groupIDs []int,
-	systemPrompt string,
-) ([]*schema.Message, []*domain.RankedNodeChunks, error) {
-	messages := make([]*schema.Message, 0)
-	rankedNodes := make([]*domain.RankedNodeChunks, 0)
-
-	msgs, err := u.conversationRepo.GetConversationMessagesByID(ctx, conversationID)
+// BuildConversationMessageWithRAG builds the prompt of the conversation, req.Messages is the history kept by the caller
+// and the messages of the conversation follow it
+func (u *LLMUsecase) BuildConversationMessageWithRAG(ctx context.Context, req *BuildMessagesRequest) ([]*schema.Message, []*domain.RankedNodeChunks, error) {
+	msgs, err := u.conversationRepo.GetConversationMessagesByID(ctx, req.ConversationID)
 	if err != nil {
 		u.logger.Error("get conversation messages failed", log.Error(err))
 		return nil, nil, errors.New("get conversation messages failed")
 	}
-	if len(msgs) > 0 {
-		historyMessages := make([]*schema.Message, 0)
-		for _, msg := range msgs {
-			switch msg.Role {
-			case schema.Assistant:
-				historyMessages = append(historyMessages, schema.AssistantMessage(msg.Content, nil))
-			case schema.User:
-				content := u.formatMessageWithImages(msg.Content, msg.ImagePaths)
-				historyMessages = append(historyMessages, schema.UserMessage(content))
-			default:
-				continue
-			}
-		}
-		if len(historyMessages) > 0 {
-			question := historyMessages[len(historyMessages)-1].Content
-			var rewrittenQuery string
-			if systemPrompt == "" {
-				if settingPrompt, err := u.promptRepo.GetPrompt(ctx, kbID); err != nil {
-					u.logger.Error("get prompt from settings failed", log.Error(err))
-				} else {
-					if settingPrompt != "" {
-						systemPrompt = settingPrompt
-					} else {
-						systemPrompt = domain.SystemDefaultPrompt
-					}
-				}
-			}
+	buildReq := *req
+	buildReq.Messages = append(slices.Clone(req.Messages), msgs...)
+	result, err := u.BuildMessagesWithRAG(ctx, &buildReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.Messages, result.Nodes, nil
+}
 
-			template := prompt.FromMessages(schema.GoTemplate,
-				schema.SystemMessage(systemPrompt),
-				schema.UserMessage(domain.UserQuestionFormatter),
-			)
-			kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, kbID)
-			if err != nil {
-				u.logger.Error("get kb failed", log.Error(err))
-				return nil, nil, errors.New("get kb failed")
-			}
-			rewrittenQuery, rankedNodes, err = u.GetRankNodes(ctx, GetRankNodesRequest{
-				DatasetID:           kb.DatasetID,
-				Question:            question,
-				GroupIDs:            groupIDs,
-				SimilarityThreshold: 0.2,
-				HistoryMessages:     historyMessages[:len(historyMessages)-1],
-			})
-			if err != nil {
-				u.logger.Error("get rank nodes failed", log.Error(err))
-				return nil, nil, errors.New("get rank nodes failed")
-			}
-			documents := domain.FormatNodeChunks(rankedNodes, kb.AccessSettings.BaseURL)
-			u.logger.Debug("documents", log.String("documents", documents))
-
-			formattedMessages, err := template.Format(ctx, map[string]any{
-				"CurrentDate": time.Now().Format("2006-01-02"),
-				"Question":    rewrittenQuery,
-				"Documents":   documents,
-			})
-			if err != nil {
-				u.logger.Error("format messages failed", log.Error(err))
-				return nil, nil, errors.New("format messages failed")
+type BuildMessagesRequest struct {
+	Messages     []*domain.ConversationMessage // 对话历史，最后一条为待回答的问题
+	KBID         string
+	GroupIDs     []int
+	SystemPrompt string
+	// Model 用于计算 token 预算及生成历史摘要，为空时使用默认上下文长度
+	Model *domain.Model
+	// FallbackModels 为 Model 失败时依次尝试的模型，预算按其中输入空间最小的模型计算，保证任一模型回答时都不超出上下文
+	FallbackModels []*domain.Model
+	// ConversationID 不为空时，超出预算的早期对话会在后台合并到会话的滚动摘要中，否则直接丢弃
+	ConversationID string
+	// ExtraPrompt 追加到系统提示词之后，如工具使用说明和调用方的系统消息
+	ExtraPrompt string
+	// ExtraMessages 放在问题之后，如调用方回传的工具调用及结果
+	ExtraMessages []*schema.Message
+	// Tools 随消息发送的工具定义，只用于计算预算
+	Tools []*schema.ToolInfo
+}
+
+type BuildMessagesResult struct {
+	Messages []*schema.Message
+	Rank     *RankNodesResult           // 为空表示没有可回答的问题
+	Nodes    []*domain.RankedNodeChunks // 放入提示词的文档，可能因预算少于 Rank.Nodes
+	Budget   *domain.TokenBudget
+}
+
+// BuildMessagesWithRAG builds the prompt that answers the last user message with the retrieved documents,
+// documents and history are fitted into the context window of the model
+func (u *LLMUsecase) BuildMessagesWithRAG(ctx context.Context, req *BuildMessagesRequest) (*BuildMessagesResult, error) {
+	result := &BuildMessagesResult{
+		Messages: make([]*schema.Message, 0),
+		Nodes:    make([]*domain.RankedNodeChunks, 0),
+	}
+	historyMessages := make([]*schema.Message, 0)
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case schema.Assistant:
+			historyMessages = append(historyMessages, schema.AssistantMessage(msg.Content, nil))
+		case schema.User:
+			content := u.formatMessageWithImages(msg.Content, msg.ImagePaths)
+			historyMessages = append(historyMessages, schema.UserMessage(content))
+		default:
+			continue
+		}
+	}
+	if len(historyMessages) == 0 {
+		return result, nil
+	}
+	question := historyMessages[len(historyMessages)-1].Content
+	systemPrompt := req.SystemPrompt
+	if systemPrompt == "" {
+		if settingPrompt, err := u.promptRepo.GetPrompt(ctx, req.KBID); err != nil {
+			u.logger.Error("get prompt from settings failed", log.Error(err))
+		} else {
+			if settingPrompt != "" {
+				systemPrompt = settingPrompt
+			} else {
+				systemPrompt = domain.SystemDefaultPrompt
 			}
-			messages = slices.Insert(formattedMessages, 1, historyMessages[:len(historyMessages)-1]...)
 		}
 	}
-	return messages, rankedNodes, nil
+
+	template := prompt.FromMessages(schema.GoTemplate,
+		schema.SystemMessage(systemPrompt),
+		schema.UserMessage(domain.UserQuestionFormatter),
+	)
+	kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, req.KBID)
+	if err != nil {
+		u.logger.Error("get kb failed", log.Error(err))
+		return nil, errors.New("get kb failed")
+	}
+	rankReq := NewGetRankNodesRequest(kb, question, req.GroupIDs)
+	rankReq.HistoryMessages = historyMessages[:len(historyMessages)-1]
+	rankResult, err := u.RankNodes(ctx, rankReq)
+	if err != nil {
+		u.logger.Error("get rank nodes failed", log.Error(err))
+		return nil, errors.New("get rank nodes failed")
+	}
+	result.Rank = rankResult
+
+	counter, err := newTokenCounter()
+	if err != nil {
+		u.logger.Error("create token counter failed", log.Error(err))
+		return nil, errors.New("create token counter failed")
+	}
+	variables := map[string]any{
+		"CurrentDate": time.Now().Format("2006-01-02"),
+		"Question":    rankResult.RewrittenQuery,
+		"Documents":   "",
+	}
+	formattedMessages, err := template.Format(ctx, variables)
+	if err != nil {
+		u.logger.Error("format messages failed", log.Error(err))
+		return nil, errors.New("format messages failed")
+	}
+	// 追加的提示词可能来自调用方，不作为模板解析
+	formattedMessages[0].Content += req.ExtraPrompt
+	budget := newTokenBudget(append([]*domain.Model{req.Model}, req.FallbackModels...)...)
+	budget.SystemPrompt = counter.CountMessage(formattedMessages[0])
+	budget.Question = counter.CountMessage(formattedMessages[1])
+	budget.Tools = counter.CountTools(req.Tools)
+	for _, msg := range req.ExtraMessages {
+		budget.Tools += counter.CountMessage(msg)
+	}
+	available := max(budget.ContextWindow-budget.ReservedOutput-budget.SystemPrompt-budget.Question-budget.Tools, 0)
+	budget.DocumentsLimit = int(float64(available) * documentBudgetRatio)
+
+	nodes, documents, documentTokens := planDocuments(counter, rankResult.Nodes, kb.AccessSettings.BaseURL, budget.DocumentsLimit)
+	result.Nodes = nodes
+	budget.Documents = documentTokens
+	budget.DroppedNodes = len(rankResult.Nodes) - len(nodes)
+	budget.HistoryLimit = available - documentTokens
+	u.logger.Debug("documents", log.String("documents", documents))
+
+	history, summaryMessage := u.planConversationHistory(ctx, counter, budget, req.KBID, req.ConversationID, req.Model, historyMessages[:len(historyMessages)-1])
+	budget.Total = budget.SystemPrompt + budget.Summary + budget.History + budget.Question + budget.Documents + budget.Tools
+	result.Budget = budget
+
+	variables["Documents"] = documents
+	formattedMessages, err = template.Format(ctx, variables)
+	if err != nil {
+		u.logger.Error("format messages failed", log.Error(err))
+		return nil, errors.New("format messages failed")
+	}
+	formattedMessages[0].Content += req.ExtraPrompt
+	if summaryMessage != nil {
+		history = append([]*schema.Message{summaryMessage}, history...)
+	}
+	result.Messages = append(slices.Insert(formattedMessages, 1, history...), req.ExtraMessages...)
+	return result, nil
 }
 
 func (u *LLMUsecase) ChatWithAgent(
@@ -146,12 +219,27 @@ func (u *LLMUsecase) ChatWithAgent(
 	usage *schema.TokenUsage,
 	onChunk func(ctx context.Context, dataType, chunk string) error,
 ) error {
-	resp, err := chatModel.Stream(ctx, messages)
+	_, err := u.streamAssistantMessage(ctx, chatModel, messages, usage, onChunk)
+	return err
+}
+
+// streamAssistantMessage streams the answer to onChunk and returns the whole assistant message with its tool calls
+func (u *LLMUsecase) streamAssistantMessage(
+	ctx context.Context,
+	chatModel model.BaseChatModel,
+	messages []*schema.Message,
+	usage *schema.TokenUsage,
+	onChunk func(ctx context.Context, dataType, chunk string) error,
+	opts ...model.Option,
+) (*schema.Message, error) {
+	resp, err := chatModel.Stream(ctx, messages, opts...)
 	if err != nil {
-		return fmt.Errorf("stream failed: %w", err)
+		return nil, fmt.Errorf("stream failed: %w", err)
 	}
 	firstReasoning := false
 	firstData := false
+	content := strings.Builder{}
+	toolCallChunks := make([]*schema.Message, 0)
 
 	for {
 		msg, err := resp.Recv()
@@ -159,7 +247,10 @@ func (u *LLMUsecase) ChatWithAgent(
 			break
 		}
 		if err != nil {
-			return fmt.Errorf("recv failed: %w", err)
+			return nil, fmt.Errorf("recv failed: %w", err)
+		}
+		if len(msg.ToolCalls) > 0 {
+			toolCallChunks = append(toolCallChunks, &schema.Message{Role: schema.Assistant, ToolCalls: msg.ToolCalls})
 		}
 		reasoning, ok := deepseek.GetReasoningContent(msg)
 		if ok {
@@ -168,29 +259,38 @@ func (u *LLMUsecase) ChatWithAgent(
 				reasoning = "<think>" + reasoning
 			}
 			if err := onChunk(ctx, "data", reasoning); err != nil {
-				return fmt.Errorf("on chunk reasoning: %w", err)
+				return nil, fmt.Errorf("on chunk reasoning: %w", err)
 			}
 			continue
 		}
+		content.WriteString(msg.Content)
 		if firstReasoning && !firstData {
 			firstData = true
 			msg.Content = "</think>\n" + msg.Content
 			if err := onChunk(ctx, "data", msg.Content); err != nil {
-				return fmt.Errorf("on chunk data: %w", err)
+				return nil, fmt.Errorf("on chunk data: %w", err)
 			}
 			continue
 		}
 		if err := onChunk(ctx, "data", msg.Content); err != nil {
-			return fmt.Errorf("on chunk data: %w", err)
+			return nil, fmt.Errorf("on chunk data: %w", err)
 		}
 
 		// set to usage
-		if msg.ResponseMeta.Usage != nil {
+		if msg.ResponseMeta != nil && msg.ResponseMeta.Usage != nil {
 			*usage = *msg.ResponseMeta.Usage
 		}
 	}
 
-	return nil
+	answer := schema.AssistantMessage(content.String(), nil)
+	if len(toolCallChunks) > 0 {
+		toolCalls, err := schema.ConcatMessages(toolCallChunks)
+		if err != nil {
+			return nil, fmt.Errorf("concat tool calls failed: %w", err)
+		}
+		answer.ToolCalls = toolCalls.ToolCalls
+	}
+	return answer, nil
 }
 
 func (u *LLMUsecase) Generate(
@@ -321,15 +421,130 @@ func (u *LLMUsecase) SplitByTokenLimit(text string, maxTokens int) ([]string, er
 }
 
 type GetRankNodesRequest struct {
+	KBID                string // required by the keyword leg
 	DatasetID           string
 	Question            string
 	GroupIDs            []int
 	SimilarityThreshold float64
 	HistoryMessages     []*schema.Message
 	MaxChunksPerDoc     int
+	TopK                int
+	VectorWeight        float64
+	KeywordWeight       float64
+}
+
+const (
+	// rrfK dampens the rank of each retrieval leg in reciprocal rank fusion
+	rrfK = 60
+	// keywordSnippetRunes is the chunk size of a node found only by the keyword leg
+	keywordSnippetRunes = 800
+)
+
+// NewGetRankNodesRequest builds the retrieval request with the retrieval settings of the knowledge base
+func NewGetRankNodesRequest(kb *domain.KnowledgeBase, question string, groupIDs []int) GetRankNodesRequest {
+	settings := kb.RetrievalSettings.WithDefaults()
+	return GetRankNodesRequest{
+		KBID:                kb.ID,
+		DatasetID:           kb.DatasetID,
+		Question:            question,
+		GroupIDs:            groupIDs,
+		SimilarityThreshold: *settings.SimilarityThreshold,
+		MaxChunksPerDoc:     settings.MaxChunksPerDoc,
+		TopK:                settings.TopK,
+		VectorWeight:        settings.VectorWeight,
+		KeywordWeight:       settings.KeywordWeight,
+	}
 }
 
+// RankNodesResult is the retrieval result of GetRankNodes with the hits of each leg kept for debugging
+type RankNodesResult struct {
+	Request        GetRankNodesRequest
+	RewrittenQuery string
+	Nodes          []*domain.RankedNodeChunks // fused and truncated to TopK
+	VectorNodes    []*domain.RankedNodeChunks
+	KeywordNodes   []*domain.RankedNodeChunks
+	FusedScores    map[string]float64 // node id -> rrf score, empty when only one leg is used
+	KeywordErr     error
+}
+
+// GetRankNodes retrieves nodes by vector search and, when KeywordWeight > 0, by postgres full-text search,
+// the two legs are fused with weighted reciprocal rank fusion
 func (u *LLMUsecase) GetRankNodes(ctx context.Context, req GetRankNodesRequest) (string, []*domain.RankedNodeChunks, error) {
+	result, err := u.RankNodes(ctx, req)
+	if err != nil {
+		return "", nil, err
+	}
+	return result.RewrittenQuery, result.Nodes, nil
+}
+
+func (u *LLMUsecase) RankNodes(ctx context.Context, req GetRankNodesRequest) (*RankNodesResult, error) {
+	result := &RankNodesResult{
+		Request:        req,
+		RewrittenQuery: req.Question,
+		VectorNodes:    make([]*domain.RankedNodeChunks, 0),
+		FusedScores:    make(map[string]float64),
+	}
+	// the rag rewrites a follow-up question into a standalone query, the keyword leg needs it as well
+	if req.VectorWeight > 0 || req.KeywordWeight <= 0 || len(req.HistoryMessages) > 0 {
+		rewrittenQuery, vectorNodes, err := u.getVectorRankNodes(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if rewrittenQuery != "" {
+			result.RewrittenQuery = rewrittenQuery
+		}
+		if req.VectorWeight > 0 || req.KeywordWeight <= 0 {
+			result.VectorNodes = vectorNodes
+		}
+	}
+	if req.KeywordWeight <= 0 || req.KBID == "" {
+		result.Nodes = truncateRankedNodes(result.VectorNodes, req.TopK)
+		return result, nil
+	}
+
+	keywordReq := req
+	keywordReq.Question = result.RewrittenQuery
+	keywordNodes, err := u.getKeywordRankNodes(ctx, keywordReq)
+	if err != nil {
+		// the keyword leg only improves recall, answer with vector hits when it fails
+		u.logger.Error("get keyword rank nodes failed", log.String("kb_id", req.KBID), log.Error(err))
+		result.KeywordErr = err
+		result.Nodes = truncateRankedNodes(result.VectorNodes, req.TopK)
+		return result, nil
+	}
+	result.KeywordNodes = keywordNodes
+	u.logger.Info("hybrid retrieval", log.Int("vector_nodes", len(result.VectorNodes)), log.Int("keyword_nodes", len(keywordNodes)))
+
+	scores := result.FusedScores
+	fused := make([]*domain.RankedNodeChunks, 0, len(result.VectorNodes)+len(keywordNodes))
+	for i, node := range result.VectorNodes {
+		if _, ok := scores[node.NodeID]; !ok {
+			fused = append(fused, node)
+		}
+		scores[node.NodeID] += req.VectorWeight / float64(rrfK+i+1)
+	}
+	for i, node := range keywordNodes {
+		// vector chunks are more focused than the keyword snippet, keep them when both legs hit
+		if _, ok := scores[node.NodeID]; !ok {
+			fused = append(fused, node)
+		}
+		scores[node.NodeID] += req.KeywordWeight / float64(rrfK+i+1)
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		return scores[fused[i].NodeID] > scores[fused[j].NodeID]
+	})
+	result.Nodes = truncateRankedNodes(fused, req.TopK)
+	return result, nil
+}
+
+func truncateRankedNodes(nodes []*domain.RankedNodeChunks, topK int) []*domain.RankedNodeChunks {
+	if topK > 0 && len(nodes) > topK {
+		return nodes[:topK]
+	}
+	return nodes
+}
+
+func (u *LLMUsecase) getVectorRankNodes(ctx context.Context, req GetRankNodesRequest) (string, []*domain.RankedNodeChunks, error) {
 	var rankedNodes []*domain.RankedNodeChunks
 	// get related documents from raglite
 	rewrittenQuery, records, err := u.rag.QueryRecords(ctx, &rag.QueryRecordsRequest{
@@ -339,6 +554,7 @@ func (u *LLMUsecase) GetRankNodes(ctx context.Context, req GetRankNodesRequest)
 		SimilarityThreshold: req.SimilarityThreshold,
 		HistoryMsgs:         req.HistoryMessages,
 		MaxChunksPerDoc:     req.MaxChunksPerDoc,
+		TopK:                req.TopK,
 	})
 	if err != nil {
 		return "", nil, fmt.Errorf("get records from raglite failed: %w", err)
@@ -378,6 +594,49 @@ func (u *LLMUsecase) GetRankNodes(ctx context.Context, req GetRankNodesRequest)
 	return rewrittenQuery, rankedNodes, nil
 }
 
+func (u *LLMUsecase) getKeywordRankNodes(ctx context.Context, req GetRankNodesRequest) ([]*domain.RankedNodeChunks, error) {
+	tokens := utils.SegmentQuery(req.Question)
+	limit := req.TopK
+	if limit <= 0 {
+		limit = domain.DefaultRetrievalTopK
+	}
+	hits, err := u.nodeRepo.SearchNodeReleasesByKeyword(ctx, req.KBID, tokens, req.GroupIDs, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(hits) == 0 {
+		return nil, nil
+	}
+	paths, err := u.nodeRepo.GetNodeReleasePathsByIDs(ctx, lo.Map(hits, func(hit *pg.NodeReleaseKeywordHit, _ int) string {
+		return hit.ID
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("get node release paths failed: %w", err)
+	}
+	rankedNodes := make([]*domain.RankedNodeChunks, 0, len(hits))
+	for _, hit := range hits {
+		rankedNode := &domain.RankedNodeChunks{
+			NodeID:      hit.NodeID,
+			NodeName:    hit.Name,
+			NodeSummary: hit.Meta.Summary,
+			NodeEmoji:   hit.Meta.Emoji,
+			Chunks: []*domain.NodeContentChunk{{
+				ID:      hit.ID,
+				KBID:    req.KBID,
+				DocID:   hit.DocID,
+				Name:    hit.Name,
+				Content: utils.KeywordSnippet(utils.ContentToMarkdown(hit.Content), tokens, keywordSnippetRunes),
+				Score:   hit.Rank,
+			}},
+		}
+		if path, ok := paths[hit.ID]; ok {
+			rankedNode.NodePathNames = path.PathNames
+		}
+		rankedNodes = append(rankedNodes, rankedNode)
+	}
+	return rankedNodes, nil
+}
+
 // formatMessageWithImages converts image paths to markdown format and appends to message
 func (u *LLMUsecase) formatMessageWithImages(message string, imagePaths []string) string {
 	if len(imagePaths) == 0 {
@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/chaitin/panda-wiki/log"
+)
+
+// ChatWithTools streams the answer while letting the model call the tools of the toolset.
+// Each step the tool calls of the model are executed and their results appended to the messages,
+// after MaxSteps steps the model answers without tools. A nil toolset or a model which does not
+// support tool calling falls back to ChatWithAgent. The calls of an external toolset are not
+// executed, the answer ends with them, see NewExternalToolset.
+func (u *LLMUsecase) ChatWithTools(
+	ctx context.Context,
+	chatModel model.BaseChatModel,
+	messages []*schema.Message,
+	toolset *AgentToolset,
+	usage *schema.TokenUsage,
+	onChunk func(ctx context.Context, dataType, chunk string) error,
+) error {
+	toolModel, ok := chatModel.(model.ToolCallingChatModel)
+	if toolset == nil || len(toolset.tools) == 0 || !ok {
+		return u.ChatWithAgent(ctx, chatModel, messages, usage, onChunk)
+	}
+	boundModel, err := toolModel.WithTools(toolset.tools)
+	if err != nil {
+		u.logger.Warn("bind agent tools failed, chat without tools", log.Error(err))
+		return u.ChatWithAgent(ctx, chatModel, messages, usage, onChunk)
+	}
+
+	var opts []model.Option
+	if toolset.toolChoice != "" {
+		opts = append(opts, model.WithToolChoice(toolset.toolChoice))
+	}
+
+	messages = slices.Clone(messages)
+	total := schema.TokenUsage{}
+	defer func() { *usage = total }()
+	for step := 1; step <= toolset.maxSteps; step++ {
+		stepUsage := schema.TokenUsage{}
+		answer, err := u.streamAssistantMessage(ctx, boundModel, messages, &stepUsage, onChunk, opts...)
+		addTokenUsage(&total, stepUsage)
+		if err != nil {
+			return err
+		}
+		if len(answer.ToolCalls) == 0 {
+			return nil
+		}
+		if toolset.external {
+			toolset.returnToolCalls(step, answer.ToolCalls)
+			return nil
+		}
+		messages = append(messages, answer)
+		for _, toolCall := range answer.ToolCalls {
+			result := toolset.call(ctx, step, toolCall)
+			messages = append(messages, schema.ToolMessage(result, toolCall.ID))
+		}
+	}
+
+	// 达到最大轮数后不再提供工具，要求模型根据已有信息回答
+	stepUsage := schema.TokenUsage{}
+	err = u.ChatWithAgent(ctx, chatModel, messages, &stepUsage, onChunk)
+	addTokenUsage(&total, stepUsage)
+	if err != nil {
+		return fmt.Errorf("answer after tool calls failed: %w", err)
+	}
+	return nil
+}
+
+func addTokenUsage(total *schema.TokenUsage, usage schema.TokenUsage) {
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+}
@@ -0,0 +1,286 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	deepseek "github.com/cohesion-org/deepseek-go"
+	openai "github.com/meguminnnnnnnnn/go-openai"
+	ollama "github.com/ollama/ollama/api"
+	"google.golang.org/genai"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+)
+
+const (
+	modelMaxRetries         = 2 // 每个模型在输出首个 token 前的最大重试次数
+	modelRetryBackoff       = 500 * time.Millisecond
+	circuitFailureThreshold = 3 // 连续失败次数达到阈值后熔断
+	circuitCooldown         = time.Minute
+)
+
+// circuitBreaker 按模型服务维度熔断，熔断期间跳过该模型，冷却结束后进入半开状态，只放行一个探测请求，
+// 探测成功后恢复，失败后重新熔断
+type circuitBreaker struct {
+	mu        sync.Mutex
+	states    map[string]*circuitState
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+type circuitState struct {
+	failures  int
+	openUntil time.Time
+	// probing 为半开状态下已放行探测请求，探测结束前其他请求仍跳过该模型
+	probing      bool
+	probeStarted time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		states:    make(map[string]*circuitState),
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a request may be sent, the caller must report the result with Success, Failure or Release
+func (b *circuitBreaker) Allow(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.states[key]
+	if !ok || state.failures < b.threshold {
+		return true
+	}
+	now := b.now()
+	if now.Before(state.openUntil) {
+		return false
+	}
+	// 探测请求超过冷却时间仍未结束时视为丢失，重新放行一次探测
+	if state.probing && now.Before(state.probeStarted.Add(b.cooldown)) {
+		return false
+	}
+	state.probing = true
+	state.probeStarted = now
+	return true
+}
+
+func (b *circuitBreaker) Success(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.states, key)
+}
+
+func (b *circuitBreaker) Failure(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.states[key]
+	if !ok {
+		state = &circuitState{}
+		b.states[key] = state
+	}
+	state.failures++
+	state.probing = false
+	if state.failures >= b.threshold {
+		state.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// Release ends a request whose result says nothing about the model service, e.g. canceled by the client
+func (b *circuitBreaker) Release(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if state, ok := b.states[key]; ok {
+		state.probing = false
+	}
+}
+
+func modelCircuitKey(model *domain.Model) string {
+	return strings.Join([]string{string(model.Provider), model.BaseURL, model.Model}, "|")
+}
+
+// isRetryableModelError reports whether the error is caused by the connection, a 5xx response or rate limiting
+func isRetryableModelError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if status, ok := modelErrorStatusCode(err); ok {
+		return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, keyword := range []string{"rate limit", "too many requests", "connection refused", "connection reset", "timeout", "overloaded", "unexpected eof"} {
+		if strings.Contains(msg, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// modelErrorStatusCode returns the http status code of the error returned by the model clients
+func modelErrorStatusCode(err error) (int, bool) {
+	var (
+		openaiAPIErr     *openai.APIError
+		openaiRequestErr *openai.RequestError
+		deepseekErr      *deepseek.APIError
+		geminiErr        genai.APIError
+		ollamaErr        ollama.StatusError
+	)
+	switch {
+	case errors.As(err, &openaiAPIErr) && openaiAPIErr.HTTPStatusCode > 0:
+		return openaiAPIErr.HTTPStatusCode, true
+	case errors.As(err, &openaiRequestErr) && openaiRequestErr.HTTPStatusCode > 0:
+		return openaiRequestErr.HTTPStatusCode, true
+	case errors.As(err, &deepseekErr) && deepseekErr.StatusCode > 0:
+		return deepseekErr.StatusCode, true
+	case errors.As(err, &geminiErr) && geminiErr.Code > 0:
+		return geminiErr.Code, true
+	case errors.As(err, &ollamaErr) && ollamaErr.StatusCode > 0:
+		return ollamaErr.StatusCode, true
+	}
+	return 0, false
+}
+
+// ChatWithFailover streams the answer with the models in order.
+// Retryable errors are retried before the first token is emitted, then the next model is tried,
+// once a token has been emitted or a tool has been called the error is returned as is.
+// The model which answered is returned.
+func (u *LLMUsecase) ChatWithFailover(
+	ctx context.Context,
+	models []*domain.Model,
+	messages []*schema.Message,
+	toolset *AgentToolset,
+	usage *schema.TokenUsage,
+	onChunk func(ctx context.Context, dataType, chunk string) error,
+) (*domain.Model, error) {
+	if len(models) == 0 {
+		return nil, errors.New("no chat model available")
+	}
+	var (
+		lastModel *domain.Model
+		lastErr   error
+	)
+	tried := false
+	for _, model := range models {
+		// 半开状态下 Allow 会占用探测名额，只在确实请求该模型前调用
+		if !u.breaker.Allow(modelCircuitKey(model)) {
+			continue
+		}
+		tried = true
+		started, err := u.chatWithBreaker(ctx, model, messages, toolset, usage, onChunk)
+		if err == nil || started || ctx.Err() != nil {
+			return model, err
+		}
+		u.logger.Warn("chat model failed, try next model",
+			log.String("provider", string(model.Provider)),
+			log.String("model", model.Model),
+			log.Error(err))
+		lastModel, lastErr = model, err
+	}
+	if tried {
+		return lastModel, lastErr
+	}
+	// 所有模型均被熔断时仍按顺序尝试，避免直接失败
+	for _, model := range models {
+		started, err := u.chatWithBreaker(ctx, model, messages, toolset, usage, onChunk)
+		if err == nil || started || ctx.Err() != nil {
+			return model, err
+		}
+		lastModel, lastErr = model, err
+	}
+	return lastModel, lastErr
+}
+
+// chatWithBreaker reports the result of the model to the circuit breaker,
+// only failures of the model service (connection, 5xx and rate limiting) count
+func (u *LLMUsecase) chatWithBreaker(
+	ctx context.Context,
+	model *domain.Model,
+	messages []*schema.Message,
+	toolset *AgentToolset,
+	usage *schema.TokenUsage,
+	onChunk func(ctx context.Context, dataType, chunk string) error,
+) (bool, error) {
+	key := modelCircuitKey(model)
+	started, err := u.chatWithRetry(ctx, model, messages, toolset, usage, onChunk)
+	switch {
+	case err == nil:
+		u.breaker.Success(key)
+	case ctx.Err() != nil:
+		u.breaker.Release(key)
+	case isRetryableModelError(err):
+		u.breaker.Failure(key)
+	case started:
+		// 已经开始输出，模型服务可用，错误来自工具或回调
+		u.breaker.Success(key)
+	default:
+		u.breaker.Release(key)
+	}
+	return started, err
+}
+
+func (u *LLMUsecase) chatWithRetry(
+	ctx context.Context,
+	model *domain.Model,
+	messages []*schema.Message,
+	toolset *AgentToolset,
+	usage *schema.TokenUsage,
+	onChunk func(ctx context.Context, dataType, chunk string) error,
+) (bool, error) {
+	modelkitModel, err := model.ToModelkitModel()
+	if err != nil {
+		return false, fmt.Errorf("convert model failed: %w", err)
+	}
+	chatModel, err := u.modelkit.GetChatModel(ctx, modelkitModel)
+	if err != nil {
+		return false, fmt.Errorf("get chat model failed: %w", err)
+	}
+
+	started := false
+	onChunkStarted := func(ctx context.Context, dataType, chunk string) error {
+		if chunk != "" {
+			started = true
+		}
+		return onChunk(ctx, dataType, chunk)
+	}
+	for attempt := 0; ; attempt++ {
+		*usage = schema.TokenUsage{}
+		err = u.ChatWithTools(ctx, chatModel, messages, toolset, usage, onChunkStarted)
+		// 工具可能有副作用，调用过工具后不再重试
+		started = started || len(toolset.Traces()) > 0
+		if err == nil || started || attempt >= modelMaxRetries || !isRetryableModelError(err) {
+			return started, err
+		}
+		u.logger.Warn("chat model failed before first token, retry",
+			log.String("model", model.Model),
+			log.Int("attempt", attempt+1),
+			log.Error(err))
+		select {
+		case <-ctx.Done():
+			return false, err
+		case <-time.After(modelRetryBackoff * time.Duration(attempt+1)):
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	deepseek "github.com/cohesion-org/deepseek-go"
+	openai "github.com/meguminnnnnnnnn/go-openai"
+	ollama "github.com/ollama/ollama/api"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genai"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	breaker.Failure("a")
+	assert.True(t, breaker.Allow("a"))
+	breaker.Failure("a")
+	assert.False(t, breaker.Allow("a"))
+	assert.True(t, breaker.Allow("b"))
+
+	// half-open: only one probe is let through
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.Allow("a"))
+	assert.False(t, breaker.Allow("a"))
+	breaker.Failure("a")
+	assert.False(t, breaker.Allow("a"))
+
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.Allow("a"))
+	breaker.Release("a")
+	assert.True(t, breaker.Allow("a"))
+	// a lost probe is replaced after the cooldown
+	assert.False(t, breaker.Allow("a"))
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.Allow("a"))
+	breaker.Success("a")
+	assert.True(t, breaker.Allow("a"))
+	breaker.Failure("a")
+	assert.True(t, breaker.Allow("a"))
+}
+
+func TestIsRetryableModelError(t *testing.T) {
+	assert.True(t, isRetryableModelError(fmt.Errorf("failed to create chat completion: %w", &openai.APIError{HTTPStatusCode: 429})))
+	assert.True(t, isRetryableModelError(fmt.Errorf("failed to receive stream chunk: %w", &openai.RequestError{HTTPStatusCode: 503})))
+	assert.True(t, isRetryableModelError(&deepseek.APIError{StatusCode: 502}))
+	assert.True(t, isRetryableModelError(genai.APIError{Code: 500}))
+	assert.True(t, isRetryableModelError(fmt.Errorf("stream failed: %w", context.DeadlineExceeded)))
+	assert.True(t, isRetryableModelError(errors.New("upstream overloaded")))
+	assert.False(t, isRetryableModelError(&openai.APIError{HTTPStatusCode: 401, Message: "invalid api key"}))
+	// a status code in the message is not a status of the response
+	assert.False(t, isRetryableModelError(&openai.APIError{HTTPStatusCode: 400, Message: "max_tokens must be less than 5000"}))
+	assert.False(t, isRetryableModelError(ollama.StatusError{StatusCode: 404}))
+	assert.False(t, isRetryableModelError(fmt.Errorf("stream failed: %w", context.Canceled)))
+	assert.False(t, isRetryableModelError(nil))
+}
@@ -0,0 +1,291 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/consts"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+type MCPUsecase struct {
+	appRepo     *pg.AppRepository
+	authRepo    *pg.AuthRepo
+	kbRepo      *pg.KnowledgeBaseRepository
+	mcpRepo     *pg.MCPRepository
+	chatUsecase *ChatUsecase
+	nodeUsecase *NodeUsecase
+	logger      *log.Logger
+
+	// kbID -> *mcpServerEntry, rebuilt when tool settings change
+	servers sync.Map
+}
+
+type mcpServerEntry struct {
+	toolSettings domain.MCPToolSettings
+	handler      http.Handler
+}
+
+func NewMCPUsecase(
+	appRepo *pg.AppRepository,
+	authRepo *pg.AuthRepo,
+	kbRepo *pg.KnowledgeBaseRepository,
+	mcpRepo *pg.MCPRepository,
+	chatUsecase *ChatUsecase,
+	nodeUsecase *NodeUsecase,
+	logger *log.Logger,
+) *MCPUsecase {
+	return &MCPUsecase{
+		appRepo:     appRepo,
+		authRepo:    authRepo,
+		kbRepo:      kbRepo,
+		mcpRepo:     mcpRepo,
+		chatUsecase: chatUsecase,
+		nodeUsecase: nodeUsecase,
+		logger:      logger.WithModule("usecase.mcp"),
+	}
+}
+
+func (u *MCPUsecase) GetMCPServerSettings(ctx context.Context, kbID string) (*domain.MCPServerSettings, error) {
+	app, err := u.appRepo.GetOrCreateAppByKBIDAndType(ctx, kbID, domain.AppTypeMcpServer)
+	if err != nil {
+		return nil, err
+	}
+	return &app.Settings.MCPServerSettings, nil
+}
+
+// GetMCPAuthID returns the bot auth id of mcp server, which is used for node permission check.
+// servers enabled before the bot auth exists fall back to 0, which only sees open nodes
+func (u *MCPUsecase) GetMCPAuthID(ctx context.Context, kbID string) (uint, error) {
+	auth, err := u.authRepo.GetAuthByKBIDAndSourceType(ctx, kbID, consts.SourceTypeMcpServer)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get mcp server auth failed: %w", err)
+	}
+	return auth.ID, nil
+}
+
+// GetMCPHandler returns the streamable http handler of the kb mcp server
+func (u *MCPUsecase) GetMCPHandler(kbID string, settings *domain.MCPServerSettings) http.Handler {
+	if v, ok := u.servers.Load(kbID); ok {
+		entry := v.(*mcpServerEntry)
+		if entry.toolSettings == settings.DocsToolSettings {
+			return entry.handler
+		}
+	}
+	entry := &mcpServerEntry{
+		toolSettings: settings.DocsToolSettings,
+		handler: server.NewStreamableHTTPServer(
+			u.newMCPServer(settings.DocsToolSettings),
+			server.WithHeartbeatInterval(30*time.Second),
+		),
+	}
+	u.servers.Store(kbID, entry)
+	return entry.handler
+}
+
+func (u *MCPUsecase) newMCPServer(toolSettings domain.MCPToolSettings) *server.MCPServer {
+	hooks := &server.Hooks{}
+	hooks.AddAfterInitialize(u.recordInitialize)
+	hooks.AddAfterCallTool(u.recordToolCall)
+
+	s := server.NewMCPServer(domain.MCPServerName, domain.MCPServerVersion,
+		server.WithToolCapabilities(false),
+		server.WithHooks(hooks),
+	)
+
+	searchName := toolSettings.Name
+	if searchName == "" {
+		searchName = domain.MCPToolSearchDefaultName
+	}
+	searchDesc := toolSettings.Desc
+	if searchDesc == "" {
+		searchDesc = domain.MCPToolSearchDefaultDesc
+	}
+	s.AddTool(mcp.NewTool(searchName,
+		mcp.WithDescription(searchDesc),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("question", mcp.Required(), mcp.Description("要搜索的问题或关键词")),
+	), u.searchDocs)
+
+	s.AddTool(mcp.NewTool(domain.MCPToolGetNodeName,
+		mcp.WithDescription(domain.MCPToolGetNodeDesc),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("id", mcp.Required(), mcp.Description("文档 ID")),
+	), u.getDoc)
+
+	s.AddTool(mcp.NewTool(domain.MCPToolListNodesName,
+		mcp.WithDescription(domain.MCPToolListNodesDesc),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("parent_id", mcp.Description("父节点 ID，为空时返回整个目录树")),
+	), u.listDocs)
+
+	return s
+}
+
+func (u *MCPUsecase) searchDocs(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	info := domain.GetMCPRequestInfoFromCtx(ctx)
+	if info == nil {
+		return mcp.NewToolResultError("invalid request"), nil
+	}
+	question, err := req.RequireString("question")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	resp, err := u.chatUsecase.Search(ctx, &domain.ChatSearchReq{
+		Message:    question,
+		KBID:       info.KBID,
+		RemoteIP:   info.RemoteIP,
+		AuthUserID: info.AuthID,
+	})
+	if err != nil {
+		u.logger.Error("mcp search docs failed", log.String("kb_id", info.KBID), log.Error(err))
+		return mcp.NewToolResultError("search docs failed"), nil
+	}
+
+	baseURL := u.getBaseURL(ctx, info.KBID)
+	results := make([]domain.MCPSearchResult, 0, len(resp.NodeResult))
+	for _, node := range resp.NodeResult {
+		result := domain.MCPSearchResult{
+			NodeID:        node.NodeID,
+			Name:          node.Name,
+			Summary:       node.Summary,
+			NodePathNames: node.NodePathNames,
+		}
+		if baseURL != "" {
+			result.URL = fmt.Sprintf("%s/node/%s", baseURL, node.NodeID)
+		}
+		results = append(results, result)
+	}
+	return toolResultJSON(results)
+}
+
+func (u *MCPUsecase) getDoc(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	info := domain.GetMCPRequestInfoFromCtx(ctx)
+	if info == nil {
+		return mcp.NewToolResultError("invalid request"), nil
+	}
+	nodeID, err := req.RequireString("id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if errCode := u.nodeUsecase.ValidateNodePerm(ctx, info.KBID, nodeID, info.AuthID); errCode != nil {
+		return mcp.NewToolResultError(errCode.Message), nil
+	}
+
+	node, err := u.nodeUsecase.GetNodeReleaseDetailByKBIDAndID(ctx, info.KBID, nodeID, "raw")
+	if err != nil {
+		return mcp.NewToolResultError("doc not found"), nil
+	}
+	if ok, err := u.nodeUsecase.IsNodeAnswerable(ctx, node.ID, node.Permissions, info.AuthID); err != nil {
+		u.logger.Error("mcp check node answerable failed", log.String("node_id", nodeID), log.Error(err))
+		return mcp.NewToolResultError("get doc failed"), nil
+	} else if !ok {
+		return mcp.NewToolResultError(domain.ErrCodePermissionDenied.Message), nil
+	}
+
+	detail := domain.MCPNodeDetail{
+		ID:        node.ID,
+		Name:      node.Name,
+		Type:      node.Type,
+		ParentID:  node.ParentID,
+		Summary:   node.Meta.Summary,
+		Content:   node.Content,
+		UpdatedAt: node.UpdatedAt,
+	}
+	if baseURL := u.getBaseURL(ctx, info.KBID); baseURL != "" {
+		detail.URL = fmt.Sprintf("%s/node/%s", baseURL, node.ID)
+	}
+	return toolResultJSON(detail)
+}
+
+func (u *MCPUsecase) listDocs(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	info := domain.GetMCPRequestInfoFromCtx(ctx)
+	if info == nil {
+		return mcp.NewToolResultError("invalid request"), nil
+	}
+
+	nodes, err := u.nodeUsecase.GetNodeReleaseListByParentID(ctx, info.KBID, req.GetString("parent_id", ""), info.AuthID)
+	if err != nil {
+		u.logger.Error("mcp list docs failed", log.String("kb_id", info.KBID), log.Error(err))
+		return mcp.NewToolResultError("list docs failed"), nil
+	}
+	return toolResultJSON(nodes)
+}
+
+func (u *MCPUsecase) getBaseURL(ctx context.Context, kbID string) string {
+	kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, kbID)
+	if err != nil {
+		return ""
+	}
+	return kb.AccessSettings.GetBaseUrl()
+}
+
+func (u *MCPUsecase) recordInitialize(ctx context.Context, id any, req *mcp.InitializeRequest, result *mcp.InitializeResult) {
+	call := u.newMCPCall(ctx)
+	if call == nil {
+		return
+	}
+	call.InitializeReq, _ = json.Marshal(req)
+	call.InitializeResp, _ = json.Marshal(result)
+	if err := u.mcpRepo.CreateMCPCall(ctx, call); err != nil {
+		u.logger.Error("record mcp initialize failed", log.Error(err))
+	}
+}
+
+func (u *MCPUsecase) recordToolCall(ctx context.Context, id any, req *mcp.CallToolRequest, result *mcp.CallToolResult) {
+	call := u.newMCPCall(ctx)
+	if call == nil {
+		return
+	}
+	call.ToolCallReq, _ = json.Marshal(req)
+	if result != nil {
+		for _, content := range result.Content {
+			if text, ok := content.(mcp.TextContent); ok {
+				call.ToolCallResp += text.Text
+			}
+		}
+	}
+	if err := u.mcpRepo.CreateMCPCall(ctx, call); err != nil {
+		u.logger.Error("record mcp tool call failed", log.Error(err))
+	}
+}
+
+func (u *MCPUsecase) newMCPCall(ctx context.Context) *domain.MCPCall {
+	info := domain.GetMCPRequestInfoFromCtx(ctx)
+	if info == nil {
+		return nil
+	}
+	call := &domain.MCPCall{
+		KBID:      info.KBID,
+		RemoteIP:  info.RemoteIP,
+		CreatedAt: time.Now(),
+	}
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		call.MCPSessionID = session.SessionID()
+	}
+	return call
+}
+
+func toolResultJSON(data any) (*mcp.CallToolResult, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(b)), nil
+}
@@ -3,9 +3,11 @@ package usecase
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/cloudwego/eino/schema"
+	"gorm.io/gorm"
 
 	modelkitDomain "github.com/chaitin/ModelKit/v2/domain"
 	modelkit "github.com/chaitin/ModelKit/v2/usecase"
@@ -29,9 +31,11 @@ type ModelUsecase struct {
 	kbRepo            *pg.KnowledgeBaseRepository
 	systemSettingRepo *pg.SystemSettingRepo
 	modelkit          *modelkit.ModelKit
+	modelClient       *rag.ModelClient
+	auditRepo         *pg.AuditLogRepository
 }
 
-func NewModelUsecase(modelRepo *pg.ModelRepository, nodeRepo *pg.NodeRepository, ragRepo *mq.RAGRepository, ragStore rag.RAGService, logger *log.Logger, config *config.Config, kbRepo *pg.KnowledgeBaseRepository, settingRepo *pg.SystemSettingRepo) *ModelUsecase {
+func NewModelUsecase(modelRepo *pg.ModelRepository, nodeRepo *pg.NodeRepository, ragRepo *mq.RAGRepository, ragStore rag.RAGService, logger *log.Logger, config *config.Config, kbRepo *pg.KnowledgeBaseRepository, settingRepo *pg.SystemSettingRepo, auditRepo *pg.AuditLogRepository) *ModelUsecase {
 	modelkit := modelkit.NewModelKit(logger.Logger)
 	u := &ModelUsecase{
 		modelRepo:         modelRepo,
@@ -43,6 +47,8 @@ func NewModelUsecase(modelRepo *pg.ModelRepository, nodeRepo *pg.NodeRepository,
 		kbRepo:            kbRepo,
 		systemSettingRepo: settingRepo,
 		modelkit:          modelkit,
+		modelClient:       rag.NewModelClient(),
+		auditRepo:         auditRepo,
 	}
 	return u
 }
@@ -55,6 +61,7 @@ func (u *ModelUsecase) Create(ctx context.Context, model *domain.Model) error {
 	if err := u.modelRepo.Create(ctx, model); err != nil {
 		return err
 	}
+	recordAuditLog(ctx, u.logger, u.auditRepo, "", domain.AuditActionModelCreate, domain.AuditTargetModel, model.ID, nil, model)
 	// 模型更新成功后，如果更新嵌入模型，则触发记录更新
 	if updatedEmbeddingModel {
 		if _, err := u.updateModeSettingConfig(ctx, "", "", "", true); err != nil {
@@ -113,9 +120,18 @@ func (u *ModelUsecase) Update(ctx context.Context, req *domain.UpdateModelReq) e
 	if req.Type == domain.ModelTypeEmbedding {
 		updatedEmbeddingModel = true
 	}
+	before, err := u.modelRepo.GetModelByID(ctx, req.ID)
+	if err != nil {
+		return err
+	}
 	if err := u.modelRepo.Update(ctx, req); err != nil {
 		return err
 	}
+	after, err := u.modelRepo.GetModelByID(ctx, req.ID)
+	if err != nil {
+		u.logger.Warn("get model for audit log failed", log.String("model_id", req.ID), log.Error(err))
+	}
+	recordAuditLog(ctx, u.logger, u.auditRepo, "", domain.AuditActionModelUpdate, domain.AuditTargetModel, req.ID, before, after)
 	data := &domain.Model{
 		Provider:   req.Provider,
 		Model:      req.Model,
@@ -173,11 +189,135 @@ func (u *ModelUsecase) GetChatModel(ctx context.Context) (*domain.Model, error)
 	return model, nil
 }
 
+// GetKBChatModel returns the chat model pinned by the app or the knowledge base, falling back to the global chat model,
+// gorm.ErrRecordNotFound is returned when none of them is configured
+func (u *ModelUsecase) GetKBChatModel(ctx context.Context, kbID string, app *domain.App) (*domain.Model, error) {
+	model, err := u.GetChatModel(ctx)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		model = nil
+	}
+	if kbID != "" {
+		kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, kbID)
+		if err != nil {
+			return nil, err
+		}
+		model = kb.ChatModel.Apply(model)
+	}
+	if app != nil {
+		model = app.Settings.ChatModel.Apply(model)
+	}
+	if model == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return model, nil
+}
+
+// GetKBChatModelChain returns the chat model of the knowledge base followed by the chat fallback models
+func (u *ModelUsecase) GetKBChatModelChain(ctx context.Context, kbID string, app *domain.App) ([]*domain.Model, error) {
+	var models []*domain.Model
+	model, err := u.GetKBChatModel(ctx, kbID, app)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	} else {
+		models = append(models, model)
+	}
+	fallbacks, err := u.modelRepo.GetFallbackList(ctx, domain.ModelTypeChat)
+	if err != nil {
+		return nil, err
+	}
+	for _, fallback := range fallbacks {
+		models = append(models, fallback.ToModel())
+	}
+	if len(models) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return models, nil
+}
+
+func (u *ModelUsecase) GetFallbackList(ctx context.Context, modelType domain.ModelType) ([]*domain.ModelFallback, error) {
+	return u.modelRepo.GetFallbackList(ctx, modelType)
+}
+
+func (u *ModelUsecase) CreateFallback(ctx context.Context, fallback *domain.ModelFallback) error {
+	return u.modelRepo.CreateFallback(ctx, fallback)
+}
+
+func (u *ModelUsecase) UpdateFallback(ctx context.Context, req *domain.UpdateModelFallbackReq) error {
+	return u.modelRepo.UpdateFallback(ctx, req)
+}
+
+func (u *ModelUsecase) DeleteFallback(ctx context.Context, id string) error {
+	return u.modelRepo.DeleteFallback(ctx, id)
+}
+
 func (u *ModelUsecase) GetModelByType(ctx context.Context, modelType domain.ModelType) (*domain.Model, error) {
 	return u.modelRepo.GetModelByType(ctx, modelType)
 }
 
+// GetEmbeddingModel returns the embedding model of the model mode, in auto mode it is the built-in model
+// which is not stored in the models table
+func (u *ModelUsecase) GetEmbeddingModel(ctx context.Context) (*domain.Model, error) {
+	modelModeSetting, err := u.GetModelModeSetting(ctx)
+	// 获取不到模型模式时，使用手动模式, 不返回错误
+	if err != nil {
+		u.logger.Error("get model mode setting failed, use manual mode", log.Error(err))
+	}
+	if err == nil && modelModeSetting.Mode == consts.ModelSettingModeAuto && modelModeSetting.AutoModeAPIKey != "" {
+		return &domain.Model{
+			Model:    consts.GetAutoModeDefaultModel(string(domain.ModelTypeEmbedding)),
+			Type:     domain.ModelTypeEmbedding,
+			IsActive: true,
+			BaseURL:  consts.AutoModeBaseURL,
+			APIKey:   modelModeSetting.AutoModeAPIKey,
+			Provider: domain.ModelProviderBrandBaiZhiCloud,
+		}, nil
+	}
+	return u.modelRepo.GetModelByType(ctx, domain.ModelTypeEmbedding)
+}
+
+// Embed returns the embeddings of texts with the embedding model
+func (u *ModelUsecase) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	model, err := u.GetEmbeddingModel(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get embedding model failed: %w", err)
+	}
+	return u.modelClient.Embed(ctx, model, texts)
+}
+
+// EmbedWithUsage embeds texts with the embedding model of the kb and records the tokens on the model,
+// the tokens are estimated when the api does not report them
+func (u *ModelUsecase) EmbedWithUsage(ctx context.Context, texts []string) ([][]float32, *domain.Model, int, error) {
+	model, err := u.GetEmbeddingModel(ctx)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("get embedding model failed: %w", err)
+	}
+	embeddings, tokens, err := u.modelClient.EmbedWithUsage(ctx, model, texts)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if tokens == 0 {
+		if counter, err := newTokenCounter(); err == nil {
+			for _, text := range texts {
+				tokens += counter.Count(text)
+			}
+		}
+	}
+	if err := u.UpdateUsage(ctx, model.ID, &schema.TokenUsage{PromptTokens: tokens, TotalTokens: tokens}); err != nil {
+		u.logger.Warn("update embedding model usage failed", log.String("model_id", model.ID), log.Error(err))
+	}
+	return embeddings, model, tokens, nil
+}
+
 func (u *ModelUsecase) UpdateUsage(ctx context.Context, modelID string, usage *schema.TokenUsage) error {
+	// 知识库或应用单独配置的模型不在 models 表中，不记录用量
+	if modelID == "" {
+		return nil
+	}
 	return u.modelRepo.UpdateUsage(ctx, modelID, usage)
 }
 
@@ -245,6 +385,7 @@ func (u *ModelUsecase) SwitchMode(ctx context.Context, req *domain.SwitchModeReq
 	if err != nil {
 		return err
 	}
+	recordAuditLog(ctx, u.logger, u.auditRepo, "", domain.AuditActionModelSwitchMode, domain.AuditTargetModelSetting, string(consts.SystemSettingModelMode), oldModelModeSetting, modelModeSetting)
 
 	if err := u.updateRAGModelsByMode(ctx, req.Mode, modelModeSetting.AutoModeAPIKey, oldModelModeSetting); err != nil {
 		return err
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"slices"
+	"time"
 
 	"github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
+	"github.com/google/uuid"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/samber/lo"
 	"gorm.io/gorm"
@@ -29,6 +31,8 @@ type NodeUsecase struct {
 	nodeRepo     *pg.NodeRepository
 	appRepo      *pg.AppRepository
 	ragRepo      *mq.RAGRepository
+	webhookRepo  *mq.WebhookRepository
+	auditRepo    *pg.AuditLogRepository
 	kbRepo       *pg.KnowledgeBaseRepository
 	modelRepo    *pg.ModelRepository
 	userRepo     *pg.UserRepository
@@ -53,6 +57,8 @@ func NewNodeUsecase(
 	modelRepo *pg.ModelRepository,
 	authRepo *pg.AuthRepo,
 	modelUsecase *ModelUsecase,
+	webhookRepo *mq.WebhookRepository,
+	auditRepo *pg.AuditLogRepository,
 ) *NodeUsecase {
 	return &NodeUsecase{
 		nodeRepo:     nodeRepo,
@@ -67,6 +73,8 @@ func NewNodeUsecase(
 		logger:       logger.WithModule("usecase.node"),
 		s3Client:     s3Client,
 		modelUsecase: modelUsecase,
+		webhookRepo:  webhookRepo,
+		auditRepo:    auditRepo,
 	}
 }
 
@@ -77,6 +85,7 @@ func (u *NodeUsecase) Create(ctx context.Context, req *domain.CreateNodeReq, use
 	if err != nil {
 		return "", err
 	}
+	u.recordNodeAuditLogs(ctx, req.KBID, domain.AuditActionNodeCreate, nil, u.nodeSnapshots(ctx, []string{nodeID}))
 	return nodeID, nil
 }
 
@@ -138,30 +147,49 @@ func (u *NodeUsecase) GetNodeByKBID(ctx context.Context, id, kbId, format string
 func (u *NodeUsecase) NodeAction(ctx context.Context, req *domain.NodeActionReq) error {
 	switch req.Action {
 	case "delete":
-		docIDs, err := u.nodeRepo.Delete(ctx, req.KBID, req.IDs)
+		var userID string
+		if authInfo := domain.GetAuthInfoFromCtx(ctx); authInfo != nil {
+			userID = authInfo.UserId
+		}
+		before := u.nodeSnapshots(ctx, req.IDs)
+		// move to recycle bin
+		docIDs, err := u.nodeRepo.Delete(ctx, req.KBID, req.IDs, userID)
 		if err != nil {
 			return err
 		}
-		nodeVectorContentRequests := make([]*domain.NodeReleaseVectorRequest, 0)
-		for _, docID := range docIDs {
-			nodeVectorContentRequests = append(nodeVectorContentRequests, &domain.NodeReleaseVectorRequest{
-				KBID:   req.KBID,
-				DocID:  docID,
-				Action: "delete",
-			})
-		}
-		if err := u.ragRepo.AsyncUpdateNodeReleaseVector(ctx, nodeVectorContentRequests); err != nil {
+		u.recordNodeAuditLogs(ctx, req.KBID, domain.AuditActionNodeDelete, before, nil)
+		if err := u.deleteDocVectors(ctx, req.KBID, docIDs); err != nil {
 			return err
 		}
+		publishWebhookEvent(ctx, u.logger, u.webhookRepo, req.KBID, domain.WebhookEventNodeDeleted, &domain.WebhookNodeDeletedData{
+			NodeIDs: req.IDs,
+		})
 	}
 	return nil
 }
 
+func (u *NodeUsecase) deleteDocVectors(ctx context.Context, kbID string, docIDs []string) error {
+	if len(docIDs) == 0 {
+		return nil
+	}
+	nodeVectorContentRequests := make([]*domain.NodeReleaseVectorRequest, 0)
+	for _, docID := range docIDs {
+		nodeVectorContentRequests = append(nodeVectorContentRequests, &domain.NodeReleaseVectorRequest{
+			KBID:   kbID,
+			DocID:  docID,
+			Action: "delete",
+		})
+	}
+	return u.ragRepo.AsyncUpdateNodeReleaseVector(ctx, nodeVectorContentRequests)
+}
+
 func (u *NodeUsecase) Update(ctx context.Context, req *domain.UpdateNodeReq, userId string) error {
+	before := u.nodeSnapshots(ctx, []string{req.ID})
 	err := u.nodeRepo.UpdateNodeContent(ctx, req, userId)
 	if err != nil {
 		return err
 	}
+	u.recordNodeAuditLogs(ctx, req.KBID, domain.AuditActionNodeUpdate, before, u.nodeSnapshots(ctx, []string{req.ID}))
 	return nil
 }
 
@@ -254,11 +282,16 @@ func (u *NodeUsecase) GetNodeReleaseDetailByKBIDAndID(ctx context.Context, kbID,
 }
 
 func (u *NodeUsecase) MoveNode(ctx context.Context, req *domain.MoveNodeReq) error {
-	return u.nodeRepo.MoveNodeBetween(ctx, req.ID, req.ParentID, req.PrevID, req.NextID, req.KbID)
+	before := u.nodeSnapshots(ctx, []string{req.ID})
+	if err := u.nodeRepo.MoveNodeBetween(ctx, req.ID, req.ParentID, req.PrevID, req.NextID, req.KbID); err != nil {
+		return err
+	}
+	u.recordNodeAuditLogs(ctx, req.KbID, domain.AuditActionNodeMove, before, u.nodeSnapshots(ctx, []string{req.ID}))
+	return nil
 }
 
 func (u *NodeUsecase) SummaryNode(ctx context.Context, req *domain.NodeSummaryReq) (string, error) {
-	model, err := u.modelUsecase.GetChatModel(ctx)
+	model, err := u.modelUsecase.GetKBChatModel(ctx, req.KBID, nil)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return "", domain.ErrModelNotConfigured
@@ -339,7 +372,42 @@ func (u *NodeUsecase) GetRecommendNodeList(ctx context.Context, req *domain.GetR
 }
 
 func (u *NodeUsecase) BatchMoveNode(ctx context.Context, req *domain.BatchMoveReq) error {
-	return u.nodeRepo.BatchMove(ctx, req)
+	before := u.nodeSnapshots(ctx, req.IDs)
+	if err := u.nodeRepo.BatchMove(ctx, req); err != nil {
+		return err
+	}
+	u.recordNodeAuditLogs(ctx, req.KBID, domain.AuditActionNodeMove, before, u.nodeSnapshots(ctx, req.IDs))
+	return nil
+}
+
+// nodeSnapshots returns the audit snapshots of the nodes, a failure only loses the snapshots
+func (u *NodeUsecase) nodeSnapshots(ctx context.Context, ids []string) map[string]*domain.AuditNodeSnapshot {
+	snapshots := make(map[string]*domain.AuditNodeSnapshot, len(ids))
+	nodes, err := u.nodeRepo.GetNodesByIDs(ctx, ids)
+	if err != nil {
+		u.logger.Warn("get nodes for audit log failed", log.Error(err))
+		return snapshots
+	}
+	for id, node := range nodes {
+		snapshots[id] = domain.NewAuditNodeSnapshot(node)
+	}
+	return snapshots
+}
+
+// recordNodeAuditLogs records one audit log per node found in before or after
+func (u *NodeUsecase) recordNodeAuditLogs(ctx context.Context, kbID string, action domain.AuditAction, before, after map[string]*domain.AuditNodeSnapshot) {
+	ids := lo.Uniq(append(lo.Keys(before), lo.Keys(after)...))
+	slices.Sort(ids)
+	for _, id := range ids {
+		var beforeSnapshot, afterSnapshot any
+		if snapshot, ok := before[id]; ok {
+			beforeSnapshot = snapshot
+		}
+		if snapshot, ok := after[id]; ok {
+			afterSnapshot = snapshot
+		}
+		recordAuditLog(ctx, u.logger, u.auditRepo, kbID, action, domain.AuditTargetNode, id, beforeSnapshot, afterSnapshot)
+	}
 }
 
 func (u *NodeUsecase) convertMDToHTML(mdStr string) string {
@@ -454,6 +522,22 @@ func (u *NodeUsecase) buildNodeTree(parentID string, childrenMap map[string][]*d
 	return result
 }
 
+// IsNodeAnswerable reports whether the auth user may get answers from the node
+func (u *NodeUsecase) IsNodeAnswerable(ctx context.Context, nodeID string, perms domain.NodePermissions, authID uint) (bool, error) {
+	switch perms.Answerable {
+	case consts.NodeAccessPermOpen:
+		return true, nil
+	case consts.NodeAccessPermPartial:
+		nodeIDs, err := u.GetNodeIdsByAuthId(ctx, authID, consts.NodePermNameAnswerable)
+		if err != nil {
+			return false, err
+		}
+		return slices.Contains(nodeIDs, nodeID), nil
+	default:
+		return false, nil
+	}
+}
+
 func (u *NodeUsecase) GetNodeIdsByAuthId(ctx context.Context, authId uint, PermName consts.NodePermName) ([]string, error) {
 	authGroups, err := u.authRepo.GetAuthGroupWithParentsByAuthId(ctx, authId)
 	if err != nil {
@@ -523,6 +607,8 @@ func (u *NodeUsecase) ValidateNodePermissionsEdit(req v1.NodePermissionEditReq,
 }
 
 func (u *NodeUsecase) NodePermissionsEdit(ctx context.Context, req v1.NodePermissionEditReq) error {
+	before := u.nodePermissionSnapshots(ctx, req.KbId, req.IDs)
+
 	if req.Permissions != nil {
 		updateMap := map[string]interface{}{
 			"permissions": req.Permissions,
@@ -587,9 +673,32 @@ func (u *NodeUsecase) NodePermissionsEdit(ctx context.Context, req v1.NodePermis
 		}
 	}
 
+	// 缓存的回答按用户组区分，可回答范围变化后原有回答可能引用已无权访问的文档
+	if err := u.kbRepo.DeleteAnswerCaches(ctx, req.KbId); err != nil {
+		return fmt.Errorf("delete answer caches failed: %w", err)
+	}
+
+	after := u.nodePermissionSnapshots(ctx, req.KbId, req.IDs)
+	for _, id := range req.IDs {
+		recordAuditLog(ctx, u.logger, u.auditRepo, req.KbId, domain.AuditActionNodePermissionUpdate, domain.AuditTargetNode, id, before[id], after[id])
+	}
+
 	return nil
 }
 
+func (u *NodeUsecase) nodePermissionSnapshots(ctx context.Context, kbID string, ids []string) map[string]*v1.NodePermissionResp {
+	snapshots := make(map[string]*v1.NodePermissionResp, len(ids))
+	for _, id := range ids {
+		permissions, err := u.GetNodePermissionsByID(ctx, id, kbID)
+		if err != nil {
+			u.logger.Warn("get node permissions for audit log failed", log.String("node_id", id), log.Error(err))
+			continue
+		}
+		snapshots[id] = permissions
+	}
+	return snapshots
+}
+
 func (u *NodeUsecase) SyncRagNodeStatus(ctx context.Context) error {
 	kbs, err := u.kbRepo.GetKnowledgeBaseList(ctx)
 	if err != nil {
@@ -710,3 +819,214 @@ func (u *NodeUsecase) NodeRestudy(ctx context.Context, req *v1.NodeRestudyReq) e
 
 	return nil
 }
+
+func (u *NodeUsecase) GetNodeReleaseList(ctx context.Context, req *v1.NodeReleaseListReq) (*v1.NodeReleaseListResp, error) {
+	total, releases, err := u.nodeRepo.GetNodeReleaseListByNodeID(ctx, req.KbId, req.NodeId, req.Offset(), req.Limit())
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPaginatedResult(releases, uint64(total)), nil
+}
+
+func (u *NodeUsecase) GetNodeReleaseDetail(ctx context.Context, req *v1.NodeReleaseDetailReq) (*v1.NodeReleaseDetailResp, error) {
+	release, err := u.getKBNodeRelease(ctx, req.KbId, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.NodeReleaseDetailResp{
+		ID:          release.ID,
+		NodeID:      release.NodeID,
+		Type:        release.Type,
+		Name:        release.Name,
+		Content:     release.Content,
+		Meta:        release.Meta,
+		PublisherId: release.PublisherId,
+		EditorId:    release.EditorId,
+		UpdatedAt:   release.UpdatedAt,
+	}, nil
+}
+
+// DiffNodeRelease compares two releases of the same node, or a release with the current draft when new_id is empty
+func (u *NodeUsecase) DiffNodeRelease(ctx context.Context, req *v1.NodeReleaseDiffReq) (*v1.NodeReleaseDiffResp, error) {
+	oldRelease, err := u.getKBNodeRelease(ctx, req.KbId, req.OldId)
+	if err != nil {
+		return nil, err
+	}
+
+	var newSide v1.NodeReleaseDiffSide
+	var newContent string
+	if req.NewId != "" {
+		newRelease, err := u.getKBNodeRelease(ctx, req.KbId, req.NewId)
+		if err != nil {
+			return nil, err
+		}
+		if newRelease.NodeID != oldRelease.NodeID {
+			return nil, fmt.Errorf("releases belong to different nodes")
+		}
+		newSide = v1.NodeReleaseDiffSide{ID: newRelease.ID, Name: newRelease.Name, UpdatedAt: newRelease.UpdatedAt}
+		newContent = newRelease.Content
+	} else {
+		node, err := u.nodeRepo.GetByID(ctx, oldRelease.NodeID, req.KbId)
+		if err != nil {
+			return nil, err
+		}
+		newSide = v1.NodeReleaseDiffSide{Name: node.Name, UpdatedAt: node.UpdatedAt}
+		newContent = node.Content
+	}
+
+	lines, stats := utils.DiffContent(oldRelease.Content, newContent)
+	return &v1.NodeReleaseDiffResp{
+		NodeID:      oldRelease.NodeID,
+		Old:         v1.NodeReleaseDiffSide{ID: oldRelease.ID, Name: oldRelease.Name, UpdatedAt: oldRelease.UpdatedAt},
+		New:         newSide,
+		NameChanged: oldRelease.Name != newSide.Name,
+		Stats:       stats,
+		Lines:       lines,
+	}, nil
+}
+
+// RestoreNodeRelease writes the release content back into the node as a draft and publishes it
+// by default, which queues the vector update of the restored content. Only vectors of published
+// releases are indexed, so a draft-only restore (req.Publish set to false) is not re-vectorized
+// until the node is published
+func (u *NodeUsecase) RestoreNodeRelease(ctx context.Context, req *v1.NodeReleaseRestoreReq, userId string) (*v1.NodeReleaseRestoreResp, error) {
+	release, err := u.getKBNodeRelease(ctx, req.KbId, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.nodeRepo.UpdateNodeContent(ctx, &domain.UpdateNodeReq{
+		ID:      release.NodeID,
+		KBID:    req.KbId,
+		Name:    &release.Name,
+		Content: &release.Content,
+		Emoji:   &release.Meta.Emoji,
+		Summary: &release.Meta.Summary,
+	}, userId); err != nil {
+		return nil, err
+	}
+
+	resp := &v1.NodeReleaseRestoreResp{NodeID: release.NodeID}
+	if req.Publish != nil && !*req.Publish {
+		return resp, nil
+	}
+
+	resp.ReleaseID, err = u.PublishNodes(ctx, req.KbId, userId, []string{release.NodeID},
+		fmt.Sprintf("restore-%s", time.Now().Format("20060102150405")),
+		fmt.Sprintf("恢复文档「%s」到 %s 的版本", release.Name, release.UpdatedAt.Format(time.DateTime)))
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// PublishNodes publishes the drafts of nodeIDs as a new kb release and queues their vector update,
+// returns the id of the kb release
+func (u *NodeUsecase) PublishNodes(ctx context.Context, kbID, userId string, nodeIDs []string, tag, message string) (string, error) {
+	releaseIDs, err := u.nodeRepo.CreateNodeReleases(ctx, kbID, userId, nodeIDs)
+	if err != nil {
+		return "", fmt.Errorf("failed to create published nodes: %w", err)
+	}
+	nodeContentVectorRequests := make([]*domain.NodeReleaseVectorRequest, 0, len(releaseIDs))
+	for _, releaseID := range releaseIDs {
+		nodeContentVectorRequests = append(nodeContentVectorRequests, &domain.NodeReleaseVectorRequest{
+			KBID:          kbID,
+			NodeReleaseID: releaseID,
+			Action:        "upsert",
+		})
+	}
+	if err := u.ragRepo.AsyncUpdateNodeReleaseVector(ctx, nodeContentVectorRequests); err != nil {
+		return "", err
+	}
+
+	kbRelease := &domain.KBRelease{
+		ID:          uuid.New().String(),
+		KBID:        kbID,
+		Tag:         tag,
+		Message:     message,
+		PublisherId: userId,
+		CreatedAt:   time.Now(),
+	}
+	if err := u.kbRepo.CreateKBRelease(ctx, kbRelease); err != nil {
+		return "", fmt.Errorf("failed to create kb release: %w", err)
+	}
+	publishKBReleaseWebhookEvents(ctx, u.logger, u.webhookRepo, kbRelease, nodeIDs)
+	return kbRelease.ID, nil
+}
+
+func (u *NodeUsecase) getKBNodeRelease(ctx context.Context, kbID, id string) (*domain.NodeRelease, error) {
+	release, err := u.nodeRepo.GetNodeReleaseByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if release.KBID != kbID {
+		return nil, domain.ErrPermissionDenied
+	}
+	return release, nil
+}
+
+func (u *NodeUsecase) GetTrashList(ctx context.Context, req *v1.NodeTrashListReq) (*v1.NodeTrashListResp, error) {
+	total, items, err := u.nodeRepo.GetTrashList(ctx, req.KbId, req.Offset(), req.Limit())
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPaginatedResult(items, uint64(total)), nil
+}
+
+// RestoreTrash restores nodes from recycle bin and re-vectorizes their latest releases
+func (u *NodeUsecase) RestoreTrash(ctx context.Context, req *v1.NodeTrashRestoreReq) error {
+	nodeIDs, err := u.nodeRepo.RestoreTrash(ctx, req.KbId, req.IDs)
+	if err != nil {
+		return err
+	}
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	nodeReleases, err := u.nodeRepo.GetLatestNodeReleaseByNodeIDs(ctx, req.KbId, nodeIDs)
+	if err != nil {
+		return err
+	}
+	if len(nodeReleases) == 0 {
+		return nil
+	}
+	nodeContentVectorRequests := make([]*domain.NodeReleaseVectorRequest, 0, len(nodeReleases))
+	for _, nodeRelease := range nodeReleases {
+		nodeContentVectorRequests = append(nodeContentVectorRequests, &domain.NodeReleaseVectorRequest{
+			KBID:          req.KbId,
+			NodeReleaseID: nodeRelease.ID,
+			Action:        "upsert",
+		})
+	}
+	return u.ragRepo.AsyncUpdateNodeReleaseVector(ctx, nodeContentVectorRequests)
+}
+
+func (u *NodeUsecase) PurgeTrash(ctx context.Context, req *v1.NodeTrashPurgeReq) error {
+	var rootIDs []string
+	if !req.All {
+		if len(req.IDs) == 0 {
+			return nil
+		}
+		rootIDs = req.IDs
+	}
+	docIDs, err := u.nodeRepo.PurgeTrash(ctx, req.KbId, rootIDs)
+	if err != nil {
+		return err
+	}
+	return u.deleteDocVectors(ctx, req.KbId, docIDs)
+}
+
+// PurgeExpiredTrash permanently deletes nodes stayed in recycle bin longer than retention
+func (u *NodeUsecase) PurgeExpiredTrash(ctx context.Context, retention time.Duration) error {
+	expired, err := u.nodeRepo.GetExpiredTrashRootIDs(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return err
+	}
+	for kbID, rootIDs := range expired {
+		if err := u.PurgeTrash(ctx, &v1.NodeTrashPurgeReq{KbId: kbID, IDs: rootIDs}); err != nil {
+			u.logger.Error("purge expired trash failed", log.String("kb_id", kbID), log.Error(err))
+			continue
+		}
+		u.logger.Info("purge expired trash", log.String("kb_id", kbID), log.Int("count", len(rootIDs)))
+	}
+	return nil
+}
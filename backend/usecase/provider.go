@@ -35,4 +35,16 @@ var ProviderSet = wire.NewSet(
 	NewWecomUsecase,
 	NewWechatAppUsecase,
 	NewAuthUsecase,
+	NewMCPUsecase,
+	NewEvalUsecase,
+	NewKnowledgeGapUsecase,
+	NewAnswerCacheUsecase,
+	NewFAQUsecase,
+	NewAgentUsecase,
+	NewRetrievalAPIUsecase,
+	NewAPITokenUsecase,
+	NewContributeUsecase,
+	NewWebhookUsecase,
+	NewAuditLogUsecase,
+	NewVectorTaskUsecase,
 )
@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+// RetrievalAPIUsecase serves the retrieval and embeddings api of the api app,
+// callers are treated as the users of the api app when filtering nodes by permissions
+type RetrievalAPIUsecase struct {
+	llmUsecase   *LLMUsecase
+	modelUsecase *ModelUsecase
+	authRepo     *pg.AuthRepo
+	usageRepo    *pg.APIUsageRepository
+	logger       *log.Logger
+}
+
+func NewRetrievalAPIUsecase(
+	llmUsecase *LLMUsecase,
+	modelUsecase *ModelUsecase,
+	authRepo *pg.AuthRepo,
+	usageRepo *pg.APIUsageRepository,
+	logger *log.Logger,
+) *RetrievalAPIUsecase {
+	return &RetrievalAPIUsecase{
+		llmUsecase:   llmUsecase,
+		modelUsecase: modelUsecase,
+		authRepo:     authRepo,
+		usageRepo:    usageRepo,
+		logger:       logger.WithModule("usecase.retrieval_api"),
+	}
+}
+
+// Retrieve returns the chunks of the ranked nodes in rank order, the tokens are those of the query
+func (u *RetrievalAPIUsecase) Retrieve(ctx context.Context, kb *domain.KnowledgeBase, req *domain.RetrievalAPIReq) (*domain.RetrievalAPIResp, error) {
+	groupIDs, err := u.getGroupIDs(ctx, kb.ID)
+	if err != nil {
+		return nil, err
+	}
+	rankReq := NewGetRankNodesRequest(kb, req.Query, groupIDs)
+	if req.TopK > 0 {
+		rankReq.TopK = req.TopK
+	}
+	result, err := u.llmUsecase.RankNodes(ctx, rankReq)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &domain.RetrievalAPIResp{
+		KBID:           kb.ID,
+		Query:          req.Query,
+		RewrittenQuery: result.RewrittenQuery,
+		Chunks:         make([]*domain.RetrievalAPIChunk, 0),
+	}
+	for i, node := range result.Nodes {
+		for _, chunk := range node.Chunks {
+			resp.Chunks = append(resp.Chunks, &domain.RetrievalAPIChunk{
+				NodeID:        node.NodeID,
+				NodeName:      node.NodeName,
+				NodePathNames: node.NodePathNames,
+				Text:          chunk.Content,
+				Score:         chunk.Score,
+				Rank:          i + 1,
+			})
+		}
+	}
+	if counter, err := newTokenCounter(); err == nil {
+		resp.Usage.Tokens = counter.Count(req.Query)
+	}
+	return resp, nil
+}
+
+// Embed embeds input with the embedding model of the kb, returns the model name and the prompt tokens
+func (u *RetrievalAPIUsecase) Embed(ctx context.Context, input []string) ([][]float32, string, int, error) {
+	embeddings, model, tokens, err := u.modelUsecase.EmbedWithUsage(ctx, input)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	return embeddings, model.Model, tokens, nil
+}
+
+// RecordUsage adds a request to the daily usage, failures are only logged
+func (u *RetrievalAPIUsecase) RecordUsage(ctx context.Context, kbID, endpoint string, tokens int) {
+	if err := u.usageRepo.Add(ctx, kbID, endpoint, time.Now(), tokens); err != nil {
+		u.logger.Error("record api usage failed", log.String("kb_id", kbID), log.String("endpoint", endpoint), log.Error(err))
+	}
+}
+
+// ListUsage returns the daily usage of the last days, today included
+func (u *RetrievalAPIUsecase) ListUsage(ctx context.Context, kbID string, days int) ([]*domain.APIUsage, error) {
+	since := time.Now().AddDate(0, 0, 1-days)
+	return u.usageRepo.List(ctx, kbID, time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location()))
+}
+
+func (u *RetrievalAPIUsecase) getGroupIDs(ctx context.Context, kbID string) ([]int, error) {
+	var authID uint
+	auth, _ := u.authRepo.GetAuthByKBIDAndSourceType(ctx, kbID, domain.AppTypeOpenAIAPI.ToSourceType())
+	if auth != nil {
+		authID = auth.ID
+	}
+	return u.authRepo.GetAuthGroupIdsWithParentsByAuthId(ctx, authID)
+}
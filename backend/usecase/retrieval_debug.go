@@ -0,0 +1,190 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/samber/lo"
+
+	v1 "github.com/chaitin/panda-wiki/api/conversation/v1"
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+// DebugRetrieval replays the retrieval of a question or an existing conversation message,
+// returning every retrieved chunk with its score and the final prompt sent to the model
+func (u *ChatUsecase) DebugRetrieval(ctx context.Context, req *v1.RetrievalDebugReq) (*v1.RetrievalDebugResp, error) {
+	resp := &v1.RetrievalDebugResp{
+		Question:   req.Question,
+		MessageId:  req.MessageId,
+		AuthUserId: req.AuthUserId,
+	}
+	appType := req.AppType
+	if appType == 0 {
+		appType = domain.AppTypeWidget
+	}
+	var (
+		msgs         []*domain.ConversationMessage
+		systemPrompt string
+		app          *domain.App
+	)
+	switch {
+	case req.MessageId != "":
+		message, err := u.conversationUsecase.repo.GetConversationMessagesDetailByKbID(ctx, req.KbId, req.MessageId)
+		if err != nil {
+			return nil, fmt.Errorf("get message failed: %w", err)
+		}
+		conversation, err := u.conversationUsecase.repo.GetConversationByID(ctx, req.KbId, message.ConversationID)
+		if err != nil {
+			return nil, fmt.Errorf("get conversation failed: %w", err)
+		}
+		history, err := u.conversationUsecase.repo.GetConversationMessagesByID(ctx, conversation.ID)
+		if err != nil {
+			return nil, fmt.Errorf("get conversation messages failed: %w", err)
+		}
+		msgs = conversationMessagesUntilQuestion(history, message)
+		if len(msgs) == 0 {
+			return nil, fmt.Errorf("question of message %s not found", message.ID)
+		}
+		resp.ConversationId = conversation.ID
+		resp.Question = msgs[len(msgs)-1].Content
+		if resp.AuthUserId == 0 {
+			resp.AuthUserId = conversation.Info.UserInfo.AuthUserID
+		}
+		if conversationApp, err := u.appRepo.GetAppDetail(ctx, conversation.AppID); err == nil {
+			app = conversationApp
+			appType = app.Type
+			if app.Type == domain.AppTypeWechatBot {
+				systemPrompt = app.Settings.WeChatAppAdvancedSetting.Prompt
+			}
+		}
+	case req.Question != "":
+		msgs = []*domain.ConversationMessage{{
+			KBID:    req.KbId,
+			Role:    schema.User,
+			Content: req.Question,
+		}}
+	default:
+		return nil, fmt.Errorf("question or message_id is required")
+	}
+
+	if resp.AuthUserId == 0 {
+		auth, _ := u.AuthRepo.GetAuthBySourceType(ctx, appType.ToSourceType())
+		if auth != nil {
+			resp.AuthUserId = auth.ID
+		}
+	}
+	groupIds, err := u.AuthRepo.GetAuthGroupIdsWithParentsByAuthId(ctx, resp.AuthUserId)
+	if err != nil {
+		return nil, fmt.Errorf("get auth group ids failed: %w", err)
+	}
+	resp.GroupIds = groupIds
+
+	kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, req.KbId)
+	if err != nil {
+		return nil, fmt.Errorf("get kb failed: %w", err)
+	}
+	resp.Settings = kb.RetrievalSettings.WithDefaults()
+
+	// 预算与对话相同，按对话模型及备用模型计算，复现时不生成新的历史摘要，超出预算的历史直接丢弃
+	buildReq := &BuildMessagesRequest{
+		Messages:     msgs,
+		KBID:         req.KbId,
+		GroupIDs:     groupIds,
+		SystemPrompt: systemPrompt,
+	}
+	if models, err := u.modelUsecase.GetKBChatModelChain(ctx, req.KbId, app); err == nil {
+		buildReq.Model, buildReq.FallbackModels = models[0], models[1:]
+	}
+	built, err := u.llmUsecase.BuildMessagesWithRAG(ctx, buildReq)
+	if err != nil {
+		return nil, err
+	}
+	result := built.Rank
+	resp.Budget = built.Budget
+	resp.RewrittenQuery = result.RewrittenQuery
+	if result.KeywordErr != nil {
+		resp.KeywordError = result.KeywordErr.Error()
+	}
+	resp.Prompt = lo.Map(built.Messages, func(msg *schema.Message, _ int) *v1.RetrievalDebugPromptMessage {
+		return &v1.RetrievalDebugPromptMessage{Role: msg.Role, Content: msg.Content}
+	})
+
+	// retrieve again with all groups of the kb, hits missing from the user's retrieval are hidden by permissions
+	allGroupIds, err := u.AuthRepo.GetAuthGroupIdsByKBID(ctx, req.KbId)
+	if err != nil {
+		return nil, fmt.Errorf("get kb auth group ids failed: %w", err)
+	}
+	unrestrictedReq := result.Request
+	unrestrictedReq.GroupIDs = allGroupIds
+	unrestricted, err := u.llmUsecase.RankNodes(ctx, unrestrictedReq)
+	if err != nil {
+		return nil, err
+	}
+
+	rankedNodeIDs := lo.SliceToMap(result.Nodes, func(node *domain.RankedNodeChunks) (string, struct{}) {
+		return node.NodeID, struct{}{}
+	})
+	usedNodeIDs := lo.SliceToMap(built.Nodes, func(node *domain.RankedNodeChunks) (string, struct{}) {
+		return node.NodeID, struct{}{}
+	})
+	resp.Chunks = make([]*v1.RetrievalDebugChunk, 0)
+	seen := make(map[string]struct{})
+	addChunks := func(nodes []*domain.RankedNodeChunks, source string, fusedScores map[string]float64, visible bool) {
+		for i, node := range nodes {
+			for _, chunk := range node.Chunks {
+				key := source + ":" + chunk.ID
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+				status := v1.RetrievalDebugChunkStatusDroppedPermission
+				if visible {
+					status = v1.RetrievalDebugChunkStatusDroppedTopK
+					if _, ok := usedNodeIDs[node.NodeID]; ok {
+						status = v1.RetrievalDebugChunkStatusUsed
+					} else if _, ok := rankedNodeIDs[node.NodeID]; ok {
+						status = v1.RetrievalDebugChunkStatusDroppedBudget
+					}
+				}
+				resp.Chunks = append(resp.Chunks, &v1.RetrievalDebugChunk{
+					NodeID:        node.NodeID,
+					NodeName:      node.NodeName,
+					NodePathNames: node.NodePathNames,
+					ChunkID:       chunk.ID,
+					DocID:         chunk.DocID,
+					Seq:           chunk.Seq,
+					Content:       chunk.Content,
+					Source:        source,
+					Rank:          i + 1,
+					Score:         chunk.Score,
+					FusedScore:    fusedScores[node.NodeID],
+					Status:        status,
+				})
+			}
+		}
+	}
+	addChunks(result.VectorNodes, "vector", result.FusedScores, true)
+	addChunks(result.KeywordNodes, "keyword", result.FusedScores, true)
+	addChunks(unrestricted.VectorNodes, "vector", unrestricted.FusedScores, false)
+	addChunks(unrestricted.KeywordNodes, "keyword", unrestricted.FusedScores, false)
+	return resp, nil
+}
+
+// conversationMessagesUntilQuestion returns the history up to the question answered by message
+func conversationMessagesUntilQuestion(history []*domain.ConversationMessage, message *domain.ConversationMessage) []*domain.ConversationMessage {
+	questionID := message.ID
+	if message.Role == schema.Assistant {
+		questionID = message.ParentID
+	}
+	for i, msg := range history {
+		if msg.ID == questionID && msg.Role == schema.User {
+			return history[:i+1]
+		}
+		// answers saved without parent id follow their question directly
+		if msg.ID == message.ID && questionID == "" && i > 0 && history[i-1].Role == schema.User {
+			return history[:i]
+		}
+	}
+	return nil
+}
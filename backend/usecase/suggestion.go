@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+const (
+	suggestionCount         = 3
+	suggestionMaxRunes      = 60  // 超长的行通常不是问题，直接丢弃
+	suggestionDocumentRunes = 300 // 每篇参考文档放入提示词的长度
+	suggestionAnswerRunes   = 1500
+	suggestionTimeout       = 20 * time.Second
+)
+
+// suggestionPrefixRegexp matches the list markers the model may add despite the prompt
+var suggestionPrefixRegexp = regexp.MustCompile(`^\s*(?:[-*•]|\d+\s*[.、:：)）]|[（(]\d+[)）])\s*`)
+
+// GenerateSuggestions asks the chat model for follow-up questions which the retrieved documents can answer
+func (u *LLMUsecase) GenerateSuggestions(
+	ctx context.Context,
+	model *domain.Model,
+	question, answer string,
+	nodes []*domain.RankedNodeChunks,
+) ([]string, error) {
+	modelkitModel, err := model.ToModelkitModel()
+	if err != nil {
+		return nil, err
+	}
+	chatModel, err := u.modelkit.GetChatModel(ctx, modelkitModel)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, suggestionTimeout)
+	defer cancel()
+
+	content := strings.Builder{}
+	content.WriteString(fmt.Sprintf("用户的问题：%s\n\n", question))
+	content.WriteString(fmt.Sprintf("助手的回答：\n%s\n\n", truncateRunes(u.trimThinking(answer), suggestionAnswerRunes)))
+	content.WriteString("参考文档：\n")
+	for i, node := range nodes {
+		document := node.NodeSummary
+		if document == "" && len(node.Chunks) > 0 {
+			document = node.Chunks[0].Content
+		}
+		content.WriteString(fmt.Sprintf("%d. %s：%s\n", i+1, node.NodeName, truncateRunes(document, suggestionDocumentRunes)))
+	}
+	output, err := u.Generate(ctx, chatModel, []*schema.Message{
+		schema.SystemMessage(domain.SystemSuggestionPrompt),
+		schema.UserMessage(content.String()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseSuggestions(u.trimThinking(output), question), nil
+}
+
+// parseSuggestions takes one question per line, dropping list markers, duplicates and the original question
+func parseSuggestions(output, question string) []string {
+	seen := map[string]bool{domain.NormalizeQuestion(question): true}
+	suggestions := make([]string, 0, suggestionCount)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.Trim(strings.TrimSpace(suggestionPrefixRegexp.ReplaceAllString(line, "")), `"“”`)
+		normalized := domain.NormalizeQuestion(line)
+		if normalized == "" || seen[normalized] || len([]rune(line)) > suggestionMaxRunes {
+			continue
+		}
+		seen[normalized] = true
+		suggestions = append(suggestions, line)
+		if len(suggestions) == suggestionCount {
+			break
+		}
+	}
+	return suggestions
+}
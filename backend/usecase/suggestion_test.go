@@ -0,0 +1,13 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSuggestions(t *testing.T) {
+	output := "1. 如何配置 SSO？\n\n- 如何配置SSO?\n2、支持哪些登录方式？\n（3）如何重置密码\n“PandaWiki 是什么？”\n多余的第四个问题？"
+	assert.Equal(t, []string{"如何配置 SSO？", "支持哪些登录方式？", "如何重置密码"}, parseSuggestions(output, "PandaWiki 是什么"))
+	assert.Empty(t, parseSuggestions("\n  \n", "问题"))
+}
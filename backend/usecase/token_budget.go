@@ -0,0 +1,274 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/pkoukk/tiktoken-go"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+)
+
+const (
+	defaultContextWindow  = 32768
+	defaultReservedOutput = 4096
+	// documentBudgetRatio 文档最多占用可用预算的比例，其余留给历史对话
+	documentBudgetRatio = 0.6
+	// messageTokenOverhead 每条消息的角色、分隔符等额外开销
+	messageTokenOverhead = 4
+	// historySummaryTimeout 后台生成历史摘要的超时时间
+	historySummaryTimeout = 2 * time.Minute
+)
+
+// tokenCounter estimates token counts with cl100k_base, which is close enough for budgeting other tokenizers
+type tokenCounter struct {
+	encoding *tiktoken.Tiktoken
+}
+
+func newTokenCounter() (*tokenCounter, error) {
+	encoding, err := tiktoken.GetEncoding("cl100k_base")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encoding: %w", err)
+	}
+	return &tokenCounter{encoding: encoding}, nil
+}
+
+func (c *tokenCounter) Count(text string) int {
+	return len(c.encoding.Encode(text, nil, nil))
+}
+
+func (c *tokenCounter) CountMessage(msg *schema.Message) int {
+	return c.Count(msg.Content) + messageTokenOverhead
+}
+
+func (c *tokenCounter) Truncate(text string, maxTokens int) string {
+	tokens := c.encoding.Encode(text, nil, nil)
+	if len(tokens) <= maxTokens {
+		return text
+	}
+	return c.encoding.Decode(tokens[:maxTokens])
+}
+
+// newTokenBudget reads the context window and the reserved output of the models. The prompt has to fit whichever
+// model of the failover chain answers, so the budget of the model with the least room for the input is used.
+// Models may be empty or contain nil.
+func newTokenBudget(models ...*domain.Model) *domain.TokenBudget {
+	budget := modelTokenBudget(nil)
+	for i, model := range models {
+		candidate := modelTokenBudget(model)
+		if i == 0 || candidate.ContextWindow-candidate.ReservedOutput < budget.ContextWindow-budget.ReservedOutput {
+			budget = candidate
+		}
+	}
+	return budget
+}
+
+func modelTokenBudget(model *domain.Model) *domain.TokenBudget {
+	budget := &domain.TokenBudget{
+		ContextWindow:  defaultContextWindow,
+		ReservedOutput: defaultReservedOutput,
+	}
+	if model != nil && model.Parameters.ContextWindow > 0 {
+		budget.ContextWindow = model.Parameters.ContextWindow
+	}
+	if model != nil && model.Parameters.MaxTokens > 0 {
+		budget.ReservedOutput = model.Parameters.MaxTokens
+	}
+	// 上下文较小的模型至少保留一半给输入
+	budget.ReservedOutput = min(budget.ReservedOutput, budget.ContextWindow/2)
+	return budget
+}
+
+// CountTools estimates the tokens of the tool definitions sent along with the messages
+func (c *tokenCounter) CountTools(tools []*schema.ToolInfo) int {
+	tokens := 0
+	for _, tool := range tools {
+		tokens += c.Count(tool.Name) + c.Count(tool.Desc) + messageTokenOverhead
+		if tool.ParamsOneOf == nil {
+			continue
+		}
+		if params, err := tool.ParamsOneOf.ToJSONSchema(); err == nil && params != nil {
+			if raw, err := json.Marshal(params); err == nil {
+				tokens += c.Count(string(raw))
+			}
+		}
+	}
+	return tokens
+}
+
+// planDocuments keeps the top ranked nodes whose formatted documents fit in limit,
+// the chunks of the first node are truncated when even it alone does not fit
+func planDocuments(counter *tokenCounter, nodes []*domain.RankedNodeChunks, baseURL string, limit int) ([]*domain.RankedNodeChunks, string, int) {
+	kept := make([]*domain.RankedNodeChunks, 0, len(nodes))
+	documents, tokens := "", 0
+	for i := range nodes {
+		candidate := domain.FormatNodeChunks(nodes[:i+1], baseURL)
+		candidateTokens := counter.Count(candidate)
+		if candidateTokens > limit {
+			break
+		}
+		kept = nodes[:i+1]
+		documents, tokens = candidate, candidateTokens
+	}
+	if len(kept) > 0 || len(nodes) == 0 {
+		return kept, documents, tokens
+	}
+
+	node := *nodes[0]
+	node.Chunks = nil
+	remaining := limit - counter.Count(domain.FormatNodeChunks([]*domain.RankedNodeChunks{&node}, baseURL))
+	for _, chunk := range nodes[0].Chunks {
+		if remaining <= 0 {
+			break
+		}
+		truncated := *chunk
+		truncated.Content = counter.Truncate(chunk.Content, remaining)
+		node.Chunks = append(node.Chunks, &truncated)
+		remaining -= counter.Count(truncated.Content) + 1
+	}
+	if len(node.Chunks) == 0 {
+		return kept, "", 0
+	}
+	kept = []*domain.RankedNodeChunks{&node}
+	documents = domain.FormatNodeChunks(kept, baseURL)
+	return kept, documents, counter.Count(documents)
+}
+
+// planHistory returns the index from which the most recent history fits in limit, and the tokens it takes.
+// The kept history never starts with an assistant message to avoid an answer without its question.
+func planHistory(counter *tokenCounter, history []*schema.Message, limit int) (int, int) {
+	keepFrom, tokens := len(history), 0
+	for i := len(history) - 1; i >= 0; i-- {
+		msgTokens := counter.CountMessage(history[i])
+		if tokens+msgTokens > limit {
+			break
+		}
+		keepFrom, tokens = i, tokens+msgTokens
+	}
+	for keepFrom < len(history) && history[keepFrom].Role == schema.Assistant {
+		tokens -= counter.CountMessage(history[keepFrom])
+		keepFrom++
+	}
+	return keepFrom, tokens
+}
+
+// summarizeHistory merges the previous summary with the messages into a new rolling summary
+func (u *LLMUsecase) summarizeHistory(ctx context.Context, model *domain.Model, previous string, history []*schema.Message) (string, error) {
+	modelkitModel, err := model.ToModelkitModel()
+	if err != nil {
+		return "", err
+	}
+	chatModel, err := u.modelkit.GetChatModel(ctx, modelkitModel)
+	if err != nil {
+		return "", err
+	}
+	content := strings.Builder{}
+	if previous != "" {
+		content.WriteString(fmt.Sprintf("之前的摘要：\n%s\n\n", previous))
+	}
+	content.WriteString("新增的对话：\n")
+	for _, msg := range history {
+		role := "用户"
+		if msg.Role == schema.Assistant {
+			role = "助手"
+		}
+		content.WriteString(fmt.Sprintf("%s：%s\n", role, u.trimThinking(msg.Content)))
+	}
+	summary, err := u.Generate(ctx, chatModel, []*schema.Message{
+		schema.SystemMessage(domain.SystemHistorySummaryPrompt),
+		schema.UserMessage(content.String()),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(u.trimThinking(summary)), nil
+}
+
+// planConversationHistory fits the history into the budget with the rolling summary of the conversation,
+// messages beyond the budget are trimmed and, when conversationID and model are given, folded into the summary in the background
+func (u *LLMUsecase) planConversationHistory(
+	ctx context.Context,
+	counter *tokenCounter,
+	budget *domain.TokenBudget,
+	kbID string,
+	conversationID string,
+	model *domain.Model,
+	history []*schema.Message,
+) ([]*schema.Message, *schema.Message) {
+	var summary *domain.ConversationHistorySummary
+	if conversationID != "" {
+		conversation, err := u.conversationRepo.GetConversationByID(ctx, kbID, conversationID)
+		if err != nil {
+			u.logger.Warn("get conversation history summary failed", log.String("conversation_id", conversationID), log.Error(err))
+		} else if conversation.HistorySummary != nil && conversation.HistorySummary.MessageCount <= len(history) {
+			summary = conversation.HistorySummary
+		}
+	}
+
+	var summaryMessage *schema.Message
+	summarized, summaryTokens := 0, 0
+	if summary != nil {
+		summarized = summary.MessageCount
+		if summary.Content != "" {
+			summaryMessage = schema.SystemMessage(fmt.Sprintf(domain.HistorySummaryFormatter, summary.Content))
+			summaryTokens = counter.CountMessage(summaryMessage)
+		}
+	}
+	keepFrom, historyTokens := planHistory(counter, history[summarized:], max(budget.HistoryLimit-summaryTokens, 0))
+	keepFrom += summarized
+
+	// 摘要在后台生成，不阻塞本次回答，本次超出预算的消息直接丢弃，之后的对话使用新的摘要
+	if keepFrom > summarized && conversationID != "" && model != nil {
+		previous := ""
+		if summary != nil {
+			previous = summary.Content
+		}
+		u.summarizeConversationHistory(ctx, conversationID, model, previous, slices.Clone(history[summarized:keepFrom]), keepFrom)
+	}
+
+	budget.SummarizedTurns = summarized
+	budget.TrimmedTurns = keepFrom - summarized
+	budget.Summary = summaryTokens
+	budget.History = historyTokens
+	return history[keepFrom:], summaryMessage
+}
+
+// summarizeConversationHistory folds the messages into the rolling summary of the conversation in the background,
+// messageCount is the number of history messages the new summary covers
+func (u *LLMUsecase) summarizeConversationHistory(
+	ctx context.Context,
+	conversationID string,
+	model *domain.Model,
+	previous string,
+	messages []*schema.Message,
+	messageCount int,
+) {
+	// 同一会话同时只生成一份摘要
+	if _, running := u.summarizing.LoadOrStore(conversationID, struct{}{}); running {
+		return
+	}
+	go func() {
+		defer u.summarizing.Delete(conversationID)
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), historySummaryTimeout)
+		defer cancel()
+		content, err := u.summarizeHistory(ctx, model, previous, messages)
+		if err != nil {
+			u.logger.Warn("summarize conversation history failed", log.String("conversation_id", conversationID), log.Error(err))
+			return
+		}
+		summary := &domain.ConversationHistorySummary{
+			Content:      content,
+			MessageCount: messageCount,
+			UpdatedAt:    time.Now(),
+		}
+		if err := u.conversationRepo.UpdateConversationHistorySummary(ctx, conversationID, summary); err != nil {
+			u.logger.Warn("save conversation history summary failed", log.String("conversation_id", conversationID), log.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/pkoukk/tiktoken-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+func newTestTokenCounter(t *testing.T) *tokenCounter {
+	tiktoken.SetBpeLoader(&utils.Localloader{})
+	counter, err := newTokenCounter()
+	require.NoError(t, err)
+	return counter
+}
+
+func TestPlanHistory(t *testing.T) {
+	counter := newTestTokenCounter(t)
+	history := []*schema.Message{
+		schema.UserMessage(strings.Repeat("old question ", 50)),
+		schema.AssistantMessage(strings.Repeat("old answer ", 50), nil),
+		schema.UserMessage("new question"),
+		schema.AssistantMessage("new answer", nil),
+	}
+
+	keepFrom, tokens := planHistory(counter, history, 1000)
+	assert.Equal(t, 0, keepFrom)
+	assert.Greater(t, tokens, 100)
+
+	keepFrom, tokens = planHistory(counter, history, 20)
+	assert.Equal(t, 2, keepFrom)
+	assert.Equal(t, counter.CountMessage(history[2])+counter.CountMessage(history[3]), tokens)
+
+	// an answer is never kept without its question
+	keepFrom, _ = planHistory(counter, history, counter.CountMessage(history[3]))
+	assert.Equal(t, 4, keepFrom)
+}
+
+func TestPlanDocuments(t *testing.T) {
+	counter := newTestTokenCounter(t)
+	nodes := []*domain.RankedNodeChunks{
+		{NodeID: "a", NodeName: "A", Chunks: []*domain.NodeContentChunk{{Content: strings.Repeat("alpha ", 200)}}},
+		{NodeID: "b", NodeName: "B", Chunks: []*domain.NodeContentChunk{{Content: strings.Repeat("beta ", 200)}}},
+	}
+
+	kept, _, tokens := planDocuments(counter, nodes, "", 10000)
+	assert.Len(t, kept, 2)
+	assert.LessOrEqual(t, tokens, 10000)
+
+	kept, _, tokens = planDocuments(counter, nodes, "", 300)
+	assert.Len(t, kept, 1)
+	assert.Equal(t, "a", kept[0].NodeID)
+	assert.LessOrEqual(t, tokens, 300)
+
+	// the first document is truncated when it alone exceeds the budget
+	kept, _, tokens = planDocuments(counter, nodes, "", 100)
+	require.Len(t, kept, 1)
+	assert.Less(t, len(kept[0].Chunks[0].Content), len(nodes[0].Chunks[0].Content))
+	assert.LessOrEqual(t, tokens, 110)
+}
+
+func TestNewTokenBudget(t *testing.T) {
+	budget := newTokenBudget()
+	assert.Equal(t, defaultContextWindow, budget.ContextWindow)
+	assert.Equal(t, defaultReservedOutput, budget.ReservedOutput)
+
+	large := &domain.Model{Parameters: domain.ModelParam{ContextWindow: 128000, MaxTokens: 8192}}
+	small := &domain.Model{Parameters: domain.ModelParam{ContextWindow: 8192, MaxTokens: 2048}}
+	budget = newTokenBudget(large, nil, small)
+	assert.Equal(t, 8192, budget.ContextWindow)
+	assert.Equal(t, 2048, budget.ReservedOutput)
+
+	// a model without parameters uses the default context window
+	budget = newTokenBudget(large, nil)
+	assert.Equal(t, defaultContextWindow, budget.ContextWindow)
+}
@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/lo"
+
+	v1 "github.com/chaitin/panda-wiki/api/vector/v1"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/mq"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+const (
+	vectorTaskMetricsDefaultHours = 24
+	vectorTaskRetention           = 30 * 24 * time.Hour
+)
+
+type VectorTaskUsecase struct {
+	repo    *pg.VectorTaskRepository
+	ragRepo *mq.RAGRepository
+	logger  *log.Logger
+}
+
+func NewVectorTaskUsecase(repo *pg.VectorTaskRepository, ragRepo *mq.RAGRepository, logger *log.Logger) *VectorTaskUsecase {
+	return &VectorTaskUsecase{
+		repo:    repo,
+		ragRepo: ragRepo,
+		logger:  logger.WithModule("usecase.vector_task"),
+	}
+}
+
+func (u *VectorTaskUsecase) SaveDeadLetter(ctx context.Context, deadLetter *domain.VectorTaskDeadLetter) error {
+	return u.repo.CreateDeadLetter(ctx, deadLetter)
+}
+
+func (u *VectorTaskUsecase) ListDeadLetters(ctx context.Context, req *v1.VectorTaskDeadLetterListReq) (*v1.VectorTaskDeadLetterListResp, error) {
+	deadLetters, total, err := u.repo.ListDeadLetters(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPaginatedResult(deadLetters, uint64(total)), nil
+}
+
+// ReplayDeadLetters sends the pending dead letters to the vector task topic again, each replay starts with fresh retries
+func (u *VectorTaskUsecase) ReplayDeadLetters(ctx context.Context, req *v1.VectorTaskDeadLetterReplayReq) (*v1.VectorTaskDeadLetterReplayResp, error) {
+	deadLetters, err := u.repo.ClaimDeadLettersForReplay(ctx, lo.Uniq(req.IDs))
+	if err != nil {
+		return nil, err
+	}
+	replayed := make([]string, 0, len(deadLetters))
+	for i, deadLetter := range deadLetters {
+		if err := u.ragRepo.ReplayVectorTask(ctx, deadLetter.Payload); err != nil {
+			unsent := lo.Map(deadLetters[i:], func(deadLetter *domain.VectorTaskDeadLetter, _ int) string { return deadLetter.ID })
+			if resetErr := u.repo.ResetDeadLetters(ctx, unsent); resetErr != nil {
+				u.logger.Error("reset dead letters failed", log.Any("ids", unsent), log.Error(resetErr))
+			}
+			return nil, err
+		}
+		replayed = append(replayed, deadLetter.ID)
+	}
+	return &v1.VectorTaskDeadLetterReplayResp{Replayed: replayed}, nil
+}
+
+func (u *VectorTaskUsecase) Metrics(ctx context.Context, req *v1.VectorTaskMetricsReq) (*v1.VectorTaskMetricsResp, error) {
+	hours := req.Hours
+	if hours == 0 {
+		hours = vectorTaskMetricsDefaultHours
+	}
+	since := time.Now().Truncate(time.Hour).Add(-time.Duration(hours-1) * time.Hour)
+	stats, err := u.repo.ListStats(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	deadLetters, err := u.repo.CountDeadLetters(ctx, domain.VectorTaskDeadLetterStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	// 队列不可用时仍返回统计数据
+	queue, err := u.ragRepo.VectorTaskQueueStat(ctx)
+	if err != nil {
+		u.logger.Warn("get vector task queue stat failed", log.Error(err))
+	}
+	return domain.NewVectorTaskMetrics(queue, deadLetters, stats), nil
+}
+
+// Cleanup removes the replayed dead letters and the stats older than the retention
+func (u *VectorTaskUsecase) Cleanup(ctx context.Context) (deadLetters, stats int64, err error) {
+	before := time.Now().Add(-vectorTaskRetention)
+	deadLetters, err = u.repo.DeleteDeadLettersBefore(ctx, before)
+	if err != nil {
+		return 0, 0, err
+	}
+	stats, err = u.repo.DeleteStatsBefore(ctx, before)
+	if err != nil {
+		return deadLetters, 0, err
+	}
+	return deadLetters, stats, nil
+}
@@ -0,0 +1,338 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	v1 "github.com/chaitin/panda-wiki/api/kb/v1"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/mq"
+	"github.com/chaitin/panda-wiki/repo/pg"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+const (
+	webhookSecretPrefix       = "whsec_"
+	webhookSecretBytes        = 24
+	webhookResponseMaxBytes   = 2 << 10 // 投递日志中保存的响应体上限
+	webhookRetryBatchSize     = 20
+	webhookDeliveryRetainDays = 30
+)
+
+type WebhookUsecase struct {
+	repo       *pg.WebhookRepository
+	httpClient *http.Client
+	logger     *log.Logger
+}
+
+func NewWebhookUsecase(repo *pg.WebhookRepository, logger *log.Logger) *WebhookUsecase {
+	return &WebhookUsecase{
+		repo:       repo,
+		httpClient: utils.NewPublicHTTPClient(domain.WebhookTimeout), // 投递地址由用户配置，不允许访问内网
+		logger:     logger.WithModule("usecase.webhook"),
+	}
+}
+
+func (u *WebhookUsecase) List(ctx context.Context, req *v1.WebhookListReq) (v1.WebhookListResp, error) {
+	return u.repo.List(ctx, req.KBId)
+}
+
+func (u *WebhookUsecase) Create(ctx context.Context, req *v1.WebhookCreateReq) (*domain.Webhook, error) {
+	events, err := normalizeWebhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	webhook := &domain.Webhook{
+		ID:        uuid.New().String(),
+		KBID:      req.KBId,
+		Name:      req.Name,
+		URL:       req.URL,
+		Secret:    secret,
+		Events:    events,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := u.repo.Create(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (u *WebhookUsecase) Update(ctx context.Context, req *v1.WebhookUpdateReq) (*domain.Webhook, error) {
+	if _, err := u.repo.Get(ctx, req.KBId, req.WebhookId); err != nil {
+		return nil, err
+	}
+	updateMap := map[string]any{}
+	if req.Name != nil {
+		updateMap["name"] = *req.Name
+	}
+	if req.URL != nil {
+		updateMap["url"] = *req.URL
+	}
+	if req.Events != nil {
+		events, err := normalizeWebhookEvents(req.Events)
+		if err != nil {
+			return nil, err
+		}
+		updateMap["events"] = pq.StringArray(events)
+	}
+	if req.Enabled != nil {
+		updateMap["enabled"] = *req.Enabled
+	}
+	if req.RotateSecret {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		updateMap["secret"] = secret
+	}
+	if err := u.repo.Update(ctx, req.KBId, req.WebhookId, updateMap); err != nil {
+		return nil, err
+	}
+	return u.repo.Get(ctx, req.KBId, req.WebhookId)
+}
+
+func (u *WebhookUsecase) Delete(ctx context.Context, req *v1.WebhookDeleteReq) error {
+	return u.repo.Delete(ctx, req.KBId, req.WebhookId)
+}
+
+func (u *WebhookUsecase) ListDeliveries(ctx context.Context, req *v1.WebhookDeliveryListReq) (*v1.WebhookDeliveryListResp, error) {
+	deliveries, total, err := u.repo.ListDeliveries(ctx, req.KBId, req.WebhookId, req.Status, req.Offset(), req.Limit())
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPaginatedResult(deliveries, uint64(total)), nil
+}
+
+// SendTest sends a webhook.test event synchronously, even if the webhook is disabled, and doesn't retry on failure
+func (u *WebhookUsecase) SendTest(ctx context.Context, req *v1.WebhookTestReq) (*domain.WebhookDelivery, error) {
+	webhook, err := u.repo.Get(ctx, req.KBId, req.WebhookId)
+	if err != nil {
+		return nil, err
+	}
+	event, err := domain.NewWebhookEvent(webhook.KBID, domain.WebhookEventTest, &domain.WebhookTestData{
+		WebhookID: webhook.ID,
+		Message:   "This is a test event from PandaWiki",
+	})
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := u.createDelivery(ctx, webhook, event)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.deliver(ctx, webhook, delivery, false); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// HandleEvent creates a delivery for every webhook subscribed to the event and makes the first attempt,
+// failed attempts are retried by RetryDueDeliveries
+func (u *WebhookUsecase) HandleEvent(ctx context.Context, event *domain.WebhookEvent) error {
+	webhooks, err := u.repo.ListSubscribed(ctx, event.KBID, event.Type)
+	if err != nil {
+		return err
+	}
+	for _, webhook := range webhooks {
+		// 单个 webhook 失败不影响其他订阅者
+		delivery, err := u.createDelivery(ctx, webhook, event)
+		if err != nil {
+			u.logger.Error("create webhook delivery failed", log.String("webhook_id", webhook.ID), log.String("event_id", event.ID), log.Error(err))
+			continue
+		}
+		if err := u.deliver(ctx, webhook, delivery, true); err != nil {
+			u.logger.Error("save webhook delivery failed", log.String("delivery_id", delivery.ID), log.Error(err))
+		}
+	}
+	return nil
+}
+
+// RetryDueDeliveries retries the pending deliveries whose backoff has expired
+func (u *WebhookUsecase) RetryDueDeliveries(ctx context.Context) error {
+	// 批次内逐个投递，租约需覆盖整批投递的最长耗时，避免未投递完的记录被其他实例重复领取
+	lease := webhookRetryBatchSize*domain.WebhookTimeout + domain.WebhookRetryBaseWait
+	deliveries, err := u.repo.ClaimDueDeliveries(ctx, time.Now(), lease, webhookRetryBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		webhook, err := u.repo.Get(ctx, delivery.KBID, delivery.WebhookID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if webhook == nil || !webhook.Enabled {
+			delivery.Status = domain.WebhookDeliveryStatusFailed
+			delivery.NextRetryAt = nil
+			delivery.Error = "webhook is deleted or disabled"
+			delivery.UpdatedAt = time.Now()
+			if err := u.repo.SaveDeliveryResult(ctx, delivery); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := u.deliver(ctx, webhook, delivery, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanupDeliveries removes the finished deliveries older than the retention days
+func (u *WebhookUsecase) CleanupDeliveries(ctx context.Context) (int64, error) {
+	return u.repo.DeleteDeliveriesBefore(ctx, time.Now().AddDate(0, 0, -webhookDeliveryRetainDays))
+}
+
+func (u *WebhookUsecase) createDelivery(ctx context.Context, webhook *domain.Webhook, event *domain.WebhookEvent) (*domain.WebhookDelivery, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	// 首次投递前先占用一个重试周期，进程在投递过程中退出时由重试任务补发
+	nextRetryAt := now.Add(domain.WebhookRetryBaseWait)
+	delivery := &domain.WebhookDelivery{
+		ID:          uuid.New().String(),
+		WebhookID:   webhook.ID,
+		KBID:        webhook.KBID,
+		EventID:     event.ID,
+		EventType:   event.Type,
+		Payload:     payload,
+		Status:      domain.WebhookDeliveryStatusPending,
+		NextRetryAt: &nextRetryAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := u.repo.CreateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// deliver makes one attempt and saves the result, on failure the next attempt is scheduled with
+// exponential backoff until the attempts are used up
+func (u *WebhookUsecase) deliver(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery, retry bool) error {
+	start := time.Now()
+	statusCode, body, err := u.send(ctx, webhook, delivery)
+	now := time.Now()
+
+	delivery.Attempts++
+	delivery.ResponseStatus = statusCode
+	delivery.ResponseBody = body
+	delivery.DurationMs = now.Sub(start).Milliseconds()
+	delivery.UpdatedAt = now
+	delivery.Error = ""
+	delivery.NextRetryAt = nil
+	switch {
+	case err == nil:
+		delivery.Status = domain.WebhookDeliveryStatusSuccess
+	case retry && delivery.Attempts < domain.WebhookMaxAttempts:
+		delivery.Status = domain.WebhookDeliveryStatusPending
+		delivery.Error = err.Error()
+		nextRetryAt := now.Add(domain.WebhookRetryDelay(delivery.Attempts))
+		delivery.NextRetryAt = &nextRetryAt
+	default:
+		delivery.Status = domain.WebhookDeliveryStatusFailed
+		delivery.Error = err.Error()
+	}
+	if err != nil {
+		u.logger.Warn("deliver webhook failed",
+			log.String("webhook_id", webhook.ID),
+			log.String("delivery_id", delivery.ID),
+			log.Int("attempts", delivery.Attempts),
+			log.Error(err))
+	}
+	return u.repo.SaveDeliveryResult(ctx, delivery)
+}
+
+func (u *WebhookUsecase) send(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery) (int, string, error) {
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PandaWiki-Webhook")
+	req.Header.Set(domain.WebhookHeaderEvent, string(delivery.EventType))
+	req.Header.Set(domain.WebhookHeaderDelivery, delivery.ID)
+	req.Header.Set(domain.WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(domain.WebhookHeaderSignature, domain.SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, webhookResponseMaxBytes))
+	if err != nil {
+		return resp.StatusCode, "", fmt.Errorf("read response failed: %w", err)
+	}
+	body := string(bytes.ToValidUTF8(data, nil))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, body, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// publishWebhookEvent sends the event to the webhook consumer, a failure is only logged so that it never breaks the caller
+func publishWebhookEvent(ctx context.Context, logger *log.Logger, repo *mq.WebhookRepository, kbID string, eventType domain.WebhookEventType, data any) {
+	if err := repo.AsyncPublishEvent(ctx, kbID, eventType, data); err != nil {
+		logger.Warn("publish webhook event failed", log.String("kb_id", kbID), log.String("event", string(eventType)), log.Error(err))
+	}
+}
+
+// publishKBReleaseWebhookEvents publishes kb_release.created, and node.published when the release publishes nodes
+func publishKBReleaseWebhookEvents(ctx context.Context, logger *log.Logger, repo *mq.WebhookRepository, release *domain.KBRelease, nodeIDs []string) {
+	if len(nodeIDs) > 0 {
+		publishWebhookEvent(ctx, logger, repo, release.KBID, domain.WebhookEventNodePublished, &domain.WebhookNodePublishedData{
+			ReleaseID: release.ID,
+			NodeIDs:   nodeIDs,
+		})
+	}
+	publishWebhookEvent(ctx, logger, repo, release.KBID, domain.WebhookEventKBReleaseCreated, &domain.WebhookKBReleaseCreatedData{
+		ReleaseID:   release.ID,
+		Tag:         release.Tag,
+		Message:     release.Message,
+		PublisherID: release.PublisherId,
+		NodeIDs:     lo.CoalesceSliceOrEmpty(nodeIDs),
+	})
+}
+
+func normalizeWebhookEvents(events []domain.WebhookEventType) ([]string, error) {
+	for _, event := range events {
+		if !slices.Contains(domain.WebhookEventTypes, event) {
+			return nil, fmt.Errorf("%w: %s", domain.ErrWebhookEventInvalid, event)
+		}
+	}
+	return lo.Uniq(lo.Map(events, func(event domain.WebhookEventType, _ int) string { return string(event) })), nil
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate webhook secret failed: %w", err)
+	}
+	return webhookSecretPrefix + hex.EncodeToString(b), nil
+}
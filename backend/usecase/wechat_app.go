@@ -106,6 +106,7 @@ func (u *WechatAppUsecase) getQAFunc(kbID string, appType domain.AppType) bot.Ge
 		contentCh := make(chan string, 10)
 		go func() {
 			defer close(contentCh)
+			var citations []*domain.ConversationReference
 			for event := range eventCh {
 				if event.Type == "done" || event.Type == "error" {
 					break
@@ -113,6 +114,12 @@ func (u *WechatAppUsecase) getQAFunc(kbID string, appType domain.AppType) bot.Ge
 				if event.Type == "data" {
 					contentCh <- event.Content
 				}
+				if event.Type == "citations" {
+					citations = event.Citations
+				}
+			}
+			if footnotes := domain.FormatCitationFootnotes(citations); footnotes != "" {
+				contentCh <- footnotes
 			}
 		}()
 		return contentCh, nil
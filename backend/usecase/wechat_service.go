@@ -87,6 +87,7 @@ func (u *WechatUsecase) getQAFunc(kbID string, appType domain.AppType) bot.GetQA
 		contentCh := make(chan string, 10)
 		go func() {
 			defer close(contentCh)
+			var citations []*domain.ConversationReference
 			for event := range eventCh {
 				if event.Type == "done" || event.Type == "error" {
 					break
@@ -94,6 +95,12 @@ func (u *WechatUsecase) getQAFunc(kbID string, appType domain.AppType) bot.GetQA
 				if event.Type == "data" {
 					contentCh <- event.Content
 				}
+				if event.Type == "citations" {
+					citations = event.Citations
+				}
+			}
+			if footnotes := domain.FormatCitationFootnotes(citations); footnotes != "" {
+				contentCh <- footnotes
 			}
 		}()
 		return contentCh, nil
@@ -0,0 +1,264 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+type DiffType string
+
+const (
+	DiffEqual  DiffType = "equal"
+	DiffInsert DiffType = "insert"
+	DiffDelete DiffType = "delete"
+)
+
+type DiffSegment struct {
+	Type DiffType `json:"type"`
+	Text string   `json:"text"`
+}
+
+type DiffLine struct {
+	Type    DiffType      `json:"type"`
+	OldLine int           `json:"old_line,omitempty"` // 1-based, 0 for inserted lines
+	NewLine int           `json:"new_line,omitempty"` // 1-based, 0 for deleted lines
+	Text    string        `json:"text"`
+	Words   []DiffSegment `json:"words,omitempty"` // word level diff of a modified line
+}
+
+type DiffStats struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// DiffContent compares two node contents line by line, html is converted to markdown first
+// so that both content types produce a readable diff
+func DiffContent(oldContent, newContent string) ([]DiffLine, DiffStats) {
+	return DiffLines(ContentToMarkdown(oldContent), ContentToMarkdown(newContent))
+}
+
+// DiffLines returns the line level diff of two texts, adjacent deleted and inserted lines
+// are paired and carry a word level diff
+func DiffLines(oldText, newText string) ([]DiffLine, DiffStats) {
+	oldLines := splitLines(oldText)
+	newLines := splitLines(newText)
+	ops := myersDiff(oldLines, newLines)
+
+	lines := make([]DiffLine, 0, len(ops))
+	stats := DiffStats{}
+	oldNo, newNo := 0, 0
+	for i := 0; i < len(ops); {
+		if ops[i] == DiffEqual {
+			oldNo++
+			newNo++
+			lines = append(lines, DiffLine{Type: DiffEqual, OldLine: oldNo, NewLine: newNo, Text: oldLines[oldNo-1]})
+			i++
+			continue
+		}
+		// collect a change block: deletes followed by inserts
+		deleted := make([]DiffLine, 0)
+		inserted := make([]DiffLine, 0)
+		for ; i < len(ops) && ops[i] != DiffEqual; i++ {
+			if ops[i] == DiffDelete {
+				oldNo++
+				deleted = append(deleted, DiffLine{Type: DiffDelete, OldLine: oldNo, Text: oldLines[oldNo-1]})
+			} else {
+				newNo++
+				inserted = append(inserted, DiffLine{Type: DiffInsert, NewLine: newNo, Text: newLines[newNo-1]})
+			}
+		}
+		for j := 0; j < len(deleted) && j < len(inserted); j++ {
+			deleted[j].Words, inserted[j].Words = DiffWords(deleted[j].Text, inserted[j].Text)
+		}
+		stats.Removed += len(deleted)
+		stats.Added += len(inserted)
+		lines = append(lines, deleted...)
+		lines = append(lines, inserted...)
+	}
+	return lines, stats
+}
+
+// DiffWords returns the word level segments of the old and new line
+func DiffWords(oldLine, newLine string) ([]DiffSegment, []DiffSegment) {
+	oldWords := splitWords(oldLine)
+	newWords := splitWords(newLine)
+	ops := myersDiff(oldWords, newWords)
+
+	oldSegs := make([]DiffSegment, 0)
+	newSegs := make([]DiffSegment, 0)
+	oi, ni := 0, 0
+	for _, op := range ops {
+		switch op {
+		case DiffEqual:
+			oldSegs = appendSegment(oldSegs, DiffEqual, oldWords[oi])
+			newSegs = appendSegment(newSegs, DiffEqual, newWords[ni])
+			oi++
+			ni++
+		case DiffDelete:
+			oldSegs = appendSegment(oldSegs, DiffDelete, oldWords[oi])
+			oi++
+		case DiffInsert:
+			newSegs = appendSegment(newSegs, DiffInsert, newWords[ni])
+			ni++
+		}
+	}
+	return oldSegs, newSegs
+}
+
+func appendSegment(segs []DiffSegment, t DiffType, text string) []DiffSegment {
+	if n := len(segs); n > 0 && segs[n-1].Type == t {
+		segs[n-1].Text += text
+		return segs
+	}
+	return append(segs, DiffSegment{Type: t, Text: text})
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+// splitWords splits a line into latin words, whitespace runs, and single cjk or punctuation runes
+func splitWords(line string) []string {
+	words := make([]string, 0)
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		switch {
+		case isWordRune(runes[i]):
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+		case unicode.IsSpace(runes[i]):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		}
+		words = append(words, string(runes[i:j]))
+		i = j
+	}
+	return words
+}
+
+func isWordRune(r rune) bool {
+	if unicode.Is(unicode.Han, r) {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// maxDiffEdits caps the edit distance myersDiff searches, the trace of the search grows with its square
+const maxDiffEdits = 1000
+
+// myersDiff returns the edit script turning a into b. The common prefix and suffix are matched first,
+// when the rest needs more than maxDiffEdits edits it is replaced as a whole block.
+func myersDiff(a, b []string) []DiffType {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]DiffType, 0, len(a)+len(b)-prefix-suffix)
+	for range prefix {
+		ops = append(ops, DiffEqual)
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if middle, ok := myersSearch(middleA, middleB, maxDiffEdits); ok {
+		ops = append(ops, middle...)
+	} else {
+		for range middleA {
+			ops = append(ops, DiffDelete)
+		}
+		for range middleB {
+			ops = append(ops, DiffInsert)
+		}
+	}
+	for range suffix {
+		ops = append(ops, DiffEqual)
+	}
+	return ops
+}
+
+// myersSearch returns the shortest edit script turning a into b, false when it needs more than maxEdits edits.
+// Only the diagonals reachable in d edits are kept for each step, so the memory is O(D²) instead of O(D·(N+M)).
+func myersSearch(a, b []string, maxEdits int) ([]DiffType, bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil, true
+	}
+	offset := max
+	v := make([]int, 2*max+2)
+	// trace[d][k+d] is v[offset+k] before step d, for k in [-d, d]
+	trace := make([][]int, 0)
+
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		if d > maxEdits {
+			return nil, false
+		}
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// backtrack
+	ops := make([]DiffType, 0, max)
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v[prevK+d]
+		}
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, DiffEqual)
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, DiffInsert)
+			} else {
+				ops = append(ops, DiffDelete)
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, true
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffLines(t *testing.T) {
+	lines, stats := DiffLines("a\nb\nc", "a\nB\nc\nd")
+
+	assert.Equal(t, DiffStats{Added: 2, Removed: 1}, stats)
+	assert.Equal(t, []DiffType{DiffEqual, DiffDelete, DiffInsert, DiffEqual, DiffInsert},
+		[]DiffType{lines[0].Type, lines[1].Type, lines[2].Type, lines[3].Type, lines[4].Type})
+	assert.Equal(t, 2, lines[1].OldLine)
+	assert.Equal(t, 2, lines[2].NewLine)
+	assert.Equal(t, 4, lines[4].NewLine)
+}
+
+func TestDiffLines_Identical(t *testing.T) {
+	lines, stats := DiffLines("same\ntext", "same\ntext")
+
+	assert.Equal(t, DiffStats{}, stats)
+	for _, line := range lines {
+		assert.Equal(t, DiffEqual, line.Type)
+	}
+}
+
+func TestDiffLines_Large(t *testing.T) {
+	oldLines := make([]string, 0, 5000)
+	newLines := make([]string, 0, 5000)
+	for i := range 5000 {
+		oldLines = append(oldLines, fmt.Sprintf("old %d", i))
+		newLines = append(newLines, fmt.Sprintf("new %d", i))
+	}
+	// 超过编辑距离上限时中间部分整体替换，首尾相同的行仍然保留
+	oldText := "head\n" + strings.Join(oldLines, "\n") + "\ntail"
+	newText := "head\n" + strings.Join(newLines, "\n") + "\ntail"
+	lines, stats := DiffLines(oldText, newText)
+
+	assert.Equal(t, DiffStats{Added: 5000, Removed: 5000}, stats)
+	assert.Equal(t, DiffEqual, lines[0].Type)
+	assert.Equal(t, DiffDelete, lines[1].Type)
+	assert.Equal(t, DiffInsert, lines[5001].Type)
+	assert.Equal(t, DiffEqual, lines[len(lines)-1].Type)
+}
+
+func TestDiffWords(t *testing.T) {
+	oldSegs, newSegs := DiffWords("hello big world", "hello small world")
+
+	assert.Equal(t, []DiffSegment{
+		{Type: DiffEqual, Text: "hello "},
+		{Type: DiffDelete, Text: "big"},
+		{Type: DiffEqual, Text: " world"},
+	}, oldSegs)
+	assert.Equal(t, []DiffSegment{
+		{Type: DiffEqual, Text: "hello "},
+		{Type: DiffInsert, Text: "small"},
+		{Type: DiffEqual, Text: " world"},
+	}, newSegs)
+}
+
+func TestDiffWords_CJK(t *testing.T) {
+	_, newSegs := DiffWords("知识库", "知识图谱")
+
+	assert.Equal(t, []DiffSegment{
+		{Type: DiffEqual, Text: "知识"},
+		{Type: DiffInsert, Text: "图谱"},
+	}, newSegs)
+}
+
+func TestDiffContent_HTML(t *testing.T) {
+	_, stats := DiffContent("<p>hello</p>", "<p>hello</p><p>world</p>")
+
+	assert.Equal(t, 0, stats.Removed)
+	assert.Greater(t, stats.Added, 0)
+}
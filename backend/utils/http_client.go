@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const publicHTTPClientMaxRedirects = 10
+
+var ErrPrivateAddress = errors.New("private or reserved address is not allowed")
+
+// NewPublicHTTPClient returns a client for user configured urls, it only connects to public addresses
+// so that the urls can not reach the internal network, including after redirects and dns rebinding
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		// 校验实际连接的地址，域名解析后的每个地址都会经过这里
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if IsPrivateOrReservedIP(host) {
+				return fmt.Errorf("dial %s: %w", host, ErrPrivateAddress)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// 不走环境变量中的代理，否则校验的是代理的地址
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= publicHTTPClientMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", publicHTTPClientMaxRedirects)
+			}
+			return CheckPublicURLHost(req.Context(), req.URL.Scheme, req.URL.Hostname())
+		},
+	}
+}
+
+// CheckPublicURLHost checks the scheme and that the host does not resolve to a private or reserved address
+func CheckPublicURLHost(ctx context.Context, scheme, host string) error {
+	if scheme != "http" && scheme != "https" {
+		return fmt.Errorf("unsupported scheme: %s", scheme)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if IsPrivateOrReservedIP(host) {
+			return fmt.Errorf("%s: %w", host, ErrPrivateAddress)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if IsPrivateOrReservedIP(addr.IP.String()) {
+			return fmt.Errorf("%s: %w", host, ErrPrivateAddress)
+		}
+	}
+	return nil
+}
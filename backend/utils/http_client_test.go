@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := NewPublicHTTPClient(time.Second).Get(server.URL)
+	assert.True(t, errors.Is(err, ErrPrivateAddress))
+
+	assert.True(t, errors.Is(CheckPublicURLHost(context.Background(), "http", "169.254.169.254"), ErrPrivateAddress))
+	assert.Error(t, CheckPublicURLHost(context.Background(), "file", "example.com"))
+	assert.NoError(t, CheckPublicURLHost(context.Background(), "https", "8.8.8.8"))
+}
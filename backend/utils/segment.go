@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxSegmentRunes bounds the text indexed per document, postgres caps tsvector positions anyway
+const maxSegmentRunes = 200000
+
+// SegmentText splits text into search tokens for postgres full-text search with the simple config:
+// latin words, numbers and identifiers such as "ERR-1024" or "v1.2" are kept whole and lowercased,
+// CJK runs are split into overlapping bigrams since postgres has no built-in chinese parser
+func SegmentText(text string) []string {
+	runes := []rune(text)
+	if len(runes) > maxSegmentRunes {
+		runes = runes[:maxSegmentRunes]
+	}
+	tokens := make([]string, 0, len(runes)/2)
+	for i := 0; i < len(runes); {
+		switch {
+		case isCJKRune(runes[i]):
+			j := i
+			for j < len(runes) && isCJKRune(runes[j]) {
+				j++
+			}
+			if j-i == 1 {
+				tokens = append(tokens, string(runes[i]))
+			}
+			for k := i; k+1 < j; k++ {
+				tokens = append(tokens, string(runes[k:k+2]))
+			}
+			i = j
+		case isTokenRune(runes[i]):
+			j := i
+			for j < len(runes) && (isTokenRune(runes[j]) ||
+				// connectors inside an identifier, e.g. ERR-1024, get_doc, v1.2
+				(strings.ContainsRune("-_.", runes[j]) && j+1 < len(runes) && isTokenRune(runes[j+1]))) {
+				j++
+			}
+			if token := strings.ToLower(string(runes[i:j])); len([]rune(token)) > 1 || unicode.IsDigit(runes[i]) {
+				tokens = append(tokens, token)
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// SegmentQuery returns the deduplicated tokens of a search query
+func SegmentQuery(query string) []string {
+	seen := make(map[string]struct{})
+	tokens := make([]string, 0)
+	for _, token := range SegmentText(query) {
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func isCJKRune(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func isTokenRune(r rune) bool {
+	return !isCJKRune(r) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// KeywordSnippet returns a window of about size runes around the most specific token found in text
+func KeywordSnippet(text string, tokens []string, size int) string {
+	runes := []rune(text)
+	if len(runes) <= size {
+		return text
+	}
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	lowerText := string(lower)
+
+	pos, best := 0, 0
+	for _, token := range tokens {
+		n := len([]rune(token))
+		if n <= best {
+			continue
+		}
+		if idx := strings.Index(lowerText, token); idx >= 0 {
+			pos, best = len([]rune(lowerText[:idx])), n
+		}
+	}
+	start := max(0, pos-size/4)
+	end := min(len(runes), start+size)
+	start = max(0, end-size)
+	return strings.TrimSpace(string(runes[start:end]))
+}
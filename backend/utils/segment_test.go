@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmentText(t *testing.T) {
+	assert.Equal(t, []string{"错误", "误码", "err-1024", "getranknodes", "v1.2"},
+		SegmentText("错误码 ERR-1024, GetRankNodes v1.2"))
+	assert.Equal(t, []string{"库", "7"}, SegmentText("库 a 7"))
+}
+
+func TestSegmentQuery_Dedup(t *testing.T) {
+	assert.Equal(t, []string{"知识", "识库", "api"}, SegmentQuery("知识库 API 知识库 api"))
+}
+
+func TestKeywordSnippet(t *testing.T) {
+	text := strings.Repeat("前言。", 100) + "错误码 ERR-1024 表示超时。" + strings.Repeat("附录。", 100)
+	snippet := KeywordSnippet(text, []string{"err-1024", "超时"}, 60)
+
+	assert.Contains(t, snippet, "ERR-1024")
+	assert.LessOrEqual(t, len([]rune(snippet)), 60)
+}
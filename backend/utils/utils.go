@@ -19,6 +19,7 @@ import (
 	"github.com/JohannesKaufmann/html-to-markdown/v2/converter"
 	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/base"
 	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/commonmark"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/table"
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
@@ -364,3 +365,22 @@ func IsLikelyHTML(text string) bool {
 	trimContent := strings.TrimSpace(text)
 	return strings.HasPrefix(trimContent, "<") && strings.HasSuffix(trimContent, ">")
 }
+
+// ContentToMarkdown converts html node content to markdown, markdown content is returned as is
+func ContentToMarkdown(content string) string {
+	if !IsLikelyHTML(content) {
+		return content
+	}
+	conv := converter.NewConverter(
+		converter.WithPlugins(
+			base.NewBasePlugin(),
+			commonmark.NewCommonmarkPlugin(),
+			table.NewTablePlugin(),
+		),
+	)
+	md, err := conv.ConvertString(content)
+	if err != nil {
+		return content
+	}
+	return md
+}